@@ -2,6 +2,7 @@ package cli
 
 import (
 	"github.com/himakhaitan/logkv-store/cli/commands"
+	"github.com/himakhaitan/logkv-store/cli/output"
 	"github.com/spf13/cobra"
 )
 
@@ -11,12 +12,42 @@ type CLI struct {
 
 func NewCLI() *CLI {
 	cli := &CLI{}
+	var outputFormat string
+	var quiet, verbose bool
 
 	rootCmd := &cobra.Command{
 		Use:   "logkv-cli",
 		Short: "A log-structured key-value store CLI",
 		Long:  "LogKV CLI is a command-line interface for the LogKV key-value store",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			format, err := output.ParseFormat(outputFormat)
+			if err != nil {
+				return err
+			}
+			output.SetFormat(format)
+			if err := commands.LoadConfig(); err != nil {
+				return err
+			}
+			switch {
+			case quiet:
+				output.SetVerbosity(output.VerbosityQuiet)
+			case verbose:
+				output.SetVerbosity(output.VerbosityVerbose)
+			default:
+				output.SetVerbosity(output.VerbosityNormal)
+			}
+			return nil
+		},
 	}
+	rootCmd.PersistentFlags().String(commands.AddrFlag, "", "Server address (default $LOGKV_ADDR, the config file's addr or "+commands.DefaultAddr+")")
+	rootCmd.PersistentFlags().String(commands.NamespaceFlag, "", "Server namespace to use (default: the default database)")
+	rootCmd.PersistentFlags().Duration(commands.TimeoutFlag, commands.DefaultTimeout, "Request timeout (0 = none)")
+	rootCmd.PersistentFlags().Int(commands.RetriesFlag, 0, "Times to retry a request after a transient connection error")
+	rootCmd.PersistentFlags().String(commands.TokenFlag, "", "Bearer token to send (default $LOGKV_TOKEN or the config file's token)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", string(output.FormatText), "Output format: text or json")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Print errors only (JSON results are still printed)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Also print debug messages, such as each request sent")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
 
 	// Create command registry and register all commands
 	registry := commands.NewCommandRegistry()
@@ -30,3 +61,14 @@ func NewCLI() *CLI {
 func (c *CLI) Run() error {
 	return c.root.Execute()
 }
+
+// Execute runs the CLI and returns the code the process should exit with:
+// commands.ExitUsage when the command line could not be parsed, or the code
+// the command finished with
+func (c *CLI) Execute() int {
+	commands.ResetExitCode()
+	if err := c.root.Execute(); err != nil {
+		return commands.ExitUsage
+	}
+	return commands.ExitCode()
+}
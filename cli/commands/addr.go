@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// AddrFlag is the persistent flag selecting the server address
+	AddrFlag = "addr"
+
+	// DefaultAddr is used when neither the flag, LOGKV_ADDR nor the config
+	// file sets an address
+	DefaultAddr = "http://localhost:8080"
+
+	// NamespaceFlag is the persistent flag selecting the server namespace
+	NamespaceFlag = "namespace"
+)
+
+// resolveAddr returns the server address from the --addr flag, falling back
+// to the LOGKV_ADDR environment variable, the config file and then DefaultAddr
+func resolveAddr(cmd *cobra.Command) string {
+	if flag := cmd.Flag(AddrFlag); flag != nil && flag.Value.String() != "" {
+		return flag.Value.String()
+	}
+	if addr := os.Getenv("LOGKV_ADDR"); addr != "" {
+		return addr
+	}
+	if fileConfig.Addr != "" {
+		return fileConfig.Addr
+	}
+	return DefaultAddr
+}
+
+// apiURL returns the URL of an API path such as "/kv" on addr, under the
+// namespace chosen with --namespace if any
+func apiURL(cmd *cobra.Command, addr, path string) string {
+	if flag := cmd.Flag(NamespaceFlag); flag != nil && flag.Value.String() != "" {
+		return addr + "/v1/ns/" + url.PathEscape(flag.Value.String()) + path
+	}
+	return addr + "/v1" + path
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resolvedAddr runs a child command under a root with the --addr flag and
+// returns the address it resolved
+func resolvedAddr(t *testing.T, args ...string) string {
+	var addr string
+	root := &cobra.Command{Use: "root"}
+	root.PersistentFlags().String(AddrFlag, "", "")
+	root.AddCommand(&cobra.Command{
+		Use: "child",
+		Run: func(cmd *cobra.Command, args []string) { addr = resolveAddr(cmd) },
+	})
+	root.SetArgs(append([]string{"child"}, args...))
+	require.NoError(t, root.Execute())
+	return addr
+}
+
+func TestResolveAddr(t *testing.T) {
+	t.Setenv("LOGKV_ADDR", "")
+	assert.Equal(t, DefaultAddr, resolvedAddr(t))
+
+	t.Setenv("LOGKV_ADDR", "http://from-env:8080")
+	assert.Equal(t, "http://from-env:8080", resolvedAddr(t))
+	assert.Equal(t, "http://from-flag:9000", resolvedAddr(t, "--addr", "http://from-flag:9000"))
+}
+
+func TestResolveAddr_WithoutFlag(t *testing.T) {
+	t.Setenv("LOGKV_ADDR", "http://from-env:8080")
+	assert.Equal(t, "http://from-env:8080", resolveAddr(&cobra.Command{}))
+}
+
+func TestAPIURL(t *testing.T) {
+	var urls []string
+	child := &cobra.Command{
+		Use: "child",
+		Run: func(cmd *cobra.Command, args []string) {
+			urls = append(urls, apiURL(cmd, "http://host", "/kv/foo"))
+		},
+	}
+	root := withGlobalFlags(child)
+
+	root.SetArgs([]string{"child"})
+	require.NoError(t, root.Execute())
+	root.SetArgs([]string{"child", "--namespace", "users"})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, []string{"http://host/v1/kv/foo", "http://host/v1/ns/users/kv/foo"}, urls)
+}
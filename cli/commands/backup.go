@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/spf13/cobra"
+)
+
+// NewBackupCommand creates a new backup command
+func NewBackupCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "backup <file>",
+		Short: "Save a snapshot of the store's segments to a tar file",
+		Long:  "Save a consistent snapshot of the server's segment files to a tar file. Restore it with the restore command.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := args[0]
+			addr := resolveAddr(cmd)
+
+			// No timeout unless --timeout is given: the snapshot streams for as
+			// long as the data set takes
+			client := newClient(cmd, 0)
+			resp, err := client.Get(apiURL(cmd, addr, "/snapshot"))
+			if err != nil {
+				failConnection(client, addr, err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				fail(fmt.Sprintf("Server error: %s", resp.Status))
+				return
+			}
+
+			file, err := os.Create(path)
+			if err != nil {
+				fail(fmt.Sprintf("Failed to create %s: %v", path, err))
+				return
+			}
+			written, err := io.Copy(file, resp.Body)
+			if err == nil {
+				err = file.Sync()
+			}
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				// A partial archive is not a usable backup
+				os.Remove(path)
+				fail(fmt.Sprintf("Backup failed: %v", err))
+				return
+			}
+			output.Success(fmt.Sprintf("Saved %d bytes to %s", written, path))
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupCommand_Success(t *testing.T) {
+	body := "tar archive bytes"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/snapshot", r.URL.Path)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	path := filepath.Join(t.TempDir(), "backup.tar")
+	output := captureOutput(func() {
+		executeCommand(t, NewBackupCommand(), []string{path})
+	})
+	assert.Contains(t, output, "[SUCCESS]")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(data))
+}
+
+func TestBackupCommand_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	path := filepath.Join(t.TempDir(), "backup.tar")
+	output := captureOutput(func() {
+		executeCommand(t, NewBackupCommand(), []string{path})
+	})
+	assert.Contains(t, output, "[ERROR]")
+	assert.NoFileExists(t, path, "Nothing should be written on failure")
+}
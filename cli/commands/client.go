@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/himakhaitan/logkv-store/client"
+	"github.com/himakhaitan/logkv-store/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// TimeoutFlag is the persistent flag setting the request timeout
+	TimeoutFlag = "timeout"
+
+	// DefaultTimeout bounds ordinary requests when --timeout isn't given
+	DefaultTimeout = 10 * time.Second
+
+	// RetriesFlag is the persistent flag setting how often a failed request
+	// is retried
+	RetriesFlag = "retries"
+)
+
+// retryBaseDelay is the wait before the first retry; each later retry waits
+// twice as long as the one before, up to retryMaxDelay
+var (
+	retryBaseDelay = 200 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+)
+
+// userAgent identifies the CLI and its version to the server
+var userAgent = "logkv-cli/" + version.Version
+
+// newClient returns an HTTP client for talking to the server, sending the
+// token from resolveToken if there is one. Its timeout is the --timeout flag
+// when given, or fallback otherwise (0 = no timeout), and covers any retries
+// asked for with --retries. A timeout in the config file replaces
+// DefaultTimeout, the fallback for ordinary requests.
+func newClient(cmd *cobra.Command, fallback time.Duration) *http.Client {
+	timeout := fallback
+	if fallback == DefaultTimeout && fileConfig.Timeout > 0 {
+		timeout = fileConfig.Timeout
+	}
+	if flag := cmd.Flag(TimeoutFlag); flag != nil && flag.Changed {
+		if d, err := time.ParseDuration(flag.Value.String()); err == nil {
+			timeout = d
+		}
+	}
+	var transport http.RoundTripper = http.DefaultTransport
+	if flag := cmd.Flag(RetriesFlag); flag != nil {
+		if retries, err := strconv.Atoi(flag.Value.String()); err == nil && retries > 0 {
+			transport = retryTransport{base: transport, retries: retries}
+		}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: headerTransport{base: transport, token: resolveToken(cmd)},
+	}
+}
+
+// newAPIClient returns a client for the server at addr and the namespace
+// chosen with --namespace, and the HTTP client from newClient it sends
+// requests through
+func newAPIClient(cmd *cobra.Command, addr string, fallback time.Duration) (*client.Client, *http.Client) {
+	httpClient := newClient(cmd, fallback)
+	opts := []client.Option{client.WithHTTPClient(httpClient)}
+	if flag := cmd.Flag(NamespaceFlag); flag != nil && flag.Value.String() != "" {
+		opts = append(opts, client.WithNamespace(flag.Value.String()))
+	}
+	return client.New(addr, opts...), httpClient
+}
+
+// headerTransport sets the User-Agent header on every request, and the
+// Authorization header when there is a token
+type headerTransport struct {
+	base  http.RoundTripper
+	token string
+}
+
+// RoundTrip sends the request with the CLI's headers, noting it and its
+// outcome as debug messages
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", userAgent)
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		output.Debug(fmt.Sprintf("%s %s failed after %s: %v", req.Method, req.URL, time.Since(start), err))
+	} else {
+		output.Debug(fmt.Sprintf("%s %s: %s in %s", req.Method, req.URL, resp.Status, time.Since(start)))
+	}
+	return resp, err
+}
+
+// retryTransport retries requests that failed for reasons likely to pass,
+// such as the server restarting, with exponential backoff. Idempotent
+// requests are retried after any connection error or a 502, 503 or 504
+// response. Others are retried only when the connection was refused, since
+// the server cannot have acted on a request it never received.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+}
+
+// RoundTrip sends the request, retrying it up to t.retries times
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt == t.retries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		output.Dim(fmt.Sprintf("Retrying in %s (%d of %d)...", delay, attempt+1, t.retries))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+// shouldRetry reports whether a request that got resp or err is worth
+// sending again
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		if req.Context().Err() != nil {
+			return false
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return true
+		}
+		return idempotent(req.Method)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent(req.Method)
+	}
+	return false
+}
+
+// idempotent reports whether a request with method is safe to send twice.
+// PUT is left out: a set repeated after the server failed part way could
+// overwrite a value another client wrote in between.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// requestError describes a failed request for the user, calling out timeouts
+func requestError(client *http.Client, addr string, err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Sprintf("Request to %s timed out after %s (use --timeout to wait longer)", addr, client.Timeout)
+	}
+	return fmt.Sprintf("Failed to connect to server at %s\n %v", addr, err)
+}
@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/pkg/version"
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withGlobalFlags attaches cmd to a root carrying the persistent flags the
+// CLI defines, so flag lookups behave as they do in the real binary
+func withGlobalFlags(cmd *cobra.Command) *cobra.Command {
+	root := &cobra.Command{Use: "root"}
+	root.PersistentFlags().String(AddrFlag, "", "")
+	root.PersistentFlags().String(NamespaceFlag, "", "")
+	root.PersistentFlags().Duration(TimeoutFlag, DefaultTimeout, "")
+	root.PersistentFlags().Int(RetriesFlag, 0, "")
+	root.PersistentFlags().String(TokenFlag, "", "")
+	root.AddCommand(cmd)
+	return root
+}
+
+func TestNewClient_Timeout(t *testing.T) {
+	cmd := &cobra.Command{Use: "child", Run: func(cmd *cobra.Command, args []string) {}}
+	root := withGlobalFlags(cmd)
+
+	root.SetArgs([]string{"child"})
+	assert.NoError(t, root.Execute())
+	assert.Equal(t, 5*time.Minute, newClient(cmd, 5*time.Minute).Timeout, "fallback applies without the flag")
+
+	root.SetArgs([]string{"child", "--timeout", "3s"})
+	assert.NoError(t, root.Execute())
+	assert.Equal(t, 3*time.Second, newClient(cmd, 5*time.Minute).Timeout)
+}
+
+func TestNewClient_UserAgent(t *testing.T) {
+	agents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents <- r.UserAgent()
+	}))
+	defer server.Close()
+
+	resp, err := newClient(&cobra.Command{}, DefaultTimeout).Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "logkv-cli/"+version.Version, <-agents)
+}
+
+func TestGetCommand_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	root := withGlobalFlags(NewGetCommand())
+	output := captureOutput(func() {
+		root.SetArgs([]string{"get", "slow", "--addr", server.URL, "--timeout", "50ms"})
+		assert.NoError(t, root.Execute())
+	})
+	assert.Contains(t, output, "[ERROR]")
+	assert.Contains(t, output, "timed out after 50ms")
+}
+
+// fastRetries shortens the retry backoff for the duration of a test
+func fastRetries(t *testing.T) {
+	base, max := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { retryBaseDelay, retryMaxDelay = base, max })
+}
+
+// flakyServer answers the first failures requests with 503 and the rest with
+// handler, counting every request
+func flakyServer(t *testing.T, failures int32, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestGetCommand_Retries(t *testing.T) {
+	fastRetries(t)
+	found := func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(servertypes.GetResponse{Key: "k", Value: "v"})
+	}
+
+	t.Run("SucceedsAfterFailures", func(t *testing.T) {
+		server, calls := flakyServer(t, 2, found)
+		output := captureOutput(func() {
+			executeCommand(t, withGlobalFlags(NewGetCommand()), []string{"get", "k", "--addr", server.URL, "--retries", "3"})
+		})
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Contains(t, output, "Retrying in")
+		assert.Contains(t, output, "Value: v")
+		assert.NotContains(t, output, "[ERROR]")
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		server, calls := flakyServer(t, 5, found)
+		output := captureOutput(func() {
+			executeCommand(t, withGlobalFlags(NewGetCommand()), []string{"get", "k", "--addr", server.URL, "--retries", "1"})
+		})
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Contains(t, output, "Server error: 503")
+	})
+
+	t.Run("OffByDefault", func(t *testing.T) {
+		server, calls := flakyServer(t, 1, found)
+		output := captureOutput(func() {
+			executeCommand(t, withGlobalFlags(NewGetCommand()), []string{"get", "k", "--addr", server.URL})
+		})
+		assert.Equal(t, int32(1), calls.Load())
+		assert.NotContains(t, output, "Retrying")
+	})
+}
+
+func TestSetCommand_NoRetryAfterServerError(t *testing.T) {
+	fastRetries(t)
+	server, calls := flakyServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	output := captureOutput(func() {
+		executeCommand(t, withGlobalFlags(NewSetCommand()), []string{"set", "k", "v", "--addr", server.URL, "--retries", "3"})
+	})
+	assert.Equal(t, int32(1), calls.Load(), "The server may have applied the set")
+	assert.Contains(t, output, "Server error: 503")
+}
+
+// refusingTransport refuses the first refusals connections and then hands
+// requests to base, recording the bodies it sends
+type refusingTransport struct {
+	refusals int
+	base     http.RoundTripper
+	bodies   []string
+}
+
+func (t *refusingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.refusals > 0 {
+		t.refusals--
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	}
+	body, _ := io.ReadAll(req.Body)
+	t.bodies = append(t.bodies, string(body))
+	req.Body = io.NopCloser(strings.NewReader(string(body)))
+	return t.base.RoundTrip(req)
+}
+
+func TestRetryTransport_RetriesRefusedSet(t *testing.T) {
+	fastRetries(t)
+	server, calls := flakyServer(t, 0, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	base := &refusingTransport{refusals: 2, base: http.DefaultTransport}
+	client := &http.Client{Transport: retryTransport{base: base, retries: 2}}
+
+	captureOutput(func() {
+		req, err := http.NewRequest(http.MethodPut, server.URL, strings.NewReader(`{"key":"k"}`))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, []string{`{"key":"k"}`}, base.bodies, "The body is sent again in full")
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/spf13/cobra"
+)
+
+// NewCompactCommand creates a new compact command
+func NewCompactCommand() *cobra.Command {
+	var dryRun, wait bool
+	cmd := &cobra.Command{
+		Use:   "compact",
+		Short: "Trigger a compaction of inactive segments",
+		Run: func(cmd *cobra.Command, args []string) {
+			addr := resolveAddr(cmd)
+			if dryRun {
+				previewCompaction(cmd, addr)
+				return
+			}
+
+			// Compaction can take a while on large stores
+			client := newClient(cmd, 5*time.Minute)
+			path := "/compact"
+			if wait {
+				path += "?wait=true"
+			}
+			resp, err := client.Post(apiURL(cmd, addr, path), "application/json", nil)
+			if err != nil {
+				failConnection(client, addr, err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode == http.StatusConflict {
+				output.Warn("A compaction is already in progress")
+				return
+			}
+			if resp.StatusCode != http.StatusOK {
+				fail(fmt.Sprintf("Server error: %s", resp.Status))
+				return
+			}
+			var out servertypes.CompactResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				fail(fmt.Sprintf("Invalid response: %v", err))
+				return
+			}
+			if !out.Success {
+				if out.Message != "" {
+					fail(out.Message)
+				} else {
+					fail("Request failed")
+				}
+				return
+			}
+			output.Success("Compaction completed")
+			output.Info(fmt.Sprintf("Segments compacted: %d", out.SegmentsCompacted))
+			output.Info(fmt.Sprintf("Bytes reclaimed: %d", out.BytesReclaimed))
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what a compaction would reclaim without running one")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for a compaction already in progress and report its result")
+	return cmd
+}
+
+// previewCompaction reports what a compaction would reclaim
+func previewCompaction(cmd *cobra.Command, addr string) {
+	client := newClient(cmd, 5*time.Minute)
+	resp, err := client.Get(apiURL(cmd, addr, "/compact/preview"))
+	if err != nil {
+		failConnection(client, addr, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fail(fmt.Sprintf("Server error: %s", resp.Status))
+		return
+	}
+	var out servertypes.CompactPreviewResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		fail(fmt.Sprintf("Invalid response: %v", err))
+		return
+	}
+	if !out.Success {
+		if out.Message != "" {
+			fail(out.Message)
+		} else {
+			fail("Request failed")
+		}
+		return
+	}
+	if len(out.Segments) == 0 {
+		output.Info("No segments to compact")
+		return
+	}
+	output.Success("Compaction preview")
+	output.Info(fmt.Sprintf("Segments: %v", out.Segments))
+	output.Info(fmt.Sprintf("Live bytes: %d", out.LiveBytes))
+	output.Info(fmt.Sprintf("Reclaimable bytes: %d", out.DeadBytes))
+	output.Info(fmt.Sprintf("Tombstones: %d", out.Tombstones))
+}
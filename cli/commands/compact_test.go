@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactCommand_Success(t *testing.T) {
+	resp := servertypes.CompactResponse{
+		BaseResponse:      servertypes.BaseResponse{Success: true},
+		SegmentsCompacted: 3,
+		BytesReclaimed:    4096,
+	}
+	data, _ := json.Marshal(resp)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/compact", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	cmd := NewCompactCommand()
+	output := captureOutput(func() {
+		cmd.SetArgs([]string{})
+		_ = cmd.Execute()
+	})
+	assert.Contains(t, output, "[SUCCESS]")
+	assert.Contains(t, output, "Segments compacted: 3")
+	assert.Contains(t, output, "Bytes reclaimed: 4096")
+}
+
+func TestCompactCommand_DryRun(t *testing.T) {
+	resp := servertypes.CompactPreviewResponse{
+		BaseResponse: servertypes.BaseResponse{Success: true},
+		Segments:     []int{1, 2},
+		LiveBytes:    1024,
+		DeadBytes:    4096,
+		Tombstones:   7,
+	}
+	data, _ := json.Marshal(resp)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/compact/preview", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	cmd := NewCompactCommand()
+	output := captureOutput(func() {
+		cmd.SetArgs([]string{"--dry-run"})
+		_ = cmd.Execute()
+	})
+	assert.Contains(t, output, "Segments: [1 2]")
+	assert.Contains(t, output, "Reclaimable bytes: 4096")
+	assert.Contains(t, output, "Tombstones: 7")
+	assert.NotContains(t, output, "Compaction completed")
+}
+
+func TestCompactCommand_Wait(t *testing.T) {
+	data, _ := json.Marshal(servertypes.CompactResponse{BaseResponse: servertypes.BaseResponse{Success: true}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/compact", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("wait"))
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	cmd := NewCompactCommand()
+	output := captureOutput(func() {
+		cmd.SetArgs([]string{"--wait"})
+		_ = cmd.Execute()
+	})
+	assert.Contains(t, output, "Compaction completed")
+}
+
+func TestCompactCommand_InProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	cmd := NewCompactCommand()
+	output := captureOutput(func() {
+		_ = cmd.Execute()
+	})
+	assert.Contains(t, output, "already in progress")
+}
+
+func TestCompactCommand_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	cmd := NewCompactCommand()
+	output := captureOutput(func() {
+		_ = cmd.Execute()
+	})
+	assert.Contains(t, output, "[ERROR]")
+	assert.Contains(t, output, "Server error")
+}
@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// TokenFlag is the persistent flag setting the bearer token sent to the server
+const TokenFlag = "token"
+
+// FileConfig holds the defaults read from the CLI config file. Flags and
+// environment variables override each of them.
+type FileConfig struct {
+	Addr    string        `yaml:"addr,omitempty"`    // Server address (overridden by --addr and LOGKV_ADDR)
+	Timeout time.Duration `yaml:"timeout,omitempty"` // Timeout for ordinary requests (overridden by --timeout)
+	Token   string        `yaml:"token,omitempty"`   // Bearer token (overridden by --token and LOGKV_TOKEN)
+}
+
+// fileConfig is the config file LoadConfig last read
+var fileConfig FileConfig
+
+// ConfigPath returns the CLI config file: $LOGKV_CLI_CONFIG, or
+// ~/.logkv/config.yaml. LOGKV_CONFIG is left to the server's config file.
+func ConfigPath() string {
+	if path := os.Getenv("LOGKV_CLI_CONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".logkv", "config.yaml")
+}
+
+// LoadConfig reads the CLI config file into the defaults every command
+// uses. A missing file leaves them unset.
+func LoadConfig() error {
+	cfg, err := readConfigFile(ConfigPath())
+	if err != nil {
+		return err
+	}
+	fileConfig = cfg
+	return nil
+}
+
+// readConfigFile parses the config file at path, which may not exist
+func readConfigFile(path string) (FileConfig, error) {
+	var cfg FileConfig
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("config: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return cfg, fmt.Errorf("config: parsing %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// writeConfigFile saves cfg to path, readable only by its owner since it
+// may hold a token
+func writeConfigFile(path string, cfg FileConfig) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// resolveToken returns the bearer token from the --token flag, falling back
+// to the LOGKV_TOKEN environment variable and then the config file
+func resolveToken(cmd *cobra.Command) string {
+	if flag := cmd.Flag(TokenFlag); flag != nil && flag.Value.String() != "" {
+		return flag.Value.String()
+	}
+	if token := os.Getenv("LOGKV_TOKEN"); token != "" {
+		return token
+	}
+	return fileConfig.Token
+}
+
+// NewConfigCommand creates a new config command
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the CLI config file",
+		Long: "Manage the CLI config file, $LOGKV_CLI_CONFIG or ~/.logkv/config.yaml, which holds " +
+			"the default addr, timeout and token. Flags and environment variables override it.",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "set <addr|timeout|token> <value>",
+		Short: "Set a default in the CLI config file",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigSet(args[0], args[1])
+		},
+	})
+	return cmd
+}
+
+// runConfigSet sets one key of the config file, keeping the others
+func runConfigSet(key, value string) {
+	path := ConfigPath()
+	if path == "" {
+		fail("Cannot find the home directory; set LOGKV_CLI_CONFIG")
+		return
+	}
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+
+	switch key {
+	case "addr":
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+			failUsage(fmt.Sprintf("Invalid addr %q: want a URL such as %s", value, DefaultAddr))
+			return
+		}
+		cfg.Addr = value
+	case "timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			failUsage(fmt.Sprintf("Invalid timeout %q: want a duration such as 30s", value))
+			return
+		}
+		cfg.Timeout = d
+	case "token":
+		cfg.Token = value
+	default:
+		failUsage(fmt.Sprintf("Unknown config key %q (want addr, timeout or token)", key))
+		return
+	}
+
+	if err := writeConfigFile(path, cfg); err != nil {
+		fail(fmt.Sprintf("Failed to write %s: %v", path, err))
+		return
+	}
+	result := struct {
+		Key  string `json:"key"`
+		Path string `json:"path"`
+	}{key, path}
+	output.Result(result, func() {
+		output.Success(fmt.Sprintf("Set %s in %s", key, path))
+	})
+}
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withConfigFile points LOGKV_CLI_CONFIG at a file holding contents, or at a
+// missing file when contents is empty, and loads it for the duration of a test
+func withConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if contents != "" {
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	}
+	t.Setenv("LOGKV_CLI_CONFIG", path)
+	old := fileConfig
+	t.Cleanup(func() { fileConfig = old })
+	require.NoError(t, LoadConfig())
+	return path
+}
+
+func TestLoadConfig_MissingFile(t *testing.T) {
+	withConfigFile(t, "")
+	assert.Equal(t, FileConfig{}, fileConfig)
+
+	t.Setenv("LOGKV_ADDR", "")
+	t.Setenv("LOGKV_TOKEN", "")
+	assert.Equal(t, DefaultAddr, resolvedAddr(t))
+	assert.Empty(t, resolveToken(&cobra.Command{}))
+	assert.Equal(t, DefaultTimeout, newClient(&cobra.Command{}, DefaultTimeout).Timeout)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("adress: http://typo:8080\n"), 0o600))
+	t.Setenv("LOGKV_CLI_CONFIG", path)
+	assert.ErrorContains(t, LoadConfig(), "adress")
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	withConfigFile(t, "addr: http://from-file:8080\ntimeout: 30s\ntoken: file-token\n")
+	assert.Equal(t, FileConfig{Addr: "http://from-file:8080", Timeout: 30 * time.Second, Token: "file-token"}, fileConfig)
+
+	var addr, token string
+	var timeout, streamTimeout time.Duration
+	child := &cobra.Command{
+		Use: "child",
+		Run: func(cmd *cobra.Command, args []string) {
+			addr, token = resolveAddr(cmd), resolveToken(cmd)
+			timeout = newClient(cmd, DefaultTimeout).Timeout
+			streamTimeout = newClient(cmd, 0).Timeout
+		},
+	}
+	root := withGlobalFlags(child)
+	run := func(args ...string) {
+		root.SetArgs(append([]string{"child"}, args...))
+		require.NoError(t, root.Execute())
+	}
+
+	// The file fills in what flags and environment variables leave unset
+	t.Setenv("LOGKV_ADDR", "")
+	t.Setenv("LOGKV_TOKEN", "")
+	run()
+	assert.Equal(t, "http://from-file:8080", addr)
+	assert.Equal(t, "file-token", token)
+	assert.Equal(t, 30*time.Second, timeout)
+	assert.Zero(t, streamTimeout, "The file's timeout only replaces the default for ordinary requests")
+
+	// Environment variables override the file
+	t.Setenv("LOGKV_ADDR", "http://from-env:8080")
+	t.Setenv("LOGKV_TOKEN", "env-token")
+	run()
+	assert.Equal(t, "http://from-env:8080", addr)
+	assert.Equal(t, "env-token", token)
+
+	// Flags override both
+	run("--addr", "http://from-flag:9000", "--token", "flag-token", "--timeout", "3s")
+	assert.Equal(t, "http://from-flag:9000", addr)
+	assert.Equal(t, "flag-token", token)
+	assert.Equal(t, 3*time.Second, timeout)
+}
+
+func TestNewClient_Token(t *testing.T) {
+	headers := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+	withConfigFile(t, "token: secret\n")
+	t.Setenv("LOGKV_TOKEN", "")
+
+	resp, err := newClient(&cobra.Command{}, DefaultTimeout).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "Bearer secret", <-headers)
+
+	fileConfig = FileConfig{}
+	resp, err = newClient(&cobra.Command{}, DefaultTimeout).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, <-headers, "No token, no Authorization header")
+}
+
+func TestConfigSetCommand(t *testing.T) {
+	path := withConfigFile(t, "")
+	path = filepath.Join(filepath.Dir(path), "nested", "config.yaml")
+	t.Setenv("LOGKV_CLI_CONFIG", path)
+	ResetExitCode()
+	t.Cleanup(ResetExitCode)
+
+	set := func(key, value string) string {
+		return captureOutput(func() {
+			executeCommand(t, NewConfigCommand(), []string{"set", key, value})
+		})
+	}
+
+	assert.Contains(t, set("addr", "http://kv.internal:8080"), "Set addr in "+path)
+	set("timeout", "45s")
+	out := set("token", "s3cret")
+	assert.NotContains(t, out, "s3cret", "The token is not echoed")
+	assert.Equal(t, ExitOK, ExitCode())
+
+	cfg, err := readConfigFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, FileConfig{Addr: "http://kv.internal:8080", Timeout: 45 * time.Second, Token: "s3cret"}, cfg)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Invalid values leave the file alone
+	for _, args := range [][2]string{{"addr", "kv.internal"}, {"timeout", "soon"}, {"color", "red"}} {
+		ResetExitCode()
+		assert.Contains(t, set(args[0], args[1]), "[ERROR]")
+		assert.Equal(t, ExitUsage, ExitCode(), args[0])
+	}
+	unchanged, err := readConfigFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, cfg, unchanged)
+}
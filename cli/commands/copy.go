@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/spf13/cobra"
+)
+
+// NewCopyCommand creates a new copy command
+func NewCopyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "copy <src> <dst>",
+		Short: "Copy a value to another key",
+		Long:  "Copy the value of src, and its expiry, to dst, overwriting any value dst has.",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runCopy(cmd, "copy", args[0], args[1])
+		},
+	}
+}
+
+// NewRenameCommand creates a new rename command
+func NewRenameCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename <src> <dst>",
+		Short: "Move a value to another key",
+		Long:  "Move the value of src, and its expiry, to dst, overwriting any value dst has, and delete src.",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runCopy(cmd, "rename", args[0], args[1])
+		},
+	}
+}
+
+// runCopy asks the server to copy or rename src to dst
+func runCopy(cmd *cobra.Command, action, src, dst string) {
+	addr := resolveAddr(cmd)
+
+	client := newClient(cmd, DefaultTimeout)
+	body, _ := json.Marshal(map[string]string{"to": dst})
+	resp, err := client.Post(apiURL(cmd, addr, "/kv/"+url.PathEscape(src)+"/"+action), "application/json", bytes.NewReader(body))
+	if err != nil {
+		failConnection(client, addr, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		notFound(fmt.Sprintf("Key '%s' not found", src))
+		return
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		fail(fmt.Sprintf("Server error: %s", resp.Status))
+		return
+	}
+
+	verb := "Copied"
+	if action == "rename" {
+		verb = "Renamed"
+	}
+	result := struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}{src, dst}
+	output.Result(result, func() {
+		output.Success(fmt.Sprintf("%s %s to %s", verb, src, dst))
+	})
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyCommands(t *testing.T) {
+	requests := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r.Method + " " + r.URL.EscapedPath() + " " + string(body)
+		if r.URL.Path == "/v1/kv/missing/rename" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	ResetExitCode()
+	output := captureOutput(func() {
+		executeCommand(t, withGlobalFlags(NewCopyCommand()), []string{"copy", "a/b", "c", "--addr", server.URL})
+	})
+	assert.Equal(t, `POST /v1/kv/a%2Fb/copy {"to":"c"}`, <-requests)
+	assert.Contains(t, output, "Copied a/b to c")
+	assert.Equal(t, ExitOK, ExitCode())
+
+	output = captureOutput(func() {
+		executeCommand(t, withGlobalFlags(NewRenameCommand()), []string{"rename", "a", "c", "--addr", server.URL})
+	})
+	assert.Equal(t, `POST /v1/kv/a/rename {"to":"c"}`, <-requests)
+	assert.Contains(t, output, "Renamed a to c")
+
+	output = captureOutput(func() {
+		executeCommand(t, withGlobalFlags(NewRenameCommand()), []string{"rename", "missing", "c", "--addr", server.URL})
+	})
+	<-requests
+	assert.Contains(t, output, "Key 'missing' not found")
+	assert.Equal(t, ExitNotFound, ExitCode())
+}
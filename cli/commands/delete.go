@@ -1,12 +1,11 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
-	"net/http"
-	"os"
-	"time"
 
 	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/himakhaitan/logkv-store/client"
 	"github.com/spf13/cobra"
 )
 
@@ -18,32 +17,25 @@ func NewDeleteCommand() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			key := args[0]
-			addr := os.Getenv("LOGKV_ADDR")
-			if addr == "" {
-				addr = "http://localhost:8080"
-			}
+			addr := resolveAddr(cmd)
 
-			client := &http.Client{Timeout: 10 * time.Second}
-			req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/v1/kv/%s", addr, key), nil)
-			if err != nil {
-				output.Error(fmt.Sprintf("Failed to create request: %v", err))
+			api, httpClient := newAPIClient(cmd, addr, DefaultTimeout)
+			err := api.Delete(cmd.Context(), key)
+			if errors.Is(err, client.ErrNotFound) {
+				notFound(fmt.Sprintf("Key '%s' not found", key))
 				return
 			}
-			resp, err := client.Do(req)
 			if err != nil {
-				output.Error(fmt.Sprintf("Failed to connect to server at %s\n %v", addr, err))
-				return
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode == http.StatusNotFound {
-				output.Warn(fmt.Sprintf("Key '%s' not found", key))
-				return
-			}
-			if resp.StatusCode != http.StatusNoContent {
-				output.Error(fmt.Sprintf("Server error: %s", resp.Status))
+				failRequest(httpClient, addr, err)
 				return
 			}
-			output.Success(fmt.Sprintf("Deleted key: %s", key))
+			result := struct {
+				Key     string `json:"key"`
+				Deleted bool   `json:"deleted"`
+			}{key, true}
+			output.Result(result, func() {
+				output.Success(fmt.Sprintf("Deleted key: %s", key))
+			})
 		},
 	}
 }
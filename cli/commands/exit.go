@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/himakhaitan/logkv-store/client"
+)
+
+// Exit codes the CLI finishes with, so that scripts can tell failures apart
+const (
+	ExitOK         = 0 // The command succeeded
+	ExitError      = 1 // The command failed for any other reason
+	ExitNotFound   = 2 // get or delete named a key that does not exist
+	ExitConnection = 3 // The server could not be reached, or stopped answering
+	ExitUsage      = 4 // The command line was invalid
+)
+
+// exitCode is the code the commands run since the last ResetExitCode finish
+// with
+var exitCode = ExitOK
+
+// ExitCode returns the code the CLI should exit with after running a command
+func ExitCode() int {
+	return exitCode
+}
+
+// ResetExitCode clears the code left by an earlier command
+func ResetExitCode() {
+	exitCode = ExitOK
+}
+
+// exitWith sets the code the CLI exits with
+func exitWith(code int) {
+	exitCode = code
+}
+
+// fail reports a failure that has no more specific exit code
+func fail(msg string) {
+	output.Error(msg)
+	exitWith(ExitError)
+}
+
+// failConnection reports a request that could not reach the server
+func failConnection(client *http.Client, addr string, err error) {
+	output.Error(requestError(client, addr, err))
+	exitWith(ExitConnection)
+}
+
+// failRequest reports a request made with an API client that failed for
+// any reason other than a missing key
+func failRequest(httpClient *http.Client, addr string, err error) {
+	var apiErr *client.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusOK:
+		// The server answered, but reported that the request failed
+		if apiErr.Message != "" {
+			fail(apiErr.Message)
+		} else {
+			fail("Request failed")
+		}
+	case errors.As(err, &apiErr):
+		fail(fmt.Sprintf("Server error: %s", apiErr.Status))
+	case errors.Is(err, client.ErrInvalidResponse):
+		fail("Invalid response" + strings.TrimPrefix(err.Error(), client.ErrInvalidResponse.Error()))
+	default:
+		failConnection(httpClient, addr, err)
+	}
+}
+
+// failUsage reports a command line that cannot be run
+func failUsage(msg string) {
+	output.Error(msg)
+	exitWith(ExitUsage)
+}
+
+// notFound reports a key that does not exist
+func notFound(msg string) {
+	output.Warn(msg)
+	exitWith(ExitNotFound)
+}
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+// runExitCode runs cmd with args and returns the code the CLI would exit with
+func runExitCode(t *testing.T, cmd *cobra.Command, args []string) int {
+	ResetExitCode()
+	captureOutput(func() {
+		executeCommand(t, withGlobalFlags(cmd), args)
+	})
+	return ExitCode()
+}
+
+func TestExitCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/kv/present":
+			if r.Method == http.MethodDelete {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			json.NewEncoder(w).Encode(servertypes.GetResponse{Key: "present", Value: "v"})
+		case "/v1/kv/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	refused := "http://127.0.0.1:1"
+
+	tests := []struct {
+		name string
+		cmd  func() *cobra.Command
+		args []string
+		want int
+	}{
+		{"GetFound", NewGetCommand, []string{"get", "present", "--addr", server.URL}, ExitOK},
+		{"GetMissing", NewGetCommand, []string{"get", "missing", "--addr", server.URL}, ExitNotFound},
+		{"GetServerError", NewGetCommand, []string{"get", "broken", "--addr", server.URL}, ExitError},
+		{"GetRefused", NewGetCommand, []string{"get", "present", "--addr", refused}, ExitConnection},
+		{"DeleteFound", NewDeleteCommand, []string{"delete", "present", "--addr", server.URL}, ExitOK},
+		{"DeleteMissing", NewDeleteCommand, []string{"delete", "missing", "--addr", server.URL}, ExitNotFound},
+		{"SetServerError", NewSetCommand, []string{"set", "k", "v", "--addr", server.URL}, ExitError},
+		{"SetRefused", NewSetCommand, []string{"set", "k", "v", "--addr", refused}, ExitConnection},
+		{"ListRefused", NewListCommand, []string{"list", "--addr", refused}, ExitConnection},
+		{"StatsServerError", NewStatsCommand, []string{"stats", "--addr", server.URL}, ExitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, runExitCode(t, tt.cmd(), tt.args))
+		})
+	}
+}
+
+func TestExitCodes_Shell(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	run := func(lines ...string) int {
+		shell := NewShellCommand()
+		shell.SetIn(strings.NewReader(strings.Join(lines, "\n")))
+		return runExitCode(t, shell, []string{"shell", "--addr", server.URL})
+	}
+
+	assert.Equal(t, ExitNotFound, run("get missing"))
+	assert.Equal(t, ExitUsage, run("bogus"))
+	assert.Equal(t, ExitUsage, run("get"))
+	assert.Equal(t, ExitOK, run("bogus", "help"), "help succeeds")
+	assert.Equal(t, ExitNotFound, run("bogus", "get missing"), "The last command decides")
+	assert.Equal(t, ExitUsage, run("bogus", "exit"))
+}
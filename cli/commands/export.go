@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/spf13/cobra"
+)
+
+// NewExportCommand creates a new export command
+func NewExportCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "export <file>",
+		Short: "Export all key-value pairs to a file",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := args[0]
+			addr := resolveAddr(cmd)
+
+			// No timeout unless --timeout is given: the export streams for as long
+			// as the dataset takes
+			client := newClient(cmd, 0)
+			resp, err := client.Get(apiURL(cmd, addr, "/export"))
+			if err != nil {
+				failConnection(client, addr, err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				fail(fmt.Sprintf("Server error: %s", resp.Status))
+				return
+			}
+
+			file, err := os.Create(path)
+			if err != nil {
+				fail(fmt.Sprintf("Failed to create %s: %v", path, err))
+				return
+			}
+			defer file.Close()
+
+			counter := &lineCounter{}
+			if _, err := io.Copy(io.MultiWriter(file, counter), resp.Body); err != nil {
+				output.Error(fmt.Sprintf("Export interrupted: %v", err))
+				exitWith(ExitConnection)
+				return
+			}
+			if err := file.Sync(); err != nil {
+				fail(fmt.Sprintf("Failed to write %s: %v", path, err))
+				return
+			}
+			output.Success(fmt.Sprintf("Exported %d keys to %s", counter.lines, path))
+		},
+	}
+}
+
+// lineCounter counts the newline-terminated records written to it
+type lineCounter struct {
+	lines int
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	c.lines += bytes.Count(p, []byte{'\n'})
+	return len(p), nil
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportCommand_Success(t *testing.T) {
+	body := `{"key":"a","value":"1"}` + "\n" + `{"key":"b","value":"2"}` + "\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/export", r.URL.Path)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	path := filepath.Join(t.TempDir(), "dump.ndjson")
+	output := captureOutput(func() {
+		executeCommand(t, NewExportCommand(), []string{path})
+	})
+	assert.Contains(t, output, "[SUCCESS]")
+	assert.Contains(t, output, "Exported 2 keys")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(data))
+}
+
+func TestExportCommand_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	path := filepath.Join(t.TempDir(), "dump.ndjson")
+	output := captureOutput(func() {
+		executeCommand(t, NewExportCommand(), []string{path})
+	})
+	assert.Contains(t, output, "[ERROR]")
+	assert.NoFileExists(t, path, "Nothing should be written on failure")
+}
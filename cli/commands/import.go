@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/spf13/cobra"
+)
+
+// NewImportCommand creates a new import command
+func NewImportCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import key-value pairs from a file written by export",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := args[0]
+			addr := resolveAddr(cmd)
+
+			file, err := os.Open(path)
+			if err != nil {
+				fail(fmt.Sprintf("Failed to open %s: %v", path, err))
+				return
+			}
+			defer file.Close()
+
+			// No timeout unless --timeout is given: the import streams for as long
+			// as the dataset takes
+			client := newClient(cmd, 0)
+			resp, err := client.Post(apiURL(cmd, addr, "/import"), "application/x-ndjson", file)
+			if err != nil {
+				failConnection(client, addr, err)
+				return
+			}
+			defer resp.Body.Close()
+
+			var out servertypes.ImportResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				fail(fmt.Sprintf("Server error: %s", resp.Status))
+				return
+			}
+			if !out.Success {
+				message := out.Message
+				if message == "" {
+					message = "Request failed"
+				}
+				fail(fmt.Sprintf("%s (%d keys imported before the failure)", message, out.Imported))
+				return
+			}
+			output.Success(fmt.Sprintf("Imported %d keys from %s", out.Imported, path))
+		},
+	}
+}
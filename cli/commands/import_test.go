@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportCommand_Success(t *testing.T) {
+	body := `{"key":"a","value":"1"}` + "\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/import", r.URL.Path)
+		received, _ := io.ReadAll(r.Body)
+		assert.Equal(t, body, string(received))
+		json.NewEncoder(w).Encode(servertypes.ImportResponse{
+			BaseResponse: servertypes.BaseResponse{Success: true},
+			Imported:     1,
+		})
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	path := filepath.Join(t.TempDir(), "dump.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(body), 0644))
+
+	output := captureOutput(func() {
+		executeCommand(t, NewImportCommand(), []string{path})
+	})
+	assert.Contains(t, output, "[SUCCESS]")
+	assert.Contains(t, output, "Imported 1 keys")
+}
+
+func TestImportCommand_PartialFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(servertypes.ImportResponse{
+			BaseResponse: servertypes.BaseResponse{Success: false, Message: "invalid json"},
+			Imported:     5,
+		})
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	path := filepath.Join(t.TempDir(), "dump.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0644))
+
+	output := captureOutput(func() {
+		executeCommand(t, NewImportCommand(), []string{path})
+	})
+	assert.Contains(t, output, "[ERROR]")
+	assert.Contains(t, output, "invalid json (5 keys imported before the failure)")
+}
+
+func TestImportCommand_MissingFile(t *testing.T) {
+	output := captureOutput(func() {
+		executeCommand(t, NewImportCommand(), []string{filepath.Join(t.TempDir(), "missing")})
+	})
+	assert.Contains(t, output, "[ERROR]")
+	assert.Contains(t, output, "Failed to open")
+}
@@ -1,10 +1,10 @@
 package commands
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
-	"net/http"
-	"os"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/himakhaitan/logkv-store/cli/output"
@@ -14,47 +14,79 @@ import (
 
 // NewListCommand creates a new list command
 func NewListCommand() *cobra.Command {
-	return &cobra.Command{
+	var meta bool
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List all keys",
 		Run: func(cmd *cobra.Command, args []string) {
-			addr := os.Getenv("LOGKV_ADDR")
-			if addr == "" {
-				addr = "http://localhost:8080"
+			if meta {
+				listWithMeta(cmd)
+				return
 			}
+			addr := resolveAddr(cmd)
 
-			client := &http.Client{Timeout: 10 * time.Second}
-			resp, err := client.Get(fmt.Sprintf("%s/v1/keys", addr))
+			api, httpClient := newAPIClient(cmd, addr, DefaultTimeout)
+			keys, err := api.List(cmd.Context())
 			if err != nil {
-				output.Error(fmt.Sprintf("Failed to connect to server at %s\n %v", addr, err))
-				return
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				output.Error(fmt.Sprintf("Server error: %s", resp.Status))
-				return
-			}
-			var out servertypes.ListKeysResponse
-			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-				output.Error(fmt.Sprintf("Invalid response: %v", err))
+				failRequest(httpClient, addr, err)
 				return
 			}
-			if !out.Success {
-				if out.Message != "" {
-					output.Error(out.Message)
+			result := struct {
+				Keys []string `json:"keys"`
+			}{keys}
+			output.Result(result, func() {
+				if len(keys) == 0 {
+					output.Info("No keys found")
 				} else {
-					output.Error("Request failed")
-				}
-				return
-			}
-			if len(out.Keys) == 0 {
-				output.Info("No keys found")
-			} else {
-				output.Success("Keys:")
-				for _, key := range out.Keys {
-					output.Info(key)
+					output.Success("Keys:")
+					for _, key := range keys {
+						output.Info(key)
+					}
 				}
-			}
+			})
 		},
 	}
+	cmd.Flags().BoolVar(&meta, "meta", false, "Show each key's size, write time and time to live")
+	return cmd
+}
+
+// listWithMeta lists keys along with their metadata
+func listWithMeta(cmd *cobra.Command) {
+	addr := resolveAddr(cmd)
+
+	api, httpClient := newAPIClient(cmd, addr, DefaultTimeout)
+	keys, err := api.ListWithMeta(cmd.Context())
+	if err != nil {
+		failRequest(httpClient, addr, err)
+		return
+	}
+	result := struct {
+		Keys []servertypes.KeyInfo `json:"keys"`
+	}{keys}
+	output.Result(result, func() {
+		if len(keys) == 0 {
+			output.Info("No keys found")
+			return
+		}
+		output.Success("Keys:")
+		for _, line := range keyTable(keys) {
+			output.Info(line)
+		}
+	})
+}
+
+// keyTable formats keys as aligned table rows under a header
+func keyTable(keys []servertypes.KeyInfo) []string {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSIZE\tWRITTEN\tTTL")
+	for _, k := range keys {
+		ttl := "-"
+		if k.TTL > 0 {
+			ttl = (time.Duration(k.TTL) * time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", k.Key, k.Size, time.Unix(k.Timestamp, 0).UTC().Format(time.RFC3339), ttl)
+	}
+	tw.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
 }
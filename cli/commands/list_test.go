@@ -95,6 +95,28 @@ func TestListCommand(t *testing.T) {
 	}
 }
 
+func TestListCommand_Meta(t *testing.T) {
+	written := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("meta"))
+		json.NewEncoder(w).Encode(servertypes.ListKeysMetaResponse{
+			BaseResponse: servertypes.BaseResponse{Success: true},
+			Keys: []servertypes.KeyInfo{
+				{Key: "session", Timestamp: written, Size: 12, TTL: 90},
+				{Key: "user", Timestamp: written, Size: 3},
+			},
+		})
+	}))
+	defer server.Close()
+
+	output := captureOutput(func() {
+		executeCommand(t, withGlobalFlags(NewListCommand()), []string{"list", "--meta", "--addr", server.URL})
+	})
+	assert.Contains(t, output, "KEY      SIZE  WRITTEN               TTL")
+	assert.Contains(t, output, "session  12    2024-05-01T12:00:00Z  1m30s")
+	assert.Contains(t, output, "user     3     2024-05-01T12:00:00Z  -")
+}
+
 func TestListCommand_NetworkFailure(t *testing.T) {
 	os.Setenv("LOGKV_ADDR", "http://127.0.0.1:1")
 	defer os.Unsetenv("LOGKV_ADDR")
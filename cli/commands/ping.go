@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/spf13/cobra"
+)
+
+// NewPingCommand creates a new ping command
+func NewPingCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ping",
+		Short: "Check the server responds and show its uptime and version",
+		Run: func(cmd *cobra.Command, args []string) {
+			addr := resolveAddr(cmd)
+
+			// Ping reports on the whole server, so it ignores --namespace
+			client := newClient(cmd, DefaultTimeout)
+			start := time.Now()
+			resp, err := client.Get(addr + "/v1/ping")
+			if err != nil {
+				failConnection(client, addr, err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				fail(fmt.Sprintf("Server error: %s", resp.Status))
+				return
+			}
+			var out servertypes.PingResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				fail(fmt.Sprintf("Invalid response: %v", err))
+				return
+			}
+			latency := time.Since(start)
+
+			uptime := time.Duration(out.UptimeSeconds * float64(time.Second))
+			result := struct {
+				LatencyMs     float64 `json:"latency_ms"`
+				Version       string  `json:"version"`
+				UptimeSeconds float64 `json:"uptime_seconds"`
+				Keys          int     `json:"keys"`
+				Segments      int     `json:"segments"`
+			}{float64(latency) / float64(time.Millisecond), out.Version, out.UptimeSeconds, out.Keys, out.Segments}
+			output.Result(result, func() {
+				output.Success(fmt.Sprintf("Pong from %s in %s", addr, latency.Round(time.Microsecond)))
+				output.Info(fmt.Sprintf("Version: %s", out.Version))
+				output.Info(fmt.Sprintf("Uptime: %s", uptime.Round(time.Second)))
+				output.Info(fmt.Sprintf("Keys: %d", out.Keys))
+				output.Info(fmt.Sprintf("Segments: %d", out.Segments))
+			})
+		},
+	}
+}
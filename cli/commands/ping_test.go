@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPingCommand_Success(t *testing.T) {
+	resp := servertypes.PingResponse{
+		BaseResponse:  servertypes.BaseResponse{Success: true},
+		Version:       "v1.2.3",
+		UptimeSeconds: 3725,
+		Keys:          42,
+		Segments:      3,
+	}
+	data, _ := json.Marshal(resp)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/ping", r.URL.Path)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	output := captureOutput(func() {
+		executeCommand(t, NewPingCommand(), []string{})
+	})
+	assert.Contains(t, output, "Pong from "+server.URL+" in ")
+	assert.Contains(t, output, "Version: v1.2.3")
+	assert.Contains(t, output, "Uptime: 1h2m5s")
+	assert.Contains(t, output, "Keys: 42")
+	assert.Contains(t, output, "Segments: 3")
+}
+
+func TestPingCommand_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+	ResetExitCode()
+	defer ResetExitCode()
+
+	output := captureOutput(func() {
+		executeCommand(t, NewPingCommand(), []string{})
+	})
+	assert.Contains(t, output, "[ERROR]")
+	assert.Contains(t, output, "401")
+	assert.Equal(t, ExitError, ExitCode())
+}
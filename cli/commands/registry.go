@@ -17,11 +17,23 @@ func NewCommandRegistry() *CommandRegistry {
 func (r *CommandRegistry) GetAllCommands() []*cobra.Command {
 	return []*cobra.Command{
 		NewVersionCommand(),
+		NewPingCommand(),
 		NewGetCommand(),
 		NewSetCommand(),
 		NewDeleteCommand(),
+		NewCopyCommand(),
+		NewRenameCommand(),
 		NewListCommand(),
 		NewStatsCommand(),
+		NewSegmentsCommand(),
+		NewCompactCommand(),
+		NewExportCommand(),
+		NewImportCommand(),
+		NewBackupCommand(),
+		NewRestoreCommand(),
+		NewWatchCommand(),
+		NewShellCommand(),
+		NewConfigCommand(),
 		NewServerCommand(),
 	}
 }
@@ -17,8 +17,8 @@ func TestGetAllCommands(t *testing.T) {
 	commands := reg.GetAllCommands()
 	assert.NotNil(t, commands)
 	assert.NotEmpty(t, commands)
-	assert.Len(t, commands, 7, "Expected 7 commands to be registered")
-	expected := []string{"version", "get", "set", "delete", "list", "stats", "server"}
+	assert.Len(t, commands, 19, "Expected 19 commands to be registered")
+	expected := []string{"version", "ping", "get", "set", "delete", "copy", "rename", "list", "stats", "segments", "compact", "export", "import", "backup", "restore", "watch", "shell", "config", "server"}
 	for i, cmd := range commands {
 		assert.Equal(t, expected[i], cmd.Name())
 	}
@@ -29,11 +29,11 @@ func TestRegisterCommands(t *testing.T) {
 	rootCmd := &cobra.Command{Use: "logkv"}
 	reg.RegisterCommands(rootCmd)
 	subCmds := rootCmd.Commands()
-	assert.Len(t, subCmds, 7)
+	assert.Len(t, subCmds, 19)
 	names := []string{}
 	for _, c := range subCmds {
 		names = append(names, c.Name())
 	}
-	expected := []string{"version", "get", "set", "delete", "list", "stats", "server"}
+	expected := []string{"version", "ping", "get", "set", "delete", "copy", "rename", "list", "stats", "segments", "compact", "export", "import", "backup", "restore", "watch", "shell", "config", "server"}
 	assert.ElementsMatch(t, expected, names)
 }
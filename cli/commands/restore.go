@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/store"
+	"github.com/spf13/cobra"
+)
+
+// NewRestoreCommand creates a new restore command
+func NewRestoreCommand() *cobra.Command {
+	var dataDir string
+	cmd := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Rebuild a data directory from a backup",
+		Long:  "Rebuild a data directory from a tar file written by backup. The directory must not already hold segments; start a server on it afterwards.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := args[0]
+			if dataDir == "" {
+				cfg, err := config.Load()
+				if err != nil {
+					fail(err.Error())
+					return
+				}
+				dataDir = cfg.DataDir
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				fail(fmt.Sprintf("Failed to open %s: %v", path, err))
+				return
+			}
+			defer file.Close()
+
+			if err := store.RestoreSnapshot(dataDir, file); err != nil {
+				fail(fmt.Sprintf("Restore failed: %v", err))
+				return
+			}
+			output.Success(fmt.Sprintf("Restored %s into %s", path, dataDir))
+		},
+	}
+	cmd.Flags().StringVar(&dataDir, "data-dir", "", "Data directory (default from config)")
+	return cmd
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRestoreCommand_Success(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	s, err := store.New(logger, &config.Config{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, s.Set("a", "1"))
+
+	path := filepath.Join(t.TempDir(), "backup.tar")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Snapshot(file))
+	require.NoError(t, file.Close())
+	require.NoError(t, s.Close())
+
+	dataDir := filepath.Join(t.TempDir(), "restored")
+	output := captureOutput(func() {
+		executeCommand(t, NewRestoreCommand(), []string{path, "--data-dir", dataDir})
+	})
+	assert.Contains(t, output, "[SUCCESS]")
+
+	restored, err := store.New(logger, &config.Config{DataDir: dataDir})
+	require.NoError(t, err)
+	defer restored.Close()
+	value, err := restored.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+}
+
+func TestRestoreCommand_MissingFile(t *testing.T) {
+	output := captureOutput(func() {
+		executeCommand(t, NewRestoreCommand(), []string{filepath.Join(t.TempDir(), "missing.tar"), "--data-dir", t.TempDir()})
+	})
+	assert.Contains(t, output, "[ERROR]")
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/spf13/cobra"
+)
+
+// NewSegmentsCommand creates a new segments command
+func NewSegmentsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "segments",
+		Short: "Show per-segment statistics",
+		Run: func(cmd *cobra.Command, args []string) {
+			addr := resolveAddr(cmd)
+
+			client := newClient(cmd, DefaultTimeout)
+			resp, err := client.Get(apiURL(cmd, addr, "/segments"))
+			if err != nil {
+				failConnection(client, addr, err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				fail(fmt.Sprintf("Server error: %s", resp.Status))
+				return
+			}
+			var out servertypes.SegmentsResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				fail(fmt.Sprintf("Invalid response: %v", err))
+				return
+			}
+			if !out.Success {
+				if out.Message != "" {
+					fail(out.Message)
+				} else {
+					fail("Request failed")
+				}
+				return
+			}
+			segments := out.Segments
+			if segments == nil {
+				segments = []servertypes.SegmentInfo{}
+			}
+			result := struct {
+				Segments []servertypes.SegmentInfo `json:"segments"`
+			}{segments}
+			output.Result(result, func() {
+				if len(segments) == 0 {
+					output.Info("No segments found")
+					return
+				}
+				output.Success("Segments:")
+				for _, line := range segmentTable(segments) {
+					output.Info(line)
+				}
+			})
+		},
+	}
+}
+
+// segmentTable formats segments as aligned table rows under a header
+func segmentTable(segments []servertypes.SegmentInfo) []string {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSIZE\tENTRIES\tACTIVE\tDEAD")
+	for _, s := range segments {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%t\t%.1f%%\n", s.ID, s.Size, s.Entries, s.Active, s.DeadRatio*100)
+	}
+	tw.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentsCommand_Success(t *testing.T) {
+	resp := servertypes.SegmentsResponse{
+		BaseResponse: servertypes.BaseResponse{Success: true},
+		Segments: []servertypes.SegmentInfo{
+			{ID: 1, Size: 4096, Entries: 100, DeadRatio: 0.25},
+			{ID: 2, Size: 512, Entries: 7, Active: true},
+		},
+	}
+	data, _ := json.Marshal(resp)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/segments", r.URL.Path)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	output := captureOutput(func() {
+		executeCommand(t, NewSegmentsCommand(), []string{})
+	})
+	assert.Contains(t, output, "[SUCCESS]")
+	assert.Regexp(t, `ID\s+SIZE\s+ENTRIES\s+ACTIVE\s+DEAD`, output)
+	assert.Regexp(t, `1\s+4096\s+100\s+false\s+25\.0%`, output)
+	assert.Regexp(t, `2\s+512\s+7\s+true\s+0\.0%`, output)
+}
+
+func TestSegmentsCommand_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	output := captureOutput(func() {
+		executeCommand(t, NewSegmentsCommand(), []string{})
+	})
+	assert.Contains(t, output, "[ERROR]")
+}
@@ -1,19 +1,54 @@
 package commands
 
 import (
+	"fmt"
+
 	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/himakhaitan/logkv-store/grpcserver"
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/pkg/logger"
+	"github.com/himakhaitan/logkv-store/pkg/metrics"
+	"github.com/himakhaitan/logkv-store/resp"
+	"github.com/himakhaitan/logkv-store/server"
 	"github.com/spf13/cobra"
+	"go.uber.org/fx"
 )
 
+// runServer runs the server app until it receives a shutdown signal. Tests
+// replace it to inspect the app without starting it.
+var runServer = func(opts ...fx.Option) {
+	fx.New(opts...).Run()
+}
+
 // NewServerCommand creates a new server command
 func NewServerCommand() *cobra.Command {
-	return &cobra.Command{
+	var dataDir, listen string
+	cmd := &cobra.Command{
 		Use:   "server",
 		Short: "Start the LogKV server",
+		Long:  "Start the LogKV server in this process, with the same HTTP, gRPC and RESP listeners as logkvd. It runs until interrupted.",
+		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			output.Info("Starting LogKV server...")
-			output.Info("Server commands are not implemented in CLI mode.")
-			output.Info("Use 'logkvd' binary to start the server.")
+			output.Info(fmt.Sprintf("Starting LogKV server on %s...", listen))
+			runServer(serverOptions(dataDir, listen)...)
 		},
 	}
+	cmd.Flags().StringVar(&dataDir, "data-dir", "", "Data directory (default from config)")
+	cmd.Flags().StringVar(&listen, "listen", ":8080", "HTTP listen address")
+	return cmd
+}
+
+// serverOptions wires the same modules as logkvd. The HTTP address comes from
+// --listen rather than LOGKV_ADDR, which holds the server URL for the CLI.
+func serverOptions(dataDir, listen string) []fx.Option {
+	return []fx.Option{
+		logger.Module("logkv-server"),
+		config.Module(),
+		metrics.Module(),
+		server.Module(),
+		grpcserver.Module(),
+		resp.Module(),
+		config.Override(config.Overrides{DataDir: dataDir, HTTPAddr: listen}),
+		config.StopTimeout(config.Overrides{DataDir: dataDir, HTTPAddr: listen}),
+	}
 }
@@ -1,19 +1,58 @@
 package commands
 
 import (
+	"net/http"
 	"testing"
 
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/store"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
 )
 
+// captureServer replaces runServer for the duration of a test and returns
+// the options the command would have started the server with
+func captureServer(t *testing.T) *[]fx.Option {
+	var captured []fx.Option
+	old := runServer
+	runServer = func(opts ...fx.Option) { captured = opts }
+	t.Cleanup(func() { runServer = old })
+	return &captured
+}
+
 func TestNewServerCommand(t *testing.T) {
+	captured := captureServer(t)
 	cmd := NewServerCommand()
 	assert.Equal(t, "server", cmd.Use)
 	assert.Contains(t, cmd.Short, "Start the LogKV server")
+
 	output := captureOutput(func() {
-		cmd.Run(cmd, []string{})
+		executeCommand(t, cmd, []string{})
+	})
+	assert.Contains(t, output, "Starting LogKV server on :8080")
+	require.NotEmpty(t, *captured, "the command should run the server in process")
+	assert.NoError(t, fx.ValidateApp(*captured...))
+}
+
+func TestNewServerCommand_Flags(t *testing.T) {
+	captured := captureServer(t)
+	dataDir := t.TempDir()
+	t.Setenv("LOGKV_ADDR", "http://localhost:9999")
+
+	cmd := NewServerCommand()
+	captureOutput(func() {
+		executeCommand(t, cmd, []string{"--data-dir", dataDir, "--listen", "127.0.0.1:0"})
 	})
-	assert.Contains(t, output, "Starting LogKV server")
-	assert.Contains(t, output, "Server commands are not implemented")
-	assert.Contains(t, output, "Use 'logkvd' binary")
+
+	// Build the graph without starting it to check the flags were applied
+	var cfg *config.Config
+	var srv *http.Server
+	var s *store.Store
+	app := fx.New(append(*captured, fx.NopLogger, fx.Populate(&cfg, &srv, &s))...)
+	require.NoError(t, app.Err())
+	defer s.Close()
+
+	assert.Equal(t, dataDir, cfg.DataDir)
+	assert.Equal(t, "127.0.0.1:0", srv.Addr, "--listen wins over LOGKV_ADDR, which is the CLI's server URL")
 }
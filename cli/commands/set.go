@@ -1,12 +1,7 @@
 package commands
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"os"
-	"time"
 
 	"github.com/himakhaitan/logkv-store/cli/output"
 	"github.com/spf13/cobra"
@@ -21,30 +16,20 @@ func NewSetCommand() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			key := args[0]
 			value := args[1]
-			addr := os.Getenv("LOGKV_ADDR")
-			if addr == "" {
-				addr = "http://localhost:8080"
-			}
+			addr := resolveAddr(cmd)
 
-			client := &http.Client{Timeout: 10 * time.Second}
-			body, _ := json.Marshal(map[string]string{"key": key, "value": value})
-			req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/v1/kv", addr), bytes.NewReader(body))
-			if err != nil {
-				output.Error(fmt.Sprintf("Failed to create request: %v", err))
-				return
-			}
-			req.Header.Set("Content-Type", "application/json")
-			resp, err := client.Do(req)
-			if err != nil {
-				output.Error(fmt.Sprintf("Failed to connect to server at %s\n %v", addr, err))
-				return
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusNoContent {
-				output.Error(fmt.Sprintf("Server error: %s", resp.Status))
+			api, httpClient := newAPIClient(cmd, addr, DefaultTimeout)
+			if err := api.Set(cmd.Context(), key, value); err != nil {
+				failRequest(httpClient, addr, err)
 				return
 			}
-			output.Success(fmt.Sprintf("Set %s = %s", key, value))
+			result := struct {
+				Key   string `json:"key"`
+				Value string `json:"value"`
+			}{key, value}
+			output.Result(result, func() {
+				output.Success(fmt.Sprintf("Set %s = %s", key, value))
+			})
 		},
 	}
 }
@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/spf13/cobra"
+)
+
+// shellCommands maps the commands available in the shell to their
+// constructors, so each line runs the same code as the one-shot command
+var shellCommands = map[string]func() *cobra.Command{
+	"get":    NewGetCommand,
+	"set":    NewSetCommand,
+	"del":    NewDeleteCommand,
+	"delete": NewDeleteCommand,
+	"list":   NewListCommand,
+	"stats":  NewStatsCommand,
+}
+
+// NewShellCommand creates a new shell command
+func NewShellCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "shell",
+		Short: "Start an interactive shell",
+		Long:  "Start an interactive shell that runs get, set, del, list and stats against the server. Type exit or press Ctrl-D to leave.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			in := cmd.InOrStdin()
+			prompt := isInteractive(in)
+			scanner := bufio.NewScanner(in)
+
+			for {
+				if prompt {
+					fmt.Fprint(os.Stdout, "logkv> ")
+				}
+				if !scanner.Scan() {
+					if prompt {
+						fmt.Fprintln(os.Stdout)
+					}
+					if err := scanner.Err(); err != nil {
+						fail(fmt.Sprintf("Failed to read input: %v", err))
+					}
+					return
+				}
+
+				words, err := splitLine(scanner.Text())
+				if err != nil {
+					failUsage(err.Error())
+					continue
+				}
+				if len(words) == 0 {
+					continue
+				}
+				switch words[0] {
+				case "exit", "quit":
+					return
+				case "help":
+					ResetExitCode()
+					printShellHelp()
+					continue
+				}
+				runShellLine(cmd, words)
+			}
+		},
+	}
+}
+
+// runShellLine runs one shell command under a fresh root that shares the
+// shell's persistent flags, so --addr, --timeout and --output still apply.
+// The shell exits with the code of the last line it ran.
+func runShellLine(shell *cobra.Command, words []string) {
+	ResetExitCode()
+	newCommand, ok := shellCommands[words[0]]
+	if !ok {
+		failUsage(fmt.Sprintf("Unknown command '%s' (type help for a list)", words[0]))
+		return
+	}
+
+	sub := newCommand()
+	sub.Aliases = append(sub.Aliases, words[0])
+	root := &cobra.Command{Use: "logkv", SilenceErrors: true, SilenceUsage: true}
+	root.PersistentFlags().AddFlagSet(shell.InheritedFlags())
+	root.AddCommand(sub)
+	root.SetArgs(words)
+	if err := root.Execute(); err != nil {
+		failUsage(err.Error())
+	}
+}
+
+// printShellHelp lists the commands available in the shell
+func printShellHelp() {
+	names := make([]string, 0, len(shellCommands))
+	for name := range shellCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := shellCommands[name]()
+		output.Info(fmt.Sprintf("%-8s %s", name, cmd.Short))
+	}
+	output.Info(fmt.Sprintf("%-8s %s", "exit", "Leave the shell"))
+}
+
+// isInteractive reports whether in is a terminal, in which case the shell
+// shows a prompt
+func isInteractive(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// splitLine splits a shell line into words. Single or double quotes group
+// words containing spaces, and a backslash escapes the next character.
+func splitLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && quote != '\'':
+			if i+1 == len(runes) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
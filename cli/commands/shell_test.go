@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellCommand(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+		mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/v1/kv/foo":
+			_ = json.NewEncoder(w).Encode(servertypes.GetResponse{Key: "foo", Value: "hello world"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	shell := NewShellCommand()
+	root := withGlobalFlags(shell)
+	shell.SetIn(strings.NewReader(strings.Join([]string{
+		`set foo "hello world"`,
+		``,
+		`get foo`,
+		`del foo`,
+		`bogus`,
+		`get`,
+		`exit`,
+		`get never`,
+	}, "\n")))
+
+	output := captureOutput(func() {
+		root.SetArgs([]string{"shell", "--addr", server.URL})
+		assert.NoError(t, root.Execute())
+	})
+
+	assert.Equal(t, []string{
+		`PUT /v1/kv {"key":"foo","value":"hello world"}`,
+		`GET /v1/kv/foo `,
+		`DELETE /v1/kv/foo `,
+	}, requests)
+	assert.Contains(t, output, "Set foo = hello world")
+	assert.Contains(t, output, "Value: hello world")
+	assert.Contains(t, output, "Deleted key: foo")
+	assert.Contains(t, output, "Unknown command 'bogus'")
+	assert.Contains(t, output, "accepts 1 arg(s)")
+	assert.NotContains(t, output, "logkv>", "no prompt when input isn't a terminal")
+}
+
+func TestShellCommand_EOF(t *testing.T) {
+	shell := NewShellCommand()
+	shell.SetIn(strings.NewReader("help"))
+	output := captureOutput(func() {
+		executeCommand(t, shell, []string{})
+	})
+	assert.Contains(t, output, "stats")
+	assert.Contains(t, output, "exit")
+}
+
+func TestSplitLine(t *testing.T) {
+	cases := map[string][]string{
+		`set foo bar`:             {"set", "foo", "bar"},
+		`  get   foo  `:           {"get", "foo"},
+		`set "a key" 'a "value"'`: {"set", "a key", `a "value"`},
+		`set foo ""`:              {"set", "foo", ""},
+		`set foo\ bar baz`:        {"set", "foo bar", "baz"},
+		``:                        nil,
+	}
+	for line, want := range cases {
+		got, err := splitLine(line)
+		assert.NoError(t, err, line)
+		assert.Equal(t, want, got, line)
+	}
+
+	_, err := splitLine(`set "foo`)
+	assert.Error(t, err)
+	_, err = splitLine(`set foo\`)
+	assert.Error(t, err)
+}
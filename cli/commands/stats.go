@@ -1,14 +1,10 @@
 package commands
 
 import (
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"os"
-	"time"
 
 	"github.com/himakhaitan/logkv-store/cli/output"
-	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/himakhaitan/logkv-store/client"
 	"github.com/spf13/cobra"
 )
 
@@ -18,39 +14,44 @@ func NewStatsCommand() *cobra.Command {
 		Use:   "stats",
 		Short: "Show database statistics",
 		Run: func(cmd *cobra.Command, args []string) {
-			addr := os.Getenv("LOGKV_ADDR")
-			if addr == "" {
-				addr = "http://localhost:8080"
-			}
+			addr := resolveAddr(cmd)
 
-			client := &http.Client{Timeout: 10 * time.Second}
-			resp, err := client.Get(fmt.Sprintf("%s/v1/stats", addr))
+			api, httpClient := newAPIClient(cmd, addr, DefaultTimeout)
+			out, err := api.Stats(cmd.Context())
 			if err != nil {
-				output.Error(fmt.Sprintf("Failed to connect to server at %s\n %v", addr, err))
-				return
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				output.Error(fmt.Sprintf("Server error: %s", resp.Status))
-				return
-			}
-			var out servertypes.StatsResponse
-			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-				output.Error(fmt.Sprintf("Invalid response: %v", err))
+				failRequest(httpClient, addr, err)
 				return
 			}
-			if !out.Success {
-				if out.Message != "" {
-					output.Error(out.Message)
-				} else {
-					output.Error("Request failed")
-				}
-				return
-			}
-			output.Success("Database Statistics")
-			output.Info(fmt.Sprintf("Total Keys: %d", out.TotalKeys))
-			output.Info(fmt.Sprintf("Total Size: %d bytes", out.TotalSize))
-			output.Info(fmt.Sprintf("Segments: %d", out.Segments))
+			result := struct {
+				TotalKeys   int             `json:"total_keys"`
+				TotalSize   int64           `json:"total_size"`
+				DiskSize    int64           `json:"disk_size"`
+				LiveSize    int64           `json:"live_size"`
+				Reclaimable int64           `json:"reclaimable"`
+				Segments    int             `json:"segments"`
+				DeadRatios  map[int]float64 `json:"dead_ratios,omitempty"`
+				CacheHits   uint64          `json:"cache_hits"`
+				CacheMisses uint64          `json:"cache_misses"`
+
+				ActiveSegmentID     int   `json:"active_segment_id"`
+				ActiveSegmentOffset int64 `json:"active_segment_offset"`
+			}{out.TotalKeys, out.TotalSize, out.DiskSize, out.LiveSize, reclaimable(out), out.Segments, out.DeadRatios, out.CacheHits, out.CacheMisses,
+				out.ActiveSegmentID, out.ActiveSegmentOffset}
+			output.Result(result, func() {
+				output.Success("Database Statistics")
+				output.Info(fmt.Sprintf("Total Keys: %d", out.TotalKeys))
+				output.Info(fmt.Sprintf("Disk Size: %d bytes", out.DiskSize))
+				output.Info(fmt.Sprintf("Live Size: %d bytes", out.LiveSize))
+				output.Info(fmt.Sprintf("Reclaimable: %d bytes", reclaimable(out)))
+				output.Info(fmt.Sprintf("Segments: %d", out.Segments))
+				output.Info(fmt.Sprintf("Write Position: segment %d, offset %d", out.ActiveSegmentID, out.ActiveSegmentOffset))
+			})
 		},
 	}
 }
+
+// reclaimable is how much of the disk size is not live values: headers, keys
+// and dead entries. Compaction can free the dead entries among it.
+func reclaimable(stats client.Stats) int64 {
+	return max(stats.DiskSize-stats.LiveSize, 0)
+}
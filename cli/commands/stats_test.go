@@ -18,6 +18,8 @@ func TestStatsCommand_Success(t *testing.T) {
 		},
 		TotalKeys: 5,
 		TotalSize: 1234,
+		DiskSize:  2000,
+		LiveSize:  1234,
 		Segments:  2,
 	}
 	data, _ := json.Marshal(resp)
@@ -39,7 +41,9 @@ func TestStatsCommand_Success(t *testing.T) {
 	assert.Contains(t, output, "[SUCCESS]", "Output should contain the SUCCESS tag.")
 	assert.Contains(t, output, "Database Statistics", "Output should contain the main success message.")
 	assert.Contains(t, output, "Total Keys: 5", "Output should contain the correct Total Keys.")
-	assert.Contains(t, output, "Total Size: 1234 bytes", "Output should contain the correct Total Size (including 'bytes').") // Must include 'bytes'
+	assert.Contains(t, output, "Disk Size: 2000 bytes", "Output should contain the bytes on disk.")
+	assert.Contains(t, output, "Live Size: 1234 bytes", "Output should contain the live value bytes.")
+	assert.Contains(t, output, "Reclaimable: 766 bytes", "Output should contain the difference.")
 	assert.Contains(t, output, "Segments: 2", "Output should contain the correct Segments count.")
 }
 func TestStatsCommand_ServerError(t *testing.T) {
@@ -3,18 +3,24 @@ package commands
 import (
 	"fmt"
 
+	"github.com/himakhaitan/logkv-store/cli/output"
+	"github.com/himakhaitan/logkv-store/pkg/version"
 	"github.com/spf13/cobra"
 )
 
-const Version = "v0.1.0"
-
 // NewVersionCommand creates a new version command
 func NewVersionCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
 		Short: "Print the CLI version",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("logkv-cli version %s\n", Version)
+			info := version.Get()
+			output.Result(info, func() {
+				fmt.Printf("logkv-cli version %s\n", info.Version)
+				fmt.Printf("  commit:     %s\n", info.Commit)
+				fmt.Printf("  built:      %s\n", info.BuildDate)
+				fmt.Printf("  go version: %s\n", info.GoVersion)
+			})
 		},
 	}
 }
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionCommand(t *testing.T) {
+	output := captureOutput(func() {
+		executeCommand(t, NewVersionCommand(), []string{})
+	})
+	assert.Contains(t, output, "logkv-cli version ")
+	assert.Contains(t, output, runtime.Version())
+}
+
+func TestVersionCommand_Ldflags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the CLI binary")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	const pkg = "github.com/himakhaitan/logkv-store/pkg/version"
+	bin := filepath.Join(t.TempDir(), "logkv-cli")
+	build := exec.Command(goBin, "build",
+		"-ldflags", "-X "+pkg+".Version=v9.8.7 -X "+pkg+".Commit=deadbeef -X "+pkg+".BuildDate=2026-01-02T03:04:05Z",
+		"-o", bin, "github.com/himakhaitan/logkv-store/cmd/logkv-cli")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	out, err = exec.Command(bin, "version").CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "logkv-cli version v9.8.7")
+	assert.Contains(t, string(out), "deadbeef")
+	assert.Contains(t, string(out), "2026-01-02T03:04:05Z")
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/cli/output"
+	servertypes "github.com/himakhaitan/logkv-store/types"
+	"github.com/spf13/cobra"
+)
+
+// NewWatchCommand creates a new watch command
+func NewWatchCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "watch",
+		Short: "Print changes to keys as they happen",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			addr := resolveAddr(cmd)
+
+			// No timeout unless --timeout is given: the stream stays open
+			// until interrupted
+			client := newClient(cmd, 0)
+			resp, err := client.Get(apiURL(cmd, addr, "/watch"))
+			if err != nil {
+				failConnection(client, addr, err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				fail(fmt.Sprintf("Server error: %s", resp.Status))
+				return
+			}
+
+			// Server-Sent Events: "field: value" lines, with a blank line
+			// ending each event
+			var event, data string
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if field, value, ok := strings.Cut(line, ": "); ok && field == "event" {
+					event = value
+					continue
+				} else if ok && field == "data" {
+					data = value
+					continue
+				}
+				if line != "" {
+					continue // Comments such as heartbeats
+				}
+
+				switch event {
+				case "change":
+					printWatchEvent(data)
+				case "dropped":
+					output.Warn("Fell too far behind the server's changes; run watch again to resume")
+					exitWith(ExitError)
+					return
+				}
+				event, data = "", ""
+			}
+			if err := scanner.Err(); err != nil {
+				output.Error(fmt.Sprintf("Watch interrupted: %v", err))
+				exitWith(ExitConnection)
+				return
+			}
+			output.Warn("Server closed the watch stream")
+			exitWith(ExitConnection)
+		},
+	}
+}
+
+// printWatchEvent prints the types.WatchEvent encoded in data
+func printWatchEvent(data string) {
+	var ev servertypes.WatchEvent
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		fail(fmt.Sprintf("Invalid event: %v", err))
+		return
+	}
+	output.Result(ev, func() {
+		output.Info(fmt.Sprintf("%s %-6s %s", time.Unix(ev.Timestamp, 0).Format(time.RFC3339), ev.Op, ev.Key))
+	})
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchCommand_PrintsEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/watch", r.URL.Path)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: change\ndata: {\"key\":\"a\",\"op\":\"set\",\"timestamp\":1700000000}\n\n")
+		fmt.Fprint(w, ": heartbeat\n\n")
+		fmt.Fprint(w, "event: change\ndata: {\"key\":\"b\",\"op\":\"delete\",\"timestamp\":1700000001}\n\n")
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	output := captureOutput(func() {
+		executeCommand(t, NewWatchCommand(), []string{})
+	})
+	assert.Regexp(t, `set +a`, output)
+	assert.Regexp(t, `delete +b`, output)
+	assert.Contains(t, output, "Server closed the watch stream")
+}
+
+func TestWatchCommand_Dropped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "event: dropped\ndata: {}\n\n")
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	output := captureOutput(func() {
+		executeCommand(t, NewWatchCommand(), []string{})
+	})
+	assert.Contains(t, output, "[WARN]")
+	assert.Contains(t, output, "run watch again")
+	assert.NotContains(t, output, "Server closed")
+}
+
+func TestWatchCommand_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	os.Setenv("LOGKV_ADDR", server.URL)
+	defer os.Unsetenv("LOGKV_ADDR")
+
+	output := captureOutput(func() {
+		executeCommand(t, NewWatchCommand(), []string{})
+	})
+	assert.Contains(t, output, "[ERROR]")
+}
@@ -2,10 +2,19 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 
+	"github.com/himakhaitan/logkv-store/cli/commands"
+	"github.com/himakhaitan/logkv-store/cli/output"
+	servertypes "github.com/himakhaitan/logkv-store/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewCLI(t *testing.T) {
@@ -66,3 +75,134 @@ func TestCLIRun(t *testing.T) {
 	// Check for the 'Available Commands' header which proves subcommands were registered
 	assert.True(t, strings.Contains(capturedOutput, "Available Commands:"), "Help output should list available commands.")
 }
+
+func TestCLIRun_JSONOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(servertypes.StatsResponse{
+			BaseResponse: servertypes.BaseResponse{Success: true},
+			TotalKeys:    5,
+			TotalSize:    1234,
+			Segments:     2,
+		})
+	}))
+	defer server.Close()
+	t.Setenv("LOGKV_ADDR", server.URL)
+	t.Cleanup(func() { output.SetFormat(output.FormatText) })
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	cli := NewCLI()
+	cli.root.SetArgs([]string{"stats", "-o", "json"})
+	runErr := cli.Run()
+	w.Close()
+	os.Stdout = stdout
+	require.NoError(t, runErr)
+
+	captured, err := io.ReadAll(r)
+	require.NoError(t, err)
+	var stats map[string]any
+	require.NoError(t, json.Unmarshal(captured, &stats), string(captured))
+	assert.Equal(t, 5.0, stats["total_keys"])
+	assert.Equal(t, 1234.0, stats["total_size"])
+	assert.Equal(t, 2.0, stats["segments"])
+}
+
+func TestCLIRun_InvalidOutputFormat(t *testing.T) {
+	cli := NewCLI()
+	cli.root.SetOut(io.Discard)
+	cli.root.SetErr(io.Discard)
+	cli.root.SetArgs([]string{"version", "--output", "yaml"})
+	assert.Error(t, cli.Run())
+}
+
+func TestCLIRun_AddrFlag(t *testing.T) {
+	requested := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	t.Setenv("LOGKV_ADDR", "http://127.0.0.1:1")
+
+	cli := NewCLI()
+	cli.root.SetArgs([]string{"--addr", server.URL, "delete", "foo"})
+	require.NoError(t, cli.Run())
+	assert.Equal(t, "/v1/kv/foo", <-requested)
+}
+
+func TestCLIExecute_ExitCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	run := func(args ...string) int {
+		cli := NewCLI()
+		cli.root.SetOut(io.Discard)
+		cli.root.SetErr(io.Discard)
+		cli.root.SetArgs(args)
+		stdout := os.Stdout
+		os.Stdout, _ = os.Open(os.DevNull)
+		defer func() { os.Stdout = stdout }()
+		return cli.Execute()
+	}
+
+	assert.Equal(t, commands.ExitOK, run("version"))
+	assert.Equal(t, commands.ExitNotFound, run("--addr", server.URL, "get", "foo"))
+	assert.Equal(t, commands.ExitOK, run("version"), "Each run starts afresh")
+	assert.Equal(t, commands.ExitUsage, run("get"))
+	assert.Equal(t, commands.ExitUsage, run("get", "foo", "--bogus"))
+	assert.Equal(t, commands.ExitUsage, run("bogus"))
+	assert.Equal(t, commands.ExitUsage, run("version", "--output", "yaml"))
+	assert.Equal(t, commands.ExitConnection, run("--addr", "http://127.0.0.1:1", "stats"))
+}
+
+func TestCLIRun_Verbosity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "broken") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	t.Setenv("LOGKV_ADDR", server.URL)
+	t.Cleanup(func() { output.SetVerbosity(output.VerbosityNormal) })
+
+	run := func(args ...string) string {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		stdout := os.Stdout
+		os.Stdout = w
+		cli := NewCLI()
+		cli.root.SetArgs(args)
+		runErr := cli.Run()
+		w.Close()
+		os.Stdout = stdout
+		require.NoError(t, runErr)
+
+		captured, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(captured)
+	}
+
+	assert.Equal(t, "[SUCCESS] Set foo = bar\n", run("set", "foo", "bar"))
+	assert.Empty(t, run("--quiet", "set", "foo", "bar"))
+	assert.Equal(t, "[ERROR] Server error: 500 Internal Server Error\n", run("-q", "delete", "broken"), "Errors are printed when quiet")
+
+	verbose := strings.Split(strings.TrimSpace(run("--verbose", "set", "foo", "bar")), "\n")
+	require.Len(t, verbose, 2)
+	assert.Regexp(t, `^\[DEBUG\] PUT `+server.URL+`/v1/kv: 204 No Content in `, verbose[0])
+	assert.Equal(t, "[SUCCESS] Set foo = bar", verbose[1])
+
+	// The flags apply afresh on each run
+	assert.Equal(t, "[SUCCESS] Set foo = bar\n", run("set", "foo", "bar"))
+
+	cli := NewCLI()
+	cli.root.SetOut(io.Discard)
+	cli.root.SetErr(io.Discard)
+	cli.root.SetArgs([]string{"version", "--quiet", "--verbose"})
+	assert.Error(t, cli.Run(), "--quiet and --verbose conflict")
+}
@@ -1,8 +1,10 @@
 package output
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 )
 
 // ANSI color codes
@@ -18,21 +20,124 @@ const (
 	grey   = "\033[90m"
 )
 
+// Format selects how commands print their results
+type Format string
+
+const (
+	// FormatText prints colored messages for people
+	FormatText Format = "text"
+
+	// FormatJSON prints one JSON object per line for scripts
+	FormatJSON Format = "json"
+)
+
+// ParseFormat converts a flag value into a Format
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatText, FormatJSON:
+		return Format(s), nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (want text or json)", s)
+	}
+}
+
+// Verbosity selects which messages are printed
+type Verbosity int
+
+const (
+	// VerbosityQuiet prints errors only. JSON results are still printed,
+	// since they are a command's output rather than a message about it.
+	VerbosityQuiet Verbosity = -1
+
+	// VerbosityNormal prints every message except debug ones
+	VerbosityNormal Verbosity = 0
+
+	// VerbosityVerbose prints debug messages too
+	VerbosityVerbose Verbosity = 1
+)
+
+var (
+	format        = FormatText
+	verbosity     = VerbosityNormal
+	colorOverride *bool
+)
+
+// SetFormat selects the output format for every printer
+func SetFormat(f Format) {
+	format = f
+}
+
+// SetVerbosity selects which messages every printer prints
+func SetVerbosity(v Verbosity) {
+	verbosity = v
+}
+
+// enabled reports whether messages needing at least v are printed
+func enabled(v Verbosity) bool {
+	return verbosity >= v
+}
+
+// SetColorEnabled forces colored output on or off, overriding terminal and
+// NO_COLOR detection
+func SetColorEnabled(enabled bool) {
+	colorOverride = &enabled
+}
+
+// colorEnabled reports whether to print ANSI colors: only when stdout is a
+// terminal and NO_COLOR (https://no-color.org) is unset or empty, unless
+// overridden by SetColorEnabled
+func colorEnabled() bool {
+	if colorOverride != nil {
+		return *colorOverride
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 // core printer
 func printMessage(title, color, message string) {
+	if format == FormatJSON {
+		writeJSON(map[string]string{"level": strings.ToLower(title), "message": message})
+		return
+	}
+	if !colorEnabled() {
+		fmt.Fprintf(os.Stdout, "[%s] %s\n", title, message)
+		return
+	}
 	fmt.Fprintf(os.Stdout, "%s%s[%s]%s %s%s%s\n",
 		color, bold, title, reset, color, message, reset,
 	)
 }
 
+// writeJSON prints v as a single line of JSON
+func writeJSON(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"level": "error", "message": err.Error()})
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", data)
+}
+
 // Public functions
 
 func Info(msg string) {
-	printMessage("INFO", blue, msg)
+	if enabled(VerbosityNormal) {
+		printMessage("INFO", blue, msg)
+	}
 }
 
 func Warn(msg string) {
-	printMessage("WARN", yellow, msg)
+	if enabled(VerbosityNormal) {
+		printMessage("WARN", yellow, msg)
+	}
 }
 
 func Error(msg string) {
@@ -40,13 +145,38 @@ func Error(msg string) {
 }
 
 func Success(msg string) {
-	printMessage("SUCCESS", green, msg)
+	if enabled(VerbosityNormal) {
+		printMessage("SUCCESS", green, msg)
+	}
 }
 
 func Debug(msg string) {
-	printMessage("DEBUG", cyan, msg)
+	if enabled(VerbosityVerbose) {
+		printMessage("DEBUG", cyan, msg)
+	}
 }
 
 func Dim(msg string) {
+	if !enabled(VerbosityNormal) {
+		return
+	}
+	if format == FormatJSON {
+		writeJSON(map[string]string{"level": "info", "message": msg})
+		return
+	}
+	if !colorEnabled() {
+		fmt.Fprintf(os.Stdout, "%s\n", msg)
+		return
+	}
 	fmt.Fprintf(os.Stdout, "%s%s%s\n", grey, msg, reset)
 }
+
+// Result prints a command's result: v as JSON in JSON mode, or whatever
+// human prints otherwise
+func Result(v any, human func()) {
+	if format == FormatJSON {
+		writeJSON(v)
+		return
+	}
+	human()
+}
@@ -1,6 +1,7 @@
 package output
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // captureOutput is a helper function that redirects os.Stdout to a buffer,
@@ -50,7 +52,30 @@ func buildExpectedString(title, colorCode, message string) string {
 	)
 }
 
+// withColor forces colored output on or off for the duration of a test
+func withColor(t *testing.T, enabled bool) {
+	old := colorOverride
+	SetColorEnabled(enabled)
+	t.Cleanup(func() { colorOverride = old })
+}
+
+// withFormat selects an output format for the duration of a test
+func withFormat(t *testing.T, f Format) {
+	old := format
+	SetFormat(f)
+	t.Cleanup(func() { SetFormat(old) })
+}
+
+// withVerbosity selects a verbosity for the duration of a test
+func withVerbosity(t *testing.T, v Verbosity) {
+	old := verbosity
+	SetVerbosity(v)
+	t.Cleanup(func() { SetVerbosity(old) })
+}
+
 func TestPrintFunctions(t *testing.T) {
+	withColor(t, true)
+	withVerbosity(t, VerbosityVerbose) // Debug messages are hidden by default
 	const testMsg = "Test message content"
 
 	tests := []struct {
@@ -105,6 +130,7 @@ func TestPrintFunctions(t *testing.T) {
 }
 
 func TestDim(t *testing.T) {
+	withColor(t, true)
 	const testMsg = "Dim message content"
 	captured := captureOutput(func() {
 		Dim(testMsg)
@@ -112,3 +138,104 @@ func TestDim(t *testing.T) {
 	expected := fmt.Sprintf("%s%s%s\n", grey, testMsg, reset)
 	assert.Equal(t, expected, captured, "Dim output string with color codes should match the expected grey format.")
 }
+
+func TestPrintFunctions_NoColor(t *testing.T) {
+	withColor(t, false)
+
+	captured := captureOutput(func() {
+		Info("message")
+		Error("failed")
+		Dim("dimmed")
+	})
+	assert.Equal(t, "[INFO] message\n[ERROR] failed\ndimmed\n", captured)
+}
+
+func TestColorEnabled_Detection(t *testing.T) {
+	// Captured output goes to a pipe, which is not a terminal
+	captured := captureOutput(func() {
+		assert.False(t, colorEnabled(), "colors should be off when stdout is a pipe")
+		Info("message")
+	})
+	assert.Equal(t, "[INFO] message\n", captured)
+
+	t.Setenv("NO_COLOR", "1")
+	assert.False(t, colorEnabled())
+
+	withColor(t, true)
+	assert.True(t, colorEnabled(), "SetColorEnabled overrides detection")
+}
+
+func TestJSONFormat(t *testing.T) {
+	withFormat(t, FormatJSON)
+
+	captured := captureOutput(func() {
+		Warn("Key 'foo' not found")
+		Dim("Timestamp: 1")
+	})
+	lines := strings.Split(strings.TrimSpace(captured), "\n")
+	require.Len(t, lines, 2)
+
+	var warn map[string]string
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &warn))
+	assert.Equal(t, map[string]string{"level": "warn", "message": "Key 'foo' not found"}, warn)
+	assert.NotContains(t, captured, "\033[", "JSON output must not contain color codes")
+}
+
+func TestResult(t *testing.T) {
+	result := map[string]int{"total_keys": 3}
+
+	withFormat(t, FormatJSON)
+	captured := captureOutput(func() {
+		Result(result, func() { Info("human") })
+	})
+	assert.JSONEq(t, `{"total_keys":3}`, captured)
+
+	SetFormat(FormatText)
+	captured = captureOutput(func() {
+		Result(result, func() { Info("human") })
+	})
+	assert.Contains(t, captured, "human")
+}
+
+func TestVerbosity(t *testing.T) {
+	withColor(t, false)
+	printAll := func() {
+		Debug("debug")
+		Info("info")
+		Success("success")
+		Warn("warn")
+		Dim("dim")
+		Error("error")
+	}
+
+	tests := []struct {
+		verbosity Verbosity
+		expected  string
+	}{
+		{VerbosityQuiet, "[ERROR] error\n"},
+		{VerbosityNormal, "[INFO] info\n[SUCCESS] success\n[WARN] warn\ndim\n[ERROR] error\n"},
+		{VerbosityVerbose, "[DEBUG] debug\n[INFO] info\n[SUCCESS] success\n[WARN] warn\ndim\n[ERROR] error\n"},
+	}
+	for _, tt := range tests {
+		withVerbosity(t, tt.verbosity)
+		assert.Equal(t, tt.expected, captureOutput(printAll), "verbosity %d", tt.verbosity)
+	}
+
+	// Quiet hides messages, not JSON results
+	withVerbosity(t, VerbosityQuiet)
+	withFormat(t, FormatJSON)
+	captured := captureOutput(func() {
+		Info("chatter")
+		Result(map[string]int{"total_keys": 3}, func() {})
+	})
+	assert.JSONEq(t, `{"total_keys":3}`, captured)
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("json")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatJSON, f)
+
+	_, err = ParseFormat("yaml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,248 @@
+// Package client talks to a logkvd server over its HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/himakhaitan/logkv-store/types"
+)
+
+// ErrNotFound is returned for a key that does not exist
+var ErrNotFound = errors.New("key not found")
+
+// ErrInvalidResponse is returned when the server's answer cannot be decoded
+var ErrInvalidResponse = errors.New("invalid response")
+
+// APIError is returned when the server answers a request with a failure:
+// an unexpected status, or a response reporting that it did not succeed
+type APIError struct {
+	StatusCode int    // HTTP status code
+	Status     string // HTTP status, such as "500 Internal Server Error"
+	Message    string // The server's explanation, if it gave one
+}
+
+// Error describes the failure, with the server's explanation if any
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("server error: %s: %s", e.Status, e.Message)
+	}
+	return "server error: " + e.Status
+}
+
+// Entry is a key's value and when it was written
+type Entry struct {
+	Key         string
+	Value       string
+	Timestamp   int64  // Unix seconds when the value was written (0 = unknown)
+	ContentType string // Media type the value was stored with (empty = none)
+}
+
+// Stats describes the store behind the server
+type Stats struct {
+	TotalKeys   int
+	TotalSize   int64
+	DiskSize    int64
+	LiveSize    int64
+	Segments    int
+	DeadRatios  map[int]float64 // Dead ratio per segment ID
+	CacheHits   uint64
+	CacheMisses uint64
+
+	ActiveSegmentID     int   // Segment new writes are appended to
+	ActiveSegmentOffset int64 // Where the next write lands in it
+}
+
+// Client sends requests to one server. It is safe for concurrent use.
+type Client struct {
+	addr       string
+	namespace  string
+	httpClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient,
+// for example to set a timeout or a custom transport
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithNamespace sends requests to the named namespace instead of the
+// server's default one
+func WithNamespace(name string) Option {
+	return func(c *Client) {
+		c.namespace = name
+	}
+}
+
+// New returns a Client for the server at addr, such as
+// "http://localhost:8080"
+func New(addr string, opts ...Option) *Client {
+	c := &Client{
+		addr:       strings.TrimSuffix(addr, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// endpoint returns the URL of an API path such as "/kv" on the client's
+// server, under its namespace if it has one
+func (c *Client) endpoint(path string) string {
+	if c.namespace != "" {
+		return c.addr + "/v1/ns/" + url.PathEscape(c.namespace) + path
+	}
+	return c.addr + "/v1" + path
+}
+
+// Get returns the value of key, or ErrNotFound
+func (c *Client) Get(ctx context.Context, key string) (Entry, error) {
+	var out types.GetResponse
+	if err := c.do(ctx, http.MethodGet, "/kv/"+url.PathEscape(key), nil, http.StatusOK, &out); err != nil {
+		return Entry{}, keyError(err)
+	}
+	return Entry{Key: out.Key, Value: out.Value, Timestamp: out.Timestamp, ContentType: out.ContentType}, nil
+}
+
+// Set stores value under key
+func (c *Client) Set(ctx context.Context, key, value string) error {
+	return c.do(ctx, http.MethodPut, "/kv", types.SetRequest{Key: key, Value: value}, http.StatusNoContent, nil)
+}
+
+// SetWithContentType stores value under key along with its media type
+func (c *Client) SetWithContentType(ctx context.Context, key, value, contentType string) error {
+	return c.do(ctx, http.MethodPut, "/kv", types.SetRequest{Key: key, Value: value, ContentType: contentType}, http.StatusNoContent, nil)
+}
+
+// Delete removes key, or returns ErrNotFound if it does not exist
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return keyError(c.do(ctx, http.MethodDelete, "/kv/"+url.PathEscape(key), nil, http.StatusNoContent, nil))
+}
+
+// List returns every key
+func (c *Client) List(ctx context.Context) ([]string, error) {
+	var out types.ListKeysResponse
+	if err := c.do(ctx, http.MethodGet, "/keys", nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	if err := succeeded(out.BaseResponse); err != nil {
+		return nil, err
+	}
+	if out.Keys == nil {
+		return []string{}, nil
+	}
+	return out.Keys, nil
+}
+
+// ListWithMeta returns every key with its size, write time and time to live
+func (c *Client) ListWithMeta(ctx context.Context) ([]types.KeyInfo, error) {
+	var out types.ListKeysMetaResponse
+	if err := c.do(ctx, http.MethodGet, "/keys?meta=true", nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	if err := succeeded(out.BaseResponse); err != nil {
+		return nil, err
+	}
+	if out.Keys == nil {
+		return []types.KeyInfo{}, nil
+	}
+	return out.Keys, nil
+}
+
+// Stats returns statistics about the store
+func (c *Client) Stats(ctx context.Context) (Stats, error) {
+	var out types.StatsResponse
+	if err := c.do(ctx, http.MethodGet, "/stats", nil, http.StatusOK, &out); err != nil {
+		return Stats{}, err
+	}
+	if err := succeeded(out.BaseResponse); err != nil {
+		return Stats{}, err
+	}
+	return Stats{
+		TotalKeys:   out.TotalKeys,
+		TotalSize:   out.TotalSize,
+		DiskSize:    out.DiskSize,
+		LiveSize:    out.LiveSize,
+		Segments:    out.Segments,
+		DeadRatios:  out.DeadRatios,
+		CacheHits:   out.CacheHits,
+		CacheMisses: out.CacheMisses,
+
+		ActiveSegmentID:     out.ActiveSegmentID,
+		ActiveSegmentOffset: out.ActiveSegmentOffset,
+	}, nil
+}
+
+// do sends a request for path with body, if not nil, encoded as JSON. A
+// response with status want is decoded into out, if not nil; any other
+// status becomes an *APIError. Errors sending the request are returned as
+// they are.
+func (c *Client) do(ctx context.Context, method, path string, body any, want int, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint(path), reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode != want:
+		apiErr := &APIError{StatusCode: resp.StatusCode, Status: resp.Status}
+		var base types.BaseResponse
+		if json.NewDecoder(resp.Body).Decode(&base) == nil {
+			apiErr.Message = base.Message
+		}
+		return apiErr
+	case out != nil:
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+	}
+	return nil
+}
+
+// keyError turns the 404 a request naming a key got into ErrNotFound
+func keyError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+// succeeded returns an *APIError for a response that reports it did not
+// succeed despite its status
+func succeeded(base types.BaseResponse) error {
+	if base.Success {
+		return nil
+	}
+	return &APIError{StatusCode: http.StatusOK, Status: "200 OK", Message: base.Message}
+}
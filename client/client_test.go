@@ -0,0 +1,210 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer serves the parts of the HTTP API the client uses from a map,
+// under both the default and any named namespace
+type fakeServer struct {
+	mu     sync.Mutex
+	values map[string]string // Namespace + "/" + key -> value
+	paths  []string          // Request paths, in order
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *httptest.Server) {
+	f := &fakeServer{values: make(map[string]string)}
+	ts := httptest.NewServer(f)
+	t.Cleanup(ts.Close)
+	return f, ts
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, r.URL.Path)
+
+	ns, path := "", strings.TrimPrefix(r.URL.Path, "/v1")
+	if rest, ok := strings.CutPrefix(path, "/ns/"); ok {
+		ns, path, _ = strings.Cut(rest, "/")
+		path = "/" + path
+	}
+
+	switch {
+	case path == "/kv" && r.Method == http.MethodPut:
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		var req types.SetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(types.BaseResponse{Message: "invalid json"})
+			return
+		}
+		f.values[ns+"/"+req.Key] = req.Value
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "/kv/"):
+		key := ns + "/" + strings.TrimPrefix(path, "/kv/")
+		value, ok := f.values[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.values, key)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(types.GetResponse{
+			BaseResponse: types.BaseResponse{Success: true},
+			Key:          strings.TrimPrefix(path, "/kv/"),
+			Value:        value,
+			Timestamp:    1700000000,
+		})
+	case path == "/keys":
+		keys := []string{}
+		for key := range f.values {
+			if name, found := strings.CutPrefix(key, ns+"/"); found {
+				keys = append(keys, name)
+			}
+		}
+		sort.Strings(keys)
+		json.NewEncoder(w).Encode(types.ListKeysResponse{BaseResponse: types.BaseResponse{Success: true}, Keys: keys})
+	case path == "/stats":
+		json.NewEncoder(w).Encode(types.StatsResponse{
+			BaseResponse: types.BaseResponse{Success: true},
+			TotalKeys:    len(f.values),
+			Segments:     1,
+			DeadRatios:   map[int]float64{1: 0.25},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClient_RoundTrip(t *testing.T) {
+	_, ts := newFakeServer(t)
+	c := New(ts.URL)
+	ctx := context.Background()
+
+	_, err := c.Get(ctx, "greeting")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, c.Set(ctx, "greeting", "hello"))
+	require.NoError(t, c.Set(ctx, "name", "logkv"))
+
+	entry, err := c.Get(ctx, "greeting")
+	require.NoError(t, err)
+	assert.Equal(t, Entry{Key: "greeting", Value: "hello", Timestamp: 1700000000}, entry)
+
+	keys, err := c.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"greeting", "name"}, keys)
+
+	stats, err := c.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalKeys)
+	assert.Equal(t, map[int]float64{1: 0.25}, stats.DeadRatios)
+
+	require.NoError(t, c.Delete(ctx, "greeting"))
+	assert.ErrorIs(t, c.Delete(ctx, "greeting"), ErrNotFound)
+	keys, err = c.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name"}, keys)
+}
+
+func TestClient_Namespace(t *testing.T) {
+	f, ts := newFakeServer(t)
+	ctx := context.Background()
+	users := New(ts.URL+"/", WithNamespace("users"))
+
+	require.NoError(t, users.Set(ctx, "alice", "1"))
+	_, err := New(ts.URL).Get(ctx, "alice")
+	assert.ErrorIs(t, err, ErrNotFound, "the default namespace is separate")
+	entry, err := users.Get(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "1", entry.Value)
+	assert.Equal(t, "/v1/ns/users/kv/alice", f.paths[len(f.paths)-1])
+}
+
+func TestClient_EscapesKeys(t *testing.T) {
+	f, ts := newFakeServer(t)
+	c := New(ts.URL)
+
+	_, err := c.Get(context.Background(), "a b?c")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, "/v1/kv/a b?c", f.paths[0], "the key reaches the server whole")
+}
+
+func TestClient_Errors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/keys":
+			json.NewEncoder(w).Encode(types.ListKeysResponse{BaseResponse: types.BaseResponse{Success: false, Message: "database offline"}})
+		case "/v1/stats":
+			w.Write([]byte(`{"success": "maybe"}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(types.BaseResponse{Message: "shutting down"})
+		}
+	}))
+	defer ts.Close()
+	c := New(ts.URL)
+	ctx := context.Background()
+
+	var apiErr *APIError
+	err := c.Set(ctx, "k", "v")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, "shutting down", apiErr.Message)
+	assert.EqualError(t, err, "server error: 503 Service Unavailable: shutting down")
+
+	_, err = c.List(ctx)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "database offline", apiErr.Message)
+
+	_, err = c.Stats(ctx)
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+
+	// Transport errors come back as they are
+	ctx, cancel := context.WithTimeout(ctx, time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	_, err = c.Get(ctx, "k")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_WithHTTPClient(t *testing.T) {
+	var agent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.Header.Set("User-Agent", "custom")
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	require.NoError(t, New(ts.URL, WithHTTPClient(hc)).Set(context.Background(), "k", "v"))
+	assert.Equal(t, "custom", agent)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
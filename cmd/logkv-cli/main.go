@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/himakhaitan/logkv-store/cli"
@@ -25,9 +24,7 @@ func main() {
 		panic(err)
 	}
 
-	// Run the CLI with command line arguments
-	if err := cliInstance.Run(); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
-	}
+	// Run the CLI with command line arguments; cobra has already printed
+	// any usage error
+	os.Exit(cliInstance.Execute())
 }
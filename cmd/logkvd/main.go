@@ -1,18 +1,82 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/grpcserver"
 	"github.com/himakhaitan/logkv-store/pkg/config"
 	"github.com/himakhaitan/logkv-store/pkg/logger"
+	"github.com/himakhaitan/logkv-store/pkg/metrics"
+	"github.com/himakhaitan/logkv-store/resp"
 	"github.com/himakhaitan/logkv-store/server"
 	"go.uber.org/fx"
 )
 
 func main() {
+	overrides, err := parseFlags(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+
 	app := fx.New(
 		logger.Module("logkv-server"),
-		config.Module(),
+		configOptions(overrides),
+		metrics.Module(),
 		server.Module(),
+		grpcserver.Module(),
+		resp.Module(),
 	)
 
 	app.Run()
 }
+
+// parseFlags reads the settings given on the command line. Problems are
+// reported on stderr along with the usage.
+func parseFlags(args []string, stderr io.Writer) (config.Overrides, error) {
+	var o config.Overrides
+	fs := flag.NewFlagSet("logkvd", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&o.DataDir, "data-dir", "", "Data directory (overrides data_dir)")
+	fs.StringVar(&o.HTTPAddr, "addr", "", "HTTP listen address (overrides addr)")
+	fs.Func("merge-interval", "Time between background merges, 0 to disable (overrides merge_interval)", func(s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		o.MergeInterval = &d
+		return nil
+	})
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: logkvd [flags]")
+		fmt.Fprintln(fs.Output(), "\nSettings come from the config file, then LOGKV_* environment variables, then these flags.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return o, err
+	}
+	if fs.NArg() > 0 {
+		err := fmt.Errorf("unexpected argument %q", fs.Arg(0))
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+		return o, err
+	}
+	return o, nil
+}
+
+// configOptions provides the Config loaded from file and environment, with
+// the command-line overrides applied on top
+func configOptions(o config.Overrides) fx.Option {
+	return fx.Options(
+		config.Module(),
+		config.Override(o),
+		config.StopTimeout(o),
+	)
+}
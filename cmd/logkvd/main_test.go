@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+// resolveConfig builds the Config logkvd would run with for args
+func resolveConfig(t *testing.T, args ...string) *config.Config {
+	t.Helper()
+	overrides, err := parseFlags(args, io.Discard)
+	require.NoError(t, err)
+
+	var cfg *config.Config
+	app := fx.New(configOptions(overrides), fx.Populate(&cfg), fx.NopLogger)
+	require.NoError(t, app.Err())
+	return cfg
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("data_dir: "+filepath.Join(dir, "file")+"\naddr: \":7000\"\nmerge_interval: 1m\n"), 0o644))
+	t.Setenv("LOGKV_CONFIG", path)
+	t.Setenv("LOGKV_MERGE_INTERVAL", "2m")
+
+	// File, with the environment on top
+	cfg := resolveConfig(t)
+	assert.Equal(t, filepath.Join(dir, "file"), cfg.DataDir)
+	assert.Equal(t, ":7000", cfg.HTTPAddr)
+	assert.Equal(t, 2*time.Minute, cfg.MergeInterval)
+
+	// Flags on top of both
+	cfg = resolveConfig(t, "--data-dir", filepath.Join(dir, "flag"), "--addr", ":7001", "--merge-interval", "3m")
+	assert.Equal(t, filepath.Join(dir, "flag"), cfg.DataDir)
+	assert.Equal(t, ":7001", cfg.HTTPAddr)
+	assert.Equal(t, 3*time.Minute, cfg.MergeInterval)
+
+	// Flags left out leave the rest alone
+	cfg = resolveConfig(t, "--addr", ":7002")
+	assert.Equal(t, filepath.Join(dir, "file"), cfg.DataDir)
+	assert.Equal(t, ":7002", cfg.HTTPAddr)
+	assert.Equal(t, 2*time.Minute, cfg.MergeInterval)
+
+	// A zero interval is given, not left out, and disables merges
+	cfg = resolveConfig(t, "--merge-interval", "0")
+	assert.Zero(t, cfg.MergeInterval)
+}
+
+func TestConfigOverridesAreValidated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("data_dir: "+dir+"\n"), 0o644))
+	t.Setenv("LOGKV_CONFIG", path)
+
+	overrides, err := parseFlags([]string{"--merge-interval", "-1s"}, io.Discard)
+	require.NoError(t, err)
+	app := fx.New(configOptions(overrides), fx.Invoke(func(*config.Config) {}), fx.NopLogger)
+	assert.ErrorContains(t, app.Err(), "merge_interval must not be negative")
+}
+
+func TestParseFlags_Invalid(t *testing.T) {
+	quiet := func(args ...string) error {
+		_, err := parseFlags(args, io.Discard)
+		return err
+	}
+
+	assert.Error(t, quiet("--merge-interval", "soon"))
+	assert.Error(t, quiet("--unknown"))
+	assert.Error(t, quiet("extra"))
+	assert.ErrorIs(t, quiet("-h"), flag.ErrHelp)
+}
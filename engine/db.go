@@ -1,34 +1,194 @@
 package engine
 
 import (
+	"context"
+	"errors"
+	"io"
 	"sync"
+	"time"
 
+	"github.com/himakhaitan/logkv-store/pkg/metrics"
 	"github.com/himakhaitan/logkv-store/store"
 )
 
 type DB struct {
-	Store *store.Store
-	mu    sync.RWMutex
+	Store   *store.Store
+	Metrics *metrics.Metrics
+	mu      sync.RWMutex
 }
 
-func NewDB(s *store.Store) *DB {
-	return &DB{Store: s}
+func NewDB(s *store.Store, m *metrics.Metrics) *DB {
+	db := &DB{Store: s, Metrics: m}
+	m.RegisterStats(db.Stats)
+	return db
 }
 
 func (db *DB) Get(key string) (string, error) {
+	return db.GetCtx(context.Background(), key)
+}
+
+func (db *DB) GetCtx(ctx context.Context, key string) (string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	value, err := db.Store.GetCtx(ctx, key)
+	db.Metrics.Lookup(metrics.OpGet, err)
+	return value, err
+}
+
+func (db *DB) GetWithMeta(key string) (string, store.EntryMeta, error) {
+	return db.GetWithMetaCtx(context.Background(), key)
+}
+
+func (db *DB) GetWithMetaCtx(ctx context.Context, key string) (string, store.EntryMeta, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	value, meta, err := db.Store.GetWithMetaCtx(ctx, key)
+	db.Metrics.Lookup(metrics.OpGet, err)
+	return value, meta, err
+}
+
+func (db *DB) Exists(key string) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.Store.Exists(key)
+}
+
+func (db *DB) MultiGet(keys []string) (map[string]string, []string, error) {
+	return db.MultiGetCtx(context.Background(), keys)
+}
+
+func (db *DB) MultiGetCtx(ctx context.Context, keys []string) (map[string]string, []string, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	return db.Store.Get(key)
+	values, missing, err := db.Store.MultiGetCtx(ctx, keys)
+	if err != nil {
+		db.Metrics.Count(metrics.OpGet, metrics.ResultError, len(keys))
+		return values, missing, err
+	}
+	db.Metrics.Count(metrics.OpGet, metrics.ResultHit, len(values))
+	db.Metrics.Count(metrics.OpGet, metrics.ResultMiss, len(missing))
+	return values, missing, nil
 }
 
 func (db *DB) Set(key, value string) error {
-	return db.Store.Set(key, value)
+	err := db.Store.Set(key, value)
+	db.Metrics.Write(metrics.OpSet, err)
+	return err
+}
+
+func (db *DB) SetBatch(pairs []store.KeyValue) error {
+	err := db.Store.SetBatch(pairs)
+	written := len(pairs)
+	var batchErr *store.BatchError
+	switch {
+	case errors.As(err, &batchErr):
+		written = batchErr.Written
+	case err != nil:
+		written = 0
+	}
+	db.Metrics.Count(metrics.OpSet, metrics.ResultOK, written)
+	if err != nil {
+		db.Metrics.Count(metrics.OpSet, metrics.ResultError, len(pairs)-written)
+	}
+	return err
+}
+
+func (db *DB) SetWithContentType(key, value, contentType string) error {
+	err := db.Store.SetWithContentType(key, value, contentType)
+	db.Metrics.Write(metrics.OpSet, err)
+	return err
+}
+
+func (db *DB) SetWithTTL(key, value string, ttl time.Duration) error {
+	err := db.Store.SetWithTTL(key, value, ttl)
+	db.Metrics.Write(metrics.OpSet, err)
+	return err
+}
+
+func (db *DB) TTL(key string) (time.Duration, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.Store.TTL(key)
 }
 
 func (db *DB) Delete(key string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
-	return db.Store.Delete(key)
+	err := db.Store.Delete(key)
+	db.Metrics.Lookup(metrics.OpDelete, err)
+	return err
+}
+
+func (db *DB) DeleteIfExists(key string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	existed, err := db.Store.DeleteIfExists(key)
+	if err == nil && !existed {
+		db.Metrics.Lookup(metrics.OpDelete, store.ErrKeyNotFound)
+	} else {
+		db.Metrics.Lookup(metrics.OpDelete, err)
+	}
+	return existed, err
+}
+
+func (db *DB) DeleteBatch(keys []string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	deleted, err := db.Store.DeleteBatch(keys)
+	db.Metrics.Count(metrics.OpDelete, metrics.ResultHit, deleted)
+	if err != nil {
+		db.Metrics.Count(metrics.OpDelete, metrics.ResultError, len(keys)-deleted)
+	} else {
+		db.Metrics.Count(metrics.OpDelete, metrics.ResultMiss, len(keys)-deleted)
+	}
+	return deleted, err
+}
+
+// Writes that remove a key take mu like Delete; those that only set one
+// leave it alone like Set
+
+func (db *DB) CompareAndSwap(key, oldValue, newValue string) (bool, error) {
+	swapped, err := db.Store.CompareAndSwap(key, oldValue, newValue)
+	db.Metrics.Write(metrics.OpSet, err)
+	return swapped, err
+}
+
+func (db *DB) CompareAndDelete(key, oldValue string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	deleted, err := db.Store.CompareAndDelete(key, oldValue)
+	if err == nil && !deleted {
+		db.Metrics.Lookup(metrics.OpDelete, store.ErrKeyNotFound)
+	} else {
+		db.Metrics.Lookup(metrics.OpDelete, err)
+	}
+	return deleted, err
+}
+
+func (db *DB) Copy(src, dst string) error {
+	err := db.Store.Copy(src, dst)
+	db.Metrics.Write(metrics.OpSet, err)
+	return err
+}
+
+func (db *DB) Rename(src, dst string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	err := db.Store.Rename(src, dst)
+	db.Metrics.Write(metrics.OpSet, err)
+	return err
+}
+
+func (db *DB) IncrBy(key string, delta int64) (int64, error) {
+	value, err := db.Store.IncrBy(key, delta)
+	db.Metrics.Write(metrics.OpSet, err)
+	return value, err
+}
+
+func (db *DB) AppendValue(key, suffix string) (string, error) {
+	value, err := db.Store.AppendValue(key, suffix)
+	db.Metrics.Write(metrics.OpSet, err)
+	return value, err
 }
 
 func (db *DB) List() ([]string, error) {
@@ -37,8 +197,102 @@ func (db *DB) List() ([]string, error) {
 	return db.Store.List()
 }
 
+func (db *DB) ListWithMeta() ([]store.KeyMeta, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.Store.ListWithMeta()
+}
+
+func (db *DB) ListPage(cursor string, limit int) ([]string, string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.Store.ListPage(cursor, limit)
+}
+
+func (db *DB) Scan(prefix string) ([]string, error) {
+	return db.ScanCtx(context.Background(), prefix)
+}
+
+func (db *DB) ScanCtx(ctx context.Context, prefix string) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.Store.ScanCtx(ctx, prefix)
+}
+
+func (db *DB) Range(start, end string) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.Store.Range(start, end)
+}
+
 func (db *DB) Stats() (store.Stats, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 	return db.Store.Stats()
 }
+
+func (db *DB) SegmentStats() []store.SegmentInfo {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.Store.SegmentStats()
+}
+
+func (db *DB) Check() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.Store.Check()
+}
+
+func (db *DB) Reload() error {
+	return db.Store.Reload()
+}
+
+func (db *DB) StopBackground() {
+	db.Store.StopBackground()
+}
+
+func (db *DB) Rotate() (int, error) {
+	return db.Store.Rotate()
+}
+
+func (db *DB) Compact() (store.MergeResult, error) {
+	return db.CompactCtx(context.Background())
+}
+
+func (db *DB) CompactCtx(ctx context.Context) (store.MergeResult, error) {
+	result, err := db.Store.CompactCtx(ctx)
+	if err == nil {
+		db.Metrics.Compaction()
+	}
+	return result, err
+}
+
+func (db *DB) MergeWait(ctx context.Context) (store.MergeResult, error) {
+	result, err := db.Store.MergeWait(ctx)
+	if err == nil {
+		db.Metrics.Compaction()
+	}
+	return result, err
+}
+
+func (db *DB) MergeDryRun() (store.MergeReport, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.Store.MergeDryRun()
+}
+
+func (db *DB) Iterator() (*store.Iterator, error) {
+	return db.IteratorCtx(context.Background())
+}
+
+func (db *DB) IteratorCtx(ctx context.Context) (*store.Iterator, error) {
+	return db.Store.IteratorCtx(ctx)
+}
+
+func (db *DB) Subscribe() (<-chan store.Event, func()) {
+	return db.Store.Subscribe()
+}
+
+func (db *DB) Snapshot(w io.Writer) error {
+	return db.Store.Snapshot(w)
+}
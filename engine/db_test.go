@@ -1,13 +1,17 @@
 package engine
 
 import (
+	"io"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/pkg/metrics"
 	"github.com/himakhaitan/logkv-store/store"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -18,7 +22,7 @@ func TestDBOperations(t *testing.T) {
 
 	s, err := store.New(logger, cfg)
 	assert.NoError(t, err)
-	db := NewDB(s)
+	db := NewDB(s, nil)
 
 	// Test Set
 	err = db.Set("foo", "bar")
@@ -52,3 +56,39 @@ func TestDBOperations(t *testing.T) {
 
 	os.RemoveAll(tempDir)
 }
+
+func TestDBWriteMetrics(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	s, err := store.New(logger, &config.Config{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer s.Close()
+	m := metrics.New()
+	db := NewDB(s, m)
+
+	require.NoError(t, db.Set("a", "1"))
+	_, err = db.IncrBy("a", 1)
+	require.NoError(t, err)
+	_, err = db.AppendValue("a", "0")
+	require.NoError(t, err)
+	require.NoError(t, db.Copy("a", "b"))
+	require.NoError(t, db.Rename("b", "c"))
+	_, err = db.CompareAndSwap("c", "20", "30")
+	require.NoError(t, err)
+	_, err = db.CompareAndDelete("c", "30")
+	require.NoError(t, err)
+	_, err = db.IncrBy("missing", 1)
+	require.NoError(t, err)
+	assert.Error(t, db.Copy("gone", "d"))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	for _, line := range []string{
+		`logkv_operations_total{op="set",result="ok"} 7`,
+		`logkv_operations_total{op="set",result="error"} 1`,
+		`logkv_operations_total{op="delete",result="hit"} 1`,
+	} {
+		assert.Contains(t, string(body), line)
+	}
+}
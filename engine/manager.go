@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/store"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidNamespace is returned for namespace names that are empty, too
+	// long or contain characters other than letters, digits, '-' and '_'
+	ErrInvalidNamespace = errors.New("invalid namespace")
+
+	// ErrManagerClosed is returned when opening a namespace after Close
+	ErrManagerClosed = errors.New("namespace manager is closed")
+)
+
+// maxNamespaceLen is the longest namespace name accepted
+const maxNamespaceLen = 64
+
+// namespaceDir is the data dir subdirectory holding one directory per
+// namespace
+const namespaceDir = "ns"
+
+// Manager holds the default database and any named namespaces. Each namespace
+// is a separate store in its own subdirectory of the data dir, with its own
+// segments and merge loop, opened the first time it is used.
+type Manager struct {
+	Default *DB
+
+	cfg        *config.Config
+	logger     *zap.Logger
+	mu         sync.Mutex
+	namespaces map[string]*DB
+	closed     bool
+}
+
+func NewManager(db *DB, cfg *config.Config, logger *zap.Logger) *Manager {
+	return &Manager{
+		Default:    db,
+		cfg:        cfg,
+		logger:     logger,
+		namespaces: make(map[string]*DB),
+	}
+}
+
+// NewManagerWithLifecycle creates a Manager whose namespaces are closed when
+// the app stops. The default database is owned by the store module.
+func NewManagerWithLifecycle(lc fx.Lifecycle, db *DB, cfg *config.Config, logger *zap.Logger) *Manager {
+	m := NewManager(db, cfg, logger)
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Closing namespaces")
+			return m.Close()
+		},
+	})
+	return m
+}
+
+// Namespace returns the database for name, opening it on first use
+func (m *Manager) Namespace(name string) (*DB, error) {
+	if err := ValidateNamespace(name); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrManagerClosed
+	}
+	if db, ok := m.namespaces[name]; ok {
+		return db, nil
+	}
+
+	cfg := *m.cfg
+	cfg.DataDir = filepath.Join(m.cfg.DataDir, namespaceDir, name)
+	s, err := store.New(m.logger.With(zap.String("namespace", name)), &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("open namespace %q: %w", name, err)
+	}
+
+	// Namespaces share the operation counters, but the key and segment
+	// gauges only describe the default database
+	db := &DB{Store: s, Metrics: m.Default.Metrics}
+	m.namespaces[name] = db
+	return db, nil
+}
+
+// Namespaces returns the names of the open namespaces in order
+func (m *Manager) Namespaces() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.namespaces))
+	for name := range m.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StopBackground stops the background merges and expiry sweeps of the
+// default database and every open namespace. They keep serving requests.
+func (m *Manager) StopBackground() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Default.StopBackground()
+	for _, db := range m.namespaces {
+		db.StopBackground()
+	}
+}
+
+// Close closes every open namespace. Later calls to Namespace fail.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+
+	var errs []error
+	for name, db := range m.namespaces {
+		if err := db.Store.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close namespace %q: %w", name, err))
+		}
+	}
+	m.namespaces = make(map[string]*DB)
+	return errors.Join(errs...)
+}
+
+// ValidateNamespace checks that name can be used as a namespace and as a
+// directory name
+func ValidateNamespace(name string) error {
+	if name == "" || len(name) > maxNamespaceLen {
+		return fmt.Errorf("%w: %q", ErrInvalidNamespace, name)
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return fmt.Errorf("%w: %q", ErrInvalidNamespace, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestManager(t *testing.T) (*Manager, *config.Config) {
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{DataDir: t.TempDir()}
+	s, err := store.New(logger, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return NewManager(NewDB(s, nil), cfg, logger), cfg
+}
+
+func TestManager_NamespacesAreIsolated(t *testing.T) {
+	m, cfg := newTestManager(t)
+	defer m.Close()
+
+	users, err := m.Namespace("users")
+	require.NoError(t, err)
+	orders, err := m.Namespace("orders")
+	require.NoError(t, err)
+
+	require.NoError(t, users.Set("id", "alice"))
+	require.NoError(t, orders.Set("id", "42"))
+	require.NoError(t, m.Default.Set("id", "default"))
+
+	for db, want := range map[*DB]string{users: "alice", orders: "42", m.Default: "default"} {
+		value, err := db.Get("id")
+		require.NoError(t, err)
+		assert.Equal(t, want, value)
+	}
+
+	// Each namespace keeps its segments in its own directory
+	segments, err := filepath.Glob(filepath.Join(cfg.DataDir, "ns", "users", "segment_*.log"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, segments)
+	assert.Equal(t, []string{"orders", "users"}, m.Namespaces())
+}
+
+func TestManager_NamespaceIsOpenedOnce(t *testing.T) {
+	m, cfg := newTestManager(t)
+	defer m.Close()
+
+	_, err := os.Stat(filepath.Join(cfg.DataDir, "ns", "lazy"))
+	assert.True(t, os.IsNotExist(err), "namespaces are created on first use")
+
+	first, err := m.Namespace("lazy")
+	require.NoError(t, err)
+	second, err := m.Namespace("lazy")
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+}
+
+func TestManager_CloseAndReopen(t *testing.T) {
+	m, cfg := newTestManager(t)
+
+	db, err := m.Namespace("users")
+	require.NoError(t, err)
+	require.NoError(t, db.Set("id", "alice"))
+	require.NoError(t, m.Close())
+
+	assert.Error(t, db.Set("id", "bob"), "the namespace store should be closed")
+	_, err = m.Namespace("users")
+	assert.ErrorIs(t, err, ErrManagerClosed)
+
+	reopened := NewManager(m.Default, cfg, zaptest.NewLogger(t))
+	defer reopened.Close()
+	db, err = reopened.Namespace("users")
+	require.NoError(t, err)
+	value, err := db.Get("id")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", value)
+}
+
+func TestValidateNamespace(t *testing.T) {
+	for _, name := range []string{"users", "Team-A", "v2_data"} {
+		assert.NoError(t, ValidateNamespace(name), name)
+	}
+	for _, name := range []string{"", "..", "a/b", "with space", string(make([]byte, maxNamespaceLen+1))} {
+		assert.ErrorIs(t, ValidateNamespace(name), ErrInvalidNamespace, name)
+	}
+}
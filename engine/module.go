@@ -9,5 +9,6 @@ func Module() fx.Option {
 	return fx.Options(
 		store.Module,
 		fx.Provide(NewDB),
+		fx.Provide(NewManagerWithLifecycle),
 	)
 }
@@ -0,0 +1,317 @@
+package grpcserver
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/himakhaitan/logkv-store/types"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages below implement the protobuf wire format of logkv.proto by
+// hand. Responses reuse the HTTP shapes from the types package; their
+// BaseResponse fields are not sent, since errors travel as gRPC statuses.
+
+// GetRequest asks for the value of a key
+type GetRequest struct {
+	Key string
+}
+
+// GetResponse carries a value and the time it was written
+type GetResponse types.GetResponse
+
+// SetRequest stores a value under a key
+type SetRequest types.SetRequest
+
+// SetResponse acknowledges a Set
+type SetResponse struct{}
+
+// DeleteRequest removes a key
+type DeleteRequest struct {
+	Key string
+}
+
+// DeleteResponse acknowledges a Delete
+type DeleteResponse struct{}
+
+// ListRequest asks for every live key
+type ListRequest struct{}
+
+// ListResponse carries the live keys
+type ListResponse types.ListKeysResponse
+
+// StatsRequest asks for database statistics
+type StatsRequest struct{}
+
+// StatsResponse carries database statistics
+type StatsResponse types.StatsResponse
+
+// message is implemented by every request and response
+type message interface {
+	marshal() []byte
+	unmarshal(data []byte) error
+}
+
+// field is a decoded protobuf field. Only the member matching the wire type is set.
+type field struct {
+	num     protowire.Number
+	varint  uint64
+	fixed64 uint64
+	bytes   []byte
+}
+
+// decodeFields splits data into its fields, skipping wire types no message uses
+func decodeFields(data []byte) ([]field, error) {
+	var fields []field
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		f := field{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			f.fixed64, n = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// appendString appends a string field, omitting the proto3 default
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendVarint appends a varint field, omitting the proto3 default
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// marshalKey encodes messages holding only a key
+func marshalKey(key string) []byte {
+	return appendString(nil, 1, key)
+}
+
+// unmarshalKey decodes messages holding only a key
+func unmarshalKey(data []byte) (string, error) {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return "", err
+	}
+	var key string
+	for _, f := range fields {
+		if f.num == 1 {
+			key = string(f.bytes)
+		}
+	}
+	return key, nil
+}
+
+// unmarshalEmpty validates messages without fields
+func unmarshalEmpty(data []byte) error {
+	_, err := decodeFields(data)
+	return err
+}
+
+func (m *GetRequest) marshal() []byte { return marshalKey(m.Key) }
+
+func (m *GetRequest) unmarshal(data []byte) (err error) {
+	m.Key, err = unmarshalKey(data)
+	return err
+}
+
+func (m *GetResponse) marshal() []byte {
+	b := appendString(nil, 1, m.Key)
+	b = appendString(b, 2, m.Value)
+	b = appendVarint(b, 3, uint64(m.Timestamp))
+	return appendString(b, 4, m.ContentType)
+}
+
+func (m *GetResponse) unmarshal(data []byte) error {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			m.Key = string(f.bytes)
+		case 2:
+			m.Value = string(f.bytes)
+		case 3:
+			m.Timestamp = int64(f.varint)
+		case 4:
+			m.ContentType = string(f.bytes)
+		}
+	}
+	return nil
+}
+
+func (m *SetRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Key)
+	b = appendString(b, 2, m.Value)
+	return appendString(b, 3, m.ContentType)
+}
+
+func (m *SetRequest) unmarshal(data []byte) error {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			m.Key = string(f.bytes)
+		case 2:
+			m.Value = string(f.bytes)
+		case 3:
+			m.ContentType = string(f.bytes)
+		}
+	}
+	return nil
+}
+
+func (m *SetResponse) marshal() []byte             { return nil }
+func (m *SetResponse) unmarshal(data []byte) error { return unmarshalEmpty(data) }
+
+func (m *DeleteRequest) marshal() []byte { return marshalKey(m.Key) }
+
+func (m *DeleteRequest) unmarshal(data []byte) (err error) {
+	m.Key, err = unmarshalKey(data)
+	return err
+}
+
+func (m *DeleteResponse) marshal() []byte             { return nil }
+func (m *DeleteResponse) unmarshal(data []byte) error { return unmarshalEmpty(data) }
+
+func (m *ListRequest) marshal() []byte             { return nil }
+func (m *ListRequest) unmarshal(data []byte) error { return unmarshalEmpty(data) }
+
+func (m *ListResponse) marshal() []byte {
+	var b []byte
+	for _, key := range m.Keys {
+		// Repeated strings keep empty elements
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, key)
+	}
+	return b
+}
+
+func (m *ListResponse) unmarshal(data []byte) error {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.num == 1 {
+			m.Keys = append(m.Keys, string(f.bytes))
+		}
+	}
+	return nil
+}
+
+func (m *StatsRequest) marshal() []byte             { return nil }
+func (m *StatsRequest) unmarshal(data []byte) error { return unmarshalEmpty(data) }
+
+func (m *StatsResponse) marshal() []byte {
+	b := appendVarint(nil, 1, uint64(m.TotalKeys))
+	b = appendVarint(b, 2, uint64(m.TotalSize))
+	b = appendVarint(b, 3, uint64(m.Segments))
+	for id, ratio := range m.DeadRatios {
+		// Map entries are nested messages of key = 1, value = 2
+		entry := protowire.AppendTag(nil, 1, protowire.VarintType)
+		entry = protowire.AppendVarint(entry, uint64(int32(id)))
+		entry = protowire.AppendTag(entry, 2, protowire.Fixed64Type)
+		entry = protowire.AppendFixed64(entry, math.Float64bits(ratio))
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	b = appendVarint(b, 5, m.CacheHits)
+	return appendVarint(b, 6, m.CacheMisses)
+}
+
+func (m *StatsResponse) unmarshal(data []byte) error {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			m.TotalKeys = int(f.varint)
+		case 2:
+			m.TotalSize = int64(f.varint)
+		case 3:
+			m.Segments = int(f.varint)
+		case 4:
+			entry, err := decodeFields(f.bytes)
+			if err != nil {
+				return err
+			}
+			var id int
+			var ratio float64
+			for _, ef := range entry {
+				switch ef.num {
+				case 1:
+					id = int(int32(ef.varint))
+				case 2:
+					ratio = math.Float64frombits(ef.fixed64)
+				}
+			}
+			if m.DeadRatios == nil {
+				m.DeadRatios = make(map[int]float64)
+			}
+			m.DeadRatios[id] = ratio
+		case 5:
+			m.CacheHits = f.varint
+		case 6:
+			m.CacheMisses = f.varint
+		}
+	}
+	return nil
+}
+
+// codec marshals the messages above in place of the generated protobuf codec
+type codec struct{}
+
+// Name returns "proto", since messages are encoded in the protobuf wire format
+func (codec) Name() string { return "proto" }
+
+// Marshal encodes a message
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("grpcserver: cannot marshal %T", v)
+	}
+	return m.marshal(), nil
+}
+
+// Unmarshal decodes data into a message
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("grpcserver: cannot unmarshal into %T", v)
+	}
+	return m.unmarshal(data)
+}
@@ -0,0 +1,90 @@
+package grpcserver
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	protoMessage = regexp.MustCompile(`(?m)^message (\w+) \{([^}]*)\}`)
+	protoField   = regexp.MustCompile(`(?m)^\s*(?:repeated\s+)?(?:map<[^>]+>|\w+)\s+\w+\s*=\s*(\d+);`)
+)
+
+// protoFields returns the sorted field numbers of each message in logkv.proto
+func protoFields(t *testing.T) map[string][]protowire.Number {
+	t.Helper()
+	src, err := os.ReadFile("logkv.proto")
+	require.NoError(t, err)
+
+	messages := make(map[string][]protowire.Number)
+	for _, m := range protoMessage.FindAllStringSubmatch(string(src), -1) {
+		nums := []protowire.Number{}
+		for _, f := range protoField.FindAllStringSubmatch(m[2], -1) {
+			n, err := strconv.Atoi(f[1])
+			require.NoError(t, err)
+			nums = append(nums, protowire.Number(n))
+		}
+		slices.Sort(nums)
+		messages[m[1]] = nums
+	}
+	require.NotEmpty(t, messages, "logkv.proto should declare messages")
+	return messages
+}
+
+// The messages are encoded by hand, so nothing but this test ties them to
+// logkv.proto: with every field set, each message must put exactly the
+// proto's fields on the wire and decode back to itself.
+func TestMessages_MatchProto(t *testing.T) {
+	messages := map[string]message{
+		"GetRequest":     &GetRequest{Key: "key"},
+		"GetResponse":    &GetResponse{Key: "key", Value: "value", Timestamp: 1700000000, ContentType: "text/plain"},
+		"SetRequest":     &SetRequest{Key: "key", Value: "value", ContentType: "text/plain"},
+		"SetResponse":    &SetResponse{},
+		"DeleteRequest":  &DeleteRequest{Key: "key"},
+		"DeleteResponse": &DeleteResponse{},
+		"ListRequest":    &ListRequest{},
+		"ListResponse":   &ListResponse{Keys: []string{"a", "b"}},
+		"StatsRequest":   &StatsRequest{},
+		"StatsResponse": &StatsResponse{
+			TotalKeys:   3,
+			TotalSize:   1 << 40,
+			Segments:    2,
+			DeadRatios:  map[int]float64{1: 0.5},
+			CacheHits:   7,
+			CacheMisses: 9,
+		},
+	}
+
+	want := protoFields(t)
+	for name := range messages {
+		assert.Contains(t, want, name, "message not declared in logkv.proto")
+	}
+	for name, nums := range want {
+		t.Run(name, func(t *testing.T) {
+			m, ok := messages[name]
+			require.True(t, ok, "no Go message for %s", name)
+
+			data := m.marshal()
+			fields, err := decodeFields(data)
+			require.NoError(t, err)
+			got := []protowire.Number{}
+			for _, f := range fields {
+				got = append(got, f.num)
+			}
+			slices.Sort(got)
+			assert.Equal(t, nums, slices.Compact(got), "encoded field numbers")
+
+			decoded := reflect.New(reflect.TypeOf(m).Elem()).Interface().(message)
+			require.NoError(t, decoded.unmarshal(data))
+			assert.Equal(t, m, decoded)
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package grpcserver
+
+import "go.uber.org/fx"
+
+// Module provides the gRPC server wired with fx. It expects an *engine.DB to
+// be provided elsewhere, e.g. by server.Module.
+func Module() fx.Option {
+	return fx.Options(
+		fx.Provide(NewGRPCServer),
+		fx.Invoke(RegisterHooks),
+	)
+}
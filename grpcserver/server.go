@@ -0,0 +1,137 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/himakhaitan/logkv-store/engine"
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/store"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements LogKVServer on top of engine.DB
+type Server struct {
+	db     *engine.DB
+	logger *zap.Logger
+}
+
+// NewServer creates a LogKV service backed by db
+func NewServer(db *engine.DB, logger *zap.Logger) *Server {
+	return &Server{db: db, logger: logger}
+}
+
+// Get fetches the value of a key
+func (s *Server) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	value, meta, err := s.db.GetWithMeta(req.Key)
+	if err != nil {
+		return nil, s.status(err)
+	}
+	return &GetResponse{Key: req.Key, Value: value, Timestamp: int64(meta.Timestamp), ContentType: meta.ContentType}, nil
+}
+
+// Set stores a value under a key
+func (s *Server) Set(ctx context.Context, req *SetRequest) (*SetResponse, error) {
+	if err := s.db.SetWithContentType(req.Key, req.Value, req.ContentType); err != nil {
+		return nil, s.status(err)
+	}
+	return &SetResponse{}, nil
+}
+
+// Delete removes a key
+func (s *Server) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	if err := s.db.Delete(req.Key); err != nil {
+		return nil, s.status(err)
+	}
+	return &DeleteResponse{}, nil
+}
+
+// List fetches every live key
+func (s *Server) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	keys, err := s.db.List()
+	if err != nil {
+		return nil, s.status(err)
+	}
+	return &ListResponse{Keys: keys}, nil
+}
+
+// Stats fetches database statistics
+func (s *Server) Stats(ctx context.Context, req *StatsRequest) (*StatsResponse, error) {
+	stats, err := s.db.Stats()
+	if err != nil {
+		return nil, s.status(err)
+	}
+	return &StatsResponse{
+		TotalKeys:   stats.TotalKeys,
+		TotalSize:   stats.TotalSize,
+		Segments:    stats.Segments,
+		DeadRatios:  stats.DeadRatios,
+		CacheHits:   stats.CacheHits,
+		CacheMisses: stats.CacheMisses,
+	}, nil
+}
+
+// status converts a store error into a gRPC status error
+func (s *Server) status(err error) error {
+	switch {
+	case errors.Is(err, store.ErrKeyNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, store.ErrEmptyKey), errors.Is(err, store.ErrKeyTooLarge), errors.Is(err, store.ErrValueTooLarge),
+		errors.Is(err, store.ErrContentTypeTooLarge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		s.logger.Error("gRPC request failed", zap.Error(err))
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// NewGRPCServer constructs the gRPC server with the LogKV service registered
+func NewGRPCServer(db *engine.DB, logger *zap.Logger) *grpc.Server {
+	server := grpc.NewServer(ServerOptions()...)
+	RegisterLogKVServer(server, NewServer(db, logger))
+	return server
+}
+
+// RegisterHooks starts and stops the gRPC server using fx Lifecycle. The
+// server is not started when no address is configured.
+func RegisterHooks(lc fx.Lifecycle, server *grpc.Server, cfg *config.Config, logger *zap.Logger) {
+	if cfg.GRPCAddr == "" {
+		logger.Info("gRPC server disabled")
+		return
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			listener, err := net.Listen("tcp", cfg.GRPCAddr)
+			if err != nil {
+				return err
+			}
+			logger.Info("Starting gRPC server", zap.String("addr", listener.Addr().String()))
+			go func() {
+				if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+					logger.Error("gRPC server failed", zap.Error(err))
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Stopping gRPC server")
+			stopped := make(chan struct{})
+			go func() {
+				server.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				server.Stop()
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,184 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/himakhaitan/logkv-store/engine"
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// setupGRPCServer serves a store in a temp dir over gRPC and returns a client for it
+func setupGRPCServer(t *testing.T) *Client {
+	logger := zaptest.NewLogger(t)
+
+	s, err := store.New(logger, &config.Config{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	server := NewGRPCServer(&engine.DB{Store: s}, logger)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return NewClient(conn)
+}
+
+func TestServer_AllMethods(t *testing.T) {
+	client := setupGRPCServer(t)
+	ctx := context.Background()
+
+	_, err := client.Set(ctx, &SetRequest{Key: "foo", Value: "bar"})
+	require.NoError(t, err)
+
+	got, err := client.Get(ctx, &GetRequest{Key: "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, "foo", got.Key)
+	assert.Equal(t, "bar", got.Value)
+	assert.NotZero(t, got.Timestamp)
+
+	list, err := client.List(ctx, &ListRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, list.Keys)
+
+	stats, err := client.Stats(ctx, &StatsRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TotalKeys)
+	assert.Equal(t, 1, stats.Segments)
+
+	_, err = client.Delete(ctx, &DeleteRequest{Key: "foo"})
+	require.NoError(t, err)
+
+	_, err = client.Get(ctx, &GetRequest{Key: "foo"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_ContentType(t *testing.T) {
+	client := setupGRPCServer(t)
+	ctx := context.Background()
+
+	_, err := client.Set(ctx, &SetRequest{Key: "doc", Value: `{"a":1}`, ContentType: "application/json"})
+	require.NoError(t, err)
+	got, err := client.Get(ctx, &GetRequest{Key: "doc"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, got.Value)
+	assert.Equal(t, "application/json", got.ContentType)
+
+	_, err = client.Set(ctx, &SetRequest{Key: "plain", Value: "x"})
+	require.NoError(t, err)
+	got, err = client.Get(ctx, &GetRequest{Key: "plain"})
+	require.NoError(t, err)
+	assert.Empty(t, got.ContentType)
+}
+
+func TestServer_InvalidArgument(t *testing.T) {
+	client := setupGRPCServer(t)
+
+	_, err := client.Set(context.Background(), &SetRequest{Key: "", Value: "bar"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestRegisterHooksLifecycle(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	server := grpc.NewServer(ServerOptions()...)
+
+	lc := fxtest.NewLifecycle(t)
+	RegisterHooks(lc, server, &config.Config{GRPCAddr: "127.0.0.1:0"}, logger)
+
+	ctx := context.Background()
+	assert.NoError(t, lc.Start(ctx))
+	assert.NoError(t, lc.Stop(ctx))
+}
+
+// statsResponseDescriptor builds the StatsResponse descriptor from logkv.proto
+func statsResponseDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	scalar := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(num),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+			JsonName: proto.String(name),
+		}
+	}
+
+	deadRatios := scalar("dead_ratios", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	deadRatios.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	deadRatios.TypeName = proto.String(".logkv.v1.StatsResponse.DeadRatiosEntry")
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("logkv.proto"),
+		Package: proto.String("logkv.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("StatsResponse"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				scalar("total_keys", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				scalar("total_size", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				scalar("segments", 3, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				deadRatios,
+				scalar("cache_hits", 5, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+				scalar("cache_misses", 6, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("DeadRatiosEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					scalar("key", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+					scalar("value", 2, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(file, nil)
+	require.NoError(t, err)
+	return fd.Messages().ByName("StatsResponse")
+}
+
+func TestStatsResponse_WireCompatible(t *testing.T) {
+	original := &StatsResponse{
+		TotalKeys:   3,
+		TotalSize:   1 << 40,
+		Segments:    2,
+		DeadRatios:  map[int]float64{1: 0.5, 2: 0},
+		CacheHits:   7,
+		CacheMisses: 9,
+	}
+
+	// Decode our encoding with the protobuf runtime
+	msg := dynamicpb.NewMessage(statsResponseDescriptor(t))
+	require.NoError(t, proto.Unmarshal(original.marshal(), msg))
+	fields := msg.Descriptor().Fields()
+	assert.Equal(t, int64(3), msg.Get(fields.ByName("total_keys")).Int())
+	assert.Equal(t, int64(1<<40), msg.Get(fields.ByName("total_size")).Int())
+	assert.Equal(t, uint64(9), msg.Get(fields.ByName("cache_misses")).Uint())
+	assert.Equal(t, 0.5, msg.Get(fields.ByName("dead_ratios")).Map().Get(protoreflect.ValueOfInt32(1).MapKey()).Float())
+
+	// And the protobuf runtime's encoding with ours
+	data, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	var decoded StatsResponse
+	require.NoError(t, decoded.unmarshal(data))
+	assert.Equal(t, original, &decoded)
+}
@@ -0,0 +1,125 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// serviceName is the fully qualified name of the LogKV service in logkv.proto
+const serviceName = "logkv.v1.LogKV"
+
+// LogKVServer is the server API for the LogKV service
+type LogKVServer interface {
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+}
+
+// serviceDesc describes the LogKV service to grpc
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*LogKVServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("Get", LogKVServer.Get),
+		unaryMethod("Set", LogKVServer.Set),
+		unaryMethod("Delete", LogKVServer.Delete),
+		unaryMethod("List", LogKVServer.List),
+		unaryMethod("Stats", LogKVServer.Stats),
+	},
+	Metadata: "logkv.proto",
+}
+
+// unaryMethod builds the method descriptor dispatching a unary RPC to call
+func unaryMethod[Req, Resp any](name string, call func(LogKVServer, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := new(Req)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(LogKVServer), ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + name}
+			return interceptor(ctx, in, info, func(ctx context.Context, req any) (any, error) {
+				return call(srv.(LogKVServer), ctx, req.(*Req))
+			})
+		},
+	}
+}
+
+// RegisterLogKVServer registers srv with a gRPC server. The server must be
+// created with ServerOptions so requests are decoded with this package's codec.
+func RegisterLogKVServer(s grpc.ServiceRegistrar, srv LogKVServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+// ServerOptions returns the options a gRPC server needs to serve LogKV
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{grpc.ForceServerCodec(codec{})}
+}
+
+// Client is a client for the LogKV service
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewClient creates a LogKV client on top of a connection
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+// invoke calls a LogKV method with this package's codec
+func (c *Client) invoke(ctx context.Context, method string, in, out any, opts []grpc.CallOption) error {
+	opts = append([]grpc.CallOption{grpc.ForceCodec(codec{})}, opts...)
+	return c.cc.Invoke(ctx, "/"+serviceName+"/"+method, in, out, opts...)
+}
+
+// Get fetches the value of a key
+func (c *Client) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	if err := c.invoke(ctx, "Get", in, out, opts); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Set stores a value under a key
+func (c *Client) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error) {
+	out := new(SetResponse)
+	if err := c.invoke(ctx, "Set", in, out, opts); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Delete removes a key
+func (c *Client) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	if err := c.invoke(ctx, "Delete", in, out, opts); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// List fetches every live key
+func (c *Client) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	if err := c.invoke(ctx, "List", in, out, opts); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Stats fetches database statistics
+func (c *Client) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	if err := c.invoke(ctx, "Stats", in, out, opts); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -1,16 +1,309 @@
 package config
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
+	"gopkg.in/yaml.v3"
+)
+
+// Every field can be set in the YAML file under its yaml key, and from the
+// environment as LOGKV_ followed by the upper-cased key (data_dir is
+// LOGKV_DATA_DIR). Lists are comma-separated in the environment.
 type Config struct {
-	DataDir       string
-	MergeInterval time.Duration
+	DataDir       string        `yaml:"data_dir"`
+	MergeInterval time.Duration `yaml:"merge_interval"` // Time between background merges (0 = none)
+	SyncMode      string        `yaml:"sync_mode"`      // none, always, interval or group
+	SyncInterval  time.Duration `yaml:"sync_interval"`  // fsync period when SyncMode is interval
+
+	GroupCommitWindow time.Duration `yaml:"group_commit_window"` // How long group mode waits for more writes to share an fsync (0 = store default)
+	GroupCommitBytes  int64         `yaml:"group_commit_bytes"`  // Unsynced bytes that make group mode fsync early (0 = store default)
+
+	MaxSegmentSize       int64 `yaml:"max_segment_size"`        // Segment rollover size in bytes (0 = store default)
+	MaxEntriesPerSegment int   `yaml:"max_entries_per_segment"` // Segment rollover entry count (0 = store default)
+
+	CompactionThreshold   float64 `yaml:"compaction_threshold"`    // Dead ratio a segment must exceed to be merged (0 = any)
+	CompactionConcurrency int     `yaml:"compaction_concurrency"`  // Segments rewritten in parallel during compaction (0 = store default)
+	CompactionSegmentSize int64   `yaml:"compaction_segment_size"` // Size in bytes compaction fills merged segments up to (0 = one per source segment)
+
+	MaxKeySize   int `yaml:"max_key_size"`   // Maximum key size in bytes (0 = format limit)
+	MaxValueSize int `yaml:"max_value_size"` // Maximum value size in bytes (0 = format limit)
+
+	MaxRequestBody int64 `yaml:"max_request_body"` // Largest set request body in bytes (0 = 8 MiB, or room for the largest allowed entry)
+
+	CacheSize         int `yaml:"cache_size"`          // Number of values kept in the read cache (0 = disabled)
+	NegativeCacheSize int `yaml:"negative_cache_size"` // Number of missing keys remembered so repeated misses skip the index (0 = disabled)
+
+	MmapReads bool `yaml:"mmap_reads"` // Read sealed segments through memory mappings instead of ReadAt (Unix only)
+
+	IndexShards int `yaml:"index_shards"` // Number of in-memory index shards (0 = store default)
+
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"` // Index snapshot period (0 = only on close)
+
+	ExpirySweepInterval time.Duration `yaml:"expiry_sweep_interval"` // How often expired keys are tombstoned (0 = only dropped when read)
+
+	ReadOnly bool `yaml:"read_only"` // Open the data dir without writing to it; writes and compaction fail
+
+	// Permissions for what the store creates, written in octal (0700). The
+	// process umask still applies.
+	DirMode  os.FileMode `yaml:"dir_mode"`  // Data directory permissions (0 = 0755)
+	FileMode os.FileMode `yaml:"file_mode"` // Segment, hint and snapshot file permissions (0 = 0644)
+
+	CompressionCodec     string `yaml:"compression_codec"`     // Value compression codec: none or gzip
+	CompressionThreshold int    `yaml:"compression_threshold"` // Values larger than this many bytes are compressed
+
+	// Values are encrypted with AES-GCM when a hex-encoded 16, 24 or 32 byte
+	// key is set. Encryption cannot be turned on or off for existing data.
+	EncryptionKey     string `yaml:"encryption_key"`      // Hex-encoded key (prefer the file or LOGKV_ENCRYPTION_KEY)
+	EncryptionKeyFile string `yaml:"encryption_key_file"` // File holding the hex-encoded key
+
+	HTTPAddr string `yaml:"addr"`      // HTTP listen address
+	GRPCAddr string `yaml:"grpc_addr"` // gRPC listen address (empty = gRPC disabled, the default)
+	RESPAddr string `yaml:"resp_addr"` // Redis protocol listen address (empty = RESP disabled, the default)
+
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // How long in-flight HTTP requests get to finish on shutdown (0 = none)
+
+	LogLevel  string `yaml:"log_level"`  // Least severe level logged: debug, info, warn or error
+	LogFormat string `yaml:"log_format"` // json (one object per line) or console
+
+	AuthToken      string `yaml:"auth_token"`      // Bearer token required by the HTTP API (empty = no auth)
+	RequestLogging bool   `yaml:"request_logging"` // Log every HTTP request
+	GzipMinSize    int    `yaml:"gzip_min_size"`   // Smallest HTTP response body gzipped for clients that accept it (0 = never)
+
+	CORSAllowedOrigins []string `yaml:"cors_origins"` // Origins allowed to call the HTTP API ("*" = any, empty = same-origin only)
+	CORSAllowedMethods []string `yaml:"cors_methods"` // Methods allowed in cross-origin requests
+	CORSAllowedHeaders []string `yaml:"cors_headers"` // Request headers allowed in cross-origin requests
 }
 
-func Load() (*Config, error) {
-	// TODO: Load configuration from yaml files or environment variables
+// DefaultPath is the config file read when LOGKV_CONFIG is not set
+const DefaultPath = "config.yaml"
+
+// Default returns the configuration used when nothing overrides it
+func Default() *Config {
 	return &Config{
 		DataDir:       "data",
 		MergeInterval: 30 * time.Minute,
-	}, nil
+		SyncMode:      "none",
+		SyncInterval:  time.Second,
+		HTTPAddr:      ":8080",
+
+		ShutdownTimeout: 30 * time.Second,
+
+		LogLevel:       "info",
+		LogFormat:      "json",
+		RequestLogging: true,
+		GzipMinSize:    1024,
+
+		CORSAllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "DELETE"},
+		CORSAllowedHeaders: []string{"Authorization", "Content-Type"},
+
+		CompactionThreshold: 0.4,
+
+		ExpirySweepInterval: time.Minute,
+	}
+}
+
+// Load builds the configuration from the defaults, then the YAML file at
+// LOGKV_CONFIG (or config.yaml if present), then LOGKV_* environment
+// variables, each overriding the one before
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path, explicit := os.LookupEnv("LOGKV_CONFIG")
+	if !explicit {
+		path = DefaultPath
+	}
+	if err := cfg.loadFile(path); err != nil {
+		// Only a file that was asked for has to exist
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate reports every value that would make the store fail or misbehave
+// at runtime, so a bad config stops startup instead
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.DataDir == "" {
+		invalid("data_dir must not be empty")
+	}
+	if c.MergeInterval < 0 {
+		invalid("merge_interval must not be negative, got %s", c.MergeInterval)
+	}
+	if c.ExpirySweepInterval < 0 {
+		invalid("expiry_sweep_interval must not be negative, got %s", c.ExpirySweepInterval)
+	}
+	if c.ShutdownTimeout < 0 {
+		invalid("shutdown_timeout must not be negative, got %s", c.ShutdownTimeout)
+	}
+	if c.SyncMode == "interval" && c.SyncInterval <= 0 {
+		invalid("sync_interval must be positive when sync_mode is interval, got %s", c.SyncInterval)
+	}
+	if c.GroupCommitWindow < 0 {
+		invalid("group_commit_window must not be negative, got %s", c.GroupCommitWindow)
+	}
+	if c.GroupCommitBytes < 0 {
+		invalid("group_commit_bytes must not be negative, got %d", c.GroupCommitBytes)
+	}
+	if c.MaxSegmentSize < 0 {
+		invalid("max_segment_size must not be negative, got %d", c.MaxSegmentSize)
+	}
+	if c.MaxEntriesPerSegment < 0 {
+		invalid("max_entries_per_segment must not be negative, got %d", c.MaxEntriesPerSegment)
+	}
+	if c.MaxSegmentSize > 0 && c.MaxValueSize > 0 && c.MaxSegmentSize < int64(c.MaxKeySize+c.MaxValueSize) {
+		invalid("max_segment_size (%d) is smaller than the largest entry allowed by max_key_size and max_value_size", c.MaxSegmentSize)
+	}
+	if c.MaxRequestBody < 0 {
+		invalid("max_request_body must not be negative, got %d", c.MaxRequestBody)
+	}
+	if c.MaxRequestBody > 0 && c.MaxValueSize > 0 && c.MaxRequestBody < int64(c.MaxKeySize+c.MaxValueSize) {
+		invalid("max_request_body (%d) is smaller than the largest entry allowed by max_key_size and max_value_size", c.MaxRequestBody)
+	}
+	if c.CompactionThreshold < 0 || c.CompactionThreshold > 1 {
+		invalid("compaction_threshold must be between 0 and 1, got %g", c.CompactionThreshold)
+	}
+	if c.CompactionConcurrency < 0 {
+		invalid("compaction_concurrency must not be negative, got %d", c.CompactionConcurrency)
+	}
+	if c.CompactionSegmentSize < 0 {
+		invalid("compaction_segment_size must not be negative, got %d", c.CompactionSegmentSize)
+	}
+	if c.DirMode&^os.ModePerm != 0 {
+		invalid("dir_mode must only hold permission bits, got %o", c.DirMode)
+	}
+	if c.FileMode&^os.ModePerm != 0 {
+		invalid("file_mode must only hold permission bits, got %o", c.FileMode)
+	}
+	switch c.LogLevel {
+	case "", "debug", "info", "warn", "error":
+	default:
+		invalid("log_level must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	switch c.LogFormat {
+	case "", "json", "console":
+	default:
+		invalid("log_format must be json or console, got %q", c.LogFormat)
+	}
+	if c.EncryptionKey != "" && c.EncryptionKeyFile != "" {
+		invalid("encryption_key and encryption_key_file must not both be set")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// loadFile overrides cfg with the keys set in a YAML file. Unknown keys are
+// rejected so typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides cfg with the LOGKV_* variables lookup finds
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		name := "LOGKV_" + strings.ToUpper(t.Field(i).Tag.Get("yaml"))
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			return fmt.Errorf("config: invalid %s %q: %w", name, value, err)
+		}
+	}
+	return nil
+}
+
+// setField parses s into a Config field
+func setField(field reflect.Value, s string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	if field.Type() == reflect.TypeOf(os.FileMode(0)) {
+		mode, err := strconv.ParseUint(s, 8, 32)
+		if err != nil {
+			return err
+		}
+		field.SetUint(mode)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(splitList(s)))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
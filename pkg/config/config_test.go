@@ -1,9 +1,15 @@
 package config
 
 import (
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
 )
 
 func TestLoad_ReturnsDefaultConfig(t *testing.T) {
@@ -22,3 +28,227 @@ func TestConfigStruct_SetAndGetFields(t *testing.T) {
 
 	assert.Equal(t, "custom_path", cfg.DataDir)
 }
+
+func TestLoad_CORSOriginsFromEnv(t *testing.T) {
+	t.Setenv("LOGKV_CORS_ORIGINS", "https://a.example.com, ,https://b.example.com")
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.CORSAllowedOrigins)
+}
+
+// writeConfig writes a YAML config file and points LOGKV_CONFIG at it
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	t.Setenv("LOGKV_CONFIG", path)
+	return path
+}
+
+func TestLoad_FileOnly(t *testing.T) {
+	writeConfig(t, `
+data_dir: /var/lib/logkv
+addr: 127.0.0.1:7000
+merge_interval: 5m
+compaction_threshold: 0.25
+request_logging: false
+cors_origins:
+  - https://a.example.com
+`)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "/var/lib/logkv", cfg.DataDir)
+	assert.Equal(t, "127.0.0.1:7000", cfg.HTTPAddr)
+	assert.Equal(t, 5*time.Minute, cfg.MergeInterval)
+	assert.Equal(t, 0.25, cfg.CompactionThreshold)
+	assert.False(t, cfg.RequestLogging)
+	assert.Equal(t, []string{"https://a.example.com"}, cfg.CORSAllowedOrigins)
+
+	// Keys the file leaves out keep their defaults
+	assert.Equal(t, Default().GzipMinSize, cfg.GzipMinSize)
+	assert.Equal(t, Default().SyncMode, cfg.SyncMode)
+}
+
+func TestLoad_EnvOnly(t *testing.T) {
+	t.Setenv("LOGKV_CONFIG", filepath.Join(t.TempDir(), "empty.yaml"))
+	require.NoError(t, os.WriteFile(os.Getenv("LOGKV_CONFIG"), nil, 0o644))
+	t.Setenv("LOGKV_DATA_DIR", "/tmp/logkv")
+	t.Setenv("LOGKV_ADDR", ":9000")
+	t.Setenv("LOGKV_MERGE_INTERVAL", "90s")
+	t.Setenv("LOGKV_MAX_SEGMENT_SIZE", "1048576")
+	t.Setenv("LOGKV_REQUEST_LOGGING", "false")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/logkv", cfg.DataDir)
+	assert.Equal(t, ":9000", cfg.HTTPAddr)
+	assert.Equal(t, 90*time.Second, cfg.MergeInterval)
+	assert.Equal(t, int64(1<<20), cfg.MaxSegmentSize)
+	assert.False(t, cfg.RequestLogging)
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	writeConfig(t, `
+data_dir: /from/file
+addr: :7000
+merge_interval: 5m
+`)
+	t.Setenv("LOGKV_ADDR", ":9000")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, ":9000", cfg.HTTPAddr, "env wins over the file")
+	assert.Equal(t, "/from/file", cfg.DataDir, "file wins over defaults")
+	assert.Equal(t, 5*time.Minute, cfg.MergeInterval)
+	assert.Equal(t, Default().SyncMode, cfg.SyncMode, "defaults fill the rest")
+	assert.Empty(t, cfg.GRPCAddr, "gRPC is off unless configured")
+	assert.Empty(t, cfg.RESPAddr, "RESP is off unless configured")
+}
+
+func TestLoad_FileModes(t *testing.T) {
+	writeConfig(t, `
+dir_mode: 0700
+`)
+	t.Setenv("LOGKV_FILE_MODE", "0600")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), cfg.DirMode)
+	assert.Equal(t, os.FileMode(0o600), cfg.FileMode)
+}
+
+func TestLoad_MissingDefaultFileIsIgnored(t *testing.T) {
+	t.Chdir(t.TempDir())
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoad_MissingExplicitFile(t *testing.T) {
+	t.Setenv("LOGKV_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	_, err := Load()
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestLoad_UnknownFileKey(t *testing.T) {
+	writeConfig(t, "data_dri: /typo\n")
+	_, err := Load()
+	assert.ErrorContains(t, err, "data_dri")
+}
+
+func TestLoad_InvalidEnvValue(t *testing.T) {
+	t.Setenv("LOGKV_MERGE_INTERVAL", "soon")
+	_, err := Load()
+	assert.ErrorContains(t, err, "LOGKV_MERGE_INTERVAL")
+}
+
+func TestValidate_Default(t *testing.T) {
+	assert.NoError(t, Default().Validate())
+}
+
+func TestValidate_MergeInterval(t *testing.T) {
+	cfg := Default()
+	cfg.MergeInterval = 0
+	assert.NoError(t, cfg.Validate(), "0 disables background merges")
+
+	cfg.MergeInterval = -time.Second
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "merge_interval must not be negative, got -1s")
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.DataDir = ""
+	cfg.MaxSegmentSize = -1
+	cfg.MaxEntriesPerSegment = -5
+	cfg.CompactionThreshold = 1.5
+	cfg.CompactionSegmentSize = -1
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, msg := range []string{"data_dir", "max_segment_size", "max_entries_per_segment", "compaction_threshold", "compaction_segment_size"} {
+		assert.Contains(t, err.Error(), msg)
+	}
+}
+
+func TestValidate_SegmentSmallerThanEntry(t *testing.T) {
+	cfg := Default()
+	cfg.MaxKeySize = 256
+	cfg.MaxValueSize = 4096
+	cfg.MaxSegmentSize = 1024
+	assert.ErrorContains(t, cfg.Validate(), "smaller than the largest entry")
+}
+
+func TestValidate_EncryptionKeyTwice(t *testing.T) {
+	cfg := Default()
+	cfg.EncryptionKey = "00"
+	cfg.EncryptionKeyFile = "key.hex"
+	assert.ErrorContains(t, cfg.Validate(), "encryption_key and encryption_key_file")
+}
+
+func TestValidate_FileModes(t *testing.T) {
+	cfg := Default()
+	cfg.DirMode = os.ModeDir | 0o700
+	cfg.FileMode = os.ModeSetuid | 0o600
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "dir_mode must only hold permission bits")
+	assert.ErrorContains(t, err, "file_mode must only hold permission bits")
+}
+
+func TestValidate_NegativeExpirySweepInterval(t *testing.T) {
+	cfg := Default()
+	cfg.ExpirySweepInterval = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "expiry_sweep_interval must not be negative")
+}
+
+func TestValidate_NegativeShutdownTimeout(t *testing.T) {
+	cfg := Default()
+	cfg.ShutdownTimeout = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "shutdown_timeout must not be negative")
+
+	cfg.ShutdownTimeout = 0
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_MaxRequestBody(t *testing.T) {
+	cfg := Default()
+	cfg.MaxKeySize = 100
+	cfg.MaxValueSize = 1000
+	cfg.MaxRequestBody = 500
+	assert.ErrorContains(t, cfg.Validate(), "max_request_body (500) is smaller than the largest entry")
+
+	cfg.MaxRequestBody = -1
+	assert.ErrorContains(t, cfg.Validate(), "max_request_body must not be negative")
+
+	cfg.MaxRequestBody = 4096
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_Logging(t *testing.T) {
+	cfg := Default()
+	cfg.LogLevel = "verbose"
+	cfg.LogFormat = "xml"
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "log_level must be debug, info, warn or error")
+	assert.ErrorContains(t, err, "log_format must be json or console")
+}
+
+func TestLoad_RejectsInvalidConfig(t *testing.T) {
+	t.Setenv("LOGKV_MERGE_INTERVAL", "-1s")
+	_, err := Load()
+	assert.ErrorContains(t, err, "merge_interval must not be negative")
+}
+
+func TestModule_RejectsInvalidConfig(t *testing.T) {
+	app := fx.New(
+		fx.NopLogger,
+		Module(),
+		fx.Decorate(func(cfg *Config) *Config {
+			cfg.DataDir = ""
+			return cfg
+		}),
+	)
+	assert.ErrorContains(t, app.Err(), "data_dir must not be empty")
+}
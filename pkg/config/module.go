@@ -1,7 +1,58 @@
 package config
 
-import "go.uber.org/fx"
+import (
+	"time"
+
+	"go.uber.org/fx"
+)
 
 func Module() fx.Option {
-	return fx.Provide(Load)
+	return fx.Options(
+		fx.Provide(Load),
+		// Checked again once the app is built, in case a decorator changed it
+		fx.Invoke((*Config).Validate),
+	)
+}
+
+// Overrides holds settings given on the command line, which take precedence
+// over the config file and the environment. Zero fields are left alone.
+type Overrides struct {
+	DataDir       string
+	HTTPAddr      string
+	MergeInterval *time.Duration // Set when given, since 0 disables merges
+}
+
+// Apply sets the fields of cfg that o overrides
+func (o Overrides) Apply(cfg *Config) {
+	if o.DataDir != "" {
+		cfg.DataDir = o.DataDir
+	}
+	if o.HTTPAddr != "" {
+		cfg.HTTPAddr = o.HTTPAddr
+	}
+	if o.MergeInterval != nil {
+		cfg.MergeInterval = *o.MergeInterval
+	}
+}
+
+// StopTimeout gives the app long enough to stop: the HTTP server's
+// shutdown_timeout to drain requests, plus fx's default for closing
+// everything else. fx needs the timeout before it builds the Config, so the
+// config is read here just for it; any error is reported once the app
+// builds the Config.
+func StopTimeout(o Overrides) fx.Option {
+	cfg, err := Load()
+	if err != nil {
+		return fx.Options()
+	}
+	o.Apply(cfg)
+	return fx.StopTimeout(cfg.ShutdownTimeout + fx.DefaultTimeout)
+}
+
+// Override decorates the Config provided by Module with o applied
+func Override(o Overrides) fx.Option {
+	return fx.Decorate(func(cfg *Config) *Config {
+		o.Apply(cfg)
+		return cfg
+	})
 }
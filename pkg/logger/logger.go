@@ -1,11 +1,39 @@
 package logger
 
 import (
+	"fmt"
+
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func New(service string) (*zap.Logger, error) {
+	return Build(service, "", "")
+}
+
+// Build returns a logger writing to stdout that drops entries below level
+// (debug, info, warn or error; empty means info) and encodes them as format
+// (json for one JSON object per line, or console; empty means json)
+func Build(service, level, format string) (*zap.Logger, error) {
 	cfg := zap.NewProductionConfig()
 	cfg.OutputPaths = []string{"stdout"}
+
+	if level != "" {
+		lvl, err := zapcore.ParseLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("logger: invalid level %q: %w", level, err)
+		}
+		cfg.Level = zap.NewAtomicLevelAt(lvl)
+	}
+
+	switch format {
+	case "", "json":
+	case "console":
+		cfg.Encoding = "console"
+		cfg.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	default:
+		return nil, fmt.Errorf("logger: invalid format %q", format)
+	}
+
 	return cfg.Build()
 }
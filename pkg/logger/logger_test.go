@@ -1,10 +1,17 @@
 package logger
 
 import (
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestNewLogger_Success(t *testing.T) {
@@ -30,3 +37,59 @@ func TestNewLogger_IndependentInstances(t *testing.T) {
 	// They should be different instances (not pointing to the same logger)
 	assert.NotEqual(t, logger1, logger2)
 }
+
+func TestBuild_LevelFiltersOutput(t *testing.T) {
+	for level, enabled := range map[string][]zapcore.Level{
+		"":      {zap.InfoLevel, zap.WarnLevel, zap.ErrorLevel},
+		"debug": {zap.DebugLevel, zap.InfoLevel, zap.WarnLevel, zap.ErrorLevel},
+		"warn":  {zap.WarnLevel, zap.ErrorLevel},
+		"error": {zap.ErrorLevel},
+	} {
+		t.Run(level, func(t *testing.T) {
+			logger, err := Build("test-service", level, "json")
+			require.NoError(t, err)
+			for _, lvl := range []zapcore.Level{zap.DebugLevel, zap.InfoLevel, zap.WarnLevel, zap.ErrorLevel} {
+				assert.Equal(t, slices.Contains(enabled, lvl), logger.Core().Enabled(lvl), lvl.String())
+			}
+		})
+	}
+}
+
+func TestBuild_WritesJSONLines(t *testing.T) {
+	// The stdout sink is bound when the logger is built
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	logger, err := Build("test-service", "warn", "json")
+	os.Stdout = stdout
+	require.NoError(t, err)
+
+	logger.Info("dropped")
+	logger.Warn("kept", zap.String("key", "k"))
+	_ = logger.Sync()
+	require.NoError(t, w.Close())
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	require.Len(t, lines, 1, "Only the warning should be written")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "warn", entry["level"])
+	assert.Equal(t, "kept", entry["msg"])
+	assert.Equal(t, "k", entry["key"])
+}
+
+func TestBuild_Invalid(t *testing.T) {
+	_, err := Build("test-service", "loud", "")
+	assert.ErrorContains(t, err, "invalid level")
+
+	_, err = Build("test-service", "", "xml")
+	assert.ErrorContains(t, err, "invalid format")
+
+	logger, err := Build("test-service", "info", "console")
+	require.NoError(t, err)
+	assert.NotNil(t, logger)
+}
@@ -1,14 +1,15 @@
 package logger
 
 import (
+	"github.com/himakhaitan/logkv-store/pkg/config"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
 func Module(service string) fx.Option {
 	return fx.Provide(
-		func() (*zap.Logger, error) {
-			return New(service)
+		func(cfg *config.Config) (*zap.Logger, error) {
+			return Build(service, cfg.LogLevel, cfg.LogFormat)
 		},
 	)
 }
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Operations counted by Metrics
+const (
+	OpGet    = "get"
+	OpSet    = "set"
+	OpDelete = "delete"
+)
+
+// Operation results
+const (
+	ResultHit   = "hit"   // A get or delete found its key
+	ResultMiss  = "miss"  // A get or delete didn't find its key
+	ResultOK    = "ok"    // A set succeeded
+	ResultError = "error" // The operation failed
+)
+
+// Metrics holds the Prometheus collectors for the store. A nil *Metrics is
+// valid and records nothing.
+type Metrics struct {
+	registry        *prometheus.Registry
+	operations      *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	compactions     prometheus.Counter
+}
+
+// New creates the collectors and registers them with a new registry, along
+// with the Go runtime and process collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "logkv_operations_total",
+			Help: "Key operations by type and result.",
+		}, []string{"op", "result"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "logkv_http_request_duration_seconds",
+			Help:    "HTTP request latency by method, route and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "code"}),
+		compactions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "logkv_compactions_total",
+			Help: "Compactions run on request.",
+		}),
+	}
+	m.registry.MustRegister(
+		m.operations,
+		m.requestDuration,
+		m.compactions,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the registered metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Count adds n operations with the given result
+func (m *Metrics) Count(op, result string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.operations.WithLabelValues(op, result).Add(float64(n))
+}
+
+// Lookup counts a get or delete as a hit, a miss or an error depending on err
+func (m *Metrics) Lookup(op string, err error) {
+	switch {
+	case err == nil:
+		m.Count(op, ResultHit, 1)
+	case errors.Is(err, store.ErrKeyNotFound):
+		m.Count(op, ResultMiss, 1)
+	default:
+		m.Count(op, ResultError, 1)
+	}
+}
+
+// Write counts a set as ok or as an error depending on err
+func (m *Metrics) Write(op string, err error) {
+	if err != nil {
+		m.Count(op, ResultError, 1)
+		return
+	}
+	m.Count(op, ResultOK, 1)
+}
+
+// Compaction counts a completed compaction
+func (m *Metrics) Compaction() {
+	if m == nil {
+		return
+	}
+	m.compactions.Inc()
+}
+
+// ObserveRequest records the latency of an HTTP request. route is the mux
+// pattern that served it, which keeps the label set bounded.
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.requestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// RegisterStats exports the key and segment counts reported by stats as
+// gauges, read on every scrape
+func (m *Metrics) RegisterStats(stats func() (store.Stats, error)) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&statsCollector{stats: stats})
+}
+
+var (
+	keysDesc     = prometheus.NewDesc("logkv_keys", "Live keys in the store.", nil, nil)
+	segmentsDesc = prometheus.NewDesc("logkv_segments", "Segment files on disk.", nil, nil)
+)
+
+// statsCollector reads its gauges from store.Stats
+type statsCollector struct {
+	stats func() (store.Stats, error)
+}
+
+// Describe sends the gauge descriptors
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- keysDesc
+	ch <- segmentsDesc
+}
+
+// Collect fetches stats once and sends both gauges
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := c.stats()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(keysDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(keysDesc, prometheus.GaugeValue, float64(stats.TotalKeys))
+	ch <- prometheus.MustNewConstMetric(segmentsDesc, prometheus.GaugeValue, float64(stats.Segments))
+}
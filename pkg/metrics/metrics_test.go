@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/store"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Operations(t *testing.T) {
+	m := New()
+
+	m.Lookup(OpGet, nil)
+	m.Lookup(OpGet, fmt.Errorf("get: %w", store.ErrKeyNotFound))
+	m.Lookup(OpDelete, errors.New("disk on fire"))
+	m.Write(OpSet, nil)
+	m.Count(OpSet, ResultOK, 2)
+	m.Compaction()
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.operations.WithLabelValues(OpGet, ResultHit)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.operations.WithLabelValues(OpGet, ResultMiss)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.operations.WithLabelValues(OpDelete, ResultError)))
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.operations.WithLabelValues(OpSet, ResultOK)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.compactions))
+}
+
+func TestMetrics_StatsGauges(t *testing.T) {
+	m := New()
+	m.RegisterStats(func() (store.Stats, error) {
+		return store.Stats{TotalKeys: 42, Segments: 3}, nil
+	})
+
+	expected := `
+# HELP logkv_keys Live keys in the store.
+# TYPE logkv_keys gauge
+logkv_keys 42
+# HELP logkv_segments Segment files on disk.
+# TYPE logkv_segments gauge
+logkv_segments 3
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "logkv_keys", "logkv_segments"))
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := New()
+	m.ObserveRequest(http.MethodGet, "/v1/keys", http.StatusOK, 5*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `logkv_http_request_duration_seconds_count{code="200",method="GET",route="/v1/keys"} 1`)
+}
+
+func TestMetrics_NilIsNoop(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.Lookup(OpGet, nil)
+		m.Write(OpSet, nil)
+		m.Compaction()
+		m.ObserveRequest(http.MethodGet, "/", http.StatusOK, time.Second)
+		m.RegisterStats(func() (store.Stats, error) { return store.Stats{}, nil })
+	})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
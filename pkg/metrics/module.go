@@ -0,0 +1,7 @@
+package metrics
+
+import "go.uber.org/fx"
+
+func Module() fx.Option {
+	return fx.Provide(New)
+}
@@ -0,0 +1,26 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	info := Get()
+	assert.Equal(t, Version, info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.NotEmpty(t, info.Commit)
+	assert.NotEmpty(t, info.BuildDate)
+}
+
+func TestGet_LinkerValuesWin(t *testing.T) {
+	oldCommit, oldDate := Commit, BuildDate
+	t.Cleanup(func() { Commit, BuildDate = oldCommit, oldDate })
+	Commit, BuildDate = "abc1234", "2026-01-02T03:04:05Z"
+
+	info := Get()
+	assert.Equal(t, "abc1234", info.Commit)
+	assert.Equal(t, "2026-01-02T03:04:05Z", info.BuildDate)
+}
@@ -0,0 +1,12 @@
+package resp
+
+import "go.uber.org/fx"
+
+// Module provides the RESP server wired with fx. It expects an *engine.DB to
+// be provided elsewhere, e.g. by server.Module.
+func Module() fx.Option {
+	return fx.Options(
+		fx.Provide(NewServer),
+		fx.Invoke(RegisterHooks),
+	)
+}
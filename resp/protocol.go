@@ -0,0 +1,147 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBulkLen is the largest bulk string accepted, matching Redis
+	maxBulkLen = 512 << 20
+
+	// maxArrayLen is the largest number of arguments accepted in one command
+	maxArrayLen = 1 << 20
+
+	// maxInlineLen is the longest inline command line accepted
+	maxInlineLen = 64 << 10
+)
+
+// ErrProtocol is returned when a client sends something that isn't RESP
+var ErrProtocol = errors.New("protocol error")
+
+// readCommand reads one command, either as a RESP array of bulk strings or as
+// an inline command line. It returns no arguments for an empty inline line.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	prefix, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if prefix[0] != '*' {
+		line, err := readLine(r, maxInlineLen)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Fields(line), nil
+	}
+
+	line, err := readLine(r, maxInlineLen)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count > maxArrayLen {
+		return nil, fmt.Errorf("%w: invalid multibulk length", ErrProtocol)
+	}
+
+	args := make([]string, 0, max(count, 0))
+	for range count {
+		arg, err := readBulk(r)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// readBulk reads a single bulk string argument
+func readBulk(r *bufio.Reader) (string, error) {
+	line, err := readLine(r, maxInlineLen)
+	if err != nil {
+		return "", err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return "", fmt.Errorf("%w: expected '$', got '%s'", ErrProtocol, truncate(line))
+	}
+	size, err := strconv.Atoi(line[1:])
+	if err != nil || size < 0 || size > maxBulkLen {
+		return "", fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return "", fmt.Errorf("%w: bulk string not terminated by CRLF", ErrProtocol)
+	}
+	return string(data[:size]), nil
+}
+
+// readLine reads a CRLF or LF terminated line without its terminator
+func readLine(r *bufio.Reader, limit int) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > limit {
+			return "", fmt.Errorf("%w: line too long", ErrProtocol)
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+// truncate shortens client input quoted in error replies
+func truncate(s string) string {
+	if len(s) > 32 {
+		return s[:32] + "..."
+	}
+	return s
+}
+
+// writer encodes RESP replies
+type writer struct {
+	*bufio.Writer
+}
+
+// simple writes a simple string reply
+func (w writer) simple(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+// error writes an error reply. Line breaks are replaced so the reply stays one line.
+func (w writer) error(msg string) {
+	w.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(msg) + "\r\n")
+}
+
+// integer writes an integer reply
+func (w writer) integer(n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+// bulk writes a bulk string reply
+func (w writer) bulk(s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+// null writes a null bulk string reply
+func (w writer) null() {
+	w.WriteString("$-1\r\n")
+}
+
+// array writes an array reply of bulk strings
+func (w writer) array(items []string) {
+	w.WriteString("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, item := range items {
+		w.bulk(item)
+	}
+}
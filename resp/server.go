@@ -0,0 +1,339 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/engine"
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/store"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Server speaks a subset of the Redis protocol on top of engine.DB
+type Server struct {
+	db     *engine.DB
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a RESP server backed by db
+func NewServer(db *engine.DB, logger *zap.Logger) *Server {
+	return &Server{
+		db:     db,
+		logger: logger,
+		conns:  make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve accepts connections on listener until Close is called. It returns nil
+// once the server is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return listener.Close()
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops accepting connections, closes open ones and waits for their
+// handlers to return
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// handleConn serves commands from one client. Pipelined commands are answered
+// in order, and replies are flushed once no more input is buffered.
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := writer{bufio.NewWriter(conn)}
+
+	for {
+		args, err := readCommand(r)
+		if errors.Is(err, ErrProtocol) {
+			// Like Redis, report the error and drop the connection since the
+			// stream can't be resynchronized
+			w.error("ERR " + err.Error())
+			w.Flush()
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Debug("RESP connection closed", zap.Error(err))
+			}
+			return
+		}
+
+		quit := false
+		if len(args) > 0 {
+			quit = s.execute(w, args)
+		}
+
+		if quit || r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// command describes a supported command and how many arguments it takes
+type command struct {
+	minArgs int
+	maxArgs int // -1 = unbounded
+	run     func(s *Server, w writer, args []string)
+}
+
+// commands maps upper-cased command names to their handlers
+var commands = map[string]command{
+	"PING":    {0, 1, (*Server).ping},
+	"COMMAND": {0, -1, (*Server).command},
+	"GET":     {1, 1, (*Server).get},
+	"SET":     {2, 4, (*Server).set},
+	"DEL":     {1, -1, (*Server).del},
+	"EXISTS":  {1, -1, (*Server).exists},
+	"KEYS":    {1, 1, (*Server).keys},
+}
+
+// execute runs one command and writes its reply. It reports whether the
+// client asked to close the connection.
+func (s *Server) execute(w writer, args []string) bool {
+	name := strings.ToUpper(args[0])
+	if name == "QUIT" {
+		w.simple("OK")
+		return true
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		w.error("ERR unknown command '" + truncate(args[0]) + "'")
+		return false
+	}
+	args = args[1:]
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+		return false
+	}
+
+	cmd.run(s, w, args)
+	return false
+}
+
+// ping handles PING [message]
+func (s *Server) ping(w writer, args []string) {
+	if len(args) > 0 {
+		w.bulk(args[0])
+		return
+	}
+	w.simple("PONG")
+}
+
+// command handles COMMAND, which redis-cli sends on startup; an empty reply is enough
+func (s *Server) command(w writer, args []string) {
+	w.array(nil)
+}
+
+// get handles GET key
+func (s *Server) get(w writer, args []string) {
+	value, err := s.db.Get(args[0])
+	switch {
+	case errors.Is(err, store.ErrKeyNotFound):
+		w.null()
+	case err != nil:
+		s.replyError(w, err)
+	default:
+		w.bulk(value)
+	}
+}
+
+// del handles DEL key [key ...], replying with the number of keys removed
+func (s *Server) del(w writer, args []string) {
+	var deleted int64
+	for _, key := range args {
+		err := s.db.Delete(key)
+		if errors.Is(err, store.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			s.replyError(w, err)
+			return
+		}
+		deleted++
+	}
+	w.integer(deleted)
+}
+
+// exists handles EXISTS key [key ...], replying with the number of keys found
+func (s *Server) exists(w writer, args []string) {
+	var found int64
+	for _, key := range args {
+		exists, err := s.db.Exists(key)
+		if err != nil {
+			s.replyError(w, err)
+			return
+		}
+		if exists {
+			found++
+		}
+	}
+	w.integer(found)
+}
+
+// keys handles KEYS pattern
+func (s *Server) keys(w writer, args []string) {
+	keys, err := s.db.List()
+	if err != nil {
+		s.replyError(w, err)
+		return
+	}
+	matched := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if match(args[0], key) {
+			matched = append(matched, key)
+		}
+	}
+	w.array(matched)
+}
+
+// set handles SET key value [EX seconds | PX milliseconds]
+func (s *Server) set(w writer, args []string) {
+	key, value := args[0], args[1]
+	var ttl time.Duration
+
+	switch len(args) {
+	case 2:
+	case 4:
+		n, err := strconv.ParseInt(args[3], 10, 64)
+		if err != nil || n <= 0 {
+			w.error("ERR invalid expire time in 'set' command")
+			return
+		}
+		switch strings.ToUpper(args[2]) {
+		case "EX":
+			ttl = time.Duration(n) * time.Second
+		case "PX":
+			ttl = time.Duration(n) * time.Millisecond
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	default:
+		w.error("ERR syntax error")
+		return
+	}
+
+	var err error
+	if ttl > 0 {
+		err = s.db.SetWithTTL(key, value, ttl)
+	} else {
+		err = s.db.Set(key, value)
+	}
+	if err != nil {
+		s.replyError(w, err)
+		return
+	}
+	w.simple("OK")
+}
+
+// replyError writes a store error as an error reply
+func (s *Server) replyError(w writer, err error) {
+	switch {
+	case errors.Is(err, store.ErrEmptyKey), errors.Is(err, store.ErrKeyTooLarge), errors.Is(err, store.ErrValueTooLarge), errors.Is(err, store.ErrInvalidTTL):
+		w.error("ERR " + err.Error())
+	default:
+		s.logger.Error("RESP command failed", zap.Error(err))
+		w.error("ERR internal error")
+	}
+}
+
+// RegisterHooks starts and stops the RESP server using fx Lifecycle. The
+// server is not started when no address is configured.
+func RegisterHooks(lc fx.Lifecycle, server *Server, cfg *config.Config, logger *zap.Logger) {
+	if cfg.RESPAddr == "" {
+		logger.Info("RESP server disabled")
+		return
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			listener, err := net.Listen("tcp", cfg.RESPAddr)
+			if err != nil {
+				return err
+			}
+			logger.Info("Starting RESP server", zap.String("addr", listener.Addr().String()))
+			go func() {
+				if err := server.Serve(listener); err != nil {
+					logger.Error("RESP server failed", zap.Error(err))
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Stopping RESP server")
+			return server.Close()
+		},
+	})
+}
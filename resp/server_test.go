@@ -0,0 +1,131 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/engine"
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/zap/zaptest"
+)
+
+// setupRESPServer serves a store in a temp dir over RESP and returns a
+// connection to it and a reader for its replies
+func setupRESPServer(t *testing.T) (net.Conn, *bufio.Reader) {
+	logger := zaptest.NewLogger(t)
+
+	s, err := store.New(logger, &config.Config{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	server := NewServer(&engine.DB{Store: s}, logger)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	return conn, bufio.NewReader(conn)
+}
+
+// send writes raw protocol data to the server
+func send(t *testing.T, conn net.Conn, data string) {
+	_, err := conn.Write([]byte(data))
+	require.NoError(t, err)
+}
+
+// expect reads len(want) bytes of reply and compares them to want
+func expect(t *testing.T, r *bufio.Reader, want string) {
+	got := make([]byte, len(want))
+	_, err := io.ReadFull(r, got)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+}
+
+func TestServer_Commands(t *testing.T) {
+	conn, r := setupRESPServer(t)
+
+	send(t, conn, "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n")
+	expect(t, r, "+OK\r\n")
+
+	send(t, conn, "*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n")
+	expect(t, r, "$3\r\nbar\r\n")
+
+	send(t, conn, "*3\r\n$6\r\nEXISTS\r\n$3\r\nfoo\r\n$7\r\nmissing\r\n")
+	expect(t, r, ":1\r\n")
+
+	send(t, conn, "*2\r\n$4\r\nKEYS\r\n$2\r\nf*\r\n")
+	expect(t, r, "*1\r\n$3\r\nfoo\r\n")
+
+	send(t, conn, "*3\r\n$3\r\nDEL\r\n$3\r\nfoo\r\n$7\r\nmissing\r\n")
+	expect(t, r, ":1\r\n")
+
+	send(t, conn, "*2\r\n$3\r\nget\r\n$3\r\nfoo\r\n")
+	expect(t, r, "$-1\r\n")
+
+	send(t, conn, "*5\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n$2\r\nEX\r\n$2\r\n60\r\n")
+	expect(t, r, "+OK\r\n")
+
+	send(t, conn, "*1\r\n$4\r\nQUIT\r\n")
+	expect(t, r, "+OK\r\n")
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF, "QUIT should close the connection")
+}
+
+func TestServer_Pipelining(t *testing.T) {
+	conn, r := setupRESPServer(t)
+
+	// Several commands in one write, including an inline one
+	send(t, conn, "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n"+
+		"*2\r\n$3\r\nGET\r\n$1\r\na\r\n"+
+		"PING\r\n"+
+		"*2\r\n$3\r\nGET\r\n$1\r\nb\r\n")
+
+	expect(t, r, "+OK\r\n$1\r\n1\r\n+PONG\r\n$-1\r\n")
+}
+
+func TestServer_Errors(t *testing.T) {
+	conn, r := setupRESPServer(t)
+
+	send(t, conn, "*1\r\n$5\r\nFLUSH\r\n")
+	expect(t, r, "-ERR unknown command 'FLUSH'\r\n")
+
+	send(t, conn, "*1\r\n$3\r\nGET\r\n")
+	expect(t, r, "-ERR wrong number of arguments for 'get' command\r\n")
+
+	send(t, conn, "*4\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n$2\r\nNX\r\n")
+	expect(t, r, "-ERR syntax error\r\n")
+
+	send(t, conn, "*3\r\n$3\r\nSET\r\n$0\r\n\r\n$1\r\nv\r\n")
+	expect(t, r, "-ERR key must not be empty\r\n")
+
+	// Malformed input is reported, then the connection is dropped
+	send(t, conn, "*1\r\n+GET\r\n")
+	expect(t, r, "-ERR protocol error: expected '$', got '+GET'\r\n")
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestRegisterHooksLifecycle(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	server := NewServer(nil, logger)
+
+	lc := fxtest.NewLifecycle(t)
+	RegisterHooks(lc, server, &config.Config{RESPAddr: "127.0.0.1:0"}, logger)
+
+	ctx := context.Background()
+	assert.NoError(t, lc.Start(ctx))
+	assert.NoError(t, lc.Stop(ctx))
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/engine"
+	"github.com/himakhaitan/logkv-store/store"
+	"github.com/himakhaitan/logkv-store/types"
+	"go.uber.org/zap"
+)
+
+// statusClientClosedRequest is the non-standard status, borrowed from nginx,
+// for a request abandoned because the client went away
+const statusClientClosedRequest = 499
+
+// statusForError maps an error from the engine or store to the HTTP status
+// it should be reported with. Anything unrecognised is an internal error.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, store.ErrKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrKeyTooLarge), errors.Is(err, store.ErrValueTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, store.ErrEmptyKey),
+		errors.Is(err, store.ErrInvalidTTL),
+		errors.Is(err, store.ErrContentTypeTooLarge),
+		errors.Is(err, store.ErrNotInteger),
+		errors.Is(err, store.ErrIntegerOverflow),
+		errors.Is(err, engine.ErrInvalidNamespace):
+		return http.StatusBadRequest
+	case errors.Is(err, store.ErrMergeInProgress):
+		return http.StatusConflict
+	case errors.Is(err, store.ErrReadOnly):
+		return http.StatusForbidden
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// decodeErrorMessage describes why a JSON request body could not be decoded,
+// precisely enough for the client to fix the request
+func decodeErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("invalid json at offset %d: %v", syntaxErr.Offset, syntaxErr)
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		return fmt.Sprintf("invalid json: expected an object, got %s", typeErr.Value)
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("invalid json: field %q must be a %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	case errors.Is(err, io.EOF):
+		return "empty request body"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "invalid json: unexpected end of input"
+	default:
+		return fmt.Sprintf("unreadable request body: %v", err)
+	}
+}
+
+// writeError replies with the status for err. Internal errors are logged and
+// reported without detail; anything else is the client's to fix, so the
+// message is passed on.
+func writeError(w http.ResponseWriter, r *http.Request, logger *zap.Logger, err error) {
+	status := statusForError(err)
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		logger.Error("Request failed", zap.String("method", r.Method), zap.String("path", r.URL.Path), zap.Error(err))
+		message = "Internal Server Error"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: message, Timestamp: time.Now().Unix()})
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/himakhaitan/logkv-store/engine"
+	"github.com/himakhaitan/logkv-store/store"
+	"github.com/himakhaitan/logkv-store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStatusForError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{store.ErrKeyNotFound, http.StatusNotFound},
+		{fmt.Errorf("get: %w", store.ErrKeyNotFound), http.StatusNotFound},
+		{store.ErrEmptyKey, http.StatusBadRequest},
+		{store.ErrInvalidTTL, http.StatusBadRequest},
+		{store.ErrNotInteger, http.StatusBadRequest},
+		{store.ErrIntegerOverflow, http.StatusBadRequest},
+		{fmt.Errorf("%w: %q", engine.ErrInvalidNamespace, "a/b"), http.StatusBadRequest},
+		{fmt.Errorf("%w: 300 bytes", store.ErrKeyTooLarge), http.StatusRequestEntityTooLarge},
+		{fmt.Errorf(`key "k": %w`, store.ErrValueTooLarge), http.StatusRequestEntityTooLarge},
+		{&store.BatchError{Written: 2, Err: store.ErrValueTooLarge}, http.StatusRequestEntityTooLarge},
+		{store.ErrMergeInProgress, http.StatusConflict},
+		{fmt.Errorf("set: %w", store.ErrReadOnly), http.StatusForbidden},
+		{context.Canceled, statusClientClosedRequest},
+		{fmt.Errorf("compact: %w", context.DeadlineExceeded), http.StatusServiceUnavailable},
+		{fmt.Errorf("failed to read entry: %w", store.ErrSegmentNotFound), http.StatusInternalServerError},
+		{store.ErrCorruptEntry, http.StatusInternalServerError},
+		{fmt.Errorf("failed to write entry: %w", os.ErrClosed), http.StatusInternalServerError},
+		{errors.New("something else"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, statusForError(tc.err), tc.err.Error())
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	rec := httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodGet, "/v1/kv/a", nil), logger, fmt.Errorf("%w: 300 bytes", store.ErrKeyTooLarge))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	var res types.BaseResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, "key too large: 300 bytes", res.Message, "client errors explain themselves")
+
+	rec = httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodGet, "/v1/kv/a", nil), logger, errors.New("disk on fire"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, "Internal Server Error", res.Message, "internal errors are not leaked")
+
+	rec = httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodHead, "/v1/kv/a", nil), logger, store.ErrKeyNotFound)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Zero(t, rec.Body.Len(), "HEAD replies have no body")
+}
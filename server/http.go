@@ -3,32 +3,169 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
-	"os"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/himakhaitan/logkv-store/engine"
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/pkg/version"
+	"github.com/himakhaitan/logkv-store/store"
 	"github.com/himakhaitan/logkv-store/types"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
-// NewMux constructs the HTTP mux with all routes
-func NewMux(db *engine.DB, logger *zap.Logger) *http.ServeMux {
+// NewMux constructs the HTTP handler with all routes, wrapped in the
+// configured middleware. The key-value routes are served for the default
+// database under /v1 and for each namespace under /v1/ns/{namespace}.
+func NewMux(dbs *engine.Manager, cfg *config.Config, logger *zap.Logger) http.Handler {
 	mux := http.NewServeMux()
 
-	// Health Check Route
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	// GET /livez reports only that the process is serving requests
+	mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
 
-	// GET or DELETE /v1/kv/{key}
+	// GET /readyz (and /health) checks that the default database can serve
+	// requests
+	ready := func(w http.ResponseWriter, r *http.Request) {
+		handleReady(w, r, dbs.Default, logger)
+	}
+	mux.HandleFunc("/readyz", ready)
+	mux.HandleFunc("/health", ready)
+
+	// Prometheus metrics
+	mux.Handle("/metrics", dbs.Default.Metrics.Handler())
+
+	// GET /v1/version
+	mux.HandleFunc("/v1/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		info := version.Get()
+		_ = json.NewEncoder(w).Encode(types.VersionResponse{
+			Version:   info.Version,
+			Commit:    info.Commit,
+			BuildDate: info.BuildDate,
+			GoVersion: info.GoVersion,
+			BaseResponse: types.BaseResponse{
+				Success:   true,
+				Timestamp: time.Now().Unix(),
+				Message:   "version fetched successfully",
+			},
+		})
+	})
+
+	// GET /v1/ping reports uptime and version with the default database's
+	// key and segment counts, for monitoring in a single call
+	mux.HandleFunc("/v1/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		stats, err := dbs.Default.Stats()
+		if err != nil {
+			writeError(w, r, logger, err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(types.PingResponse{
+			Version:       version.Version,
+			UptimeSeconds: time.Since(startTime).Seconds(),
+			Keys:          stats.TotalKeys,
+			Segments:      stats.Segments,
+			BaseResponse: types.BaseResponse{
+				Success:   true,
+				Timestamp: time.Now().Unix(),
+				Message:   "pong",
+			},
+		})
+	})
+
+	maxBody := maxSetBody(cfg)
+	registerDBRoutes(mux, dbs.Default, logger, maxBody)
+
+	// /v1/ns/{namespace}/...
+	mux.Handle("/v1/ns/", &namespaceRouter{dbs: dbs, logger: logger, maxBody: maxBody, muxes: make(map[string]http.Handler)})
+
+	var requestLogger *zap.Logger
+	if cfg.RequestLogging {
+		requestLogger = logger
+	}
+	return Chain(mux,
+		LogRequests(requestLogger),
+		InstrumentRequests(dbs.Default.Metrics),
+		Gzip(cfg.GzipMinSize),
+		CORS(cfg.CORSAllowedOrigins, cfg.CORSAllowedMethods, cfg.CORSAllowedHeaders),
+		RequireToken(cfg.AuthToken, "/health", "/livez", "/readyz"),
+	)
+}
+
+// DefaultMaxRequestBody is the largest set request body read when the config
+// sets no limit and allows no entry that needs more (8 MiB)
+const DefaultMaxRequestBody = 8 << 20
+
+// maxSetBody returns the largest set request body to read. Without a
+// configured limit it leaves room for the largest entry the store accepts:
+// JSON escaping can take six bytes per byte of key or value ("\u0000").
+func maxSetBody(cfg *config.Config) int64 {
+	if cfg.MaxRequestBody > 0 {
+		return cfg.MaxRequestBody
+	}
+	limit := int64(DefaultMaxRequestBody)
+	if cfg.MaxValueSize > 0 {
+		limit = max(limit, 6*int64(cfg.MaxKeySize+cfg.MaxValueSize)+64)
+	}
+	return limit
+}
+
+// registerDBRoutes adds the routes that operate on a single database. Set
+// request bodies larger than maxBody are refused.
+func registerDBRoutes(mux *http.ServeMux, db *engine.DB, logger *zap.Logger, maxBody int64) {
+	// GET /v1/kv/{key}[?raw=true], HEAD or DELETE /v1/kv/{key}[?force=true],
+	// GET or HEAD /v1/kv/{key}/exists,
+	// POST /v1/kv/{key}/incr, POST /v1/kv/{key}/append,
+	// POST /v1/kv/{key}/copy, POST /v1/kv/{key}/rename
 	mux.HandleFunc("/v1/kv/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		// Extract key from URL
-		key := r.URL.Path[len("/v1/kv/"):]
+		key, action, err := parseKeyPath(r.URL)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: err.Error(), Timestamp: time.Now().Unix()})
+			return
+		}
+		switch {
+		case action == "incr" && r.Method == http.MethodPost:
+			handleIncr(w, r, db, logger, key)
+			return
+		case action == "append" && r.Method == http.MethodPost:
+			handleAppend(w, r, db, logger, key)
+			return
+		case (action == "copy" || action == "rename") && r.Method == http.MethodPost:
+			handleCopy(w, r, db, logger, key, action == "rename")
+			return
+		case action == "exists" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+			handleExists(w, r, db, logger, key)
+			return
+		case action != "":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
 		if key == "" {
 			w.WriteHeader(http.StatusBadRequest)
 			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "missing key", Timestamp: time.Now().Unix()})
@@ -36,18 +173,68 @@ func NewMux(db *engine.DB, logger *zap.Logger) *http.ServeMux {
 		}
 		switch r.Method {
 		case http.MethodGet:
-			value, err := db.Get(key)
+			// ?raw=true returns the value itself with its stored content type
+			raw := false
+			if r.URL.Query().Has("raw") {
+				raw, err = strconv.ParseBool(r.URL.Query().Get("raw"))
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "raw must be a boolean", Timestamp: time.Now().Unix()})
+					return
+				}
+			}
+			value, meta, err := db.GetWithMetaCtx(r.Context(), key)
 			if err != nil {
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: err.Error(), Timestamp: time.Now().Unix()})
+				writeError(w, r, logger, err)
+				return
+			}
+			etag := entryETag(meta)
+			w.Header().Set("ETag", etag)
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			if raw {
+				contentType := meta.ContentType
+				if contentType == "" {
+					contentType = "application/octet-stream"
+				}
+				w.Header().Set("Content-Type", contentType)
+				w.Header().Set("Content-Length", strconv.Itoa(len(value)))
+				w.WriteHeader(http.StatusOK)
+				_, _ = io.WriteString(w, value)
 				return
 			}
 			w.WriteHeader(http.StatusOK)
-			_ = json.NewEncoder(w).Encode(types.GetResponse{Key: key, Value: value, BaseResponse: types.BaseResponse{Success: true, Timestamp: time.Now().Unix(), Message: "key fetched successfully"}})
-		case http.MethodDelete:
-			if err := db.Delete(key); err != nil {
+			_ = json.NewEncoder(w).Encode(types.GetResponse{Key: key, Value: value, Timestamp: int64(meta.Timestamp), ContentType: meta.ContentType, BaseResponse: types.BaseResponse{Success: true, Timestamp: time.Now().Unix(), Message: "key fetched successfully"}})
+		case http.MethodHead:
+			exists, err := db.Exists(key)
+			switch {
+			case err != nil:
+				writeError(w, r, logger, err)
+			case !exists:
 				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: err.Error(), Timestamp: time.Now().Unix()})
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+		case http.MethodDelete:
+			// ?force=true makes the delete succeed whether or not the key exists
+			force := false
+			if r.URL.Query().Has("force") {
+				force, err = strconv.ParseBool(r.URL.Query().Get("force"))
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "force must be a boolean", Timestamp: time.Now().Unix()})
+					return
+				}
+			}
+			if force {
+				_, err = db.DeleteIfExists(key)
+			} else {
+				err = db.Delete(key)
+			}
+			if err != nil {
+				writeError(w, r, logger, err)
 				return
 			}
 			w.WriteHeader(http.StatusNoContent)
@@ -66,26 +253,106 @@ func NewMux(db *engine.DB, logger *zap.Logger) *http.ServeMux {
 			return
 		}
 		var req types.SetRequest
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), Timestamp: time.Now().Unix()})
+				return
+			}
 			w.WriteHeader(http.StatusBadRequest)
-			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "invalid json", Timestamp: time.Now().Unix()})
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: decodeErrorMessage(err), Timestamp: time.Now().Unix()})
 			return
 		}
 		if req.Key == "" {
 			w.WriteHeader(http.StatusBadRequest)
-			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "missing key", Timestamp: time.Now().Unix()})
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: `missing required field "key"`, Timestamp: time.Now().Unix()})
 			return
 		}
 
-		if err := db.Set(req.Key, req.Value); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: err.Error(), Timestamp: time.Now().Unix()})
+		if err := db.SetWithContentType(req.Key, req.Value, req.ContentType); err != nil {
+			writeError(w, r, logger, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 	})
 
-	// GET /v1/keys
+	// POST /v1/kv/batch-get
+	mux.HandleFunc("/v1/kv/batch-get", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		var req types.MultiGetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "invalid json", Timestamp: time.Now().Unix()})
+			return
+		}
+		if len(req.Keys) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "missing keys", Timestamp: time.Now().Unix()})
+			return
+		}
+
+		values, missing, err := db.MultiGetCtx(r.Context(), req.Keys)
+		if err != nil {
+			writeError(w, r, logger, err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(types.MultiGetResponse{
+			Values:  values,
+			Missing: missing,
+			BaseResponse: types.BaseResponse{
+				Success:   true,
+				Timestamp: time.Now().Unix(),
+				Message:   "keys fetched successfully",
+			},
+		})
+	})
+
+	// POST /v1/kv/batch-delete
+	mux.HandleFunc("/v1/kv/batch-delete", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		var req types.BatchDeleteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "invalid json", Timestamp: time.Now().Unix()})
+			return
+		}
+		if len(req.Keys) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "missing keys", Timestamp: time.Now().Unix()})
+			return
+		}
+
+		deleted, err := db.DeleteBatch(req.Keys)
+		if err != nil {
+			writeError(w, r, logger, err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(types.BatchDeleteResponse{
+			Deleted: deleted,
+			Missing: len(req.Keys) - deleted,
+			BaseResponse: types.BaseResponse{
+				Success:   true,
+				Timestamp: time.Now().Unix(),
+				Message:   "keys deleted successfully",
+			},
+		})
+	})
+
+	// GET /v1/keys?prefix= or GET /v1/keys?limit=&cursor=, either with &meta=true
+	// for key metadata instead of bare keys
 	mux.HandleFunc("/v1/keys", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
@@ -94,15 +361,64 @@ func NewMux(db *engine.DB, logger *zap.Logger) *http.ServeMux {
 			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
 			return
 		}
-		keys, err := db.List()
+		query := r.URL.Query()
+		paged := query.Has("limit") || query.Has("cursor")
+		limit := 0
+		if query.Has("limit") {
+			n, err := strconv.Atoi(query.Get("limit"))
+			if err != nil || n <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "limit must be a positive integer", Timestamp: time.Now().Unix()})
+				return
+			}
+			limit = n
+		}
+		prefix := query.Get("prefix")
+		if paged && prefix != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "prefix cannot be combined with limit or cursor", Timestamp: time.Now().Unix()})
+			return
+		}
+
+		meta := false
+		if query.Has("meta") {
+			var err error
+			meta, err = strconv.ParseBool(query.Get("meta"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "meta must be a boolean", Timestamp: time.Now().Unix()})
+				return
+			}
+		}
+		if meta && paged {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "meta cannot be combined with limit or cursor", Timestamp: time.Now().Unix()})
+			return
+		}
+		if meta {
+			handleListMeta(w, r, db, logger, prefix)
+			return
+		}
+
+		var keys []string
+		var nextCursor string
+		var err error
+		switch {
+		case paged:
+			keys, nextCursor, err = db.ListPage(query.Get("cursor"), limit)
+		case prefix != "":
+			keys, err = db.ScanCtx(r.Context(), prefix)
+		default:
+			keys, err = db.List()
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Internal Server Error", Timestamp: time.Now().Unix()})
+			writeError(w, r, logger, err)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(types.ListKeysResponse{
-			Keys: keys,
+			Keys:       keys,
+			NextCursor: nextCursor,
 			BaseResponse: types.BaseResponse{
 				Success:   true,
 				Timestamp: time.Now().Unix(),
@@ -121,14 +437,21 @@ func NewMux(db *engine.DB, logger *zap.Logger) *http.ServeMux {
 		}
 		stats, err := db.Stats()
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Internal Server Error", Timestamp: time.Now().Unix()})
+			writeError(w, r, logger, err)
 			return
 		}
 		_ = json.NewEncoder(w).Encode(types.StatsResponse{
-			TotalKeys: stats.TotalKeys,
-			TotalSize: stats.TotalSize,
-			Segments:  stats.Segments,
+			TotalKeys:   stats.TotalKeys,
+			TotalSize:   stats.TotalSize,
+			DiskSize:    stats.DiskSize,
+			LiveSize:    stats.LiveSize,
+			Segments:    stats.Segments,
+			DeadRatios:  stats.DeadRatios,
+			CacheHits:   stats.CacheHits,
+			CacheMisses: stats.CacheMisses,
+
+			ActiveSegmentID:     stats.ActiveSegmentID,
+			ActiveSegmentOffset: stats.ActiveSegmentOffset,
 			BaseResponse: types.BaseResponse{
 				Success:   true,
 				Timestamp: time.Now().Unix(),
@@ -137,33 +460,673 @@ func NewMux(db *engine.DB, logger *zap.Logger) *http.ServeMux {
 		})
 	})
 
-	return mux
+	// GET /v1/segments
+	mux.HandleFunc("/v1/segments", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		segments := []types.SegmentInfo{}
+		for _, info := range db.SegmentStats() {
+			segments = append(segments, types.SegmentInfo{
+				ID:        info.ID,
+				Size:      info.Size,
+				Entries:   info.Entries,
+				Active:    info.Active,
+				DeadRatio: info.DeadRatio,
+			})
+		}
+		_ = json.NewEncoder(w).Encode(types.SegmentsResponse{
+			Segments: segments,
+			BaseResponse: types.BaseResponse{
+				Success:   true,
+				Timestamp: time.Now().Unix(),
+				Message:   "segments fetched successfully",
+			},
+		})
+	})
+
+	// POST /v1/compact
+	mux.HandleFunc("/v1/compact", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		// ?wait=true waits for a compaction already running, and returns its
+		// result, instead of failing with 409
+		wait := false
+		if r.URL.Query().Has("wait") {
+			var err error
+			wait, err = strconv.ParseBool(r.URL.Query().Get("wait"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "wait must be a boolean", Timestamp: time.Now().Unix()})
+				return
+			}
+		}
+		var result store.MergeResult
+		var err error
+		if wait {
+			result, err = db.MergeWait(r.Context())
+		} else {
+			result, err = db.CompactCtx(r.Context())
+		}
+		if err != nil {
+			writeError(w, r, logger, err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(types.CompactResponse{
+			SegmentsCompacted: result.SegmentsCompacted,
+			BytesReclaimed:    result.BytesReclaimed,
+			BaseResponse: types.BaseResponse{
+				Success:   true,
+				Timestamp: time.Now().Unix(),
+				Message:   "compaction completed",
+			},
+		})
+	})
+
+	// POST /v1/rotate
+	mux.HandleFunc("/v1/rotate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		segmentID, err := db.Rotate()
+		if err != nil {
+			writeError(w, r, logger, err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(types.RotateResponse{
+			SegmentID: segmentID,
+			BaseResponse: types.BaseResponse{
+				Success:   true,
+				Timestamp: time.Now().Unix(),
+				Message:   "active segment rotated",
+			},
+		})
+	})
+
+	// GET /v1/compact/preview
+	mux.HandleFunc("/v1/compact/preview", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		report, err := db.MergeDryRun()
+		if err != nil {
+			writeError(w, r, logger, err)
+			return
+		}
+		segments := report.Segments
+		if segments == nil {
+			segments = []int{}
+		}
+		_ = json.NewEncoder(w).Encode(types.CompactPreviewResponse{
+			Segments:   segments,
+			LiveBytes:  report.LiveBytes,
+			DeadBytes:  report.DeadBytes,
+			Tombstones: report.Tombstones,
+			BaseResponse: types.BaseResponse{
+				Success:   true,
+				Timestamp: time.Now().Unix(),
+				Message:   "compaction preview computed",
+			},
+		})
+	})
+
+	// GET /v1/export
+	mux.HandleFunc("/v1/export", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		handleExport(w, r, db, logger)
+	})
+
+	// GET /v1/snapshot
+	mux.HandleFunc("/v1/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		handleSnapshot(w, r, db, logger)
+	})
+
+	// GET /v1/watch
+	// Streams are ended when the server shuts down, which does not wait for
+	// them otherwise. The hook is registered with the first server to serve one.
+	var onShutdown sync.Once
+	shutdown := make(chan struct{})
+	mux.HandleFunc("/v1/watch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		if srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server); ok {
+			onShutdown.Do(func() {
+				srv.RegisterOnShutdown(func() { close(shutdown) })
+			})
+		}
+		handleWatch(w, r, db, shutdown)
+	})
+
+	// POST /v1/import
+	mux.HandleFunc("/v1/import", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+			return
+		}
+		handleImport(w, r, db, logger)
+	})
+}
+
+// namespaceRouter serves /v1/ns/{namespace}/... by handing the request, with
+// the namespace prefix removed, to the routes of that namespace's database
+type namespaceRouter struct {
+	dbs     *engine.Manager
+	logger  *zap.Logger
+	maxBody int64 // Largest set request body, as for the default database
+	mu      sync.Mutex
+	muxes   map[string]http.Handler
+}
+
+func (nr *namespaceRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/ns/"), "/")
+	handler, err := nr.handler(name)
+	if err != nil {
+		writeError(w, r, nr.logger, err)
+		return
+	}
+
+	// Valid names need no escaping, so the prefix is the same in both forms
+	prefix := "/v1/ns/" + name
+	u := *r.URL
+	u.Path = "/v1" + strings.TrimPrefix(r.URL.Path, prefix)
+	if u.RawPath != "" {
+		u.RawPath = "/v1" + strings.TrimPrefix(r.URL.RawPath, prefix)
+	}
+	inner := *r
+	inner.URL = &u
+	handler.ServeHTTP(w, &inner)
+}
+
+// handler returns the routes for namespace name, opening it on first use
+func (nr *namespaceRouter) handler(name string) (http.Handler, error) {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+	if h, ok := nr.muxes[name]; ok {
+		return h, nil
+	}
+	db, err := nr.dbs.Namespace(name)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	registerDBRoutes(mux, db, nr.logger, nr.maxBody)
+	nr.muxes[name] = mux
+	return mux, nil
+}
+
+// handleReady replies 200 if db passes its health check and 503 with the
+// reason otherwise
+func handleReady(w http.ResponseWriter, r *http.Request, db *engine.DB, logger *zap.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "Method not allowed", Timestamp: time.Now().Unix()})
+		return
+	}
+
+	if err := db.Check(); err != nil {
+		logger.Warn("Health check failed", zap.Error(err))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(types.HealthResponse{
+			Status:       "unavailable",
+			BaseResponse: types.BaseResponse{Success: false, Message: err.Error(), Timestamp: time.Now().Unix()},
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(types.HealthResponse{
+		Status:       "ok",
+		BaseResponse: types.BaseResponse{Success: true, Message: "store is ready", Timestamp: time.Now().Unix()},
+	})
+}
+
+// importBatchSize is the number of imported pairs written per SetBatch call
+const importBatchSize = 1000
+
+// handleExport streams every live key-value pair as newline-delimited JSON
+func handleExport(w http.ResponseWriter, r *http.Request, db *engine.DB, logger *zap.Logger) {
+	it, err := db.IteratorCtx(r.Context())
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	defer it.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for it.Next() {
+		if err := enc.Encode(types.ExportRecord{Key: it.Key(), Value: it.Value()}); err != nil {
+			return // Client went away
+		}
+	}
+	if err := it.Err(); err != nil {
+		// The status is already sent, so abort the response to tell the
+		// client the stream is incomplete
+		logger.Error("Export failed part way through", zap.Error(err))
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// watchHeartbeat is how often an idle watch stream sends a comment, so that
+// proxies keep it open and a vanished client is noticed
+const watchHeartbeat = 15 * time.Second
+
+// handleWatch streams changes to db as Server-Sent Events until the client
+// goes away, shutdown is closed or the subscription ends. Each change is a
+// "change" event carrying a types.WatchEvent. A subscriber that falls too far
+// behind is sent a "dropped" event before the stream ends, so the client
+// knows to resubscribe and re-read what it needs.
+func handleWatch(w http.ResponseWriter, r *http.Request, db *engine.DB, shutdown <-chan struct{}) {
+	events, cancel := db.Subscribe()
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				_, _ = io.WriteString(w, "event: dropped\ndata: {}\n\n")
+				_ = rc.Flush()
+				return
+			}
+			data, _ := json.Marshal(types.WatchEvent{Key: event.Key, Op: string(event.Op), Timestamp: event.Timestamp.Unix()})
+			if _, err := fmt.Fprintf(w, "event: change\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-shutdown:
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// handleSnapshot streams a tar archive of the database's segments, which
+// store.RestoreSnapshot turns back into a data directory
+func handleSnapshot(w http.ResponseWriter, r *http.Request, db *engine.DB, logger *zap.Logger) {
+	sw := &startedWriter{ResponseWriter: w}
+	err := db.Snapshot(sw)
+	if err == nil {
+		return
+	}
+	if !sw.started {
+		writeError(w, r, logger, err)
+		return
+	}
+	// The status is already sent, so abort the response to tell the client
+	// the archive is incomplete
+	logger.Error("Snapshot failed part way through", zap.Error(err))
+	panic(http.ErrAbortHandler)
+}
+
+// startedWriter sets the snapshot headers on the first write, so an error
+// before any data can still be reported with a proper status
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (sw *startedWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.started = true
+		sw.Header().Set("Content-Type", "application/x-tar")
+		sw.Header().Set("Content-Disposition", `attachment; filename="snapshot.tar"`)
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+// handleImport reads newline-delimited JSON pairs as written by export and
+// stores them in batches
+func handleImport(w http.ResponseWriter, r *http.Request, db *engine.DB, logger *zap.Logger) {
+	imported := 0
+	fail := func(status int, message string) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(types.ImportResponse{
+			Imported:     imported,
+			BaseResponse: types.BaseResponse{Success: false, Message: message, Timestamp: time.Now().Unix()},
+		})
+	}
+
+	batch := make([]store.KeyValue, 0, importBatchSize)
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		err := db.SetBatch(batch)
+		var batchErr *store.BatchError
+		if errors.As(err, &batchErr) {
+			imported += batchErr.Written
+		}
+		if err == nil {
+			imported += len(batch)
+			batch = batch[:0]
+			return true
+		}
+		if status := statusForError(err); status != http.StatusInternalServerError {
+			fail(status, err.Error())
+		} else {
+			logger.Error("Import failed", zap.Error(err))
+			fail(status, "Internal Server Error")
+		}
+		return false
+	}
+
+	dec := json.NewDecoder(r.Body)
+	for {
+		var record types.ExportRecord
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if flush() {
+				fail(http.StatusBadRequest, "invalid json")
+			}
+			return
+		}
+		batch = append(batch, store.KeyValue{Key: record.Key, Value: record.Value})
+		if len(batch) == importBatchSize && !flush() {
+			return
+		}
+	}
+	if !flush() {
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(types.ImportResponse{
+		Imported: imported,
+		BaseResponse: types.BaseResponse{
+			Success:   true,
+			Timestamp: time.Now().Unix(),
+			Message:   "import completed",
+		},
+	})
+}
+
+// keyActions are the sub-resources that may follow a key in /v1/kv/ paths
+var keyActions = []string{"incr", "append", "exists", "copy", "rename"}
+
+// parseKeyPath splits a /v1/kv/ URL into its key and optional action. It works
+// on the escaped path, so an encoded slash (%2F) is part of the key while a
+// literal one can introduce an action: /v1/kv/a%2Fincr is the key "a/incr",
+// and /v1/kv/a/incr is the incr action on "a". Other literal slashes are
+// kept in the key, and a single trailing slash is ignored.
+func parseKeyPath(u *url.URL) (key, action string, err error) {
+	escaped := strings.TrimPrefix(u.EscapedPath(), "/v1/kv/")
+	escaped = strings.TrimSuffix(escaped, "/")
+	for _, a := range keyActions {
+		if rest, ok := strings.CutSuffix(escaped, "/"+a); ok {
+			escaped, action = rest, a
+			break
+		}
+	}
+	key, err = url.PathUnescape(escaped)
+	if err != nil {
+		return "", "", errors.New("invalid key encoding")
+	}
+	return key, action, nil
+}
+
+// entryETag identifies the write that produced a value. Timestamps only have
+// second resolution, so the entry's place in the log tells apart writes made
+// in the same second; compaction moves entries, which merely costs clients
+// one full response.
+func entryETag(meta store.EntryMeta) string {
+	return fmt.Sprintf(`"%x-%x-%x-%x"`, meta.FileID, meta.ValuePos, meta.Timestamp, meta.ValueSize)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires for it
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// handleExists reports whether key exists. HEAD replies with the status only.
+func handleExists(w http.ResponseWriter, r *http.Request, db *engine.DB, logger *zap.Logger, key string) {
+	if key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		if r.Method != http.MethodHead {
+			_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "missing key", Timestamp: time.Now().Unix()})
+		}
+		return
+	}
+	exists, err := db.Exists(key)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	if r.Method == http.MethodHead {
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(types.ExistsResponse{
+		Key:    key,
+		Exists: exists,
+		BaseResponse: types.BaseResponse{
+			Success:   true,
+			Timestamp: time.Now().Unix(),
+			Message:   "key checked successfully",
+		},
+	})
+}
+
+// handleIncr adds the requested delta to the integer value of key
+func handleIncr(w http.ResponseWriter, r *http.Request, db *engine.DB, logger *zap.Logger, key string) {
+	if key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "missing key", Timestamp: time.Now().Unix()})
+		return
+	}
+	var req types.IncrRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "invalid json", Timestamp: time.Now().Unix()})
+		return
+	}
+
+	value, err := db.IncrBy(key, req.Delta)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(types.IncrResponse{
+		Key:   key,
+		Value: value,
+		BaseResponse: types.BaseResponse{
+			Success:   true,
+			Timestamp: time.Now().Unix(),
+			Message:   "key incremented successfully",
+		},
+	})
+}
+
+// handleAppend appends the requested suffix to the value of key
+func handleAppend(w http.ResponseWriter, r *http.Request, db *engine.DB, logger *zap.Logger, key string) {
+	if key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "missing key", Timestamp: time.Now().Unix()})
+		return
+	}
+	var req types.AppendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "invalid json", Timestamp: time.Now().Unix()})
+		return
+	}
+
+	value, err := db.AppendValue(key, req.Suffix)
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(types.AppendResponse{
+		Key:   key,
+		Value: value,
+		BaseResponse: types.BaseResponse{
+			Success:   true,
+			Timestamp: time.Now().Unix(),
+			Message:   "value appended successfully",
+		},
+	})
+}
+
+// handleListMeta lists the keys starting with prefix along with their
+// metadata
+func handleListMeta(w http.ResponseWriter, r *http.Request, db *engine.DB, logger *zap.Logger, prefix string) {
+	metas, err := db.ListWithMeta()
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	keys := []types.KeyInfo{}
+	for _, meta := range metas {
+		if !strings.HasPrefix(meta.Key, prefix) {
+			continue
+		}
+		info := types.KeyInfo{Key: meta.Key, Timestamp: int64(meta.Timestamp), Size: meta.StoredSize}
+		if meta.TTL > 0 {
+			// Round up so that a key about to expire still shows a TTL
+			info.TTL = int64((meta.TTL + time.Second - 1) / time.Second)
+		}
+		keys = append(keys, info)
+	}
+	_ = json.NewEncoder(w).Encode(types.ListKeysMetaResponse{
+		Keys: keys,
+		BaseResponse: types.BaseResponse{
+			Success:   true,
+			Timestamp: time.Now().Unix(),
+			Message:   "keys fetched successfully",
+		},
+	})
+}
+
+// handleCopy copies key to the key named in the request, or moves it there
+// when rename is set
+func handleCopy(w http.ResponseWriter, r *http.Request, db *engine.DB, logger *zap.Logger, key string, rename bool) {
+	if key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "missing key", Timestamp: time.Now().Unix()})
+		return
+	}
+	var req types.CopyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: decodeErrorMessage(err), Timestamp: time.Now().Unix()})
+		return
+	}
+	if req.To == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: `missing required field "to"`, Timestamp: time.Now().Unix()})
+		return
+	}
+
+	var err error
+	if rename {
+		err = db.Rename(key, req.To)
+	} else {
+		err = db.Copy(key, req.To)
+	}
+	if err != nil {
+		writeError(w, r, logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // NewHTTPServer constructs the http.Server with configured addr
-func NewHTTPServer(mux *http.ServeMux) *http.Server {
-	addr := os.Getenv("LOGKV_ADDR")
+func NewHTTPServer(handler http.Handler, cfg *config.Config) *http.Server {
+	addr := cfg.HTTPAddr
 	if addr == "" {
 		addr = ":8080"
 	}
-	return &http.Server{Addr: addr, Handler: mux}
+	return &http.Server{Addr: addr, Handler: handler}
 }
 
-// RegisterHooks starts and stops the server using fx Lifecycle
-func RegisterHooks(lc fx.Lifecycle, server *http.Server, logger *zap.Logger) {
+// RegisterHooks starts and stops the server using fx Lifecycle. On stop,
+// background merges and expiry sweeps are stopped first, so that none starts
+// while in-flight requests drain. Requests get cfg.ShutdownTimeout to finish
+// before their connections are closed.
+func RegisterHooks(lc fx.Lifecycle, server *http.Server, dbs *engine.Manager, cfg *config.Config, logger *zap.Logger) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
-			logger.Info("Starting Append-only log based Key-Value store", zap.String("addr", server.Addr))
+			listener, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return err
+			}
+			logger.Info("Starting Append-only log based Key-Value store", zap.String("addr", listener.Addr().String()))
 			go func() {
-				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-					logger.Fatal("Server failed to start", zap.Error(err))
+				if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+					logger.Fatal("Server failed", zap.Error(err))
 				}
 			}()
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
-			logger.Info("Stopping LogKV Store server")
-			return server.Shutdown(ctx)
+			dbs.StopBackground()
+
+			logger.Info("Stopping LogKV Store server", zap.Duration("grace_period", cfg.ShutdownTimeout))
+			drainCtx, cancel := context.WithTimeout(ctx, cfg.ShutdownTimeout)
+			defer cancel()
+			if err := server.Shutdown(drainCtx); err != nil {
+				logger.Warn("Closing connections still busy after the shutdown grace period", zap.Error(err))
+				return server.Close()
+			}
+			return nil
 		},
 	})
 }
@@ -5,17 +5,21 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 	"time"
 
+	"github.com/himakhaitan/logkv-store/engine"
+	"github.com/himakhaitan/logkv-store/pkg/config"
 	"github.com/himakhaitan/logkv-store/server"
 	"github.com/himakhaitan/logkv-store/store"
 	"github.com/himakhaitan/logkv-store/types"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	fxt "go.uber.org/fx/fxtest"
 	"go.uber.org/zap"
 )
@@ -470,21 +474,143 @@ func TestStatsEndpoint_DBError(t *testing.T) {
 }
 
 func TestNewHTTPServer_DefaultAddr(t *testing.T) {
-	os.Unsetenv("LOGKV_ADDR")
 	mux := http.NewServeMux()
-	server := server.NewHTTPServer(mux)
+	server := server.NewHTTPServer(mux, &config.Config{})
 	assert.Equal(t, ":8080", server.Addr)
 }
 
+func TestNewHTTPServer_ConfiguredAddr(t *testing.T) {
+	mux := http.NewServeMux()
+	server := server.NewHTTPServer(mux, &config.Config{HTTPAddr: "127.0.0.1:9999"})
+	assert.Equal(t, "127.0.0.1:9999", server.Addr)
+}
+
+// newTestManager returns a manager over a store in a temporary directory
+func newTestManager(t *testing.T, cfg *config.Config) *engine.Manager {
+	t.Helper()
+	cfg.DataDir = t.TempDir()
+	s, err := store.New(zap.NewNop(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return engine.NewManager(&engine.DB{Store: s}, cfg, zap.NewNop())
+}
+
+// freeAddr returns a local address nothing is listening on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
 func TestRegisterHooksLifecycle(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	mux := http.NewServeMux()
 	srv := &http.Server{Addr: "127.0.0.1:0", Handler: mux}
+	cfg := &config.Config{ShutdownTimeout: time.Second}
 
 	mockLC := fxt.NewLifecycle(t)
-	server.RegisterHooks(mockLC, srv, logger)
+	server.RegisterHooks(mockLC, srv, newTestManager(t, cfg), cfg, logger)
 
 	ctx := context.Background()
 	assert.NoError(t, mockLC.Start(ctx))
 	assert.NoError(t, mockLC.Stop(ctx))
 }
+
+func TestRegisterHooks_StartFailsOnBusyAddr(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	cfg := &config.Config{}
+	srv := &http.Server{Addr: l.Addr().String(), Handler: http.NewServeMux()}
+	lc := fxt.NewLifecycle(t)
+	server.RegisterHooks(lc, srv, newTestManager(t, cfg), cfg, zap.NewNop())
+	assert.Error(t, lc.Start(context.Background()))
+}
+
+// slowServer starts a server through RegisterHooks whose /slow handler
+// signals started, then waits for release or for its request to be cancelled
+func slowServer(t *testing.T, grace time.Duration, release <-chan struct{}) (*fxt.Lifecycle, string, <-chan struct{}) {
+	t.Helper()
+	started := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			_, _ = io.WriteString(w, "done")
+		case <-r.Context().Done():
+		}
+	})
+
+	addr := freeAddr(t)
+	cfg := &config.Config{ShutdownTimeout: grace, MergeInterval: time.Hour}
+	lc := fxt.NewLifecycle(t)
+	server.RegisterHooks(lc, &http.Server{Addr: addr, Handler: mux}, newTestManager(t, cfg), cfg, zap.NewNop())
+	require.NoError(t, lc.Start(context.Background()))
+	return lc, "http://" + addr + "/slow", started
+}
+
+func TestRegisterHooks_DrainsInFlightRequests(t *testing.T) {
+	release := make(chan struct{})
+	lc, url, started := slowServer(t, 5*time.Second, release)
+
+	type result struct {
+		body string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		done <- result{string(body), err}
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- lc.Stop(context.Background()) }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop returned while a request was in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// New connections are refused while the request drains
+	_, err := http.Get(url)
+	assert.Error(t, err)
+
+	close(release)
+	res := <-done
+	require.NoError(t, res.err)
+	assert.Equal(t, "done", res.body)
+	assert.NoError(t, <-stopped)
+}
+
+func TestRegisterHooks_GracePeriodExpires(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	lc, url, started := slowServer(t, 100*time.Millisecond, release)
+
+	done := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	<-started
+
+	start := time.Now()
+	assert.NoError(t, lc.Stop(context.Background()))
+	assert.Less(t, time.Since(start), 2*time.Second, "Stop waits no longer than the grace period")
+	assert.Error(t, <-done, "The request still running is cut off")
+}
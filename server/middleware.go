@@ -0,0 +1,320 @@
+package server
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/pkg/metrics"
+	"github.com/himakhaitan/logkv-store/types"
+	"go.uber.org/zap"
+)
+
+// Middleware decorates an http.Handler
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in middlewares. The first middleware is the outermost, so it
+// sees requests first.
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// RequireToken rejects requests whose Authorization header doesn't carry
+// "Bearer <token>". Paths in open are served without a token. An empty token
+// disables the check.
+func RequireToken(token string, open ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if token == "" {
+			return next
+		}
+		// Compare digests so the comparison takes the same time whatever
+		// the length of the presented token
+		want := sha256.Sum256([]byte(token))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, path := range open {
+				if r.URL.Path == path {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			got := sha256.Sum256([]byte(presented))
+			if !ok || subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="logkv"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(types.BaseResponse{Success: false, Message: "unauthorized", Timestamp: time.Now().Unix()})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// responseWriter records the status code and body size of a response
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// WriteHeader records the status code before sending it
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body size. A write without WriteHeader implies 200 OK.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// LogRequests logs the method, path, status, response size and duration of
+// every request. A nil logger disables logging.
+func LogRequests(logger *zap.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		if logger == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+			defer func() {
+				status := rw.status
+				if status == 0 {
+					// Nothing was written, so net/http sends 200 OK
+					status = http.StatusOK
+				}
+				logger.Info("HTTP request",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.Int("status", status),
+					zap.Int64("size", rw.size),
+					zap.Duration("duration", time.Since(start)),
+				)
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// InstrumentRequests records the latency of every request by method, route
+// and status. A nil m disables it.
+func InstrumentRequests(m *metrics.Metrics) Middleware {
+	return func(next http.Handler) http.Handler {
+		if m == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			// The mux sets Pattern on the request it routed
+			m.ObserveRequest(r.Method, r.Pattern, status, time.Since(start))
+		})
+	}
+}
+
+// CORS lets browsers on the allowed origins call the API. "*" allows any
+// origin. Preflight requests are answered here and never reach the handler.
+// With no origins, no CORS headers are sent, so browsers only allow
+// same-origin requests.
+func CORS(origins, methods, headers []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+		allowed := make(map[string]bool, len(origins))
+		for _, origin := range origins {
+			allowed[origin] = true
+		}
+		allowMethods := strings.Join(methods, ", ")
+		allowHeaders := strings.Join(headers, ", ")
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Origin")
+			ok := allowed["*"] || allowed[origin]
+			if ok {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				if ok {
+					w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+					w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+					w.Header().Set("Access-Control-Max-Age", "600")
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Gzip compresses response bodies of at least minSize bytes for clients that
+// accept gzip. Smaller bodies, bodiless responses and responses that already
+// carry a Content-Encoding are sent as is. A minSize of 0 disables it.
+func Gzip(minSize int) Middleware {
+	return func(next http.Handler) http.Handler {
+		if minSize <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+			next.ServeHTTP(gw, r)
+			// Not deferred: a handler that panics to abort the response must
+			// not have its truncated body completed with a gzip trailer
+			gw.finish()
+		})
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+		return !ok || strings.Trim(q, "0.") != ""
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether
+// the body is large enough to compress, then streams the rest
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+	buf     []byte
+	gz      *gzip.Writer
+	started bool // Headers have been sent
+}
+
+// WriteHeader holds the status until the body size is known. Statuses that
+// never carry a body are sent straight away.
+func (gw *gzipResponseWriter) WriteHeader(status int) {
+	if gw.started || gw.status != 0 {
+		return
+	}
+	gw.status = status
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < 200 {
+		gw.start(false)
+	}
+}
+
+// Write buffers until minSize bytes have been written, then compresses
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !gw.started {
+		gw.buf = append(gw.buf, b...)
+		if len(gw.buf) < gw.minSize {
+			return len(b), nil
+		}
+		if err := gw.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if gw.gz != nil {
+		return gw.gz.Write(b)
+	}
+	return gw.ResponseWriter.Write(b)
+}
+
+// Flush starts the response, compressing it if it may still grow past
+// minSize, and pushes everything written so far to the client
+func (gw *gzipResponseWriter) Flush() {
+	if !gw.started {
+		if err := gw.start(true); err != nil {
+			return
+		}
+	}
+	if gw.gz != nil {
+		_ = gw.gz.Flush()
+	}
+	_ = http.NewResponseController(gw.ResponseWriter).Flush()
+}
+
+// start sends the headers and any buffered body, compressed or not
+func (gw *gzipResponseWriter) start(compress bool) error {
+	gw.started = true
+	header := gw.Header()
+	if header.Get("Content-Encoding") != "" {
+		compress = false
+	}
+	if compress {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		if header.Get("Content-Type") == "" {
+			// Sniff before the body turns into gzip data
+			header.Set("Content-Type", http.DetectContentType(gw.buf))
+		}
+	}
+	if gw.status != 0 {
+		gw.ResponseWriter.WriteHeader(gw.status)
+	}
+
+	buf := gw.buf
+	gw.buf = nil
+	if compress {
+		gw.gz = gzip.NewWriter(gw.ResponseWriter)
+		_, err := gw.gz.Write(buf)
+		return err
+	}
+	if len(buf) > 0 {
+		_, err := gw.ResponseWriter.Write(buf)
+		return err
+	}
+	return nil
+}
+
+// finish sends a body that stayed under minSize uncompressed, or completes
+// the gzip stream
+func (gw *gzipResponseWriter) finish() {
+	if !gw.started {
+		_ = gw.start(false)
+	}
+	if gw.gz != nil {
+		_ = gw.gz.Close()
+	}
+}
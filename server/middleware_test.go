@@ -0,0 +1,291 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/himakhaitan/logkv-store/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// okHandler replies 200 to every request
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := Chain(okHandler, tag("outer"), tag("inner"))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"outer", "inner"}, order)
+}
+
+func TestRequireToken(t *testing.T) {
+	h := RequireToken("secret", "/health")(okHandler)
+
+	cases := []struct {
+		name   string
+		path   string
+		header string
+		status int
+	}{
+		{"valid token", "/v1/keys", "Bearer secret", http.StatusOK},
+		{"missing header", "/v1/keys", "", http.StatusUnauthorized},
+		{"wrong token", "/v1/keys", "Bearer secreT", http.StatusUnauthorized},
+		{"token prefix", "/v1/keys", "Bearer secret2", http.StatusUnauthorized},
+		{"wrong scheme", "/v1/keys", "Basic secret", http.StatusUnauthorized},
+		{"open path", "/health", "", http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			assert.Equal(t, tc.status, rec.Code)
+
+			if tc.status == http.StatusUnauthorized {
+				var body types.BaseResponse
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+				assert.False(t, body.Success)
+				assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRequireToken_EmptyTokenDisabled(t *testing.T) {
+	h := RequireToken("")(okHandler)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/keys", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestLogRequests(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := LogRequests(zap.New(core))(mux)
+
+	for _, path := range []string{"/hello", "/empty", "/missing"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	entries := logs.All()
+	require.Len(t, entries, 3)
+	statuses := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		assert.Equal(t, "GET", fields["method"])
+		assert.Contains(t, fields, "duration")
+		statuses = append(statuses, fields["status"].(int64))
+	}
+	assert.Equal(t, []int64{http.StatusOK, http.StatusNoContent, http.StatusNotFound}, statuses)
+	assert.Equal(t, int64(5), entries[0].ContextMap()["size"])
+	assert.Equal(t, int64(0), entries[1].ContextMap()["size"])
+}
+
+func TestLogRequests_NoContentPassesThrough(t *testing.T) {
+	core, _ := observer.New(zap.InfoLevel)
+	h := LogRequests(zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/kv/foo", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.Bytes())
+}
+
+func TestLogRequests_NilLoggerDisabled(t *testing.T) {
+	h := LogRequests(nil)(okHandler)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestCORS(t *testing.T) {
+	h := CORS([]string{"https://ui.example.com"}, []string{"GET", "PUT"}, []string{"Content-Type"})(okHandler)
+
+	t.Run("allowed origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/keys", nil)
+		req.Header.Set("Origin", "https://ui.example.com")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "https://ui.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "Origin", rec.Header().Get("Vary"))
+	})
+
+	t.Run("other origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/keys", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/v1/kv", nil)
+		req.Header.Set("Origin", "https://ui.example.com")
+		req.Header.Set("Access-Control-Request-Method", "PUT")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "https://ui.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, PUT", rec.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type", rec.Header().Get("Access-Control-Allow-Headers"))
+	})
+
+	t.Run("preflight from other origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/v1/kv", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		req.Header.Set("Access-Control-Request-Method", "PUT")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Methods"))
+	})
+}
+
+func TestCORS_Wildcard(t *testing.T) {
+	h := CORS([]string{"*"}, nil, nil)(okHandler)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "http://localhost:3000", rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS_NoOriginsSameOriginOnly(t *testing.T) {
+	h := CORS(nil, nil, nil)(okHandler)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	cases := map[string]bool{
+		"":                  false,
+		"gzip":              true,
+		"deflate, gzip":     true,
+		"gzip;q=0.5, br":    true,
+		"gzip; q=0":         false,
+		"gzip;q=0.000":      false,
+		"br":                false,
+		"x-gzip, identity":  false,
+		"deflate,gzip;q=1.": true,
+	}
+	for header, want := range cases {
+		assert.Equal(t, want, acceptsGzip(header), header)
+	}
+}
+
+func TestGzip(t *testing.T) {
+	large := strings.Repeat("logkv ", 1000)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		// Write in pieces so the size threshold is crossed part way through
+		for i := 0; i < len(large); i += 100 {
+			_, _ = w.Write([]byte(large[i : i+100]))
+		}
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("small"))
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := Gzip(1024)(mux)
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("large body is compressed", func(t *testing.T) {
+		rec := get("/large", "gzip")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+		assert.Less(t, rec.Body.Len(), len(large))
+
+		zr, err := gzip.NewReader(rec.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, large, string(body))
+	})
+
+	t.Run("client without gzip", func(t *testing.T) {
+		rec := get("/large", "")
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, rec.Body.String())
+	})
+
+	t.Run("small body is not compressed", func(t *testing.T) {
+		rec := get("/small", "gzip")
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "small", rec.Body.String())
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		rec := get("/empty", "gzip")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Zero(t, rec.Body.Len())
+	})
+}
+
+func TestGzip_AbortedResponseIsNotCompleted(t *testing.T) {
+	h := Gzip(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+		panic(http.ErrAbortHandler)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	assert.Panics(t, func() { h.ServeHTTP(rec, req) })
+
+	// Without the trailer, readers see the stream as truncated
+	zr, err := gzip.NewReader(rec.Body)
+	if err == nil {
+		_, err = io.ReadAll(zr)
+	}
+	assert.Error(t, err)
+}
@@ -1,10 +1,16 @@
 package server
 
 import (
+	"time"
+
 	"github.com/himakhaitan/logkv-store/engine"
 	"go.uber.org/fx"
 )
 
+// startTime is when the process started, near enough: package variables are
+// initialised before main runs
+var startTime = time.Now()
+
 // Module provides the HTTP server wired with fx
 func Module() fx.Option {
 	return fx.Options(
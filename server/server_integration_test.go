@@ -1,19 +1,33 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/himakhaitan/logkv-store/engine"
 	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/himakhaitan/logkv-store/pkg/metrics"
+	"github.com/himakhaitan/logkv-store/pkg/version"
 	"github.com/himakhaitan/logkv-store/store"
 	"github.com/himakhaitan/logkv-store/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
 	"go.uber.org/zap/zaptest"
 )
 
@@ -28,11 +42,13 @@ func setupIntegrationServer(t *testing.T) (*httptest.Server, *store.Store, *engi
 	require.NoError(t, err)
 
 	db := &engine.DB{Store: s}
-	mux := NewMux(db, logger)
+	dbs := engine.NewManager(db, cfg, logger)
+	mux := NewMux(dbs, cfg, logger)
 	ts := httptest.NewServer(mux)
 
 	cleanup := func() {
 		ts.Close()
+		dbs.Close()
 		s.Close()
 		os.RemoveAll(tmpDir)
 	}
@@ -62,6 +78,7 @@ func TestServerIntegration_AllEndpoints(t *testing.T) {
 	_ = json.NewDecoder(getResp.Body).Decode(&getData)
 	assert.Equal(t, "foo", getData.Key)
 	assert.Equal(t, "bar", getData.Value)
+	assert.InDelta(t, time.Now().Unix(), getData.Timestamp, 5, "Timestamp should come from the entry metadata")
 
 	// DELETE /v1/kv/foo
 	delReq, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/kv/foo", nil)
@@ -91,6 +108,8 @@ func TestServerIntegration_AllEndpoints(t *testing.T) {
 		totalSize += int64(len(val))
 	}
 	assert.Equal(t, totalSize, statsData.TotalSize)
+	assert.Equal(t, 1, statsData.ActiveSegmentID)
+	assert.Equal(t, statsData.DiskSize, statsData.ActiveSegmentOffset)
 }
 
 func TestServerIntegration_EmptyKeyAndMethodNotAllowed(t *testing.T) {
@@ -150,3 +169,1169 @@ func TestServerIntegration_DeleteNonExistentKey(t *testing.T) {
 	resp, _ := http.DefaultClient.Do(req)
 	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
+
+func TestServerIntegration_ForceDelete(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("key", "value"))
+	for _, path := range []string{"/v1/kv/key?force=true", "/v1/kv/key?force=true", "/v1/kv/nonexistent?force=1"} {
+		req, _ := http.NewRequest(http.MethodDelete, ts.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode, path)
+	}
+	_, err := s.Get("key")
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/kv/nonexistent?force=false", nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	req, _ = http.NewRequest(http.MethodDelete, ts.URL+"/v1/kv/key?force=maybe", nil)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServerIntegration_BatchGet(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("a", "1"))
+	require.NoError(t, s.Set("b", "2"))
+
+	body := `{"keys":["a","b","missing"]}`
+	resp, err := http.Post(ts.URL+"/v1/kv/batch-get", "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var data types.MultiGetResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	assert.True(t, data.Success)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, data.Values)
+	assert.Equal(t, []string{"missing"}, data.Missing)
+
+	// Empty key list
+	resp2, _ := http.Post(ts.URL+"/v1/kv/batch-get", "application/json", bytes.NewBufferString(`{"keys":[]}`))
+	assert.Equal(t, http.StatusBadRequest, resp2.StatusCode)
+
+	// Invalid JSON
+	resp3, _ := http.Post(ts.URL+"/v1/kv/batch-get", "application/json", bytes.NewBufferString(`{"keys":`))
+	assert.Equal(t, http.StatusBadRequest, resp3.StatusCode)
+
+	// Wrong method
+	resp4, _ := http.Get(ts.URL + "/v1/kv/batch-get")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp4.StatusCode)
+}
+
+func TestServerIntegration_SetMalformedJSON(t *testing.T) {
+	ts, _, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	cases := map[string]struct {
+		body    io.Reader
+		message string
+	}{
+		"empty body":      {strings.NewReader(""), "empty request body"},
+		"syntax error":    {strings.NewReader(`{"key": "a",, "value": "b"}`), "invalid json at offset 13: invalid character ',' looking for beginning of object key string"},
+		"truncated":       {strings.NewReader(`{"key": "a"`), "invalid json: unexpected end of input"},
+		"wrong type":      {strings.NewReader(`{"key": "a", "value": 42}`), `invalid json: field "value" must be a string, got number`},
+		"not an object":   {strings.NewReader(`["a", "b"]`), "invalid json: expected an object, got array"},
+		"missing key":     {strings.NewReader(`{"value": "b"}`), `missing required field "key"`},
+		"unreadable body": {iotest.ErrReader(errors.New("connection reset")), "unreadable request body: connection reset"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Served directly: a body that fails to read cannot be sent over the wire
+			rec := httptest.NewRecorder()
+			ts.Config.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/v1/kv", tc.body))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var data types.BaseResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&data))
+			assert.False(t, data.Success)
+			assert.Equal(t, tc.message, data.Message)
+		})
+	}
+}
+
+func TestServerIntegration_SetBodyTooLarge(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{DataDir: t.TempDir(), MaxRequestBody: 64}
+	s, err := store.New(logger, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	dbs := engine.NewManager(&engine.DB{Store: s}, cfg, logger)
+	defer dbs.Close()
+	ts := httptest.NewServer(NewMux(dbs, cfg, logger))
+	defer ts.Close()
+
+	body := `{"key":"big","value":"` + strings.Repeat("x", 100) + `"}`
+	resp, err := http.Post(ts.URL+"/v1/kv", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	var data types.BaseResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	assert.False(t, data.Success)
+	assert.Equal(t, "request body exceeds 64 bytes", data.Message)
+	_, err = s.Get("big")
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+
+	// Namespaces share the limit
+	resp2, err := http.Post(ts.URL+"/v1/ns/other/kv", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	resp2.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp2.StatusCode)
+
+	resp3, err := http.Post(ts.URL+"/v1/kv", "application/json", strings.NewReader(`{"key":"small","value":"ok"}`))
+	require.NoError(t, err)
+	resp3.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp3.StatusCode)
+}
+
+func TestMaxSetBody(t *testing.T) {
+	assert.Equal(t, int64(DefaultMaxRequestBody), maxSetBody(&config.Config{}))
+	assert.Equal(t, int64(DefaultMaxRequestBody), maxSetBody(&config.Config{MaxValueSize: 1024}), "Small entries fit the default")
+	assert.Equal(t, int64(100), maxSetBody(&config.Config{MaxRequestBody: 100}))
+
+	// Room for the largest allowed entry with every byte escaped
+	limit := maxSetBody(&config.Config{MaxKeySize: 1 << 10, MaxValueSize: 4 << 20})
+	assert.GreaterOrEqual(t, limit, int64(6*(1<<10+4<<20)))
+}
+
+func TestServerIntegration_BatchDelete(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("a", "1"))
+	require.NoError(t, s.Set("b", "2"))
+	require.NoError(t, s.Set("c", "3"))
+
+	body := `{"keys":["a","b","missing"]}`
+	resp, err := http.Post(ts.URL+"/v1/kv/batch-delete", "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var data types.BatchDeleteResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	assert.True(t, data.Success)
+	assert.Equal(t, 2, data.Deleted)
+	assert.Equal(t, 1, data.Missing)
+
+	keys, err := s.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, keys)
+
+	// Empty key list
+	resp2, _ := http.Post(ts.URL+"/v1/kv/batch-delete", "application/json", bytes.NewBufferString(`{"keys":[]}`))
+	assert.Equal(t, http.StatusBadRequest, resp2.StatusCode)
+
+	// Invalid JSON
+	resp3, _ := http.Post(ts.URL+"/v1/kv/batch-delete", "application/json", bytes.NewBufferString(`{"keys":`))
+	assert.Equal(t, http.StatusBadRequest, resp3.StatusCode)
+
+	// Wrong method
+	resp4, _ := http.Get(ts.URL + "/v1/kv/batch-delete")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp4.StatusCode)
+}
+
+func TestServerIntegration_ListKeysPrefix(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("user:2", "b"))
+	require.NoError(t, s.Set("user:1", "a"))
+	require.NoError(t, s.Set("order:1", "c"))
+
+	resp, err := http.Get(ts.URL + "/v1/keys?prefix=user:")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var data types.ListKeysResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	assert.Equal(t, []string{"user:1", "user:2"}, data.Keys)
+}
+
+func TestServerIntegration_ListKeysMeta(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("user:1", "abc"))
+	require.NoError(t, s.SetWithTTL("user:2", "b", time.Hour))
+	require.NoError(t, s.Set("order:1", "c"))
+
+	resp, err := http.Get(ts.URL + "/v1/keys?meta=true&prefix=user:")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var raw struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&raw))
+	require.Len(t, raw.Keys, 2)
+	assert.Equal(t, "user:1", raw.Keys[0]["key"])
+	assert.Equal(t, 3.0, raw.Keys[0]["size"])
+	assert.NotContains(t, raw.Keys[0], "ttl", "Keys without an expiry have no ttl")
+	assert.Equal(t, "user:2", raw.Keys[1]["key"])
+	assert.InDelta(t, 3600, raw.Keys[1]["ttl"], 1)
+	assert.InDelta(t, time.Now().Unix(), raw.Keys[1]["timestamp"], 2)
+
+	// Without meta the keys stay bare strings
+	resp2, err := http.Get(ts.URL + "/v1/keys?meta=false&prefix=user:")
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	var bare types.ListKeysResponse
+	require.NoError(t, json.NewDecoder(resp2.Body).Decode(&bare))
+	assert.Equal(t, []string{"user:1", "user:2"}, bare.Keys)
+
+	for _, query := range []string{"meta=maybe", "meta=true&limit=2"} {
+		resp, err := http.Get(ts.URL + "/v1/keys?" + query)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func TestServerIntegration_Incr(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	resp, err := http.Post(ts.URL+"/v1/kv/hits/incr", "application/json", bytes.NewBufferString(`{"delta":3}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var data types.IncrResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	assert.True(t, data.Success)
+	assert.Equal(t, "hits", data.Key)
+	assert.Equal(t, int64(3), data.Value)
+
+	// Non-integer value
+	require.NoError(t, s.Set("name", "abc"))
+	resp2, _ := http.Post(ts.URL+"/v1/kv/name/incr", "application/json", bytes.NewBufferString(`{"delta":1}`))
+	assert.Equal(t, http.StatusBadRequest, resp2.StatusCode)
+
+	// Invalid JSON
+	resp3, _ := http.Post(ts.URL+"/v1/kv/hits/incr", "application/json", bytes.NewBufferString(`{"delta":`))
+	assert.Equal(t, http.StatusBadRequest, resp3.StatusCode)
+}
+
+func TestServerIntegration_CopyRename(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("a", "1"))
+	resp, err := http.Post(ts.URL+"/v1/kv/a/copy", "application/json", bytes.NewBufferString(`{"to":"b"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	value, err := s.Get("b")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	resp, err = http.Post(ts.URL+"/v1/kv/b/rename", "application/json", bytes.NewBufferString(`{"to":"c"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	_, err = s.Get("b")
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+	value, err = s.Get("c")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	// Missing source
+	resp, err = http.Post(ts.URL+"/v1/kv/missing/rename", "application/json", bytes.NewBufferString(`{"to":"c"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Missing destination
+	resp, err = http.Post(ts.URL+"/v1/kv/a/copy", "application/json", bytes.NewBufferString(`{}`))
+	require.NoError(t, err)
+	var data types.BaseResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, `missing required field "to"`, data.Message)
+
+	// Wrong method
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/v1/kv/a/copy", nil)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestServerIntegration_Append(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("log", "a"))
+	resp, err := http.Post(ts.URL+"/v1/kv/log/append", "application/json", bytes.NewBufferString(`{"suffix":"bc"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var data types.AppendResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	assert.True(t, data.Success)
+	assert.Equal(t, "log", data.Key)
+	assert.Equal(t, "abc", data.Value)
+
+	// Wrong method
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/kv/log/append", nil)
+	resp2, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp2.StatusCode)
+
+	// Invalid JSON
+	resp3, _ := http.Post(ts.URL+"/v1/kv/log/append", "application/json", bytes.NewBufferString(`{"suffix":`))
+	assert.Equal(t, http.StatusBadRequest, resp3.StatusCode)
+}
+
+func TestServerIntegration_Segments(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("a", "1"))
+	resp, err := http.Get(ts.URL + "/v1/segments")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var data types.SegmentsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	assert.True(t, data.Success)
+	require.Len(t, data.Segments, 1)
+	assert.Equal(t, 1, data.Segments[0].ID)
+	assert.Equal(t, 1, data.Segments[0].Entries)
+	assert.True(t, data.Segments[0].Active)
+	assert.Positive(t, data.Segments[0].Size)
+}
+
+func TestServerIntegration_Compact(t *testing.T) {
+	ts, _, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	resp, err := http.Post(ts.URL+"/v1/compact", "application/json", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var data types.CompactResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	assert.True(t, data.Success)
+	assert.Equal(t, 0, data.SegmentsCompacted, "A fresh store has no inactive segments")
+
+	resp3, err := http.Post(ts.URL+"/v1/compact?wait=true", "application/json", nil)
+	require.NoError(t, err)
+	resp3.Body.Close()
+	assert.Equal(t, http.StatusOK, resp3.StatusCode)
+
+	resp4, err := http.Post(ts.URL+"/v1/compact?wait=maybe", "application/json", nil)
+	require.NoError(t, err)
+	resp4.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp4.StatusCode)
+
+	// Wrong method
+	resp2, err := http.Get(ts.URL + "/v1/compact")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp2.StatusCode)
+}
+
+func TestServerIntegration_Rotate(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("key", "value"))
+	resp, err := http.Post(ts.URL+"/v1/rotate", "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var data types.RotateResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	assert.True(t, data.Success)
+	assert.Equal(t, 2, data.SegmentID)
+
+	// The rotated segment can be compacted straight away
+	resp2, err := http.Post(ts.URL+"/v1/compact", "application/json", nil)
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	var compact types.CompactResponse
+	require.NoError(t, json.NewDecoder(resp2.Body).Decode(&compact))
+	assert.Equal(t, 1, compact.SegmentsCompacted)
+
+	value, err := s.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	resp3, err := http.Get(ts.URL + "/v1/rotate")
+	require.NoError(t, err)
+	resp3.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp3.StatusCode)
+}
+
+func TestServerIntegration_CompactPreview(t *testing.T) {
+	ts, _, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	resp, err := http.Get(ts.URL + "/v1/compact/preview")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var data types.CompactPreviewResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&data))
+	assert.True(t, data.Success)
+	assert.Empty(t, data.Segments, "A fresh store has no inactive segments")
+	assert.Zero(t, data.DeadBytes)
+
+	// Wrong method
+	resp2, err := http.Post(ts.URL+"/v1/compact/preview", "application/json", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp2.StatusCode)
+}
+
+func TestServerIntegration_ConditionalGet(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("key", "v1"))
+	get := func(ifNoneMatch string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/kv/key", nil)
+		require.NoError(t, err)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	resp, _ := get("")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	for _, header := range []string{etag, `"other", ` + etag, "W/" + etag, "*"} {
+		resp, body := get(header)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode, header)
+		assert.Empty(t, body)
+		assert.Equal(t, etag, resp.Header.Get("ETag"))
+	}
+
+	// The same value written again in the same second is still a new write
+	require.NoError(t, s.Set("key", "v1"))
+	resp, body := get(etag)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+	var data types.GetResponse
+	require.NoError(t, json.Unmarshal(body, &data))
+	assert.Equal(t, "v1", data.Value)
+
+	resp, _ = get(`"stale"`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServerIntegration_ContentType(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	body, err := json.Marshal(types.SetRequest{Key: "doc", Value: "<p>hi</p>", ContentType: "text/html"})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, ts.URL+"/v1/kv", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NoError(t, s.Set("plain", "bytes"))
+
+	get := func(path string) (*http.Response, []byte) {
+		resp, err := http.Get(ts.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	t.Run("Envelope", func(t *testing.T) {
+		resp, body := get("/v1/kv/doc")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var data types.GetResponse
+		require.NoError(t, json.Unmarshal(body, &data))
+		assert.Equal(t, "<p>hi</p>", data.Value)
+		assert.Equal(t, "text/html", data.ContentType)
+	})
+
+	t.Run("Raw", func(t *testing.T) {
+		resp, body := get("/v1/kv/doc?raw=true")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/html", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "<p>hi</p>", string(body))
+		assert.NotEmpty(t, resp.Header.Get("ETag"))
+
+		resp, body = get("/v1/kv/plain?raw=true")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "bytes", string(body))
+
+		resp, _ = get("/v1/kv/missing?raw=true")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp, _ = get("/v1/kv/doc?raw=maybe")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestServerIntegration_HeadKey(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("present", "value"))
+
+	resp, err := http.Head(ts.URL + "/v1/kv/present")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, body)
+
+	resp2, err := http.Head(ts.URL + "/v1/kv/missing")
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp2.Body)
+	assert.Equal(t, http.StatusNotFound, resp2.StatusCode)
+	assert.Empty(t, body)
+}
+
+func TestServerIntegration_EncodedKeys(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("user/123", "alice"))
+	require.NoError(t, s.Set("a b", "spaced"))
+	require.NoError(t, s.Set("n/incr", "literal"))
+	require.NoError(t, s.Set("plain", "value"))
+
+	cases := []struct {
+		path  string
+		value string
+	}{
+		{"/v1/kv/user%2F123", "alice"},
+		{"/v1/kv/user/123", "alice"},
+		{"/v1/kv/a%20b", "spaced"},
+		{"/v1/kv/n%2Fincr", "literal"},
+		{"/v1/kv/plain/", "value"},
+	}
+	for _, tc := range cases {
+		resp, err := http.Get(ts.URL + tc.path)
+		require.NoError(t, err)
+		var out types.GetResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, tc.path)
+		assert.Equal(t, tc.value, out.Value, tc.path)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/kv/user%2F123", nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	_, err = s.Get("user/123")
+	assert.ErrorIs(t, err, store.ErrKeyNotFound)
+}
+
+func TestServerIntegration_Namespaces(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	for _, tc := range []struct{ path, body string }{
+		{"/v1/ns/users/kv", `{"key":"id","value":"alice"}`},
+		{"/v1/ns/orders/kv", `{"key":"id","value":"42"}`},
+		{"/v1/kv", `{"key":"id","value":"default"}`},
+	} {
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+tc.path, bytes.NewBufferString(tc.body))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusNoContent, resp.StatusCode, tc.path)
+	}
+
+	for path, want := range map[string]string{
+		"/v1/ns/users/kv/id":  "alice",
+		"/v1/ns/orders/kv/id": "42",
+		"/v1/kv/id":           "default",
+	} {
+		resp, err := http.Get(ts.URL + path)
+		require.NoError(t, err)
+		var out types.GetResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		resp.Body.Close()
+		assert.Equal(t, want, out.Value, path)
+	}
+	value, err := s.Get("id")
+	require.NoError(t, err)
+	assert.Equal(t, "default", value, "namespaced writes must not reach the default store")
+
+	// Encoded keys work under a namespace too
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/v1/ns/users/kv", bytes.NewBufferString(`{"key":"a/b","value":"slash"}`))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	resp, err = http.Get(ts.URL + "/v1/ns/users/kv/a%2Fb/exists")
+	require.NoError(t, err)
+	var exists types.ExistsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&exists))
+	resp.Body.Close()
+	assert.True(t, exists.Exists)
+
+	resp, err = http.Get(ts.URL + "/v1/ns/users/keys")
+	require.NoError(t, err)
+	var list types.ListKeysResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	assert.ElementsMatch(t, []string{"id", "a/b"}, list.Keys)
+
+	resp, err = http.Get(ts.URL + "/v1/ns/bad%20name/keys")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServerIntegration_KeyExists(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("user/1", "value"))
+
+	for key, want := range map[string]bool{"user%2F1": true, "missing": false} {
+		resp, err := http.Get(ts.URL + "/v1/kv/" + key + "/exists")
+		require.NoError(t, err)
+		var out types.ExistsResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, want, out.Exists, key)
+	}
+
+	resp, err := http.Head(ts.URL + "/v1/kv/user%2F1/exists")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = http.Head(ts.URL + "/v1/kv/missing/exists")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/kv/user%2F1/exists", nil)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestServerIntegration_ErrorStatuses(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("text", "abc"))
+	require.NoError(t, s.Set("lost", "value"))
+
+	// Not found
+	resp, err := http.Get(ts.URL + "/v1/kv/missing")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Validation
+	resp, err = http.Post(ts.URL+"/v1/kv/text/incr", "application/json", bytes.NewBufferString(`{"delta":1}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// An index entry whose segment is gone is the server's problem, not a
+	// missing key
+	require.NoError(t, s.Close())
+	resp, err = http.Get(ts.URL + "/v1/kv/lost")
+	require.NoError(t, err)
+	var out types.BaseResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "Internal Server Error", out.Message)
+}
+
+func TestServerIntegration_SetTooLarge(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{DataDir: t.TempDir(), MaxKeySize: 8, MaxValueSize: 16}
+	s, err := store.New(logger, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	ts := httptest.NewServer(NewMux(engine.NewManager(&engine.DB{Store: s}, cfg, logger), cfg, logger))
+	defer ts.Close()
+
+	for _, body := range []string{
+		`{"key":"much_too_long_key","value":"v"}`,
+		`{"key":"k","value":"a value well over sixteen bytes"}`,
+	} {
+		resp, err := http.Post(ts.URL+"/v1/kv", "application/json", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode, body)
+	}
+
+	resp, err := http.Post(ts.URL+"/v1/kv", "application/json", bytes.NewBufferString(`{"key":"k","value":"v"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestServerIntegration_ExportImportRoundTrip(t *testing.T) {
+	src, srcStore, _, cleanupSrc := setupIntegrationServer(t)
+	defer cleanupSrc()
+	dst, dstStore, _, cleanupDst := setupIntegrationServer(t)
+	defer cleanupDst()
+
+	// More pairs than one import batch, including awkward values
+	want := map[string]string{"quote": `say "hi"`, "newline": "a\nb", "empty": ""}
+	for i := 0; i < 2500; i++ {
+		want[fmt.Sprintf("key_%04d", i)] = fmt.Sprintf("value_%d", i)
+	}
+	for key, value := range want {
+		require.NoError(t, srcStore.Set(key, value))
+	}
+	require.NoError(t, srcStore.Delete("key_0000"))
+	delete(want, "key_0000")
+
+	resp, err := http.Get(src.URL + "/v1/export")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	resp2, err := http.Post(dst.URL+"/v1/import", "application/x-ndjson", resp.Body)
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	require.Equal(t, http.StatusOK, resp2.StatusCode)
+
+	var out types.ImportResponse
+	require.NoError(t, json.NewDecoder(resp2.Body).Decode(&out))
+	assert.True(t, out.Success)
+	assert.Equal(t, len(want), out.Imported)
+
+	keys, err := dstStore.List()
+	require.NoError(t, err)
+	assert.Len(t, keys, len(want))
+	for key, value := range want {
+		got, err := dstStore.Get(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, value, got, key)
+	}
+}
+
+func TestServerIntegration_Snapshot(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set("a", "1"))
+	require.NoError(t, s.Set("b", "2"))
+
+	resp, err := http.Get(ts.URL + "/v1/snapshot")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-tar", resp.Header.Get("Content-Type"))
+
+	restoredDir := t.TempDir()
+	require.NoError(t, store.RestoreSnapshot(restoredDir, resp.Body))
+	restored, err := store.New(zaptest.NewLogger(t), &config.Config{DataDir: restoredDir})
+	require.NoError(t, err)
+	defer restored.Close()
+	keys, err := restored.List()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, keys)
+
+	resp2, err := http.Post(ts.URL+"/v1/snapshot", "application/json", nil)
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp2.StatusCode)
+}
+
+func TestServerIntegration_ImportInvalid(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	body := `{"key":"a","value":"1"}` + "\n" + `{"key":` + "\n"
+	resp, err := http.Post(ts.URL+"/v1/import", "application/x-ndjson", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var out types.ImportResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.False(t, out.Success)
+	assert.Equal(t, 1, out.Imported, "Pairs before the bad record are kept")
+
+	value, err := s.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	resp2, err := http.Post(ts.URL+"/v1/import", "application/x-ndjson", bytes.NewBufferString(`{"key":"","value":"1"}`))
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp2.StatusCode)
+}
+
+func TestServerIntegration_ListKeysPagination(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	for _, key := range []string{"c", "a", "e", "b", "d"} {
+		require.NoError(t, s.Set(key, "value"))
+	}
+
+	var got []string
+	url := ts.URL + "/v1/keys?limit=2"
+	for {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var out types.ListKeysResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		resp.Body.Close()
+
+		got = append(got, out.Keys...)
+		if out.NextCursor == "" {
+			break
+		}
+		url = ts.URL + "/v1/keys?limit=2&cursor=" + out.NextCursor
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, got)
+
+	for _, query := range []string{"limit=0", "limit=abc", "limit=2&prefix=a"} {
+		resp, err := http.Get(ts.URL + "/v1/keys?" + query)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func TestServerIntegration_Metrics(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{DataDir: t.TempDir()}
+	s, err := store.New(logger, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	ts := httptest.NewServer(NewMux(engine.NewManager(engine.NewDB(s, metrics.New()), cfg, logger), cfg, logger))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/v1/kv", "application/json", bytes.NewBufferString(`{"key":"foo","value":"bar"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	for _, key := range []string{"foo", "missing"} {
+		resp, err := http.Get(ts.URL + "/v1/kv/" + key)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	resp, err = http.Get(ts.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		`logkv_operations_total{op="set",result="ok"} 1`,
+		`logkv_operations_total{op="get",result="hit"} 1`,
+		`logkv_operations_total{op="get",result="miss"} 1`,
+		`logkv_keys 1`,
+		`logkv_segments 1`,
+		`logkv_http_request_duration_seconds_count{code="404",method="GET",route="/v1/kv/"} 1`,
+	} {
+		assert.Contains(t, string(body), line)
+	}
+}
+
+func TestServerIntegration_ShutdownFlushesStore(t *testing.T) {
+	cfg := &config.Config{DataDir: t.TempDir(), HTTPAddr: "127.0.0.1:0"}
+
+	newApp := func(db **engine.DB) *fxtest.App {
+		return fxtest.New(t,
+			fx.Supply(cfg, zaptest.NewLogger(t)),
+			metrics.Module(),
+			Module(),
+			fx.Populate(db),
+		)
+	}
+
+	var db *engine.DB
+	app := newApp(&db)
+	app.RequireStart()
+	require.NoError(t, db.Set("foo", "first"))
+	require.NoError(t, db.Set("foo", "last"))
+	app.RequireStop()
+	assert.Error(t, db.Set("foo", "after stop"), "store should be closed")
+
+	var reopened *engine.DB
+	app = newApp(&reopened)
+	app.RequireStart()
+	defer app.RequireStop()
+
+	value, err := reopened.Get("foo")
+	require.NoError(t, err)
+	assert.Equal(t, "last", value)
+}
+
+func TestServerIntegration_CORSPreflightSkipsAuth(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{
+		DataDir:            t.TempDir(),
+		AuthToken:          "secret",
+		CORSAllowedOrigins: []string{"https://ui.example.com"},
+		CORSAllowedMethods: []string{"GET", "PUT"},
+		CORSAllowedHeaders: []string{"Authorization", "Content-Type"},
+	}
+	s, err := store.New(logger, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	ts := httptest.NewServer(NewMux(engine.NewManager(&engine.DB{Store: s}, cfg, logger), cfg, logger))
+	defer ts.Close()
+
+	// Browsers send preflights without credentials
+	req, _ := http.NewRequest(http.MethodOptions, ts.URL+"/v1/kv", nil)
+	req.Header.Set("Origin", "https://ui.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "authorization")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "https://ui.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Authorization, Content-Type", resp.Header.Get("Access-Control-Allow-Headers"))
+
+	// The actual request still needs the token, and the rejection carries
+	// CORS headers so the browser can read it
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/v1/keys", nil)
+	req.Header.Set("Origin", "https://ui.example.com")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "https://ui.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestServerIntegration_GzipListKeys(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{DataDir: t.TempDir(), GzipMinSize: 1024}
+	s, err := store.New(logger, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	ts := httptest.NewServer(NewMux(engine.NewManager(&engine.DB{Store: s}, cfg, logger), cfg, logger))
+	defer ts.Close()
+
+	pairs := make([]store.KeyValue, 2000)
+	for i := range pairs {
+		pairs[i] = store.KeyValue{Key: fmt.Sprintf("user:%05d", i), Value: "v"}
+	}
+	require.NoError(t, s.SetBatch(pairs))
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/keys", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	// Setting Accept-Encoding ourselves stops the transport decompressing transparently
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	zr, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	var out types.ListKeysResponse
+	require.NoError(t, json.NewDecoder(zr).Decode(&out))
+	assert.True(t, out.Success)
+	assert.Len(t, out.Keys, len(pairs))
+}
+
+func TestServerIntegration_Version(t *testing.T) {
+	ts, _, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	resp, err := http.Get(ts.URL + "/v1/version")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var out types.VersionResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.True(t, out.Success)
+	assert.Equal(t, version.Version, out.Version)
+	assert.NotEmpty(t, out.GoVersion)
+}
+
+func TestServerIntegration_Ping(t *testing.T) {
+	old := version.Version
+	version.Version = "v9.8.7-test" // As -ldflags -X would set it
+	defer func() { version.Version = old }()
+
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+	require.NoError(t, s.Set("a", "1"))
+	require.NoError(t, s.Set("b", "2"))
+
+	resp, err := http.Get(ts.URL + "/v1/ping")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var out types.PingResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.True(t, out.Success)
+	assert.Equal(t, "v9.8.7-test", out.Version)
+	assert.Positive(t, out.UptimeSeconds)
+	assert.Equal(t, 2, out.Keys)
+	assert.Equal(t, 1, out.Segments)
+
+	resp2, err := http.Post(ts.URL+"/v1/ping", "application/json", nil)
+	require.NoError(t, err)
+	resp2.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp2.StatusCode)
+}
+
+func TestServerIntegration_Health(t *testing.T) {
+	ts, s, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	resp, err := http.Get(ts.URL + "/livez")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, path := range []string{"/readyz", "/health"} {
+		resp, err := http.Get(ts.URL + path)
+		require.NoError(t, err)
+		var out types.HealthResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Equal(t, "ok", out.Status, path)
+	}
+
+	// A closed store has no active segment to write to
+	require.NoError(t, s.Close())
+	resp, err = http.Get(ts.URL + "/readyz")
+	require.NoError(t, err)
+	var out types.HealthResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "unavailable", out.Status)
+	assert.Equal(t, store.ErrNoActiveSegment.Error(), out.Message)
+}
+
+func TestServerIntegration_HealthUninitializedStore(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{}
+	ts := httptest.NewServer(NewMux(engine.NewManager(&engine.DB{Store: &store.Store{}}, cfg, logger), cfg, logger))
+	defer ts.Close()
+
+	for _, path := range []string{"/readyz", "/health"} {
+		resp, err := http.Get(ts.URL + path)
+		require.NoError(t, err)
+		var out types.HealthResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, path)
+		assert.Equal(t, "unavailable", out.Status, path)
+		assert.Equal(t, store.ErrStoreNotInitialized.Error(), out.Message, path)
+	}
+
+	resp, err := http.Get(ts.URL + "/livez")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "liveness does not depend on the store")
+}
+
+// readSSEEvent reads the next event from an event stream, skipping comments
+func readSSEEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event != "":
+			return event, data
+		}
+	}
+}
+
+func TestServerIntegration_Watch(t *testing.T) {
+	ts, _, _, cleanup := setupIntegrationServer(t)
+	defer cleanup()
+
+	resp, err := http.Get(ts.URL + "/v1/watch")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	put, err := http.Post(ts.URL+"/v1/kv", "application/json", bytes.NewBufferString(`{"key":"foo","value":"bar"}`))
+	require.NoError(t, err)
+	put.Body.Close()
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/v1/kv/foo", nil)
+	del, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	del.Body.Close()
+
+	body := bufio.NewReader(resp.Body)
+	for _, op := range []string{"set", "delete"} {
+		event, data := readSSEEvent(t, body)
+		assert.Equal(t, "change", event)
+		var ev types.WatchEvent
+		require.NoError(t, json.Unmarshal([]byte(data), &ev))
+		assert.Equal(t, "foo", ev.Key)
+		assert.Equal(t, op, ev.Op)
+		assert.InDelta(t, time.Now().Unix(), ev.Timestamp, 5)
+	}
+
+	post, err := http.Post(ts.URL+"/v1/watch", "application/json", nil)
+	require.NoError(t, err)
+	post.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, post.StatusCode)
+}
+
+func TestServerIntegration_WatchEndsOnShutdown(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{DataDir: t.TempDir()}
+	s, err := store.New(logger, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: NewMux(engine.NewManager(&engine.DB{Store: s}, cfg, logger), cfg, logger)}
+	go srv.Serve(ln)
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/v1/watch")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Shutdown waits for active requests, so an open stream must end by itself
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+	_, err = io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// hookWriter calls onWrite before its first write
+type hookWriter struct {
+	bytes.Buffer
+	once    sync.Once
+	onWrite func()
+}
+
+func (w *hookWriter) Write(p []byte) (int, error) {
+	w.once.Do(w.onWrite)
+	return w.Buffer.Write(p)
+}
+
+func TestStore_SnapshotRestore(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+
+	store, err := New(logger, &config.Config{DataDir: t.TempDir(), MaxEntriesPerSegment: 2})
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("b", "2"))
+	require.NoError(t, store.Set("c", "3"))
+	require.NoError(t, store.Delete("b"))
+
+	// Writes and compaction attempts made while the snapshot is being copied
+	// must not end up in it
+	var buf hookWriter
+	buf.onWrite = func() {
+		assert.NoError(t, store.Set("late", "value"))
+		assert.ErrorIs(t, store.Merge(), ErrMergeInProgress)
+	}
+	require.NoError(t, store.Snapshot(&buf))
+
+	restoredDir := filepath.Join(t.TempDir(), "restored")
+	require.NoError(t, RestoreSnapshot(restoredDir, bytes.NewReader(buf.Bytes())))
+
+	restored, err := New(logger, &config.Config{DataDir: restoredDir})
+	require.NoError(t, err)
+	defer restored.Close()
+
+	keys, err := restored.List()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "c"}, keys)
+	value, err := restored.Get("c")
+	require.NoError(t, err)
+	assert.Equal(t, "3", value)
+
+	value, err = store.Get("late")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestRestoreSnapshot_NonEmptyDir(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+
+	store, err := New(zaptest.NewLogger(t), &config.Config{DataDir: dataDir})
+	require.NoError(t, err)
+	require.NoError(t, store.Set("a", "1"))
+	var buf bytes.Buffer
+	require.NoError(t, store.Snapshot(&buf))
+	require.NoError(t, store.Close())
+
+	assert.ErrorIs(t, RestoreSnapshot(dataDir, &buf), ErrDataDirNotEmpty)
+}
+
+func TestRestoreSnapshot_RejectsUnexpectedFiles(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"../segment_1.log", "notes.txt", "segment_0.log", "sub/segment_1.log"} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1}))
+			_, err := io.WriteString(tw, "x")
+			require.NoError(t, err)
+			require.NoError(t, tw.Close())
+
+			dir := t.TempDir()
+			assert.ErrorIs(t, RestoreSnapshot(dir, &buf), ErrInvalidSnapshot)
+			entries, err := filepath.Glob(filepath.Join(dir, "*"))
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// cacheItem is a cached value and its content type together with the index
+// location it was read from
+type cacheItem struct {
+	key         string
+	value       []byte
+	contentType string
+	fileID      int
+	valuePos    int64
+}
+
+// valueCache is a size-bounded LRU of recently read values. A nil cache is
+// valid and caches nothing.
+type valueCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Front is most recently used
+	items    map[string]*list.Element
+	hits     atomic.Uint64
+	misses   atomic.Uint64
+}
+
+// newValueCache creates a cache holding up to capacity values, or nil when
+// capacity is not positive
+func newValueCache(capacity int) *valueCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &valueCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns a copy of the cached value for key and its content type if it
+// was read from the given location. A value cached from any other location is
+// stale and treated as a miss.
+func (c *valueCache) get(key string, fileID int, valuePos int64) ([]byte, string, bool) {
+	if c == nil {
+		return nil, "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, "", false
+	}
+	item := elem.Value.(*cacheItem)
+	if item.fileID != fileID || item.valuePos != valuePos {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		c.misses.Add(1)
+		return nil, "", false
+	}
+
+	c.order.MoveToFront(elem)
+	c.hits.Add(1)
+	return append([]byte(nil), item.value...), item.contentType, true
+}
+
+// put caches a value and its content type read from the given location,
+// evicting the least recently used value when full
+func (c *valueCache) put(key string, value []byte, contentType string, fileID int, valuePos int64) {
+	if c == nil {
+		return
+	}
+
+	value = append([]byte(nil), value...) // Callers may modify the slice they passed in
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value = &cacheItem{key: key, value: value, contentType: contentType, fileID: fileID, valuePos: valuePos}
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheItem{key: key, value: value, contentType: contentType, fileID: fileID, valuePos: valuePos})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheItem).key)
+	}
+}
+
+// remove drops key from the cache
+func (c *valueCache) remove(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// purge drops every cached value
+func (c *valueCache) purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// counters returns the hit and miss counts
+func (c *valueCache) counters() (uint64, uint64) {
+	if c == nil {
+		return 0, 0
+	}
+	return c.hits.Load(), c.misses.Load()
+}
+
+// missCache is a size-bounded LRU of keys recently found missing, so repeated
+// lookups of them skip the index. Every write of a key must remove it. A nil
+// cache is valid and remembers nothing.
+type missCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Front is most recently used; elements hold keys
+	items    map[string]*list.Element
+}
+
+// newMissCache creates a cache remembering up to capacity keys, or nil when
+// capacity is not positive
+func newMissCache(capacity int) *missCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &missCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// has reports whether key is remembered as missing
+func (c *missCache) has(key string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if ok {
+		c.order.MoveToFront(elem)
+	}
+	return ok
+}
+
+// add remembers key as missing, forgetting the least recently used key when
+// full
+func (c *missCache) add(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(key)
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(string))
+	}
+}
+
+// remove forgets key
+func (c *missCache) remove(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// purge forgets every key
+func (c *missCache) purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
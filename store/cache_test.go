@@ -0,0 +1,102 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueCache_LRUEviction(t *testing.T) {
+	t.Parallel()
+	c := newValueCache(2)
+
+	c.put("a", []byte("1"), "", 1, 0)
+	c.put("b", []byte("2"), "", 1, 10)
+	_, _, ok := c.get("a", 1, 0) // a is now most recently used
+	assert.True(t, ok)
+
+	c.put("c", []byte("3"), "", 1, 20)
+	_, _, ok = c.get("b", 1, 10)
+	assert.False(t, ok, "b should have been evicted")
+	value, _, ok := c.get("a", 1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	hits, misses := c.counters()
+	assert.Equal(t, uint64(2), hits)
+	assert.Equal(t, uint64(1), misses)
+}
+
+func TestValueCache_StaleLocation(t *testing.T) {
+	t.Parallel()
+	c := newValueCache(4)
+
+	c.put("a", []byte("old"), "", 1, 0)
+	_, _, ok := c.get("a", 2, 0)
+	assert.False(t, ok, "A value cached from another location must not be served")
+	_, _, ok = c.get("a", 1, 0)
+	assert.False(t, ok, "The stale value should have been dropped")
+}
+
+func TestValueCache_CopiesValues(t *testing.T) {
+	t.Parallel()
+	c := newValueCache(4)
+
+	value := []byte("value")
+	c.put("a", value, "", 1, 0)
+	value[0] = 'X'
+
+	cached, _, _ := c.get("a", 1, 0)
+	assert.Equal(t, []byte("value"), cached)
+	cached[0] = 'Y'
+	cached, _, _ = c.get("a", 1, 0)
+	assert.Equal(t, []byte("value"), cached)
+}
+
+func TestValueCache_ContentType(t *testing.T) {
+	t.Parallel()
+	c := newValueCache(4)
+
+	c.put("a", []byte("{}"), "application/json", 1, 0)
+	value, contentType, ok := c.get("a", 1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("{}"), value)
+	assert.Equal(t, "application/json", contentType)
+}
+
+func TestValueCache_Disabled(t *testing.T) {
+	t.Parallel()
+	c := newValueCache(0)
+	assert.Nil(t, c)
+
+	c.put("a", []byte("1"), "", 1, 0)
+	_, _, ok := c.get("a", 1, 0)
+	assert.False(t, ok)
+	c.remove("a")
+	c.purge()
+	hits, misses := c.counters()
+	assert.Zero(t, hits)
+	assert.Zero(t, misses)
+}
+
+func TestMissCache(t *testing.T) {
+	t.Parallel()
+	c := newMissCache(2)
+
+	c.add("a")
+	c.add("b")
+	assert.True(t, c.has("a")) // a is now most recently used
+	c.add("c")
+	assert.False(t, c.has("b"), "b should have been forgotten")
+	assert.True(t, c.has("a"))
+	assert.True(t, c.has("c"))
+
+	c.remove("a")
+	assert.False(t, c.has("a"))
+	c.purge()
+	assert.False(t, c.has("c"))
+
+	var disabled *missCache
+	disabled.add("a")
+	assert.False(t, disabled.has("a"))
+}
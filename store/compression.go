@@ -0,0 +1,96 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Codec compresses and decompresses entry values. Every compressed value is
+// stored behind its codec's ID, so values stay readable after the configured
+// codec changes as long as the old codec is still registered.
+type Codec interface {
+	ID() byte
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[byte]Codec{}
+)
+
+func init() {
+	RegisterCodec(GzipCodec{})
+}
+
+// RegisterCodec makes a codec available for writing by name and for reading
+// by ID, replacing any codec registered under the same ID
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	codecs[codec.ID()] = codec
+}
+
+// codecByID returns the registered codec with the given ID
+func codecByID(id byte) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	codec, ok := codecs[id]
+	return codec, ok
+}
+
+// ParseCodec returns the registered codec called name, or nil when name is
+// empty or "none"
+func ParseCodec(name string) (Codec, error) {
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	for _, codec := range codecs {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+}
+
+// GzipCodec compresses values with gzip
+type GzipCodec struct{}
+
+// ID returns the gzip codec ID
+func (GzipCodec) ID() byte { return 1 }
+
+// Name returns "gzip"
+func (GzipCodec) Name() string { return "gzip" }
+
+// Compress gzips data
+func (GzipCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress gunzips data
+func (GzipCodec) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
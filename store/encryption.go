@@ -0,0 +1,109 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+)
+
+// Cipher encrypts entry values with AES-GCM. Each value is sealed under its
+// own random nonce, stored in front of the ciphertext, with the entry's key
+// as additional data so that a value cannot be passed off as another key's.
+// Keys themselves are stored in plaintext.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher returns a Cipher using key, which must be 16, 24 or 32 bytes
+// long to select AES-128, AES-192 or AES-256
+func NewCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncryptionKey, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncryptionKey, err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// LoadCipher returns the Cipher for the key configured as hex, either
+// directly in EncryptionKey or in the file named by EncryptionKeyFile. It
+// returns nil when neither is set.
+func LoadCipher(cfg *config.Config) (*Cipher, error) {
+	encoded := cfg.EncryptionKey
+	if cfg.EncryptionKeyFile != "" {
+		data, err := os.ReadFile(cfg.EncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read encryption key: %w", err)
+		}
+		encoded = string(data)
+	}
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not hex: %v", ErrInvalidEncryptionKey, err)
+	}
+	return NewCipher(key)
+}
+
+// seal encrypts plaintext for the entry with the given key
+func (c *Cipher) seal(key, plaintext []byte) []byte {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	_, _ = rand.Read(nonce) // Never fails; see crypto/rand.Read
+	return c.aead.Seal(nonce, nonce, plaintext, key)
+}
+
+// open decrypts a value sealed for the entry with the given key
+func (c *Cipher) open(key, sealed []byte) ([]byte, error) {
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, key)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// checkEncryption reads the first value in the data directory and fails
+// unless it can be read with the store's cipher, and is encrypted exactly
+// when a cipher is configured. Index snapshots and hint files are loaded
+// without reading values, so a wrong key would otherwise go unnoticed until
+// the first read.
+func (s *Store) checkEncryption() error {
+	for _, segment := range s.segmentManager.Segments() {
+		size := segment.Size()
+		for pos := int64(0); pos < size; {
+			entry, err := segment.Read(pos)
+			if errors.Is(err, ErrEncryptionKeyRequired) || errors.Is(err, ErrDecryptionFailed) {
+				return err
+			}
+			if err != nil {
+				break // Damaged data is left to the load to deal with
+			}
+			if entry.IsTombstone() {
+				pos += int64(entry.Size())
+				continue
+			}
+			if s.cipher != nil && entry.Cipher == nil {
+				return ErrNotEncrypted
+			}
+			return nil
+		}
+	}
+	return nil
+}
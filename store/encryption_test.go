@@ -0,0 +1,184 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+const (
+	testKeyHex  = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	otherKeyHex = "ffeeddccbbaa99887766554433221100ffeeddccbbaa99887766554433221100"
+)
+
+func testCipher(t *testing.T, keyHex string) *Cipher {
+	t.Helper()
+	key, err := hex.DecodeString(keyHex)
+	require.NoError(t, err)
+	c, err := NewCipher(key)
+	require.NoError(t, err)
+	return c
+}
+
+func TestEntry_Encryption(t *testing.T) {
+	t.Parallel()
+	c := testCipher(t, testKeyHex)
+
+	newEntry := func(key string, value []byte) *Entry {
+		return &Entry{
+			Timestamp: uint32(time.Now().Unix()),
+			KeySize:   uint32(len(key)),
+			ValueSize: uint32(len(value)),
+			Key:       []byte(key),
+			Value:     value,
+			Cipher:    c,
+		}
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		data := newEntry("key", []byte("secret value")).Serialize()
+		assert.False(t, bytes.Contains(data, []byte("secret value")), "the value must not be stored in plaintext")
+
+		entry, err := deserializeEntry(data, c)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("secret value"), entry.Value)
+		assert.Equal(t, len(data), entry.Size())
+
+		// Re-serializing a read entry, as compaction does, keeps it readable
+		entry, err = deserializeEntry(entry.Serialize(), c)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("secret value"), entry.Value)
+	})
+
+	t.Run("Compressed", func(t *testing.T) {
+		value := []byte(strings.Repeat("compressible ", 100))
+		original := newEntry("key", value)
+		original.Codec = GzipCodec{}
+		data := original.Serialize()
+		assert.Less(t, len(data), len(value), "values are compressed before they are encrypted")
+
+		entry, err := deserializeEntry(data, c)
+		require.NoError(t, err)
+		assert.Equal(t, value, entry.Value)
+	})
+
+	t.Run("Fresh Nonce Per Entry", func(t *testing.T) {
+		a := newEntry("key", []byte("same")).Serialize()
+		b := newEntry("key", []byte("same")).Serialize()
+		assert.NotEqual(t, a, b)
+	})
+
+	t.Run("Tombstones Stay Plain", func(t *testing.T) {
+		data := newEntry("key", nil).Serialize()
+		entry, err := DeserializeEntry(data)
+		require.NoError(t, err)
+		assert.True(t, entry.IsTombstone())
+	})
+
+	t.Run("Fails Closed", func(t *testing.T) {
+		data := newEntry("key", []byte("secret")).Serialize()
+
+		_, err := DeserializeEntry(data)
+		assert.ErrorIs(t, err, ErrEncryptionKeyRequired)
+		_, err = deserializeEntry(data, testCipher(t, otherKeyHex))
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+		// A value moved to another key does not decrypt. The key is the same
+		// length, so only the key bytes differ.
+		moved := bytes.Clone(data)
+		copy(moved[headerSize:], "kez")
+		binary.LittleEndian.PutUint32(moved[12:16], checksum([]byte("kez"), moved[headerSize+3:]))
+		_, err = deserializeEntry(moved, c)
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+	})
+}
+
+func TestLoadCipher(t *testing.T) {
+	t.Parallel()
+
+	c, err := LoadCipher(&config.Config{})
+	require.NoError(t, err)
+	assert.Nil(t, c, "encryption is off without a key")
+
+	c, err = LoadCipher(&config.Config{EncryptionKey: testKeyHex})
+	require.NoError(t, err)
+	assert.NotNil(t, c)
+
+	keyFile := filepath.Join(t.TempDir(), "key.hex")
+	require.NoError(t, os.WriteFile(keyFile, []byte(testKeyHex+"\n"), 0600))
+	c, err = LoadCipher(&config.Config{EncryptionKeyFile: keyFile})
+	require.NoError(t, err)
+	assert.NotNil(t, c)
+
+	for _, key := range []string{"not hex", "0011"} {
+		_, err = LoadCipher(&config.Config{EncryptionKey: key})
+		assert.ErrorIs(t, err, ErrInvalidEncryptionKey, key)
+	}
+	_, err = LoadCipher(&config.Config{EncryptionKeyFile: filepath.Join(t.TempDir(), "missing")})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestStore_Encryption(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	dataDir := t.TempDir()
+	cfg := &config.Config{DataDir: dataDir, EncryptionKey: testKeyHex, MaxEntriesPerSegment: 2}
+
+	store, err := New(logger, cfg)
+	require.NoError(t, err)
+	require.NoError(t, store.Set("a", "secret-a"))
+	require.NoError(t, store.SetBatch([]KeyValue{{Key: "b", Value: "secret-b"}, {Key: "c", Value: "secret-c"}}))
+	require.NoError(t, store.Set("a", "secret-a2"))
+	require.NoError(t, store.Delete("c"))
+	require.NoError(t, store.Merge())
+	require.NoError(t, store.Close())
+
+	files, err := filepath.Glob(filepath.Join(dataDir, "segment_*.log"))
+	require.NoError(t, err)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.False(t, bytes.Contains(data, []byte("secret")), "%s holds a plaintext value", file)
+	}
+
+	store, err = New(logger, cfg)
+	require.NoError(t, err)
+	for key, want := range map[string]string{"a": "secret-a2", "b": "secret-b"} {
+		value, err := store.Get(key)
+		require.NoError(t, err)
+		assert.Equal(t, want, value)
+	}
+	_, err = store.Get("c")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	require.NoError(t, store.Close())
+
+	// The index snapshot alone would load, so the key must be checked up front
+	_, err = New(logger, &config.Config{DataDir: dataDir})
+	assert.ErrorIs(t, err, ErrEncryptionKeyRequired)
+	_, err = New(logger, &config.Config{DataDir: dataDir, EncryptionKey: otherKeyHex})
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+}
+
+func TestStore_EncryptionOnPlainData(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	dataDir := t.TempDir()
+
+	store, err := New(logger, &config.Config{DataDir: dataDir})
+	require.NoError(t, err)
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Close())
+
+	_, err = New(logger, &config.Config{DataDir: dataDir, EncryptionKey: testKeyHex})
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+}
@@ -2,16 +2,61 @@ package store
 
 import (
 	"encoding/binary"
+	"fmt"
+	"hash/crc32"
 	"time"
 )
 
+const (
+	// headerSize is the on-disk header size: timestamp + keysize + valuesize + crc
+	headerSize = 16
+
+	// legacyHeaderSize is the header size of entries written before checksums existed
+	legacyHeaderSize = 12
+
+	// flagChecksum marks an entry written with a CRC32 in its header. It lives in
+	// the high bit of the key size field, which legacy entries never set.
+	flagChecksum uint32 = 1 << 31
+
+	// flagExpiry marks an entry carrying a 4 byte expiry timestamp after the checksum
+	flagExpiry uint32 = 1 << 30
+
+	// flagCompressed marks an entry whose value is stored compressed, prefixed
+	// with the ID of the codec that compressed it
+	flagCompressed uint32 = 1 << 29
+
+	// flagEncrypted marks an entry whose value is sealed by a Cipher, after
+	// any compression
+	flagEncrypted uint32 = 1 << 28
+
+	// flagContentType marks an entry whose value, before compression and
+	// encryption, is prefixed with a length byte and a content type
+	flagContentType uint32 = 1 << 27
+
+	// maxContentTypeSize is the longest content type an entry can carry
+	maxContentTypeSize = 255
+
+	// keySizeMask extracts the key size from the key size field
+	keySizeMask uint32 = 0x00FFFFFF
+)
+
 // Entry represents a single entry in the append-only log
 type Entry struct {
-	Timestamp uint32 // Unix timestamp
-	KeySize   uint32 // Size of the key in bytes
-	ValueSize uint32 // Size of the value in bytes
-	Key       []byte // Key data
-	Value     []byte // Value data
+	Timestamp   uint32  // Unix timestamp
+	KeySize     uint32  // Size of the key in bytes
+	ValueSize   uint32  // Size of the value in bytes
+	Checksum    uint32  // CRC32 of key + value
+	ExpiresAt   uint32  // Unix timestamp after which the entry is expired (0 = never)
+	Key         []byte  // Key data
+	Value       []byte  // Value data
+	ContentType string  // Media type of Value (empty = none)
+	Codec       Codec   // Compresses Value on Serialize when set; set on entries read back compressed
+	Cipher      *Cipher // Encrypts Value on Serialize when set; set on entries read back encrypted
+
+	legacy     bool   // Entry uses the pre-checksum 12 byte header
+	compressed []byte // Value after compression, when Codec compressed it
+	sealed     []byte // On-disk value when Cipher encrypted it
+	buf        []byte // Holds Key and the on-disk value of a decoded entry
 }
 
 // TombstoneEntry represents a deleted entry (tombstone)
@@ -30,13 +75,98 @@ func (e *Entry) IsTombstone() bool {
 	return e.ValueSize == 0
 }
 
+// IsExpired checks if the entry has an expiry that has passed at now
+func (e *Entry) IsExpired(now time.Time) bool {
+	return e.ExpiresAt != 0 && uint32(now.Unix()) >= e.ExpiresAt
+}
+
 // Size returns the total size of the entry in bytes
 func (e *Entry) Size() int {
-	return 12 + int(e.KeySize) + int(e.ValueSize) // 12 bytes for timestamp + keysize + valuesize
+	return e.headerSize() + int(e.KeySize) + int(e.ValueSize)
+}
+
+// headerSize returns the header size for the entry's on-disk format
+func (e *Entry) headerSize() int {
+	if e.legacy {
+		return legacyHeaderSize
+	}
+	if e.ExpiresAt != 0 {
+		return headerSize + 4
+	}
+	return headerSize
+}
+
+// checksum computes the CRC32 of the key and value data
+func checksum(key, value []byte) uint32 {
+	crc := crc32.NewIEEE()
+	crc.Write(key)
+	crc.Write(value)
+	return crc.Sum32()
+}
+
+// payload returns the value as it is stored before compression and
+// encryption: the value itself, or the value prefixed with its content type
+func (e *Entry) payload() []byte {
+	if e.ContentType == "" || e.legacy {
+		return e.Value
+	}
+	p := make([]byte, 0, 1+len(e.ContentType)+len(e.Value))
+	p = append(p, byte(len(e.ContentType)))
+	p = append(p, e.ContentType...)
+	return append(p, e.Value...)
+}
+
+// compress prepares the on-disk value from the payload. When the entry has a
+// codec and compressing shrinks the payload, ValueSize is updated to the
+// compressed size.
+func (e *Entry) compress(payload []byte) {
+	e.compressed = nil
+	if e.Codec == nil || e.legacy || len(payload) == 0 {
+		return
+	}
+
+	data, err := e.Codec.Compress(payload)
+	if err != nil || len(data)+1 >= len(payload) {
+		// Not worth it; store the payload as is
+		e.ValueSize = uint32(len(payload))
+		return
+	}
+
+	e.compressed = append([]byte{e.Codec.ID()}, data...)
+	e.ValueSize = uint32(len(e.compressed))
+}
+
+// encrypt seals the (possibly compressed) payload when the entry has a
+// cipher, updating ValueSize to the sealed size
+func (e *Entry) encrypt(payload []byte) {
+	e.sealed = nil
+	value := payload
+	if e.compressed != nil {
+		value = e.compressed
+	}
+	if e.Cipher == nil || e.legacy || len(value) == 0 {
+		return
+	}
+
+	e.sealed = e.Cipher.seal(e.Key, value)
+	e.ValueSize = uint32(len(e.sealed))
 }
 
 // Serialize converts the entry to bytes for writing to disk
 func (e *Entry) Serialize() []byte {
+	value := e.payload()
+	if e.ContentType != "" && !e.legacy {
+		e.ValueSize = uint32(len(value))
+	}
+	e.compress(value)
+	e.encrypt(value)
+	switch {
+	case e.sealed != nil:
+		value = e.sealed
+	case e.compressed != nil:
+		value = e.compressed
+	}
+
 	buf := make([]byte, e.Size())
 	offset := 0
 
@@ -44,57 +174,182 @@ func (e *Entry) Serialize() []byte {
 	binary.LittleEndian.PutUint32(buf[offset:], e.Timestamp)
 	offset += 4
 
-	// Write key size (4 bytes)
-	binary.LittleEndian.PutUint32(buf[offset:], e.KeySize)
+	// Write key size (4 bytes), flagged when a checksum follows
+	keyField := e.KeySize
+	if !e.legacy {
+		keyField |= flagChecksum
+		if e.ExpiresAt != 0 {
+			keyField |= flagExpiry
+		}
+		if e.compressed != nil {
+			keyField |= flagCompressed
+		}
+		if e.sealed != nil {
+			keyField |= flagEncrypted
+		}
+		if e.ContentType != "" {
+			keyField |= flagContentType
+		}
+	}
+	binary.LittleEndian.PutUint32(buf[offset:], keyField)
 	offset += 4
 
 	// Write value size (4 bytes)
 	binary.LittleEndian.PutUint32(buf[offset:], e.ValueSize)
 	offset += 4
 
+	// Write checksum (4 bytes)
+	if !e.legacy {
+		e.Checksum = checksum(e.Key, value)
+		binary.LittleEndian.PutUint32(buf[offset:], e.Checksum)
+		offset += 4
+	}
+
+	// Write expiry (4 bytes)
+	if !e.legacy && e.ExpiresAt != 0 {
+		binary.LittleEndian.PutUint32(buf[offset:], e.ExpiresAt)
+		offset += 4
+	}
+
 	// Write key data
 	copy(buf[offset:], e.Key)
 	offset += int(e.KeySize)
 
 	// Write value data
 	if e.ValueSize > 0 {
-		copy(buf[offset:], e.Value)
+		copy(buf[offset:], value)
 	}
 
 	return buf
 }
 
-// DeserializeEntry creates an entry from bytes read from disk
-func DeserializeEntry(data []byte) (*Entry, error) {
-	if len(data) < 12 {
-		return nil, ErrInvalidEntry
+// decodeHeaderPrefix parses the first legacyHeaderSize bytes of an entry and
+// returns the full header size, the header flags, and the key and value sizes
+func decodeHeaderPrefix(data []byte) (int, uint32, uint32, uint32) {
+	keyField := binary.LittleEndian.Uint32(data[4:8])
+	valueSize := binary.LittleEndian.Uint32(data[8:12])
+
+	if keyField&flagChecksum == 0 {
+		return legacyHeaderSize, 0, keyField, valueSize
 	}
 
+	flags := keyField &^ keySizeMask
+	size := headerSize
+	if flags&flagExpiry != 0 {
+		size += 4
+	}
+	return size, flags, keyField & keySizeMask, valueSize
+}
+
+// DeserializeEntry creates an entry from bytes read from disk. Encrypted
+// entries need a cipher; see deserializeEntry.
+func DeserializeEntry(data []byte) (*Entry, error) {
+	return deserializeEntry(data, nil)
+}
+
+// deserializeEntry creates an entry from bytes read from disk, decrypting
+// its value with c if it is encrypted
+func deserializeEntry(data []byte, c *Cipher) (*Entry, error) {
 	entry := &Entry{}
+	if err := decodeEntry(data, c, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// decodeEntry is deserializeEntry into an existing entry. The key and value
+// are copied into a single buffer, which is kept for the next decodeEntry
+// into the same entry, so data may be reused once it returns.
+func decodeEntry(data []byte, c *Cipher, entry *Entry) error {
+	if len(data) < legacyHeaderSize {
+		return ErrInvalidEntry
+	}
+
+	*entry = Entry{buf: entry.buf}
 
 	// Read timestamp
 	entry.Timestamp = binary.LittleEndian.Uint32(data[0:4])
 
-	// Read key size
-	entry.KeySize = binary.LittleEndian.Uint32(data[4:8])
-
-	// Read value size
-	entry.ValueSize = binary.LittleEndian.Uint32(data[8:12])
+	// Read key size, value size and header format
+	hdrSize, flags, keySize, valueSize := decodeHeaderPrefix(data)
+	entry.KeySize = keySize
+	entry.ValueSize = valueSize
+	entry.legacy = hdrSize == legacyHeaderSize
 
 	// Validate sizes
-	if int(entry.KeySize)+int(entry.ValueSize) != len(data)-12 {
-		return nil, ErrInvalidEntry
+	dataSize := int(entry.KeySize) + int(entry.ValueSize)
+	if len(data) < hdrSize || dataSize != len(data)-hdrSize {
+		return ErrInvalidEntry
 	}
 
-	// Read key data
-	entry.Key = make([]byte, entry.KeySize)
-	copy(entry.Key, data[12:12+entry.KeySize])
+	// Every entry written has a key, so an empty one means these bytes are
+	// not an entry, such as a zero-filled hole in the file
+	if entry.KeySize == 0 {
+		return ErrInvalidEntry
+	}
 
-	// Read value data
+	// Read key and value data
+	if cap(entry.buf) < dataSize {
+		entry.buf = make([]byte, dataSize)
+	}
+	buf := entry.buf[:dataSize]
+	copy(buf, data[hdrSize:])
+	entry.Key = buf[:entry.KeySize:entry.KeySize]
 	if entry.ValueSize > 0 {
-		entry.Value = make([]byte, entry.ValueSize)
-		copy(entry.Value, data[12+entry.KeySize:12+entry.KeySize+entry.ValueSize])
+		entry.Value = buf[entry.KeySize:]
 	}
 
-	return entry, nil
+	// Read expiry
+	if flags&flagExpiry != 0 {
+		entry.ExpiresAt = binary.LittleEndian.Uint32(data[16:20])
+	}
+
+	// Verify checksum
+	if !entry.legacy {
+		entry.Checksum = binary.LittleEndian.Uint32(data[12:16])
+		if checksum(entry.Key, entry.Value) != entry.Checksum {
+			return ErrCorruptEntry
+		}
+	}
+
+	// Decrypt, decompress, then strip any content type from the value;
+	// ValueSize keeps the on-disk size
+	if flags&flagEncrypted != 0 {
+		if c == nil {
+			return ErrEncryptionKeyRequired
+		}
+		value, err := c.open(entry.Key, entry.Value)
+		if err != nil {
+			return err
+		}
+		entry.Cipher = c
+		entry.sealed = entry.Value
+		entry.Value = value
+	}
+	if flags&flagCompressed != 0 {
+		if len(entry.Value) == 0 {
+			return ErrCorruptEntry
+		}
+		codec, ok := codecByID(entry.Value[0])
+		if !ok {
+			return fmt.Errorf("%w: id %d", ErrUnknownCodec, entry.Value[0])
+		}
+		value, err := codec.Decompress(entry.Value[1:])
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCorruptEntry, err)
+		}
+		entry.Codec = codec
+		entry.compressed = entry.Value
+		entry.Value = value
+	}
+	if flags&flagContentType != 0 {
+		if len(entry.Value) == 0 || len(entry.Value) < 1+int(entry.Value[0]) {
+			return ErrCorruptEntry
+		}
+		n := 1 + int(entry.Value[0])
+		entry.ContentType = string(entry.Value[1:n])
+		entry.Value = entry.Value[n:]
+	}
+
+	return nil
 }
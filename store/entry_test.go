@@ -2,10 +2,12 @@ package store
 
 import (
 	"encoding/binary"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEntry_IsTombstone(t *testing.T) {
@@ -58,15 +60,15 @@ func TestEntry_TombstoneEntry(t *testing.T) {
 func TestEntry_Size(t *testing.T) {
 	t.Parallel()
 
-	// Fixed header size: Timestamp (4) + KeySize (4) + ValueSize (4) = 12 bytes
-	const headerSize = 12
+	// Fixed header size: Timestamp (4) + KeySize (4) + ValueSize (4) + Checksum (4) = 16 bytes
+	const headerSize = 16
 
 	t.Run("Zero Size", func(t *testing.T) {
 		entry := &Entry{
 			KeySize:   0,
 			ValueSize: 0,
 		}
-		assert.Equal(t, headerSize, entry.Size(), "Size should be 12 bytes for zero key/value")
+		assert.Equal(t, headerSize, entry.Size(), "Size should be 16 bytes for zero key/value")
 	})
 
 	t.Run("Standard Entry", func(t *testing.T) {
@@ -163,4 +165,185 @@ func TestDeserializeEntry_Errors(t *testing.T) {
 		_, err := DeserializeEntry(data)
 		assert.ErrorIs(t, err, ErrInvalidEntry, "Should fail if actual data length does not match sizes in header")
 	})
+
+	// Case 3: Empty key, as read from a zero-filled hole in a segment
+	t.Run("Empty Key", func(t *testing.T) {
+		_, err := DeserializeEntry(make([]byte, 12))
+		assert.ErrorIs(t, err, ErrInvalidEntry, "Should fail if the entry has no key")
+	})
+}
+
+func TestDeserializeEntry_Checksum(t *testing.T) {
+	t.Parallel()
+
+	original := &Entry{
+		Timestamp: uint32(time.Now().Unix()),
+		KeySize:   5,
+		ValueSize: 7,
+		Key:       []byte("mykey"),
+		Value:     []byte("myvalue"),
+	}
+
+	t.Run("Checksum Round Trip", func(t *testing.T) {
+		data := original.Serialize()
+		deserialized, err := DeserializeEntry(data)
+
+		assert.NoError(t, err)
+		assert.NotZero(t, deserialized.Checksum, "Checksum should be populated")
+		assert.Equal(t, original.Checksum, deserialized.Checksum)
+	})
+
+	t.Run("Corrupt Value", func(t *testing.T) {
+		data := original.Serialize()
+		data[len(data)-1] ^= 0xFF // Flip bits in the last value byte
+
+		_, err := DeserializeEntry(data)
+		assert.ErrorIs(t, err, ErrCorruptEntry, "Should fail if the value does not match the checksum")
+	})
+
+	t.Run("Corrupt Checksum", func(t *testing.T) {
+		data := original.Serialize()
+		data[12] ^= 0xFF // Flip bits in the checksum field
+
+		_, err := DeserializeEntry(data)
+		assert.ErrorIs(t, err, ErrCorruptEntry, "Should fail if the stored checksum is damaged")
+	})
+}
+
+func TestDeserializeEntry_Legacy(t *testing.T) {
+	t.Parallel()
+
+	// Legacy 12-byte header: timestamp + keysize + valuesize, no checksum
+	key := []byte("oldkey")
+	value := []byte("oldvalue")
+	data := make([]byte, 12+len(key)+len(value))
+	binary.LittleEndian.PutUint32(data[0:], 1678886400)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(data[8:], uint32(len(value)))
+	copy(data[12:], key)
+	copy(data[12+len(key):], value)
+
+	entry, err := DeserializeEntry(data)
+	assert.NoError(t, err, "Legacy entries must still be readable")
+	assert.Equal(t, key, entry.Key)
+	assert.Equal(t, value, entry.Value)
+	assert.Equal(t, len(data), entry.Size(), "Size should reflect the legacy header")
+	assert.Equal(t, data, entry.Serialize(), "Legacy entries should re-serialize in their original format")
+}
+
+func TestEntry_Compression(t *testing.T) {
+	t.Parallel()
+
+	value := []byte(strings.Repeat(`{"field":"compressible"}`, 100))
+	newEntry := func(value []byte) *Entry {
+		return &Entry{
+			Timestamp: uint32(time.Now().Unix()),
+			KeySize:   3,
+			ValueSize: uint32(len(value)),
+			Key:       []byte("key"),
+			Value:     value,
+			Codec:     GzipCodec{},
+		}
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		original := newEntry(value)
+		data := original.Serialize()
+
+		assert.Less(t, int(original.ValueSize), len(value), "ValueSize should be the compressed size")
+		assert.Equal(t, original.Size(), len(data))
+
+		deserialized, err := DeserializeEntry(data)
+		require.NoError(t, err)
+		assert.Equal(t, value, deserialized.Value)
+		assert.Equal(t, original.ValueSize, deserialized.ValueSize)
+		assert.Equal(t, len(data), deserialized.Size())
+	})
+
+	t.Run("Incompressible Value", func(t *testing.T) {
+		original := newEntry([]byte("tiny"))
+		data := original.Serialize()
+
+		assert.Equal(t, uint32(4), original.ValueSize, "Values that don't shrink are stored as is")
+		deserialized, err := DeserializeEntry(data)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("tiny"), deserialized.Value)
+		assert.Nil(t, deserialized.Codec)
+	})
+
+	t.Run("Unknown Codec", func(t *testing.T) {
+		original := newEntry(value)
+		original.Serialize()
+		original.compressed[0] = 0xEE
+
+		// Rebuild the bytes around the altered codec ID with a valid checksum
+		data := make([]byte, 0, original.Size())
+		data = binary.LittleEndian.AppendUint32(data, original.Timestamp)
+		data = binary.LittleEndian.AppendUint32(data, original.KeySize|flagChecksum|flagCompressed)
+		data = binary.LittleEndian.AppendUint32(data, original.ValueSize)
+		data = binary.LittleEndian.AppendUint32(data, checksum(original.Key, original.compressed))
+		data = append(data, original.Key...)
+		data = append(data, original.compressed...)
+
+		_, err := DeserializeEntry(data)
+		assert.ErrorIs(t, err, ErrUnknownCodec)
+	})
+}
+
+func TestEntry_ContentType(t *testing.T) {
+	t.Parallel()
+
+	value := []byte(strings.Repeat(`{"field":"compressible"}`, 100))
+	for name, entry := range map[string]*Entry{
+		"Plain":      {Key: []byte("key"), Value: value},
+		"Compressed": {Key: []byte("key"), Value: value, Codec: GzipCodec{}},
+		"Encrypted":  {Key: []byte("key"), Value: value, Codec: GzipCodec{}, Cipher: testCipher(t, testKeyHex)},
+		"Empty":      {Key: []byte("key")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			entry.Timestamp = uint32(time.Now().Unix())
+			entry.KeySize = uint32(len(entry.Key))
+			entry.ContentType = "application/json"
+			data := entry.Serialize()
+			assert.Equal(t, entry.Size(), len(data))
+			assert.False(t, entry.IsTombstone(), "A content type makes even an empty value a value")
+
+			deserialized, err := deserializeEntry(data, entry.Cipher)
+			require.NoError(t, err)
+			assert.Equal(t, "application/json", deserialized.ContentType)
+			assert.Equal(t, len(entry.Value), len(deserialized.Value))
+			assert.Equal(t, string(entry.Value), string(deserialized.Value))
+			assert.Equal(t, entry.ValueSize, deserialized.ValueSize)
+		})
+	}
+
+	t.Run("Malformed Prefix", func(t *testing.T) {
+		// A content type length running past the end of the value
+		value := []byte{10, 'a'}
+		data := make([]byte, 0, headerSize+3+len(value))
+		data = binary.LittleEndian.AppendUint32(data, uint32(time.Now().Unix()))
+		data = binary.LittleEndian.AppendUint32(data, 3|flagChecksum|flagContentType)
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(value)))
+		data = binary.LittleEndian.AppendUint32(data, checksum([]byte("key"), value))
+		data = append(data, "key"...)
+		data = append(data, value...)
+
+		_, err := DeserializeEntry(data)
+		assert.ErrorIs(t, err, ErrCorruptEntry)
+	})
+}
+
+func TestParseCodec(t *testing.T) {
+	t.Parallel()
+
+	codec, err := ParseCodec("")
+	assert.NoError(t, err)
+	assert.Nil(t, codec)
+
+	codec, err = ParseCodec("gzip")
+	assert.NoError(t, err)
+	assert.Equal(t, GzipCodec{}, codec)
+
+	_, err = ParseCodec("lz4")
+	assert.ErrorIs(t, err, ErrUnknownCodec)
 }
@@ -1,6 +1,9 @@
 package store
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrKeyNotFound is returned when a key is not found
@@ -9,12 +12,124 @@ var (
 	// ErrInvalidEntry is returned when an entry cannot be deserialized
 	ErrInvalidEntry = errors.New("invalid entry")
 
+	// ErrCorruptEntry is returned when an entry's checksum does not match its data
+	ErrCorruptEntry = errors.New("corrupt entry: checksum mismatch")
+
+	// ErrTruncatedEntry is returned when an entry extends past the end of its segment,
+	// usually because a write was interrupted
+	ErrTruncatedEntry = errors.New("truncated entry")
+
+	// ErrCorruptHint is returned when a hint file is truncated, fails its checksum,
+	// or does not match the segment it describes
+	ErrCorruptHint = errors.New("corrupt hint file")
+
+	// ErrCorruptSnapshot is returned when the index snapshot fails its checksum or
+	// no longer matches the segments on disk
+	ErrCorruptSnapshot = errors.New("corrupt index snapshot")
+
 	// ErrSegmentClosed is returned when trying to write to a closed segment
 	ErrSegmentClosed = errors.New("segment is closed")
 
 	// ErrSegmentFull is returned when a segment has reached its maximum size
 	ErrSegmentFull = errors.New("segment is full")
 
+	// ErrSegmentNotFound is returned when a segment ID is not managed, usually
+	// because compaction removed it
+	ErrSegmentNotFound = errors.New("segment not found")
+
+	// ErrNoActiveSegment is returned when appending to a closed segment manager
+	ErrNoActiveSegment = errors.New("no active segment")
+
+	// ErrPositionOutOfRange is returned when reading past the end of a segment
+	ErrPositionOutOfRange = errors.New("position out of range")
+
+	// ErrStoreNotInitialized is returned when a store has no segment manager,
+	// because it was not created by New or its data directory was unusable
+	ErrStoreNotInitialized = errors.New("store not properly initialized")
+
+	// ErrEmptyKey is returned when writing an empty key
+	ErrEmptyKey = errors.New("key must not be empty")
+
+	// ErrKeyTooLarge is returned when a key exceeds the configured maximum size
+	ErrKeyTooLarge = errors.New("key too large")
+
+	// ErrValueTooLarge is returned when a value exceeds the configured maximum size
+	ErrValueTooLarge = errors.New("value too large")
+
+	// ErrContentTypeTooLarge is returned when a content type exceeds 255 bytes
+	ErrContentTypeTooLarge = errors.New("content type too large")
+
+	// ErrInvalidTTL is returned when a non-positive TTL is given
+	ErrInvalidTTL = errors.New("ttl must be positive")
+
+	// ErrNotInteger is returned when incrementing a value that is not an integer
+	ErrNotInteger = errors.New("value is not an integer")
+
+	// ErrIntegerOverflow is returned when an increment would overflow int64
+	ErrIntegerOverflow = errors.New("increment would overflow")
+
+	// ErrInvalidSyncMode is returned when the configured sync mode is unknown
+	ErrInvalidSyncMode = errors.New("invalid sync mode")
+
+	// ErrUnknownCodec is returned for a compression codec that is not registered
+	ErrUnknownCodec = errors.New("unknown compression codec")
+
+	// ErrInvalidEncryptionKey is returned when the configured encryption key is
+	// not a hex-encoded AES key
+	ErrInvalidEncryptionKey = errors.New("invalid encryption key")
+
+	// ErrEncryptionKeyRequired is returned when reading an encrypted value
+	// with no encryption key configured
+	ErrEncryptionKeyRequired = errors.New("value is encrypted but no encryption key is configured")
+
+	// ErrDecryptionFailed is returned when a value cannot be decrypted with
+	// the configured key, because the key is wrong or the value was altered
+	ErrDecryptionFailed = errors.New("cannot decrypt value: wrong encryption key or tampered data")
+
+	// ErrNotEncrypted is returned when opening unencrypted data with an
+	// encryption key configured
+	ErrNotEncrypted = errors.New("data is not encrypted but an encryption key is configured")
+
 	// ErrMergeInProgress prevents concurrent compactions.
 	ErrMergeInProgress = errors.New("merge in progress")
+
+	// ErrUnfinishedMerge is returned when opening read-only a data directory
+	// in which a merge was committed but not finished; opening it writable
+	// finishes the merge
+	ErrUnfinishedMerge = errors.New("data directory has an unfinished merge")
+
+	// ErrReloadWritable is returned when reloading a writable store, which
+	// is the only writer to its data directory
+	ErrReloadWritable = errors.New("only a read-only store can reload")
+
+	// ErrReadOnly is returned for writes and compactions on a store opened
+	// read-only
+	ErrReadOnly = errors.New("store is read-only")
+
+	// ErrDataDirLocked is returned when opening a data directory that
+	// another store already has open
+	ErrDataDirLocked = errors.New("data directory is locked by another process")
+
+	// ErrDataDirNotEmpty is returned when restoring a snapshot into a
+	// directory that already holds segments
+	ErrDataDirNotEmpty = errors.New("data directory already contains segments")
+
+	// ErrInvalidSnapshot is returned when a snapshot archive holds anything
+	// other than segment files
+	ErrInvalidSnapshot = errors.New("invalid snapshot archive")
 )
+
+// BatchError is returned when a batch write fails part way through.
+// Entries before the failure were written and remain visible.
+type BatchError struct {
+	Written int   // Number of entries written before the failure
+	Err     error // Underlying write error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch write failed after %d entries: %v", e.Written, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
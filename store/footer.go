@@ -0,0 +1,90 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// footerSize is magic + data size + entry count + data crc + footer crc
+const footerSize = 28
+
+// footerMagic starts every segment footer
+var footerMagic = []byte("LKVFOOT1")
+
+// footerState describes what OpenSegment found at the end of a segment
+type footerState int
+
+const (
+	footerMissing    footerState = iota // No intact footer; the entries are counted when first asked for
+	footerUnverified                    // The footer is intact; the data is checked against it by verify
+	footerValid                         // verify found the data matches its footer
+	footerMismatch                      // verify found the data no longer matches its footer
+)
+
+// encodeFooter returns the footer for a segment holding entries entries in
+// its first dataSize bytes, whose CRC32 is crc
+func encodeFooter(dataSize int64, entries int, crc uint32) []byte {
+	footer := make([]byte, footerSize)
+	copy(footer[0:8], footerMagic)
+	binary.LittleEndian.PutUint64(footer[8:16], uint64(dataSize))
+	binary.LittleEndian.PutUint32(footer[16:20], uint32(entries))
+	binary.LittleEndian.PutUint32(footer[20:24], crc)
+	binary.LittleEndian.PutUint32(footer[24:28], crc32.ChecksumIEEE(footer[:24]))
+	return footer
+}
+
+// segmentLayout works out how many bytes of entries a segment file of
+// fileSize bytes holds, how many entries they are and their CRC32, reading
+// only the footer. Without an intact footer the whole file is data and the
+// entry count is -1, for the caller to count when it is needed; the data is
+// never read here, so opening a segment stays cheap.
+func segmentLayout(file *os.File, fileSize int64) (int64, int, uint32, footerState, error) {
+	if fileSize >= footerSize {
+		footer := make([]byte, footerSize)
+		if _, err := file.ReadAt(footer, fileSize-footerSize); err != nil {
+			return 0, 0, 0, footerMissing, err
+		}
+
+		dataSize := int64(binary.LittleEndian.Uint64(footer[8:16]))
+		intact := bytes.Equal(footer[0:8], footerMagic) &&
+			crc32.ChecksumIEEE(footer[:24]) == binary.LittleEndian.Uint32(footer[24:28]) &&
+			dataSize == fileSize-footerSize
+		if intact {
+			entries := int(binary.LittleEndian.Uint32(footer[16:20]))
+			return dataSize, entries, binary.LittleEndian.Uint32(footer[20:24]), footerUnverified, nil
+		}
+	}
+	return fileSize, -1, 0, footerMissing, nil
+}
+
+// dataChecksum returns the CRC32 of the first size bytes of file
+func dataChecksum(file *os.File, size int64) (uint32, error) {
+	crc := crc32.NewIEEE()
+	if _, err := io.Copy(crc, io.NewSectionReader(file, 0, size)); err != nil {
+		return 0, err
+	}
+	return crc.Sum32(), nil
+}
+
+// countEntries counts the entries that fit entirely in the first size bytes
+// of file by walking their headers. It stops at anything that cannot be an
+// entry, which loading then deals with.
+func countEntries(file *os.File, size int64) (int, error) {
+	header := make([]byte, legacyHeaderSize)
+	entries := 0
+	for pos := int64(0); pos+legacyHeaderSize <= size; entries++ {
+		if _, err := file.ReadAt(header, pos); err != nil {
+			return 0, err
+		}
+		hdrSize, _, keySize, valueSize := decodeHeaderPrefix(header)
+		next := pos + int64(hdrSize) + int64(keySize) + int64(valueSize)
+		if keySize == 0 || next > size {
+			break
+		}
+		pos = next
+	}
+	return entries, nil
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stripFooter removes the footer from a closed segment file, leaving it as a
+// crash before the segment was sealed would
+func stripFooter(t *testing.T, path string) {
+	t.Helper()
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-footerSize))
+}
+
+// writeSealedSegment writes n entries to segment id in dir and closes it
+func writeSealedSegment(t *testing.T, dir string, id, n int) *Segment {
+	t.Helper()
+	seg, err := NewSegment(id, dir, SegmentOptions{})
+	require.NoError(t, err)
+	for range n {
+		_, err := seg.Append(createTestEntry("key", "value"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, seg.Close())
+	return seg
+}
+
+func TestSegment_Footer(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	t.Run("Present", func(t *testing.T) {
+		seg := writeSealedSegment(t, dir, 1, 3)
+		info, err := os.Stat(seg.Path())
+		require.NoError(t, err)
+		assert.Equal(t, seg.Size()+footerSize, info.Size(), "Close should append a footer")
+
+		reopened, err := OpenSegment(1, dir, SegmentOptions{})
+		require.NoError(t, err)
+		defer reopened.Close()
+		assert.Equal(t, footerUnverified, reopened.footer, "Opening reads only the footer, not the data")
+		assert.Equal(t, seg.Size(), reopened.Size(), "the footer is not part of the data")
+		assert.Equal(t, 3, reopened.EntryCount())
+		assert.Equal(t, footerValid, reopened.footer, "Counting checks the data against the footer")
+
+		// Reading every entry ends exactly at the footer
+		pos := int64(0)
+		for range 3 {
+			entry, err := reopened.Read(pos)
+			require.NoError(t, err)
+			pos += int64(entry.Size())
+		}
+		assert.Equal(t, reopened.Size(), pos)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		seg := writeSealedSegment(t, dir, 2, 3)
+		stripFooter(t, seg.Path())
+
+		reopened, err := OpenSegment(2, dir, SegmentOptions{})
+		require.NoError(t, err)
+		defer reopened.Close()
+		assert.Equal(t, footerMissing, reopened.footer)
+		assert.Equal(t, seg.Size(), reopened.Size())
+		assert.Equal(t, 3, reopened.EntryCount(), "entries should be counted without a footer")
+	})
+
+	t.Run("Mismatched", func(t *testing.T) {
+		seg := writeSealedSegment(t, dir, 3, 3)
+		data, err := os.ReadFile(seg.Path())
+		require.NoError(t, err)
+		data[seg.Size()-1] ^= 0xFF // Last value byte, just before the footer
+		require.NoError(t, os.WriteFile(seg.Path(), data, 0644))
+
+		reopened, err := OpenSegment(3, dir, SegmentOptions{})
+		require.NoError(t, err)
+		defer reopened.Close()
+		assert.Equal(t, footerUnverified, reopened.footer, "Opening reads only the footer, not the data")
+
+		assert.Equal(t, 3, reopened.EntryCount(), "The entries are counted again")
+		assert.Equal(t, footerMismatch, reopened.footer)
+		assert.Equal(t, seg.Size(), reopened.Size(), "the footer stays out of the data")
+
+		intact, err := reopened.verify()
+		require.NoError(t, err)
+		assert.False(t, intact)
+	})
+
+	t.Run("Verified", func(t *testing.T) {
+		seg := writeSealedSegment(t, dir, 5, 3)
+		reopened, err := OpenSegment(5, dir, SegmentOptions{})
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		intact, err := reopened.verify()
+		require.NoError(t, err)
+		assert.True(t, intact)
+		assert.Equal(t, footerValid, reopened.footer)
+		assert.Equal(t, seg.Size(), reopened.Size())
+	})
+
+	t.Run("Rollover", func(t *testing.T) {
+		seg, err := NewSegment(4, dir, SegmentOptions{MaxEntriesPerSegment: 2})
+		require.NoError(t, err)
+		for range 2 {
+			_, err := seg.Append(createTestEntry("key", "value"))
+			require.NoError(t, err)
+		}
+		_, err = seg.Append(createTestEntry("key", "value"))
+		require.ErrorIs(t, err, ErrSegmentFull)
+		require.NoError(t, seg.Close())
+
+		reopened, err := OpenSegment(4, dir, SegmentOptions{})
+		require.NoError(t, err)
+		defer reopened.Close()
+		assert.Equal(t, footerUnverified, reopened.footer, "a full segment is sealed once")
+		assert.Equal(t, 2, reopened.EntryCount())
+	})
+}
+
+func TestSegmentManager_FooterMismatch(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	sealed := writeSealedSegment(t, dir, 1, 3)
+	writeSealedSegment(t, dir, 2, 3)
+	data, err := os.ReadFile(sealed.Path())
+	require.NoError(t, err)
+	data[sealed.Size()-1] ^= 0xFF
+	require.NoError(t, os.WriteFile(sealed.Path(), data, 0644))
+
+	sm, err := NewSegmentManager(dir, SegmentOptions{})
+	require.NoError(t, err)
+	defer sm.Close()
+
+	// The damaged segment is not the tail, so it is only checked when used
+	seg, ok := sm.GetSegment(1)
+	require.True(t, ok)
+	assert.Equal(t, footerUnverified, seg.footer)
+	assert.Equal(t, 3, seg.EntryCount())
+	assert.Equal(t, footerMismatch, seg.footer)
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultGroupCommitWindow is how long a group commit waits for more
+	// appends before syncing
+	DefaultGroupCommitWindow = 2 * time.Millisecond
+
+	// DefaultGroupCommitBytes is how many unsynced bytes make a group commit
+	// sync without waiting for the window to end (1MB)
+	DefaultGroupCommitBytes = 1024 * 1024
+)
+
+// Commit is a group of appends made durable by a single fsync
+type Commit struct {
+	done chan struct{}
+	err  error
+}
+
+// Wait blocks until the commit's fsync has finished and returns its error.
+// A nil Commit has nothing to wait for.
+func (c *Commit) Wait() error {
+	if c == nil {
+		return nil
+	}
+	<-c.done
+	return c.err
+}
+
+// groupCommitter batches the fsyncs of concurrent appends to one file. Each
+// append joins the open commit, which syncs once window has passed since its
+// first append or once maxBytes have been written to it, whichever is first.
+type groupCommitter struct {
+	syncFile func() error
+	window   time.Duration
+	maxBytes int64
+
+	mu      sync.Mutex
+	open    *Commit // Commit that new appends join (nil = none yet)
+	pending int64   // Bytes written since open was started
+	syncing sync.WaitGroup
+}
+
+// newGroupCommitter creates a committer that calls syncFile to make writes durable
+func newGroupCommitter(syncFile func() error, opts SegmentOptions) *groupCommitter {
+	g := &groupCommitter{
+		syncFile: syncFile,
+		window:   opts.GroupCommitWindow,
+		maxBytes: opts.GroupCommitBytes,
+	}
+	if g.window <= 0 {
+		g.window = DefaultGroupCommitWindow
+	}
+	if g.maxBytes <= 0 {
+		g.maxBytes = DefaultGroupCommitBytes
+	}
+	return g
+}
+
+// add records n bytes already written to the file and returns the commit
+// that will sync them
+func (g *groupCommitter) add(n int) *Commit {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c := g.open
+	if c == nil {
+		c = &Commit{done: make(chan struct{})}
+		g.open = c
+		time.AfterFunc(g.window, func() { g.commit(c) })
+	}
+	g.pending += int64(n)
+	if g.pending >= g.maxBytes {
+		// The caller may hold locks, so sync from another goroutine
+		go g.commit(c)
+	}
+	return c
+}
+
+// commit syncs the file and releases everyone waiting on c, unless c was
+// already committed
+func (g *groupCommitter) commit(c *Commit) {
+	g.mu.Lock()
+	if g.open != c {
+		g.mu.Unlock()
+		return
+	}
+	g.open = nil
+	g.pending = 0
+	g.syncing.Add(1)
+	g.mu.Unlock()
+	defer g.syncing.Done()
+
+	// Everything in c was written before it was closed above, so this
+	// sync covers it
+	c.err = g.syncFile()
+	close(c.done)
+}
+
+// flush commits the open commit, if any, and waits for every sync in
+// progress, so the file can be closed afterwards
+func (g *groupCommitter) flush() error {
+	g.mu.Lock()
+	c := g.open
+	g.mu.Unlock()
+
+	var err error
+	if c != nil {
+		g.commit(c)
+		err = c.Wait()
+	}
+	g.syncing.Wait()
+	return err
+}
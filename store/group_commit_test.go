@@ -0,0 +1,105 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingSync returns a sync function that counts its calls and returns err
+func countingSync(calls *atomic.Int32, err error) func() error {
+	return func() error {
+		calls.Add(1)
+		return err
+	}
+}
+
+func TestGroupCommitter_ConcurrentAppendsShareOneSync(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	g := newGroupCommitter(countingSync(&calls, nil), SegmentOptions{GroupCommitWindow: 50 * time.Millisecond})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, g.add(10).Wait())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestGroupCommitter_ByteLimitSyncsEarly(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	g := newGroupCommitter(countingSync(&calls, nil), SegmentOptions{GroupCommitWindow: time.Hour, GroupCommitBytes: 10})
+
+	first := g.add(4)
+	second := g.add(6)
+	assert.Same(t, first, second, "appends in the window join the same commit")
+
+	done := make(chan error)
+	go func() { done <- first.Wait() }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reaching the byte limit should sync without waiting for the window")
+	}
+	assert.NotSame(t, first, g.add(1), "a new commit starts after a sync")
+}
+
+func TestGroupCommitter_SyncErrorReachesEveryWaiter(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	syncErr := errors.New("disk full")
+	g := newGroupCommitter(countingSync(&calls, syncErr), SegmentOptions{GroupCommitWindow: time.Millisecond})
+
+	first, second := g.add(1), g.add(1)
+	assert.ErrorIs(t, first.Wait(), syncErr)
+	assert.ErrorIs(t, second.Wait(), syncErr)
+}
+
+func TestGroupCommitter_FlushReleasesWaiters(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	g := newGroupCommitter(countingSync(&calls, nil), SegmentOptions{GroupCommitWindow: time.Hour})
+
+	commit := g.add(1)
+	require.NoError(t, g.flush())
+	assert.NoError(t, commit.Wait())
+	assert.Equal(t, int32(1), calls.Load())
+
+	require.NoError(t, g.flush(), "flushing with nothing pending is a no-op")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestCommit_NilWait(t *testing.T) {
+	var c *Commit
+	assert.NoError(t, c.Wait())
+}
+
+func TestSegment_Append_SyncGroup(t *testing.T) {
+	t.Parallel()
+	seg, err := NewSegment(1, t.TempDir(), SegmentOptions{SyncMode: SyncGroup, GroupCommitWindow: time.Hour})
+	require.NoError(t, err)
+
+	offset, commit, err := seg.AppendAsync(createTestEntry("key", "value"))
+	require.NoError(t, err)
+	require.NotNil(t, commit)
+
+	// Readable before the commit, which Close releases
+	read, err := seg.Read(offset)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), read.Value)
+
+	require.NoError(t, seg.Close())
+	assert.NoError(t, commit.Wait())
+}
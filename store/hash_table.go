@@ -1,82 +1,277 @@
 package store
 
 import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 // HashTableEntry represents an entry in the HashTable for key lookups
 type HashTableEntry struct {
-	FileID    int    // ID of the segment file
-	ValueSize uint32 // Size of the value
-	ValuePos  int64  // Position of the value in the segment
-	Timestamp uint32 // Timestamp when the entry was written
+	FileID     int    // ID of the segment file
+	StoredSize uint32 // Size of the value as written, after compression, encryption and any content type
+	ValuePos   int64  // Position of the value in the segment
+	Timestamp  uint32 // Timestamp when the entry was written
+	ExpiresAt  uint32 // Timestamp after which the entry is expired (0 = never)
 }
 
-// HashTable is an in-memory hash index for key lookups
-type HashTable struct {
+// IsExpired checks if the entry has an expiry that has passed at now
+func (e *HashTableEntry) IsExpired(now time.Time) bool {
+	return e.ExpiresAt != 0 && uint32(now.Unix()) >= e.ExpiresAt
+}
+
+// DefaultHashTableShards is the default number of HashTable shards
+const DefaultHashTableShards = 16
+
+// hashTableShard is one independently locked part of the HashTable
+type hashTableShard struct {
 	mu    sync.RWMutex
 	index map[string]*HashTableEntry
+	size  int64 // Sum of StoredSize over index, kept up to date by set and remove
 }
 
-// NewHashTable creates a new HashTable
+// set stores entry under key, adjusting size by the change in stored size.
+// The caller must hold mu for writing.
+func (s *hashTableShard) set(key string, entry *HashTableEntry) {
+	if old, ok := s.index[key]; ok {
+		s.size -= int64(old.StoredSize)
+	}
+	s.index[key] = entry
+	s.size += int64(entry.StoredSize)
+}
+
+// remove deletes key, if present. The caller must hold mu for writing.
+func (s *hashTableShard) remove(key string) {
+	if old, ok := s.index[key]; ok {
+		s.size -= int64(old.StoredSize)
+		delete(s.index, key)
+	}
+}
+
+// HashTable is an in-memory hash index for key lookups. Keys are spread over
+// shards by hash so that writers to different keys rarely contend.
+type HashTable struct {
+	shards []*hashTableShard
+}
+
+// NewHashTable creates a new HashTable with DefaultHashTableShards shards
 func NewHashTable() *HashTable {
-	return &HashTable{
-		index: make(map[string]*HashTableEntry),
+	return NewShardedHashTable(DefaultHashTableShards)
+}
+
+// NewShardedHashTable creates a new HashTable with n shards (at least one)
+func NewShardedHashTable(n int) *HashTable {
+	if n <= 0 {
+		n = DefaultHashTableShards
+	}
+	shards := make([]*hashTableShard, n)
+	for i := range shards {
+		shards[i] = &hashTableShard{index: make(map[string]*HashTableEntry)}
 	}
+	return &HashTable{shards: shards}
+}
+
+// shard returns the shard responsible for key
+func (kd *HashTable) shard(key string) *hashTableShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return kd.shards[h.Sum32()%uint32(len(kd.shards))]
 }
 
 // Put adds a key in the HashTable
-func (kd *HashTable) Put(key string, fileID int, valuePos int64, valueSize uint32, timestamp uint32) {
-	kd.mu.Lock()
-	defer kd.mu.Unlock()
+func (kd *HashTable) Put(key string, fileID int, valuePos int64, storedSize uint32, timestamp uint32) {
+	kd.PutWithExpiry(key, fileID, valuePos, storedSize, timestamp, 0)
+}
 
-	kd.index[key] = &HashTableEntry{
-		FileID:    fileID,
-		ValueSize: valueSize,
-		ValuePos:  valuePos,
-		Timestamp: timestamp,
-	}
+// PutWithExpiry adds a key in the HashTable that expires at expiresAt
+func (kd *HashTable) PutWithExpiry(key string, fileID int, valuePos int64, storedSize uint32, timestamp uint32, expiresAt uint32) {
+	shard := kd.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.set(key, &HashTableEntry{
+		FileID:     fileID,
+		StoredSize: storedSize,
+		ValuePos:   valuePos,
+		Timestamp:  timestamp,
+		ExpiresAt:  expiresAt,
+	})
 }
 
 // Get retrieves a key from the HashTable
 func (kd *HashTable) Get(key string) (*HashTableEntry, bool) {
-	kd.mu.RLock()
-	defer kd.mu.RUnlock()
+	shard := kd.shard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
 
-	entry, exists := kd.index[key]
+	entry, exists := shard.index[key]
 	return entry, exists
 }
 
 // Delete removes a key from the HashTable
 func (kd *HashTable) Delete(key string) {
-	kd.mu.Lock()
-	defer kd.mu.Unlock()
+	shard := kd.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
 
-	delete(kd.index, key)
+	shard.remove(key)
+}
+
+// len returns the number of keys across all shards
+func (kd *HashTable) len() int {
+	n := 0
+	for _, shard := range kd.shards {
+		shard.mu.RLock()
+		n += len(shard.index)
+		shard.mu.RUnlock()
+	}
+	return n
+}
+
+// collect returns the keys of every shard for which keep returns true
+func (kd *HashTable) collect(keep func(key string, entry *HashTableEntry) bool) []string {
+	keys, _ := kd.collectCtx(context.Background(), keep)
+	return keys
+}
+
+// collectCtx is collect, checking ctx before each shard
+func (kd *HashTable) collectCtx(ctx context.Context, keep func(key string, entry *HashTableEntry) bool) ([]string, error) {
+	keys := make([]string, 0, kd.len())
+	for _, shard := range kd.shards {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		shard.mu.RLock()
+		for key, entry := range shard.index {
+			if keep(key, entry) {
+				keys = append(keys, key)
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	return keys, nil
 }
 
 // List returns all keys in the HashTable
 func (kd *HashTable) List() []string {
-	kd.mu.RLock()
-	defer kd.mu.RUnlock()
+	return kd.collect(func(string, *HashTableEntry) bool { return true })
+}
+
+// ListUnexpired returns all keys in the HashTable that have not expired at now
+func (kd *HashTable) ListUnexpired(now time.Time) []string {
+	return kd.collect(func(_ string, entry *HashTableEntry) bool {
+		return !entry.IsExpired(now)
+	})
+}
+
+// ListExpired returns all keys in the HashTable that have expired at now
+func (kd *HashTable) ListExpired(now time.Time) []string {
+	return kd.collect(func(_ string, entry *HashTableEntry) bool {
+		return entry.IsExpired(now)
+	})
+}
+
+// Keys returns the unexpired keys starting with prefix in sorted order
+func (kd *HashTable) Keys(prefix string) []string {
+	keys, _ := kd.KeysCtx(context.Background(), prefix)
+	return keys
+}
+
+// KeysCtx is Keys, giving up with ctx's error once ctx is done
+func (kd *HashTable) KeysCtx(ctx context.Context, prefix string) ([]string, error) {
+	now := time.Now()
+	keys, err := kd.collectCtx(ctx, func(key string, entry *HashTableEntry) bool {
+		return strings.HasPrefix(key, prefix) && !entry.IsExpired(now)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	keys := make([]string, 0, len(kd.index))
-	for key := range kd.index {
-		keys = append(keys, key)
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// KeysInRange returns the unexpired keys in [start, end) in sorted order.
+// An empty start or end leaves that side of the interval unbounded.
+func (kd *HashTable) KeysInRange(start, end string) []string {
+	now := time.Now()
+	keys := kd.collect(func(key string, entry *HashTableEntry) bool {
+		return key >= start && (end == "" || key < end) && !entry.IsExpired(now)
+	})
+
+	sort.Strings(keys)
+	return keys
+}
+
+// KeysAfter returns up to limit unexpired keys greater than cursor in sorted
+// order. A limit <= 0 returns every such key. Only limit keys are kept while
+// scanning, so a page costs O(n log limit) rather than a full sort.
+func (kd *HashTable) KeysAfter(cursor string, limit int) []string {
+	now := time.Now()
+	if limit <= 0 {
+		keys := kd.collect(func(key string, entry *HashTableEntry) bool {
+			return key > cursor && !entry.IsExpired(now)
+		})
+		sort.Strings(keys)
+		return keys
 	}
+
+	// Max-heap of the smallest keys seen so far
+	h := make(keyHeap, 0, limit)
+	for _, shard := range kd.shards {
+		shard.mu.RLock()
+		for key, entry := range shard.index {
+			if key <= cursor || entry.IsExpired(now) {
+				continue
+			}
+			if len(h) < limit {
+				heap.Push(&h, key)
+			} else if key < h[0] {
+				h[0] = key
+				heap.Fix(&h, 0)
+			}
+		}
+		shard.mu.RUnlock()
+	}
+
+	keys := []string(h)
+	sort.Strings(keys)
 	return keys
 }
 
-// Stats returns statistics about the HashTable (optional)
-func (kd *HashTable) Stats() (int, int64) {
-	kd.mu.RLock()
-	defer kd.mu.RUnlock()
+// keyHeap is a max-heap of keys
+type keyHeap []string
 
-	totalKeys := len(kd.index)
+func (h keyHeap) Len() int           { return len(h) }
+func (h keyHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h keyHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *keyHeap) Push(x any)        { *h = append(*h, x.(string)) }
+func (h *keyHeap) Pop() any {
+	old := *h
+	key := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return key
+}
+
+// Stats returns the number of keys and the sum of their stored value sizes. Both
+// are kept per shard as keys change, so this does not walk the index.
+func (kd *HashTable) Stats() (int, int64) {
+	totalKeys := 0
 	totalSize := int64(0)
 
-	for _, entry := range kd.index {
-		totalSize += int64(entry.ValueSize)
+	for _, shard := range kd.shards {
+		shard.mu.RLock()
+		totalKeys += len(shard.index)
+		totalSize += shard.size
+		shard.mu.RUnlock()
 	}
 
 	return totalKeys, totalSize
@@ -85,28 +280,120 @@ func (kd *HashTable) Stats() (int, int64) {
 // Merge applies updates from src only if current value still equals snap's.
 // Prevents compaction from overwriting newer writes.
 func (h *HashTable) Merge(src, snap *HashTable) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	for k, v := range src.index {
-		cur, ok := h.index[k]
-		sv, okSnap := snap.index[k]
-		// must exist in snapshot and be unchanged since snapshot
-		if !okSnap || !ok || cur != sv {
-			continue
+	for _, srcShard := range src.shards {
+		srcShard.mu.RLock()
+		for k, v := range srcShard.index {
+			sv, okSnap := snap.Get(k)
+
+			shard := h.shard(k)
+			shard.mu.Lock()
+			cur, ok := shard.index[k]
+			// must exist in snapshot and be unchanged since snapshot
+			if okSnap && ok && cur == sv {
+				shard.set(k, v)
+			}
+			shard.mu.Unlock()
 		}
-		h.index[k] = v
+		srcShard.mu.RUnlock()
 	}
 }
 
 // Clone returns a shallow snapshot of the table (for compaction checks).
+// All shards are locked together so the snapshot is consistent.
 func (h *HashTable) Clone() *HashTable {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+	for _, shard := range h.shards {
+		shard.mu.RLock()
+	}
+	defer func() {
+		for _, shard := range h.shards {
+			shard.mu.RUnlock()
+		}
+	}()
 
-	c := NewHashTable()
-	for k, v := range h.index {
-		c.index[k] = v
+	c := NewShardedHashTable(len(h.shards))
+	for i, shard := range h.shards {
+		for k, v := range shard.index {
+			c.shards[i].index[k] = v
+		}
+		c.shards[i].size = shard.size
 	}
 	return c
 }
+
+// hashTableRecordSize is keysize + fileID + valuesize + valuepos + timestamp + expiresAt
+const hashTableRecordSize = 28
+
+// Serialize writes every entry of the HashTable to w
+func (h *HashTable) Serialize(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	count := make([]byte, 8)
+	binary.LittleEndian.PutUint64(count, uint64(h.len()))
+	if _, err := bw.Write(count); err != nil {
+		return err
+	}
+
+	written := 0
+	record := make([]byte, hashTableRecordSize)
+	for _, shard := range h.shards {
+		shard.mu.RLock()
+		for key, entry := range shard.index {
+			binary.LittleEndian.PutUint32(record[0:4], uint32(len(key)))
+			binary.LittleEndian.PutUint32(record[4:8], uint32(entry.FileID))
+			binary.LittleEndian.PutUint32(record[8:12], entry.StoredSize)
+			binary.LittleEndian.PutUint64(record[12:20], uint64(entry.ValuePos))
+			binary.LittleEndian.PutUint32(record[20:24], entry.Timestamp)
+			binary.LittleEndian.PutUint32(record[24:28], entry.ExpiresAt)
+			bw.Write(record)
+			bw.WriteString(key)
+			written++
+		}
+		shard.mu.RUnlock()
+	}
+
+	// The count is written up front, so the table must not change meanwhile
+	if written != int(binary.LittleEndian.Uint64(count)) {
+		return fmt.Errorf("hash table changed while serializing")
+	}
+
+	return bw.Flush()
+}
+
+// LoadHashTable reads a HashTable written by Serialize
+func LoadHashTable(r io.Reader) (*HashTable, error) {
+	h := NewHashTable()
+	if err := h.load(r); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// load adds the entries written by Serialize to h
+func (h *HashTable) load(r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	count := make([]byte, 8)
+	if _, err := io.ReadFull(br, count); err != nil {
+		return fmt.Errorf("failed to read entry count: %w", err)
+	}
+
+	record := make([]byte, hashTableRecordSize)
+	for i := uint64(0); i < binary.LittleEndian.Uint64(count); i++ {
+		if _, err := io.ReadFull(br, record); err != nil {
+			return fmt.Errorf("failed to read entry %d: %w", i, err)
+		}
+		key := make([]byte, binary.LittleEndian.Uint32(record[0:4]))
+		if _, err := io.ReadFull(br, key); err != nil {
+			return fmt.Errorf("failed to read key %d: %w", i, err)
+		}
+
+		h.PutWithExpiry(string(key),
+			int(binary.LittleEndian.Uint32(record[4:8])),
+			int64(binary.LittleEndian.Uint64(record[12:20])),
+			binary.LittleEndian.Uint32(record[8:12]),
+			binary.LittleEndian.Uint32(record[20:24]),
+			binary.LittleEndian.Uint32(record[24:28]))
+	}
+
+	return nil
+}
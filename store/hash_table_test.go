@@ -1,13 +1,18 @@
 package store
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"math/rand/v2"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -27,8 +32,12 @@ func TestNewHashTable(t *testing.T) {
 	t.Parallel()
 	ht := NewHashTable()
 	assert.NotNil(t, ht, "NewHashTable should not return nil")
-	assert.NotNil(t, ht.index, "Internal index map should be initialized")
-	assert.Zero(t, len(ht.index), "New hash table should be empty")
+	assert.Len(t, ht.shards, DefaultHashTableShards, "Shards should be initialized")
+	for _, shard := range ht.shards {
+		assert.NotNil(t, shard.index, "Internal index maps should be initialized")
+	}
+	count, _ := ht.Stats()
+	assert.Zero(t, count, "New hash table should be empty")
 }
 
 func TestHashTable_PutAndGet(t *testing.T) {
@@ -41,7 +50,7 @@ func TestHashTable_PutAndGet(t *testing.T) {
 	assert.True(t, exists, "Key should exist after Put")
 	assert.Equal(t, fileID1, entry.FileID)
 	assert.Equal(t, valuePos1, entry.ValuePos)
-	assert.Equal(t, valueSize1, entry.ValueSize)
+	assert.Equal(t, valueSize1, entry.StoredSize)
 	assert.Equal(t, timestamp1, entry.Timestamp)
 
 	ht.Put(key1, fileID2, valuePos2, valueSize2, timestamp2)
@@ -49,7 +58,7 @@ func TestHashTable_PutAndGet(t *testing.T) {
 
 	assert.True(t, exists, "Key should still exist after update")
 	assert.Equal(t, fileID2, updatedEntry.FileID, "FileID should be updated")
-	assert.Equal(t, valueSize2, updatedEntry.ValueSize, "ValueSize should be updated")
+	assert.Equal(t, valueSize2, updatedEntry.StoredSize, "StoredSize should be updated")
 	assert.Equal(t, timestamp2, updatedEntry.Timestamp, "Timestamp should be updated")
 
 	_, exists = ht.Get("missing_key")
@@ -119,6 +128,41 @@ func TestHashTable_Stats(t *testing.T) {
 	assert.Equal(t, int64(100), size, "Total size should reflect the deletion")
 }
 
+func TestHashTable_Stats_Incremental(t *testing.T) {
+	t.Parallel()
+	ht := NewHashTable()
+
+	ht.Put(key1, fileID1, valuePos1, 100, timestamp1)
+	ht.Put(key1, fileID1, valuePos1, 40, timestamp2) // Overwrite with a smaller value
+
+	count, size := ht.Stats()
+	assert.Equal(t, 1, count)
+	assert.Equal(t, int64(40), size, "A smaller overwrite should shrink the total")
+
+	ht.Delete("missing")
+	count, size = ht.Stats()
+	assert.Equal(t, 1, count, "Deleting a missing key changes nothing")
+	assert.Equal(t, int64(40), size)
+
+	// Compaction swaps entries in through Clone and Merge
+	snap := ht.Clone()
+	count, size = snap.Stats()
+	assert.Equal(t, 1, count)
+	assert.Equal(t, int64(40), size, "A clone keeps the total")
+
+	src := NewHashTable()
+	src.Put(key1, fileID2, valuePos2, 60, timestamp2)
+	ht.Merge(src, snap)
+	count, size = ht.Stats()
+	assert.Equal(t, 1, count)
+	assert.Equal(t, int64(60), size, "Merged entries adjust the total")
+
+	ht.Delete(key1)
+	count, size = ht.Stats()
+	assert.Zero(t, count)
+	assert.Zero(t, size)
+}
+
 func TestHashTable_Concurrency(t *testing.T) {
 	ht := NewHashTable()
 	numGoroutines := 100
@@ -168,8 +212,138 @@ func TestHashTable_Concurrency(t *testing.T) {
 
 	select {
 	case <-done:
-		assert.GreaterOrEqual(t, len(ht.index), 0, "Final index size should be valid")
+		count, _ := ht.Stats()
+		assert.GreaterOrEqual(t, count, 0, "Final index size should be valid")
 	case <-time.After(5 * time.Second):
 		t.Fatal("Concurrency test timed out (possible deadlock)")
 	}
 }
+
+func TestHashTable_Keys(t *testing.T) {
+	t.Parallel()
+	ht := NewHashTable()
+
+	ht.Put("user:2:name", fileID1, valuePos1, valueSize1, timestamp1)
+	ht.Put("user:1:name", fileID1, valuePos1, valueSize1, timestamp1)
+	ht.Put("user:1:email", fileID1, valuePos1, valueSize1, timestamp1)
+	ht.Put("order:1", fileID1, valuePos1, valueSize1, timestamp1)
+	ht.PutWithExpiry("user:3:name", fileID1, valuePos1, valueSize1, timestamp1, uint32(time.Now().Add(-time.Minute).Unix()))
+
+	assert.Equal(t, []string{"user:1:email", "user:1:name", "user:2:name"}, ht.Keys("user:"), "Keys should be sorted and exclude expired entries")
+	assert.Equal(t, []string{"user:1:email", "user:1:name"}, ht.Keys("user:1:"))
+	assert.Empty(t, ht.Keys("missing:"))
+	assert.Len(t, ht.Keys(""), 4, "Empty prefix should match every live key")
+}
+
+func TestHashTable_ShardsAggregate(t *testing.T) {
+	t.Parallel()
+	ht := NewShardedHashTable(4)
+
+	for i := 0; i < 100; i++ {
+		ht.Put(fmt.Sprintf("key_%03d", i), fileID1, int64(i), 2, timestamp1)
+	}
+
+	count, size := ht.Stats()
+	assert.Equal(t, 100, count)
+	assert.Equal(t, int64(200), size)
+	assert.Len(t, ht.List(), 100)
+	assert.Equal(t, []string{"key_010", "key_011"}, ht.KeysInRange("key_010", "key_012"))
+
+	clone := ht.Clone()
+	assert.Len(t, clone.shards, 4)
+	ht.Delete("key_000")
+	_, ok := clone.Get("key_000")
+	assert.True(t, ok, "Clone should be unaffected by later writes")
+
+	// Merge works across tables with different shard counts
+	src := NewShardedHashTable(1)
+	src.Put("key_001", 9, 0, 2, timestamp1)
+	ht.Merge(src, clone)
+	entry, _ := ht.Get("key_001")
+	assert.Equal(t, 9, entry.FileID)
+}
+
+func TestHashTable_SerializeAndLoad(t *testing.T) {
+	ht := NewHashTable()
+	ht.Put(key1, fileID1, valuePos1, valueSize1, timestamp1)
+	ht.PutWithExpiry("expiring", 3, 1<<40, 7, timestamp1, timestamp1+60)
+
+	var buf bytes.Buffer
+	require.NoError(t, ht.Serialize(&buf))
+
+	loaded, err := LoadHashTable(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, ht.List(), loaded.List())
+	for _, key := range ht.List() {
+		want, _ := ht.Get(key)
+		got, ok := loaded.Get(key)
+		require.True(t, ok)
+		assert.Equal(t, *want, *got)
+	}
+
+	_, err = LoadHashTable(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.Error(t, err, "Truncated input should fail to load")
+}
+
+func benchmarkHashTableConcurrent(b *testing.B, shards int) {
+	ht := NewShardedHashTable(shards)
+	var counter atomic.Int64
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		id := counter.Add(1)
+		j := 0
+		for pb.Next() {
+			// Same mix as TestHashTable_Concurrency: put, occasional delete, read
+			key := fmt.Sprintf("key_%d_%d", id, j)
+			ht.Put(key, int(id), int64(j), uint32(j), timestamp1)
+			if j%10 == 0 {
+				ht.Delete(key)
+			}
+			ht.Get(key1)
+			j++
+		}
+	})
+}
+
+func BenchmarkHashTable_Concurrent_SingleShard(b *testing.B) {
+	benchmarkHashTableConcurrent(b, 1)
+}
+
+func BenchmarkHashTable_Concurrent_Sharded(b *testing.B) {
+	benchmarkHashTableConcurrent(b, DefaultHashTableShards)
+}
+
+// BenchmarkHashTable_Concurrent runs a read-mostly workload, 9 lookups to
+// each update, over a prefilled index with different numbers of shards
+func BenchmarkHashTable_Concurrent(b *testing.B) {
+	const keys = 100000
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = fmt.Sprintf("user:%08d", i)
+	}
+
+	for _, shards := range []int{1, 4, DefaultHashTableShards, 64} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			ht := NewShardedHashTable(shards)
+			for i, name := range names {
+				ht.Put(name, 0, int64(i), 512, timestamp1)
+			}
+			var seed atomic.Uint64
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewPCG(seed.Add(1), 0))
+				for i := 0; pb.Next(); i++ {
+					name := names[rng.IntN(keys)]
+					if i%10 == 0 {
+						ht.Put(name, 1, int64(i), 512, timestamp1)
+					} else {
+						ht.Get(name)
+					}
+				}
+			})
+		})
+	}
+}
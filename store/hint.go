@@ -0,0 +1,123 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strings"
+)
+
+const (
+	// hintRecordHeaderSize is timestamp + keysize + valuesize + expiresAt + valuePos
+	hintRecordHeaderSize = 24
+
+	// hintFooterSize is the segment size the hint was built from + crc of the records
+	hintFooterSize = 12
+)
+
+// hintRecord is one index entry in a hint file. Tombstones are recorded too so
+// that loading hints in segment order produces the same index as a full scan.
+type hintRecord struct {
+	Timestamp uint32
+	ValueSize uint32
+	ExpiresAt uint32
+	ValuePos  int64
+	Key       []byte
+}
+
+// hintPath returns the hint file path for a segment
+func hintPath(segmentPath string) string {
+	return strings.TrimSuffix(segmentPath, ".log") + ".hint"
+}
+
+// writeHintFile scans a segment and writes a hint file describing every entry.
+// The file is written to a temporary path and renamed so readers never see a
+// partial hint.
+func writeHintFile(segment *Segment) error {
+	size := segment.Size()
+	var buf []byte
+
+	entry := &Entry{}
+	for pos := int64(0); pos < size; {
+		if err := segment.ReadInto(pos, entry); err != nil {
+			return fmt.Errorf("failed to read entry at position %d: %w", pos, err)
+		}
+
+		record := make([]byte, hintRecordHeaderSize)
+		binary.LittleEndian.PutUint32(record[0:4], entry.Timestamp)
+		binary.LittleEndian.PutUint32(record[4:8], entry.KeySize)
+		binary.LittleEndian.PutUint32(record[8:12], entry.ValueSize)
+		binary.LittleEndian.PutUint32(record[12:16], entry.ExpiresAt)
+		binary.LittleEndian.PutUint64(record[16:24], uint64(pos))
+		buf = append(buf, record...)
+		buf = append(buf, entry.Key...)
+
+		pos += int64(entry.Size())
+	}
+
+	footer := make([]byte, hintFooterSize)
+	binary.LittleEndian.PutUint64(footer[0:8], uint64(size))
+	binary.LittleEndian.PutUint32(footer[8:12], crc32.ChecksumIEEE(buf))
+	buf = append(buf, footer...)
+
+	path := hintPath(segment.Path())
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf, segment.fileMode); err != nil {
+		return fmt.Errorf("failed to write hint file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename hint file: %w", err)
+	}
+
+	return nil
+}
+
+// readHintFile reads the hint file for a segment. It returns an error wrapping
+// os.ErrNotExist when there is no hint, or ErrCorruptHint when the hint cannot
+// be trusted.
+func readHintFile(segment *Segment) ([]hintRecord, error) {
+	data, err := os.ReadFile(hintPath(segment.Path()))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < hintFooterSize {
+		return nil, ErrCorruptHint
+	}
+
+	body := data[:len(data)-hintFooterSize]
+	footer := data[len(data)-hintFooterSize:]
+	if int64(binary.LittleEndian.Uint64(footer[0:8])) != segment.Size() {
+		return nil, fmt.Errorf("%w: segment size changed", ErrCorruptHint)
+	}
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(footer[8:12]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptHint)
+	}
+
+	var records []hintRecord
+	for offset := 0; offset < len(body); {
+		if len(body)-offset < hintRecordHeaderSize {
+			return nil, ErrCorruptHint
+		}
+
+		header := body[offset : offset+hintRecordHeaderSize]
+		keySize := int(binary.LittleEndian.Uint32(header[4:8]))
+		offset += hintRecordHeaderSize
+		if len(body)-offset < keySize {
+			return nil, ErrCorruptHint
+		}
+
+		records = append(records, hintRecord{
+			Timestamp: binary.LittleEndian.Uint32(header[0:4]),
+			ValueSize: binary.LittleEndian.Uint32(header[8:12]),
+			ExpiresAt: binary.LittleEndian.Uint32(header[12:16]),
+			ValuePos:  int64(binary.LittleEndian.Uint64(header[16:24])),
+			Key:       body[offset : offset+keySize],
+		})
+		offset += keySize
+	}
+
+	return records, nil
+}
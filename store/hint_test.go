@@ -0,0 +1,153 @@
+package store
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"go.uber.org/zap/zaptest"
+)
+
+// openHintStore opens a store in dataDir with default config
+func openHintStore(t *testing.T, dataDir string) *Store {
+	store, err := New(zaptest.NewLogger(t), &config.Config{DataDir: dataDir})
+	require.NoError(t, err)
+	return store
+}
+
+// closeHintStore closes a store and drops its index snapshot, so the next open
+// loads from hint files
+func closeHintStore(t *testing.T, store *Store) {
+	require.NoError(t, store.Close())
+	require.NoError(t, os.Remove(snapshotPath(store.basePath)))
+}
+
+func TestHintFile_WriteAndRead(t *testing.T) {
+	t.Parallel()
+	ctx := setupTest(t)
+	defer teardownTest(ctx)
+
+	seg, err := NewSegment(1, ctx.tempDir, SegmentOptions{})
+	require.NoError(t, err)
+	defer seg.Close()
+
+	entry := createTestEntry("key_1", "value_1")
+	_, err = seg.Append(entry)
+	require.NoError(t, err)
+	offset, err := seg.Append(entry.TombstoneEntry())
+	require.NoError(t, err)
+
+	require.NoError(t, writeHintFile(seg))
+
+	records, err := readHintFile(seg)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, []byte("key_1"), records[0].Key)
+	assert.Equal(t, int64(0), records[0].ValuePos)
+	assert.Equal(t, uint32(len("value_1")), records[0].ValueSize)
+	assert.Equal(t, offset, records[1].ValuePos)
+	assert.Equal(t, uint32(0), records[1].ValueSize, "Tombstones should be recorded")
+}
+
+func TestHintFile_Missing(t *testing.T) {
+	t.Parallel()
+	ctx := setupTest(t)
+	defer teardownTest(ctx)
+
+	seg, err := NewSegment(1, ctx.tempDir, SegmentOptions{})
+	require.NoError(t, err)
+	defer seg.Close()
+
+	_, err = readHintFile(seg)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestStore_LoadsFromHintFile(t *testing.T) {
+	dataDir := t.TempDir()
+
+	store := openHintStore(t, dataDir)
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("b", "2"))
+	require.NoError(t, store.Delete("a"))
+	closeHintStore(t, store)
+
+	assert.FileExists(t, hintPath(segmentPath(dataDir, 1)))
+
+	reopened := openHintStore(t, dataDir)
+	defer reopened.Close()
+
+	_, err := reopened.Get("a")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	value, err := reopened.Get("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", value)
+}
+
+func TestStore_CorruptHintFile_FallsBackToScan(t *testing.T) {
+	corruptions := map[string]func([]byte) []byte{
+		"flipped byte": func(data []byte) []byte {
+			data[0] ^= 0xFF
+			return data
+		},
+		"truncated": func(data []byte) []byte {
+			return data[:len(data)/2]
+		},
+		"empty": func(data []byte) []byte {
+			return nil
+		},
+	}
+
+	for name, corrupt := range corruptions {
+		t.Run(name, func(t *testing.T) {
+			dataDir := t.TempDir()
+
+			store := openHintStore(t, dataDir)
+			require.NoError(t, store.Set("key", "value"))
+			closeHintStore(t, store)
+
+			hint := hintPath(segmentPath(dataDir, 1))
+			data, err := os.ReadFile(hint)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(hint, corrupt(data), 0644))
+
+			reopened := openHintStore(t, dataDir)
+			value, err := reopened.Get("key")
+			assert.NoError(t, err)
+			assert.Equal(t, "value", value)
+			require.NoError(t, reopened.Close())
+
+			// The bad hint is rebuilt on Close
+			seg, err := OpenSegment(1, dataDir, SegmentOptions{})
+			require.NoError(t, err)
+			defer seg.Close()
+			_, err = readHintFile(seg)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestStore_StaleHintFile_FallsBackToScan(t *testing.T) {
+	dataDir := t.TempDir()
+
+	store := openHintStore(t, dataDir)
+	require.NoError(t, store.Set("key", "value"))
+	closeHintStore(t, store)
+
+	// Append to the segment behind the hint's back
+	stripFooter(t, segmentPath(dataDir, 1))
+	file, err := os.OpenFile(segmentPath(dataDir, 1), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.Write(createTestEntry("late", "entry").Serialize())
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reopened := openHintStore(t, dataDir)
+	defer reopened.Close()
+
+	value, err := reopened.Get("late")
+	assert.NoError(t, err)
+	assert.Equal(t, "entry", value)
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Iterator walks the live entries of a store snapshot in key order,
+// reading values lazily from the segments
+type Iterator struct {
+	ctx      context.Context
+	store    *Store
+	snapshot *HashTable
+	keys     []string
+	pos      int
+	key      string
+	value    string
+	err      error
+}
+
+// Iterator returns an iterator over a consistent snapshot of all live keys.
+// Writes made after the iterator is created are not observed.
+func (s *Store) Iterator() (*Iterator, error) {
+	return s.IteratorCtx(context.Background())
+}
+
+// IteratorCtx is Iterator, with an iterator that stops once ctx is done and
+// then reports ctx's error from Err
+func (s *Store) IteratorCtx(ctx context.Context) (*Iterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.segmentManager == nil {
+		return nil, ErrStoreNotInitialized
+	}
+
+	snapshot := s.hashTable.Clone()
+	keys := snapshot.ListUnexpired(time.Now())
+	sort.Strings(keys)
+
+	return &Iterator{
+		ctx:      ctx,
+		store:    s,
+		snapshot: snapshot,
+		keys:     keys,
+	}, nil
+}
+
+// Next advances to the next entry and reports whether one is available
+func (it *Iterator) Next() bool {
+	if it.err != nil || it.snapshot == nil || it.pos >= len(it.keys) {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+
+	key := it.keys[it.pos]
+	it.pos++
+
+	entry, _ := it.snapshot.Get(key)
+
+	it.store.mu.RLock()
+	logEntry, err := it.store.segmentManager.Read(entry.FileID, entry.ValuePos)
+	it.store.mu.RUnlock()
+	if err != nil {
+		it.err = fmt.Errorf("failed to read entry for key %q: %w", key, err)
+		return false
+	}
+
+	it.key = key
+	it.value = string(logEntry.Value)
+	return true
+}
+
+// Key returns the key of the current entry
+func (it *Iterator) Key() string {
+	return it.key
+}
+
+// Value returns the value of the current entry
+func (it *Iterator) Value() string {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close releases the snapshot held by the iterator
+func (it *Iterator) Close() error {
+	it.snapshot = nil
+	it.keys = nil
+	return nil
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIterator_Snapshot(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("b", "2"))
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("c", "3"))
+	require.NoError(t, store.Delete("c"))
+
+	it, err := store.Iterator()
+	require.NoError(t, err)
+	defer it.Close()
+
+	// Writes after the snapshot must not affect iteration
+	require.NoError(t, store.Set("a", "changed"))
+	require.NoError(t, store.Set("z", "26"))
+
+	got := map[string]string{}
+	var order []string
+	for it.Next() {
+		got[it.Key()] = it.Value()
+		order = append(order, it.Key())
+	}
+
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []string{"a", "b"}, order, "Iterator should return snapshot keys in order")
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, got, "Iterator should return snapshot values")
+}
+
+func TestIterator_Close(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("a", "1"))
+
+	it, err := store.Iterator()
+	require.NoError(t, err)
+	assert.NoError(t, it.Close())
+	assert.False(t, it.Next(), "Next should return false after Close")
+	assert.NoError(t, it.Err())
+}
+
+func TestIterator_ReadError(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("a", "1"))
+
+	it, err := store.Iterator()
+	require.NoError(t, err)
+	defer it.Close()
+
+	// Simulate the segment disappearing underneath the iterator
+	require.NoError(t, store.segmentManager.DeleteSegment(1))
+
+	assert.False(t, it.Next())
+	assert.ErrorIs(t, it.Err(), ErrSegmentNotFound)
+}
+
+func TestIterator_NoSegmentManager(t *testing.T) {
+	store := &Store{hashTable: NewHashTable()}
+
+	_, err := store.Iterator()
+	assert.ErrorIs(t, err, ErrStoreNotInitialized)
+}
+
+func TestIterator_Cancel(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("b", "2"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it, err := store.IteratorCtx(ctx)
+	require.NoError(t, err)
+	defer it.Close()
+
+	require.True(t, it.Next())
+	assert.Equal(t, "a", it.Key())
+	cancel()
+	assert.False(t, it.Next(), "Next should stop once the context is done")
+	assert.ErrorIs(t, it.Err(), context.Canceled)
+}
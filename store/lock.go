@@ -0,0 +1,29 @@
+//go:build unix
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDir takes an exclusive advisory lock on the LOCK file in dir, creating
+// it if needed. It fails with ErrDataDirLocked while another store, in this
+// process or another, holds the lock. Closing the returned file releases it.
+func lockDir(dir string, mode os.FileMode) (*os.File, error) {
+	file, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_CREATE|os.O_RDWR, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w: %s", ErrDataDirLocked, dir)
+		}
+		return nil, fmt.Errorf("failed to lock data directory: %w", err)
+	}
+	return file, nil
+}
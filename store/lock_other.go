@@ -0,0 +1,11 @@
+//go:build !unix
+
+package store
+
+import "os"
+
+// lockDir is a no-op where flock is unavailable; nothing stops two stores
+// from opening the same directory there
+func lockDir(dir string, mode os.FileMode) (*os.File, error) {
+	return nil, nil
+}
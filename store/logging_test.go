@@ -0,0 +1,65 @@
+package store
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStore_SetLogsKeyOnlyAtDebug(t *testing.T) {
+	t.Parallel()
+
+	for level, want := range map[zap.AtomicLevel]int{
+		zap.NewAtomicLevelAt(zap.InfoLevel):  0,
+		zap.NewAtomicLevelAt(zap.DebugLevel): 1,
+	} {
+		core, logs := observer.New(level)
+		store, err := New(zap.New(core), &config.Config{DataDir: t.TempDir()})
+		require.NoError(t, err)
+		require.NoError(t, store.Set("user:1", "s3cret"))
+		require.NoError(t, store.Close())
+
+		entries := logs.FilterMessage("Setting key").All()
+		require.Len(t, entries, want, level.String())
+		for _, entry := range entries {
+			fields := entry.ContextMap()
+			assert.Equal(t, "user:1", fields["key"])
+			for _, value := range fields {
+				assert.NotContains(t, value, "s3cret", "Values must never be logged")
+			}
+		}
+	}
+}
+
+// The store logs through zap so that the level and output are configurable;
+// the standard library logger writes everything to stderr unconditionally
+func TestStore_NoStdlibLogger(t *testing.T) {
+	t.Parallel()
+
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		require.NoError(t, err)
+		parsed, err := parser.ParseFile(fset, file, src, parser.ImportsOnly)
+		require.NoError(t, err)
+		for _, spec := range parsed.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			assert.NotEqual(t, "log", path, "%s imports the standard library logger", file)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	// mergeDirName is the directory in the data directory a merge writes its
+	// segments to before swapping them in
+	mergeDirName = "merge_tmp"
+
+	// manifestName is the file in the merge directory whose presence commits
+	// a merge
+	manifestName = "MANIFEST"
+)
+
+// mergeManifest records the segments a committed merge leaves behind. It is
+// written, atomically, once every merged segment is complete: before that a
+// crash leaves the old segments untouched and the merge directory is thrown
+// away on the next open, and after it the next open finishes the swap.
+type mergeManifest struct {
+	Segments []int    `json:"segments"` // Every segment ID in the store once the merge is applied
+	Merged   []string `json:"merged"`   // Merged segment files, relative to the merge directory
+}
+
+// writeMergeManifest commits a merge by writing m to the merge directory dir
+// through a temporary file and a rename
+func writeMergeManifest(dir string, m mergeManifest, mode os.FileMode) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, manifestName)
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return fmt.Errorf("failed to write merge manifest: %w", err)
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write merge manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename merge manifest: %w", err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// readMergeManifest returns the manifest in the merge directory dir. It
+// returns an error wrapping os.ErrNotExist when the merge was not committed.
+func readMergeManifest(dir string) (mergeManifest, error) {
+	var m mergeManifest
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("invalid merge manifest: %w", err)
+	}
+	return m, nil
+}
+
+// applyMergeManifest moves the merged segments m names from the merge
+// directory into basePath, replacing the files of the same name, and removes
+// the segments the merge dropped. Every step can be repeated, so a swap cut
+// short by a crash is finished by applying the manifest again.
+func applyMergeManifest(basePath, mergeDir string, m mergeManifest) error {
+	for _, rel := range m.Merged {
+		src := filepath.Join(mergeDir, rel)
+		if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) {
+			continue // Already moved
+		}
+		dst := filepath.Join(basePath, filepath.Base(src))
+
+		// The old hint goes first so it is never paired with the new segment
+		if err := os.Remove(hintPath(dst)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+		if err := os.Rename(hintPath(src), hintPath(dst)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	// Segments are numbered in creation order, so any file numbered below
+	// the newest the manifest names and missing from it was merged away
+	files, err := filepath.Glob(filepath.Join(basePath, "segment_*.log"))
+	if err != nil {
+		return err
+	}
+	newest := slices.Max(m.Segments)
+	for _, file := range files {
+		var id int
+		if _, err := fmt.Sscanf(filepath.Base(file), "segment_%d.log", &id); err != nil {
+			continue
+		}
+		if id < newest && !slices.Contains(m.Segments, id) {
+			if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if err := os.Remove(hintPath(file)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	syncDir(basePath)
+	return nil
+}
+
+// recoverMerge brings the data directory basePath to a consistent state
+// after a crash: a committed merge is finished, an uncommitted one is thrown
+// away, and temporary files left by interrupted writes are removed. A
+// read-only store cannot finish a merge, so it fails with
+// ErrUnfinishedMerge instead.
+func recoverMerge(basePath string, readOnly bool) error {
+	mergeDir := filepath.Join(basePath, mergeDirName)
+	m, err := readMergeManifest(mergeDir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if readOnly {
+			return nil
+		}
+		if err := os.RemoveAll(mergeDir); err != nil {
+			return fmt.Errorf("failed to remove abandoned merge: %w", err)
+		}
+	case err != nil:
+		return err
+	case readOnly:
+		return ErrUnfinishedMerge
+	default:
+		if len(m.Segments) > 0 {
+			if err := applyMergeManifest(basePath, mergeDir, m); err != nil {
+				return fmt.Errorf("failed to finish merge: %w", err)
+			}
+		}
+		if err := os.RemoveAll(mergeDir); err != nil {
+			return fmt.Errorf("failed to remove finished merge: %w", err)
+		}
+	}
+
+	// Hints, snapshots and manifests are renamed into place when complete,
+	// so anything still under a temporary name is a write that never finished
+	tmpFiles, err := filepath.Glob(filepath.Join(basePath, "*.tmp"))
+	if err != nil {
+		return err
+	}
+	for _, file := range tmpFiles {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDir makes renames and removals in dir durable. It is best effort:
+// not every platform can sync a directory.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// writeMergeableStore fills dataDir with three sealed segments that are
+// mostly overwritten and an active one, and returns what every key holds
+func writeMergeableStore(t *testing.T, dataDir string) map[string]string {
+	t.Helper()
+	store, err := New(zaptest.NewLogger(t), &config.Config{DataDir: dataDir, MaxEntriesPerSegment: 10})
+	require.NoError(t, err)
+
+	want := make(map[string]string)
+	for i := 0; i < 35; i++ {
+		key := fmt.Sprintf("key_%d", i%12)
+		want[key] = fmt.Sprintf("value_%d", i)
+		require.NoError(t, store.Set(key, want[key]))
+	}
+	require.NoError(t, store.Delete("key_0"))
+	delete(want, "key_0")
+	require.NoError(t, store.Close())
+	return want
+}
+
+// copyDir copies the files directly in src to dst
+func copyDir(t *testing.T, src, dst string) {
+	t.Helper()
+	entries, err := os.ReadDir(src)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == lockFileName {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dst, entry.Name()), data, 0o644))
+	}
+}
+
+func assertStoreHolds(t *testing.T, dataDir string, want map[string]string) {
+	t.Helper()
+	store, err := New(zaptest.NewLogger(t), &config.Config{DataDir: dataDir})
+	require.NoError(t, err)
+	defer store.Close()
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, keys, len(want))
+	for key, value := range want {
+		got, err := store.Get(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, value, got, key)
+	}
+}
+
+func TestRecoverMerge_Uncommitted(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+	want := writeMergeableStore(t, dataDir)
+
+	// A merge that died while writing: half a segment and no manifest, next
+	// to temporary files from interrupted hint and snapshot writes
+	mergeDir := filepath.Join(dataDir, mergeDirName)
+	require.NoError(t, os.Mkdir(mergeDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(mergeDir, "segment_1.log"), []byte("partial"), 0o644))
+	for _, name := range []string{"segment_2.hint.tmp", "index.snapshot.tmp"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dataDir, name), []byte("partial"), 0o644))
+	}
+
+	assertStoreHolds(t, dataDir, want)
+
+	_, err := os.Stat(mergeDir)
+	assert.ErrorIs(t, err, os.ErrNotExist, "the abandoned merge should be removed")
+	tmpFiles, err := filepath.Glob(filepath.Join(dataDir, "*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, tmpFiles)
+}
+
+func TestRecoverMerge_CommittedMidSwap(t *testing.T) {
+	t.Parallel()
+
+	// Merge one copy of the data directory to learn what the merge leaves
+	merged := t.TempDir()
+	want := writeMergeableStore(t, merged)
+	crashed := t.TempDir()
+	copyDir(t, merged, crashed)
+
+	store, err := New(zaptest.NewLogger(t), &config.Config{DataDir: merged})
+	require.NoError(t, err)
+	require.NoError(t, store.Merge())
+	ids := store.segmentManager.GetSegmentIDs()
+	require.NoError(t, store.Close())
+	require.Equal(t, []int{3, 4}, ids, "segments 1 and 2 hold nothing live")
+
+	// Rebuild the other copy as a crash would leave it after committing the
+	// same merge: segment 1 already removed, segment 2 not yet, and merged
+	// segment 3 still waiting in the merge directory with the old hint gone
+	mergeDir := filepath.Join(crashed, mergeDirName)
+	require.NoError(t, os.Mkdir(mergeDir, 0o755))
+	name := filepath.Base(segmentPath("", 3))
+	for _, file := range []string{name, hintPath(name)} {
+		data, err := os.ReadFile(filepath.Join(merged, file))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(mergeDir, file), data, 0o644))
+	}
+	require.NoError(t, os.Remove(segmentPath(crashed, 1)))
+	require.NoError(t, os.Remove(hintPath(segmentPath(crashed, 3))))
+	manifest := mergeManifest{Segments: ids, Merged: []string{name}}
+	require.NoError(t, writeMergeManifest(mergeDir, manifest, 0o644))
+
+	// A read-only store cannot finish the swap, so it refuses to open
+	_, err = New(zaptest.NewLogger(t), &config.Config{DataDir: crashed, ReadOnly: true})
+	assert.ErrorIs(t, err, ErrUnfinishedMerge)
+
+	assertStoreHolds(t, crashed, want)
+
+	_, err = os.Stat(mergeDir)
+	assert.ErrorIs(t, err, os.ErrNotExist, "the finished merge should be removed")
+	_, err = os.Stat(segmentPath(crashed, 2))
+	assert.ErrorIs(t, err, os.ErrNotExist, "segment 2 was merged away")
+	got, err := os.ReadFile(segmentPath(crashed, 3))
+	require.NoError(t, err)
+	expected, err := os.ReadFile(segmentPath(merged, 3))
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
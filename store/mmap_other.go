@@ -0,0 +1,16 @@
+//go:build !unix
+
+package store
+
+import "os"
+
+// mapFile maps nothing where mmap is unavailable, leaving segments to be
+// read with ReadAt
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	return nil, nil
+}
+
+// unmapFile has nothing to release where mmap is unavailable
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,203 @@
+//go:build unix
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// writeMappedSegment writes n entries to a new segment and reopens it with
+// Mmap set, returning it with the entries' offsets
+func writeMappedSegment(t *testing.T, dir string, n int) (*Segment, []int64) {
+	t.Helper()
+	seg, err := NewSegment(1, dir, SegmentOptions{})
+	require.NoError(t, err)
+	offsets := make([]int64, n)
+	for i := range offsets {
+		offsets[i], err = seg.Append(createTestEntry(fmt.Sprintf("key_%d", i), fmt.Sprintf("value_%d", i)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, seg.Close())
+
+	seg, err = OpenSegment(1, dir, SegmentOptions{Mmap: true})
+	require.NoError(t, err)
+	return seg, offsets
+}
+
+func TestSegment_MmapReads(t *testing.T) {
+	t.Parallel()
+	seg, offsets := writeMappedSegment(t, t.TempDir(), 50)
+	defer seg.Close()
+
+	require.NotNil(t, seg.mapped, "an opened segment should be mapped")
+	for i, offset := range offsets {
+		entry, err := seg.Read(offset)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("key_%d", i), string(entry.Key))
+		assert.Equal(t, fmt.Sprintf("value_%d", i), string(entry.Value))
+	}
+
+	_, err := seg.Read(seg.Size())
+	assert.ErrorIs(t, err, ErrPositionOutOfRange)
+}
+
+func TestSegment_MmapReleased(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Close", func(t *testing.T) {
+		seg, _ := writeMappedSegment(t, t.TempDir(), 3)
+		require.NoError(t, seg.Close())
+		assert.Nil(t, seg.mapped)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		seg, _ := writeMappedSegment(t, t.TempDir(), 3)
+		require.NoError(t, seg.Delete())
+		assert.Nil(t, seg.mapped)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		seg, offsets := writeMappedSegment(t, t.TempDir(), 3)
+		defer seg.Close()
+		require.NoError(t, seg.resume(SegmentOptions{Mmap: true}))
+		assert.Nil(t, seg.mapped, "the active segment should be read with ReadAt")
+
+		offset, err := seg.Append(createTestEntry("after", "resume"))
+		require.NoError(t, err)
+		entry, err := seg.Read(offset)
+		require.NoError(t, err)
+		assert.Equal(t, "resume", string(entry.Value))
+		entry, err = seg.Read(offsets[0])
+		require.NoError(t, err)
+		assert.Equal(t, "value_0", string(entry.Value))
+	})
+
+	t.Run("Truncate", func(t *testing.T) {
+		seg, offsets := writeMappedSegment(t, t.TempDir(), 3)
+		defer seg.Close()
+		require.NoError(t, seg.Truncate(offsets[2]))
+		require.NotNil(t, seg.mapped)
+		assert.Equal(t, offsets[2], int64(len(seg.mapped)))
+
+		_, err := seg.Read(offsets[2])
+		assert.ErrorIs(t, err, ErrPositionOutOfRange)
+		entry, err := seg.Read(offsets[1])
+		require.NoError(t, err)
+		assert.Equal(t, "value_1", string(entry.Value))
+	})
+}
+
+func TestSegment_MmapOnRollover(t *testing.T) {
+	t.Parallel()
+	seg, err := NewSegment(1, t.TempDir(), SegmentOptions{MaxEntriesPerSegment: 2, Mmap: true})
+	require.NoError(t, err)
+	defer seg.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := seg.Append(createTestEntry(fmt.Sprintf("key_%d", i), "value"))
+		require.NoError(t, err)
+		assert.Nil(t, seg.mapped, "the active segment should not be mapped")
+	}
+	_, err = seg.Append(createTestEntry("key_2", "value"))
+	require.ErrorIs(t, err, ErrSegmentFull)
+	require.NotNil(t, seg.mapped, "a sealed segment should be mapped")
+
+	entry, err := seg.Read(0)
+	require.NoError(t, err)
+	assert.Equal(t, "key_0", string(entry.Key))
+}
+
+func TestStore_MmapReadsSurviveMerge(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	store, err := New(zaptest.NewLogger(t), &config.Config{DataDir: dir, MaxEntriesPerSegment: 10, MmapReads: true})
+	require.NoError(t, err)
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 25; i++ {
+			require.NoError(t, store.Set(fmt.Sprintf("key_%d", i), fmt.Sprintf("value_%d_%d", i, round)))
+		}
+	}
+	for i := 0; i < 25; i += 2 {
+		require.NoError(t, store.Delete(fmt.Sprintf("key_%d", i)))
+	}
+
+	check := func(store *Store) {
+		t.Helper()
+		for i := 0; i < 25; i++ {
+			value, err := store.Get(fmt.Sprintf("key_%d", i))
+			if i%2 == 0 {
+				assert.ErrorIs(t, err, ErrKeyNotFound)
+				continue
+			}
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("value_%d_2", i), value)
+		}
+	}
+	check(store)
+
+	require.NoError(t, store.Merge())
+	for _, seg := range store.segmentManager.Segments() {
+		if !seg.IsActive() && seg.Size() > 0 {
+			assert.NotNil(t, seg.mapped, "segment %d should be mapped after the merge", seg.ID())
+		}
+	}
+	check(store)
+	require.NoError(t, store.Close())
+
+	store, err = New(zaptest.NewLogger(t), &config.Config{DataDir: dir, MmapReads: true})
+	require.NoError(t, err)
+	defer store.Close()
+	check(store)
+}
+
+// BenchmarkSegment_ReadMmap reads a sealed segment with ReadAt and through
+// its memory mapping
+func BenchmarkSegment_ReadMmap(b *testing.B) {
+	dir := b.TempDir()
+	seg, err := NewSegment(1, dir, SegmentOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	offsets := make([]int64, 1000)
+	for i := range offsets {
+		offsets[i], err = seg.Append(createTestEntry(fmt.Sprintf("user:%08d", i), string(bytes.Repeat([]byte("v"), 512))))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := seg.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, mmap := range []bool{false, true} {
+		name := "ReadAt"
+		if mmap {
+			name = "Mmap"
+		}
+		b.Run(name, func(b *testing.B) {
+			seg, err := OpenSegment(1, dir, SegmentOptions{Mmap: mmap})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer seg.Close()
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				entry := &Entry{}
+				for i := 0; pb.Next(); i++ {
+					if err := seg.ReadInto(offsets[i%len(offsets)], entry); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
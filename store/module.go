@@ -1,7 +1,31 @@
 package store
 
-import "go.uber.org/fx"
+import (
+	"context"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
 
 var Module = fx.Options(
-	fx.Provide(New),
+	fx.Provide(NewWithLifecycle),
 )
+
+// NewWithLifecycle opens the store and closes it when the app stops. The hook
+// is registered as the store is constructed, before anything that depends on
+// it, so fx runs it after their stop hooks and no request can reach a closed
+// store.
+func NewWithLifecycle(lc fx.Lifecycle, logger *zap.Logger, cfg *config.Config) (*Store, error) {
+	s, err := New(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Closing store")
+			return s.Close()
+		},
+	})
+	return s, nil
+}
@@ -0,0 +1,53 @@
+package store
+
+import "fmt"
+
+// Reload rescans the data directory and replaces the index with one built
+// from the segments found there, picking up segments another process has
+// added, grown or removed. It is meant for read-only stores that follow a
+// data directory written by someone else; a writable store already sees
+// every change and fails with ErrReloadWritable.
+//
+// The new index is built without holding the store's lock, so reads carry on
+// against the old one until the swap. If loading fails, the store is left as
+// it was.
+func (s *Store) Reload() error {
+	if !s.readOnly {
+		return ErrReloadWritable
+	}
+
+	segmentManager, err := NewSegmentManager(s.basePath, s.segmentOpts)
+	if err != nil {
+		return fmt.Errorf("failed to reload segments: %w", err)
+	}
+
+	// Load into a scratch store sharing this one's settings, so that none
+	// of the index or dead byte accounting is visible until it is complete
+	fresh := &Store{
+		basePath:       s.basePath,
+		segmentManager: segmentManager,
+		hashTable:      NewShardedHashTable(len(s.hashTable.shards)),
+		logger:         s.logger,
+		segmentOpts:    s.segmentOpts,
+		deadBytes:      make(map[int]int64),
+		readOnly:       true,
+	}
+	if err := fresh.loadFromSegments(); err != nil {
+		segmentManager.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	old := s.segmentManager
+	s.segmentManager = fresh.segmentManager
+	s.hashTable = fresh.hashTable
+	s.deadBytes = fresh.deadBytes
+	s.cache.purge() // Cached values may belong to keys since changed or removed
+	s.missing.purge()
+	s.mu.Unlock()
+
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
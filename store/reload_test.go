@@ -0,0 +1,75 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStore_Reload(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+	primary, err := New(zaptest.NewLogger(t), &config.Config{DataDir: dataDir, MaxEntriesPerSegment: 5})
+	require.NoError(t, err)
+	defer primary.Close()
+	for i := 0; i < 8; i++ {
+		require.NoError(t, primary.Set(fmt.Sprintf("key_%d", i), "v1"))
+	}
+
+	follower, err := New(zaptest.NewLogger(t), &config.Config{DataDir: dataDir, ReadOnly: true})
+	require.NoError(t, err)
+	defer follower.Close()
+	value, err := follower.Get("key_7")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", value)
+
+	// New keys, overwrites and deletes, some in a segment created since
+	for i := 0; i < 12; i++ {
+		require.NoError(t, primary.Set(fmt.Sprintf("key_%d", i), "v2"))
+	}
+	require.NoError(t, primary.Delete("key_0"))
+	_, err = follower.Get("key_11")
+	require.ErrorIs(t, err, ErrKeyNotFound, "the follower has not reloaded yet")
+
+	// Reads may run alongside the reload
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for !stop.Load() {
+			_, err := follower.Get("key_5")
+			assert.NoError(t, err)
+		}
+	}()
+	require.NoError(t, follower.Reload())
+	stop.Store(true)
+	wg.Wait()
+
+	check := func() {
+		t.Helper()
+		_, err := follower.Get("key_0")
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+		for i := 1; i < 12; i++ {
+			value, err := follower.Get(fmt.Sprintf("key_%d", i))
+			require.NoError(t, err)
+			assert.Equal(t, "v2", value)
+		}
+	}
+	check()
+	assert.Equal(t, primary.segmentManager.GetSegmentIDs(), follower.segmentManager.GetSegmentIDs())
+
+	// Segments the primary merges away are dropped
+	require.NoError(t, primary.Merge())
+	require.NoError(t, follower.Reload())
+	check()
+	assert.Equal(t, primary.segmentManager.GetSegmentIDs(), follower.segmentManager.GetSegmentIDs())
+
+	assert.ErrorIs(t, primary.Reload(), ErrReloadWritable)
+}
@@ -1,12 +1,12 @@
 package store
 
 import (
-	"encoding/binary"
 	"fmt"
-	"io"
+	"hash/crc32"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 const (
@@ -15,8 +15,95 @@ const (
 
 	// DefaultMaxEntriesPerSegment is the default maximum number of entries per segment
 	DefaultMaxEntriesPerSegment = 10000
+
+	// DefaultDirMode is the default permission for created data directories
+	DefaultDirMode os.FileMode = 0755
+
+	// DefaultFileMode is the default permission for created data files
+	DefaultFileMode os.FileMode = 0644
+)
+
+// SyncMode controls when segment writes are fsynced to durable storage
+type SyncMode string
+
+const (
+	// SyncNone leaves flushing to the OS; a crash may lose recent writes
+	SyncNone SyncMode = "none"
+
+	// SyncAlways fsyncs after every append so each write is durable on return
+	SyncAlways SyncMode = "always"
+
+	// SyncInterval fsyncs periodically from a background loop in the store
+	SyncInterval SyncMode = "interval"
+
+	// SyncGroup makes each write durable on return like SyncAlways, but
+	// concurrent writes share one fsync (see GroupCommitWindow)
+	SyncGroup SyncMode = "group"
 )
 
+// ParseSyncMode converts a config string into a SyncMode (empty means none)
+func ParseSyncMode(mode string) (SyncMode, error) {
+	switch SyncMode(mode) {
+	case "", SyncNone:
+		return SyncNone, nil
+	case SyncAlways, SyncInterval, SyncGroup:
+		return SyncMode(mode), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidSyncMode, mode)
+	}
+}
+
+// SegmentOptions configures how segments are written
+type SegmentOptions struct {
+	SyncMode             SyncMode
+	MaxSegmentSize       int64 // Rollover size in bytes (0 = DefaultMaxSegmentSize)
+	MaxEntriesPerSegment int   // Rollover entry count (0 = DefaultMaxEntriesPerSegment)
+
+	GroupCommitWindow time.Duration // SyncGroup wait for more writes (0 = DefaultGroupCommitWindow)
+	GroupCommitBytes  int64         // SyncGroup unsynced bytes that end the wait early (0 = DefaultGroupCommitBytes)
+
+	ReadOnly bool // Open existing segments only and never create an active one
+
+	DirMode  os.FileMode // Permissions for created directories (0 = DefaultDirMode)
+	FileMode os.FileMode // Permissions for created files (0 = DefaultFileMode)
+
+	Cipher *Cipher // Decrypts encrypted values on read (nil = none can be read)
+
+	Mmap bool // Read inactive segments through a memory mapping instead of ReadAt
+}
+
+// maxSize returns the configured segment size limit or the default
+func (o SegmentOptions) maxSize() int64 {
+	if o.MaxSegmentSize > 0 {
+		return o.MaxSegmentSize
+	}
+	return DefaultMaxSegmentSize
+}
+
+// maxEntries returns the configured entry limit or the default
+func (o SegmentOptions) maxEntries() int {
+	if o.MaxEntriesPerSegment > 0 {
+		return o.MaxEntriesPerSegment
+	}
+	return DefaultMaxEntriesPerSegment
+}
+
+// dirMode returns the configured directory permissions or the default
+func (o SegmentOptions) dirMode() os.FileMode {
+	if o.DirMode != 0 {
+		return o.DirMode
+	}
+	return DefaultDirMode
+}
+
+// fileMode returns the configured file permissions or the default
+func (o SegmentOptions) fileMode() os.FileMode {
+	if o.FileMode != 0 {
+		return o.FileMode
+	}
+	return DefaultFileMode
+}
+
 // Segment represents a single segment file in the append-only log
 type Segment struct {
 	mu         sync.RWMutex
@@ -24,18 +111,32 @@ type Segment struct {
 	path       string
 	file       *os.File
 	size       int64
-	entryCount int
+	entryCount int // -1 until counted, for a segment opened without a footer
 	maxSize    int64
 	maxEntries int
 	isActive   bool
 	isClosed   bool
+	syncMode   SyncMode
+	commits    *groupCommitter // Batches fsyncs in SyncGroup mode (nil otherwise)
+	cipher     *Cipher         // Decrypts encrypted values on read
+	crc        uint32          // CRC32 of the entries, kept up to date by appends
+	sealed     bool            // The footer is written, or the file is not ours to write
+	footer     footerState     // What OpenSegment found at the end of the file
+	fileMode   os.FileMode     // Permissions for files written alongside, like hints
+	mmap       bool            // Map the segment for reads once it is inactive
+	mapped     []byte          // Read-only mapping of the entries (nil = read with ReadAt)
+}
+
+// segmentPath returns the log file path for a segment ID
+func segmentPath(basePath string, id int) string {
+	return filepath.Join(basePath, fmt.Sprintf("segment_%d.log", id))
 }
 
 // NewSegment creates a new segment
-func NewSegment(id int, basePath string) (*Segment, error) {
-	path := filepath.Join(basePath, fmt.Sprintf("segment_%d.log", id))
+func NewSegment(id int, basePath string, opts SegmentOptions) (*Segment, error) {
+	path := segmentPath(basePath, id)
 
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, opts.fileMode())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create segment file: %w", err)
 	}
@@ -46,26 +147,43 @@ func NewSegment(id int, basePath string) (*Segment, error) {
 		file.Close()
 		return nil, fmt.Errorf("failed to stat segment file: %w", err)
 	}
+	crc, err := dataChecksum(file, stat.Size())
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to checksum segment file: %w", err)
+	}
 
 	segment := &Segment{
 		id:         id,
 		path:       path,
 		file:       file,
 		size:       stat.Size(),
-		maxSize:    DefaultMaxSegmentSize,
-		maxEntries: DefaultMaxEntriesPerSegment,
+		crc:        crc,
+		maxSize:    opts.maxSize(),
+		maxEntries: opts.maxEntries(),
 		isActive:   true,
 		isClosed:   false,
+		syncMode:   opts.SyncMode,
+		cipher:     opts.Cipher,
+		fileMode:   opts.fileMode(),
+		mmap:       opts.Mmap,
+	}
+	if opts.SyncMode == SyncGroup {
+		segment.commits = newGroupCommitter(file.Sync, opts)
 	}
 
 	return segment, nil
 }
 
-// OpenSegment opens an existing segment for reading
-func OpenSegment(id int, basePath string) (*Segment, error) {
-	path := filepath.Join(basePath, fmt.Sprintf("segment_%d.log", id))
+// OpenSegment opens an existing segment for reading. The entry count and
+// where the entries end come from the segment's footer when it is intact,
+// without reading the data; verify checks the data against the footer.
+// Without a footer the entries are counted the first time EntryCount is
+// called.
+func OpenSegment(id int, basePath string, opts SegmentOptions) (*Segment, error) {
+	path := segmentPath(basePath, id)
 
-	file, err := os.OpenFile(path, os.O_RDONLY, 0644)
+	file, err := os.OpenFile(path, os.O_RDONLY, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open segment file: %w", err)
 	}
@@ -76,38 +194,70 @@ func OpenSegment(id int, basePath string) (*Segment, error) {
 		file.Close()
 		return nil, fmt.Errorf("failed to stat segment file: %w", err)
 	}
+	size, entries, crc, footer, err := segmentLayout(file, stat.Size())
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read segment layout: %w", err)
+	}
 
 	segment := &Segment{
 		id:         id,
 		path:       path,
 		file:       file,
-		size:       stat.Size(),
-		maxSize:    DefaultMaxSegmentSize,
-		maxEntries: DefaultMaxEntriesPerSegment,
+		size:       size,
+		entryCount: entries,
+		crc:        crc,
+		maxSize:    opts.maxSize(),
+		maxEntries: opts.maxEntries(),
 		isActive:   false,
 		isClosed:   false,
+		syncMode:   opts.SyncMode,
+		cipher:     opts.Cipher,
+		sealed:     true,
+		footer:     footer,
+		fileMode:   opts.fileMode(),
+		mmap:       opts.Mmap,
 	}
+	segment.mapForReads()
 
 	return segment, nil
 }
 
-// Append writes an entry to the segment
+// Append writes an entry to the segment and, in SyncGroup mode, waits for it
+// to be committed
 func (s *Segment) Append(entry *Entry) (int64, error) {
+	offset, commit, err := s.AppendAsync(entry)
+	if err != nil {
+		return 0, err
+	}
+	if err := commit.Wait(); err != nil {
+		return 0, fmt.Errorf("failed to sync entry: %w", err)
+	}
+	return offset, nil
+}
+
+// AppendAsync writes an entry to the segment. In SyncGroup mode the entry is
+// durable once the returned Commit's Wait returns; otherwise the Commit is
+// nil. The entry can be read back straight away either way.
+func (s *Segment) AppendAsync(entry *Entry) (int64, *Commit, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.isClosed {
-		return 0, ErrSegmentClosed
+		return 0, nil, ErrSegmentClosed
 	}
 
 	if !s.isActive {
-		return 0, ErrSegmentClosed
+		return 0, nil, ErrSegmentClosed
 	}
 
 	// Check if segment is full
-	if s.size >= s.maxSize || s.entryCount >= s.maxEntries {
+	if s.full() {
 		s.isActive = false
-		return 0, ErrSegmentFull
+		// Best effort: a segment without a footer is counted when opened
+		_ = s.seal()
+		s.mapForReads()
+		return 0, nil, ErrSegmentFull
 	}
 
 	// Serialize entry
@@ -117,53 +267,192 @@ func (s *Segment) Append(entry *Entry) (int64, error) {
 	offset := s.size
 	_, err := s.file.Write(data)
 	if err != nil {
-		return 0, fmt.Errorf("failed to write entry: %w", err)
+		return 0, nil, fmt.Errorf("failed to write entry: %w", err)
+	}
+
+	// Make the write durable before acknowledging it
+	if s.syncMode == SyncAlways {
+		if err := s.file.Sync(); err != nil {
+			return 0, nil, fmt.Errorf("failed to sync entry: %w", err)
+		}
 	}
 
 	// Update segment stats
 	s.size += int64(len(data))
 	s.entryCount++
+	s.crc = crc32.Update(s.crc, crc32.IEEETable, data)
 
-	return offset, nil
+	var commit *Commit
+	if s.commits != nil {
+		commit = s.commits.add(len(data))
+	}
+	return offset, commit, nil
+}
+
+// full reports whether the segment has reached its size or entry limit; the
+// caller must hold s.mu
+func (s *Segment) full() bool {
+	return s.size >= s.maxSize || s.entryCount >= s.maxEntries
+}
+
+// resume makes a segment loaded by OpenSegment the active one again. Its
+// footer is cut off so that appends continue straight after the last entry,
+// and is written again when the segment next fills up or closes.
+func (s *Segment) resume(opts SegmentOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Appends would run past the end of the mapping
+	s.unmap()
+
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("failed to reopen segment file: %w", err)
+	}
+	if err := file.Truncate(s.size); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to remove segment footer: %w", err)
+	}
+
+	s.file.Close()
+	s.file = file
+	s.isActive = true
+	s.sealed = false
+	s.footer = footerMissing
+	if opts.SyncMode == SyncGroup {
+		s.commits = newGroupCommitter(file.Sync, opts)
+	}
+	return nil
+}
+
+// maxPooledReadBuffer is the largest read buffer kept for reuse; reading a
+// bigger entry allocates a buffer just for it
+const maxPooledReadBuffer = 64 * 1024
+
+// readBuffers holds the buffers entries are read into before being decoded.
+// Each read takes its own, so concurrent reads never share one.
+var readBuffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 4096)
+		return &buf
+	},
 }
 
 // Read reads an entry from the segment at the given position
 func (s *Segment) Read(pos int64) (*Entry, error) {
+	entry := &Entry{}
+	if err := s.ReadInto(pos, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ReadInto reads the entry at the given position into entry, reusing the
+// memory behind its Key and Value when it is large enough. Callers reading
+// many entries can pass the same Entry each time, but must copy anything
+// they keep from Key or Value before the next call.
+func (s *Segment) ReadInto(pos int64, entry *Entry) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	if pos >= s.size {
-		return nil, fmt.Errorf("position %d is beyond segment size %d", pos, s.size)
+		return fmt.Errorf("%w: %d is beyond segment size %d", ErrPositionOutOfRange, pos, s.size)
 	}
-
-	// Seek to position
-	_, err := s.file.Seek(pos, io.SeekStart)
-	if err != nil {
-		return nil, fmt.Errorf("failed to seek to position %d: %w", pos, err)
+	if pos+legacyHeaderSize > s.size {
+		return fmt.Errorf("failed to read entry header: %w", ErrTruncatedEntry)
+	}
+	if s.mapped != nil {
+		return s.readMapped(pos, entry)
 	}
 
-	// Read entry header (12 bytes: timestamp + keysize + valuesize)
-	header := make([]byte, 12)
-	_, err = io.ReadFull(s.file, header)
+	bufp := readBuffers.Get().(*[]byte)
+	defer func() {
+		if cap(*bufp) <= maxPooledReadBuffer {
+			readBuffers.Put(bufp)
+		}
+	}()
+
+	// Read entry header prefix (12 bytes: timestamp + keysize + valuesize).
+	// ReadAt leaves the file offset alone, so concurrent reads don't need to
+	// take turns seeking, and never move the offset appends write at.
+	header := (*bufp)[:legacyHeaderSize]
+	_, err := s.file.ReadAt(header, pos)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read entry header: %w", err)
+		return fmt.Errorf("failed to read entry header: %w", err)
 	}
 
-	// Parse sizes
-	keySize := binary.LittleEndian.Uint32(header[4:8])
-	valueSize := binary.LittleEndian.Uint32(header[8:12])
+	// Parse sizes (the checksum, if any, is read along with the data)
+	hdrSize, _, keySize, valueSize := decodeHeaderPrefix(header)
 
 	// Read full entry
-	entrySize := 12 + int(keySize) + int(valueSize)
-	entryData := make([]byte, entrySize)
-	copy(entryData, header)
+	entrySize := hdrSize + int(keySize) + int(valueSize)
+	if pos+int64(entrySize) > s.size {
+		return fmt.Errorf("failed to read entry data: %w", ErrTruncatedEntry)
+	}
+	if cap(*bufp) < entrySize {
+		grown := make([]byte, entrySize)
+		copy(grown, header)
+		*bufp = grown
+	}
+	entryData := (*bufp)[:entrySize]
 
-	_, err = io.ReadFull(s.file, entryData[12:])
+	_, err = s.file.ReadAt(entryData[legacyHeaderSize:], pos+legacyHeaderSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read entry data: %w", err)
+		return fmt.Errorf("failed to read entry data: %w", err)
 	}
 
-	return DeserializeEntry(entryData)
+	return decodeEntry(entryData, s.cipher, entry)
+}
+
+// readMapped decodes the entry at pos from the segment's mapping; the caller
+// must hold s.mu and have checked that the header lies within the segment
+func (s *Segment) readMapped(pos int64, entry *Entry) error {
+	hdrSize, _, keySize, valueSize := decodeHeaderPrefix(s.mapped[pos : pos+legacyHeaderSize])
+	end := pos + int64(hdrSize) + int64(keySize) + int64(valueSize)
+	if end > s.size || end > int64(len(s.mapped)) {
+		return fmt.Errorf("failed to read entry data: %w", ErrTruncatedEntry)
+	}
+
+	// decodeEntry copies the key and value out, so nothing refers to the
+	// mapping once it is released
+	return decodeEntry(s.mapped[pos:end], s.cipher, entry)
+}
+
+// mapForReads maps an inactive segment's entries into memory when the
+// segment was opened with Mmap. Mapping is an optimisation: if it fails, the
+// segment is read with ReadAt. The caller must hold s.mu for writing or own
+// s exclusively.
+func (s *Segment) mapForReads() {
+	if !s.mmap || s.mapped != nil || s.isActive || s.isClosed || s.size == 0 {
+		return
+	}
+	if mapped, err := mapFile(s.file, s.size); err == nil {
+		s.mapped = mapped
+	}
+}
+
+// unmap releases the segment's mapping, if any; the caller must hold s.mu
+// for writing
+func (s *Segment) unmap() {
+	if s.mapped != nil {
+		_ = unmapFile(s.mapped)
+		s.mapped = nil
+	}
+}
+
+// Truncate cuts the segment file back to size, discarding anything after it
+func (s *Segment) Truncate(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Touching a mapped page past the new end of the file would fault
+	s.unmap()
+	if err := os.Truncate(s.path, size); err != nil {
+		return fmt.Errorf("failed to truncate segment: %w", err)
+	}
+	s.size = size
+	s.mapForReads()
+	return nil
 }
 
 // Close closes the segment
@@ -177,8 +466,60 @@ func (s *Segment) Close() error {
 
 	s.isActive = false
 	s.isClosed = true
+	s.unmap()
+
+	sealErr := s.seal()
+
+	// Release anyone still waiting for a group commit
+	var flushErr error
+	if s.commits != nil {
+		flushErr = s.commits.flush()
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if sealErr != nil {
+		return fmt.Errorf("failed to write segment footer: %w", sealErr)
+	}
+	return flushErr
+}
+
+// deactivate stops the segment accepting appends and seals it, as happens
+// when it fills up
+func (s *Segment) deactivate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isClosed {
+		return ErrSegmentClosed
+	}
+	if !s.isActive {
+		return nil
+	}
+
+	s.isActive = false
+	if err := s.seal(); err != nil {
+		return fmt.Errorf("failed to write segment footer: %w", err)
+	}
+	s.mapForReads()
+	return nil
+}
 
-	return s.file.Close()
+// seal appends the footer recording the segment's entry count and checksum,
+// once, after its last append; the caller must hold s.mu for writing
+func (s *Segment) seal() error {
+	if s.sealed {
+		return nil
+	}
+	s.sealed = true
+
+	if _, err := s.file.Write(encodeFooter(s.size, s.entryCount, s.crc)); err != nil {
+		return err
+	}
+	if s.syncMode != SyncNone && s.syncMode != "" {
+		return s.file.Sync()
+	}
+	return nil
 }
 
 // IsActive returns whether the segment is active (can be written to)
@@ -188,6 +529,20 @@ func (s *Segment) IsActive() bool {
 	return s.isActive && !s.isClosed
 }
 
+// writable reports why appends to the segment would fail, if they would
+func (s *Segment) writable() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.isClosed {
+		return ErrSegmentClosed
+	}
+	if _, err := s.file.Stat(); err != nil {
+		return fmt.Errorf("stat segment %d: %w", s.id, err)
+	}
+	return nil
+}
+
 // Size returns the current size of the segment
 func (s *Segment) Size() int64 {
 	s.mu.RLock()
@@ -195,13 +550,62 @@ func (s *Segment) Size() int64 {
 	return s.size
 }
 
-// EntryCount returns the number of entries in the segment
+// EntryCount returns the number of entries in the segment. The first call
+// for a segment opened from disk reads it: a footer's count is only used
+// once the data has been checked against the footer, and a segment without
+// a usable footer has its entries counted by walking their headers.
 func (s *Segment) EntryCount() int {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
+	count, footer := s.entryCount, s.footer
+	s.mu.RUnlock()
+	if count >= 0 && footer != footerUnverified {
+		return count
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.verifyLocked(); err != nil {
+		return max(s.entryCount, 0)
+	}
+	if s.entryCount < 0 {
+		count, err := countEntries(s.file, s.size)
+		if err != nil {
+			return 0
+		}
+		s.entryCount = count
+	}
 	return s.entryCount
 }
 
+// verify checks the data of a segment opened with an intact footer against
+// the footer's checksum, reading the whole segment the first time. On a
+// mismatch the footer state becomes footerMismatch and the footer's entry
+// count is dropped, to be counted again when needed. It reports whether the
+// data matched; a segment without a footer has nothing to mismatch.
+func (s *Segment) verify() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.verifyLocked()
+}
+
+// verifyLocked is verify; the caller must hold s.mu for writing
+func (s *Segment) verifyLocked() (bool, error) {
+	if s.footer != footerUnverified {
+		return s.footer != footerMismatch, nil
+	}
+	crc, err := dataChecksum(s.file, s.size)
+	if err != nil {
+		return false, err
+	}
+	if crc != s.crc {
+		s.footer = footerMismatch
+		s.entryCount = -1
+		return false, nil
+	}
+	s.footer = footerValid
+	return true, nil
+}
+
 // ID returns the segment ID
 func (s *Segment) ID() int {
 	return s.id
@@ -214,12 +618,21 @@ func (s *Segment) Path() string {
 
 // Delete the segment
 func (s *Segment) Delete() error {
+	s.mu.Lock()
+	s.unmap()
 	if !s.isClosed {
+		s.isClosed = true
+		s.isActive = false
 		s.file.Close()
 	}
+	s.mu.Unlock()
+
 	if err := os.Remove(s.Path()); err != nil {
 		return err
 	}
+	if err := os.Remove(hintPath(s.Path())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	return nil
 }
 
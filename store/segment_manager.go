@@ -8,6 +8,10 @@ import (
 	"sync"
 )
 
+// lockFileName is the file in the data directory a writable store holds an
+// exclusive lock on while it is open
+const lockFileName = "LOCK"
+
 // SegmentManager manages multiple segments in the append-only log
 type SegmentManager struct {
 	mu       sync.RWMutex
@@ -15,29 +19,52 @@ type SegmentManager struct {
 	segments map[int]*Segment
 	activeID int
 	nextID   int
+	opts     SegmentOptions
+	lock     *os.File // Holds the data directory lock (nil if read-only)
 }
 
 // NewSegmentManager creates a new segment manager
-func NewSegmentManager(basePath string) (*SegmentManager, error) {
+func NewSegmentManager(basePath string, opts SegmentOptions) (*SegmentManager, error) {
 	sm := &SegmentManager{
 		basePath: basePath,
 		segments: make(map[int]*Segment),
 		nextID:   1,
+		opts:     opts,
 	}
 
 	// Ensure base directory exists
-	if err := os.MkdirAll(basePath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create base directory: %w", err)
+	if !opts.ReadOnly {
+		if err := os.MkdirAll(basePath, opts.dirMode()); err != nil {
+			return nil, fmt.Errorf("failed to create base directory: %w", err)
+		}
+
+		// Two writers appending to the same segments would corrupt them.
+		// A read-only store writes nothing, so it neither takes nor
+		// respects the lock.
+		lock, err := lockDir(basePath, opts.fileMode())
+		if err != nil {
+			return nil, err
+		}
+		sm.lock = lock
+	}
+
+	// Finish or discard a merge a crash interrupted before looking at the
+	// segments it touches
+	if err := recoverMerge(basePath, opts.ReadOnly); err != nil {
+		sm.Close()
+		return nil, err
 	}
 
 	// Load existing segments
 	if err := sm.loadSegments(); err != nil {
+		sm.Close()
 		return nil, fmt.Errorf("failed to load segments: %w", err)
 	}
 
 	// Create active segment if none exists
-	if sm.activeID == 0 {
+	if sm.activeID == 0 && !opts.ReadOnly {
 		if err := sm.createActiveSegment(); err != nil {
+			sm.Close()
 			return nil, fmt.Errorf("failed to create active segment: %w", err)
 		}
 	}
@@ -45,7 +72,8 @@ func NewSegmentManager(basePath string) (*SegmentManager, error) {
 	return sm, nil
 }
 
-// loadSegments scans the base directory for existing segment files
+// loadSegments scans the base directory for existing segment files. It only
+// runs from NewSegmentManager, before the manager is shared.
 func (sm *SegmentManager) loadSegments() error {
 	files, err := filepath.Glob(filepath.Join(sm.basePath, "segment_*.log"))
 	if err != nil {
@@ -63,7 +91,7 @@ func (sm *SegmentManager) loadSegments() error {
 			continue // Skip invalid files
 		}
 
-		segment, err := OpenSegment(id, sm.basePath)
+		segment, err := OpenSegment(id, sm.basePath, sm.opts)
 		if err != nil {
 			return fmt.Errorf("failed to open segment %d: %w", id, err)
 		}
@@ -80,14 +108,23 @@ func (sm *SegmentManager) loadSegments() error {
 	// Sort segment IDs
 	sort.Ints(segmentIDs)
 
-	// Determine active segment (the last one that's not full)
-	for i := len(segmentIDs) - 1; i >= 0; i-- {
-		id := segmentIDs[i]
-		segment := segmentMap[id]
-
-		if segment.IsActive() {
-			sm.activeID = id
-			break
+	// Keep appending to the last segment if it has room. Only one that was
+	// sealed cleanly, and whose data still matches its footer, is resumed:
+	// anything else may end in damaged data that loading has to step around,
+	// so it is left as it is. Only this segment's data is checksummed here.
+	if n := len(segmentIDs); n > 0 && !sm.opts.ReadOnly {
+		last := segmentMap[segmentIDs[n-1]]
+		if last.footer == footerUnverified && !last.full() {
+			intact, err := last.verify()
+			if err != nil {
+				return fmt.Errorf("failed to verify segment %d: %w", last.ID(), err)
+			}
+			if intact {
+				if err := last.resume(sm.opts); err != nil {
+					return fmt.Errorf("failed to resume segment %d: %w", last.ID(), err)
+				}
+				sm.activeID = last.ID()
+			}
 		}
 	}
 
@@ -95,9 +132,11 @@ func (sm *SegmentManager) loadSegments() error {
 	return nil
 }
 
-// createActiveSegment creates a new active segment
+// createActiveSegment creates a new active segment. The caller must hold
+// sm.mu for writing (or own sm exclusively) so that activeID and nextID
+// advance together and no appender can observe a half-finished rollover.
 func (sm *SegmentManager) createActiveSegment() error {
-	segment, err := NewSegment(sm.nextID, sm.basePath)
+	segment, err := NewSegment(sm.nextID, sm.basePath, sm.opts)
 	if err != nil {
 		return fmt.Errorf("failed to create new segment: %w", err)
 	}
@@ -115,12 +154,12 @@ func (sm *SegmentManager) GetActiveSegment() (*Segment, error) {
 	defer sm.mu.RUnlock()
 
 	if sm.activeID == 0 {
-		return nil, fmt.Errorf("no active segment")
+		return nil, ErrNoActiveSegment
 	}
 
 	segment, exists := sm.segments[sm.activeID]
 	if !exists {
-		return nil, fmt.Errorf("active segment %d not found", sm.activeID)
+		return nil, fmt.Errorf("%w: active segment %d", ErrSegmentNotFound, sm.activeID)
 	}
 
 	return segment, nil
@@ -135,40 +174,83 @@ func (sm *SegmentManager) GetSegment(id int) (*Segment, bool) {
 	return segment, exists
 }
 
-// Append writes an entry to the active segment
+// Append writes an entry to the active segment and, in SyncGroup mode, waits
+// for it to be committed
 func (sm *SegmentManager) Append(entry *Entry) (int, int64, error) {
+	segmentID, offset, commit, err := sm.AppendAsync(entry)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := commit.Wait(); err != nil {
+		return 0, 0, fmt.Errorf("failed to sync entry: %w", err)
+	}
+	return segmentID, offset, nil
+}
+
+// AppendAsync writes an entry to the active segment without waiting for a
+// group commit; see Segment.AppendAsync. The write lock is held across the
+// full check, rollover and retry, so concurrent appends near a segment
+// boundary roll over exactly once.
+func (sm *SegmentManager) AppendAsync(entry *Entry) (int, int64, *Commit, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
 	// Access active segment directly while holding write lock (avoid nested locks)
 	if sm.activeID == 0 {
-		return 0, 0, fmt.Errorf("no active segment")
+		return 0, 0, nil, ErrNoActiveSegment
 	}
 	segment, exists := sm.segments[sm.activeID]
 	if !exists {
-		return 0, 0, fmt.Errorf("active segment %d not found", sm.activeID)
+		return 0, 0, nil, fmt.Errorf("%w: active segment %d", ErrSegmentNotFound, sm.activeID)
 	}
 
-	offset, err := segment.Append(entry)
+	offset, commit, err := segment.AppendAsync(entry)
 	if err != nil {
 		if err == ErrSegmentFull {
 			// Create new active segment
 			if err := sm.createActiveSegment(); err != nil {
-				return 0, 0, err
+				return 0, 0, nil, err
 			}
 
 			// Try again with new segment
 			segment = sm.segments[sm.activeID]
-			offset, err = segment.Append(entry)
+			offset, commit, err = segment.AppendAsync(entry)
 			if err != nil {
-				return 0, 0, err
+				return 0, 0, nil, err
 			}
 		} else {
-			return 0, 0, err
+			return 0, 0, nil, err
 		}
 	}
 
-	return segment.ID(), offset, nil
+	return segment.ID(), offset, commit, nil
+}
+
+// Rotate seals the active segment and makes a new, empty segment active
+// without waiting for the old one to fill up. It returns the ID of the active
+// segment; an active segment with no entries is kept rather than rotated.
+func (sm *SegmentManager) Rotate() (int, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.activeID == 0 {
+		return 0, ErrNoActiveSegment
+	}
+	segment, exists := sm.segments[sm.activeID]
+	if !exists {
+		return 0, fmt.Errorf("%w: active segment %d", ErrSegmentNotFound, sm.activeID)
+	}
+	if segment.EntryCount() == 0 {
+		return sm.activeID, nil
+	}
+
+	if err := segment.deactivate(); err != nil {
+		return 0, err
+	}
+	if err := sm.createActiveSegment(); err != nil {
+		return 0, err
+	}
+	return sm.activeID, nil
 }
 
 // Read reads an entry from a specific segment and position
@@ -178,13 +260,13 @@ func (sm *SegmentManager) Read(segmentID int, pos int64) (*Entry, error) {
 
 	segment, exists := sm.segments[segmentID]
 	if !exists {
-		return nil, fmt.Errorf("segment %d not found", segmentID)
+		return nil, fmt.Errorf("%w: %d", ErrSegmentNotFound, segmentID)
 	}
 
 	return segment.Read(pos)
 }
 
-// Close closes all segments
+// Close closes all segments and releases the data directory lock
 func (sm *SegmentManager) Close() error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -199,6 +281,13 @@ func (sm *SegmentManager) Close() error {
 	sm.segments = make(map[int]*Segment)
 	sm.activeID = 0
 
+	if sm.lock != nil {
+		if err := sm.lock.Close(); err != nil {
+			lastErr = err
+		}
+		sm.lock = nil
+	}
+
 	return lastErr
 }
 
@@ -216,14 +305,28 @@ func (sm *SegmentManager) GetSegmentIDs() []int {
 	return ids
 }
 
-// GetSegmentIDs returns all segment IDs
+// Segments returns every segment in ID order
+func (sm *SegmentManager) Segments() []*Segment {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	segments := make([]*Segment, 0, len(sm.segments))
+	for _, segment := range sm.segments {
+		segments = append(segments, segment)
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].ID() < segments[j].ID() })
+	return segments
+}
+
+// GetInactiveSegmentIDs returns the IDs of segments that no longer accept writes
 func (sm *SegmentManager) GetInactiveSegmentIDs() []int {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
 	ids := make([]int, 0, len(sm.segments))
 	for id, segment := range sm.segments {
-		if !segment.isActive {
+		if !segment.IsActive() {
 			ids = append(ids, id)
 		}
 	}
@@ -249,13 +352,11 @@ func (sm *SegmentManager) DeleteSegment(id int) error {
 	return nil
 }
 
-// MergeFrom copies segment pointers from src into sm.
-func (sm *SegmentManager) Merge(src *SegmentManager) {
+// AddSegment registers a segment, replacing any segment with the same ID.
+func (sm *SegmentManager) AddSegment(segment *Segment) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	for k, v := range src.segments {
-		sm.segments[k] = v
-	}
+	sm.segments[segment.ID()] = segment
 }
 
 // FlushAll fsyncs all segment files in the manager.
@@ -1,12 +1,15 @@
 package store
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type segmentTestContext struct {
@@ -43,7 +46,7 @@ func TestNewSegmentManager_EmptyDir(t *testing.T) {
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
-	sm, err := NewSegmentManager(ctx.tempDir)
+	sm, err := NewSegmentManager(ctx.tempDir, SegmentOptions{})
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, sm.activeID, "Active ID should be 1")
@@ -68,7 +71,7 @@ func TestNewSegmentManager_LoadExisting(t *testing.T) {
 	file5Path := filepath.Join(ctx.tempDir, "segment_5.log")
 	os.WriteFile(file5Path, []byte("data"), 0644)
 
-	sm, err := NewSegmentManager(ctx.tempDir)
+	sm, err := NewSegmentManager(ctx.tempDir, SegmentOptions{})
 	assert.NoError(t, err)
 
 	assert.Len(t, sm.segments, 3, "Should load 1, 5 and create 6")
@@ -77,12 +80,91 @@ func TestNewSegmentManager_LoadExisting(t *testing.T) {
 	assert.Equal(t, []int{1, 5, 6}, sm.GetSegmentIDs(), "Segment IDs should be sorted")
 }
 
+func TestNewSegmentManager_ResumesLastSegment(t *testing.T) {
+	t.Parallel()
+	ctx := setupTest(t)
+	defer teardownTest(ctx)
+
+	sm, err := NewSegmentManager(ctx.tempDir, SegmentOptions{})
+	require.NoError(t, err)
+	_, first, err := sm.Append(createEntry("a"))
+	require.NoError(t, err)
+	require.NoError(t, sm.Close())
+
+	reopened, err := NewSegmentManager(ctx.tempDir, SegmentOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, reopened.GetSegmentIDs(), "No new segment should be created")
+	assert.Equal(t, 1, reopened.activeID)
+	assert.Equal(t, 2, reopened.nextID)
+
+	active, err := reopened.GetActiveSegment()
+	require.NoError(t, err)
+	assert.True(t, active.IsActive())
+	assert.Equal(t, 1, active.EntryCount())
+
+	// Appends continue straight after the first entry, not after the footer
+	id, second, err := reopened.Append(createEntry("b"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+	assert.Equal(t, int64(createEntry("a").Size()), second)
+	require.NoError(t, reopened.Close())
+
+	again, err := NewSegmentManager(ctx.tempDir, SegmentOptions{})
+	require.NoError(t, err)
+	defer again.Close()
+	assert.Equal(t, []int{1}, again.GetSegmentIDs())
+	for pos, key := range map[int64]string{first: "a", second: "b"} {
+		entry, err := again.Read(1, pos)
+		require.NoError(t, err)
+		assert.Equal(t, key, string(entry.Key))
+	}
+	active, err = again.GetActiveSegment()
+	require.NoError(t, err)
+	assert.Equal(t, 2, active.EntryCount())
+}
+
+func TestNewSegmentManager_DoesNotResume(t *testing.T) {
+	t.Parallel()
+
+	for name, opts := range map[string]SegmentOptions{
+		"full":       {MaxEntriesPerSegment: 1},
+		"no footer":  {},
+		"mismatched": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			sm, err := NewSegmentManager(dir, opts)
+			require.NoError(t, err)
+			_, _, err = sm.Append(createEntry("a"))
+			require.NoError(t, err)
+			require.NoError(t, sm.Close())
+			switch name {
+			case "no footer":
+				stripFooter(t, segmentPath(dir, 1))
+			case "mismatched":
+				data, err := os.ReadFile(segmentPath(dir, 1))
+				require.NoError(t, err)
+				data[len(data)-footerSize-1] ^= 0xFF // Last value byte, before the footer
+				require.NoError(t, os.WriteFile(segmentPath(dir, 1), data, 0o644))
+			}
+
+			reopened, err := NewSegmentManager(dir, opts)
+			require.NoError(t, err)
+			defer reopened.Close()
+			assert.Equal(t, []int{1, 2}, reopened.GetSegmentIDs())
+			assert.Equal(t, 2, reopened.activeID)
+			segment, _ := reopened.GetSegment(1)
+			assert.False(t, segment.IsActive())
+		})
+	}
+}
+
 func TestSegmentManager_AppendAndRead(t *testing.T) {
 	t.Parallel()
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
-	sm, _ := NewSegmentManager(ctx.tempDir)
+	sm, _ := NewSegmentManager(ctx.tempDir, SegmentOptions{})
 	entry1 := createEntry("key_1")
 	entry2 := createEntry("key_2")
 
@@ -106,10 +188,10 @@ func TestSegmentManager_AppendAndRead(t *testing.T) {
 	assert.Equal(t, string(entry2.Key), string(readEntry2.Key), "Read key must match")
 
 	_, err = sm.Read(segID1, 99999)
-	assert.Error(t, err, "Should fail to read beyond segment size")
+	assert.ErrorIs(t, err, ErrPositionOutOfRange, "Should fail to read beyond segment size")
 
 	_, err = sm.Read(99, 0)
-	assert.ErrorContains(t, err, "segment 99 not found")
+	assert.ErrorIs(t, err, ErrSegmentNotFound)
 }
 
 func TestSegmentManager_Append_SegmentSwitch_Forced(t *testing.T) {
@@ -117,7 +199,7 @@ func TestSegmentManager_Append_SegmentSwitch_Forced(t *testing.T) {
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
-	sm, _ := NewSegmentManager(ctx.tempDir)
+	sm, _ := NewSegmentManager(ctx.tempDir, SegmentOptions{})
 
 	segment1 := sm.segments[1]
 
@@ -144,12 +226,54 @@ func TestSegmentManager_Append_SegmentSwitch_Forced(t *testing.T) {
 	assert.GreaterOrEqual(t, segment2.EntryCount(), 1, "Segment 2 should have 1 entry")
 }
 
+func TestSegmentManager_Rotate(t *testing.T) {
+	t.Parallel()
+	ctx := setupTest(t)
+	defer teardownTest(ctx)
+
+	sm, err := NewSegmentManager(ctx.tempDir, SegmentOptions{})
+	require.NoError(t, err)
+	defer sm.Close()
+
+	id, err := sm.Rotate()
+	require.NoError(t, err)
+	assert.Equal(t, 1, id, "An empty active segment is not rotated")
+	assert.Empty(t, sm.GetInactiveSegmentIDs())
+
+	_, offset, err := sm.Append(createEntry("before"))
+	require.NoError(t, err)
+
+	id, err = sm.Rotate()
+	require.NoError(t, err)
+	assert.Equal(t, 2, id)
+	active, err := sm.GetActiveSegment()
+	require.NoError(t, err)
+	assert.Equal(t, 2, active.ID())
+	assert.True(t, active.IsActive())
+	assert.Equal(t, []int{1}, sm.GetInactiveSegmentIDs())
+
+	// The old segment stays readable, and new writes go to the new one
+	entry, err := sm.Read(1, offset)
+	require.NoError(t, err)
+	assert.Equal(t, "before_value", string(entry.Value))
+	segID, _, err := sm.Append(createEntry("after"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, segID)
+
+	// The rotated segment was sealed, so it reopens as a complete segment
+	require.NoError(t, sm.Close())
+	sm, err = NewSegmentManager(ctx.tempDir, SegmentOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, sm.GetInactiveSegmentIDs())
+	assert.Equal(t, 2, sm.activeID)
+}
+
 func TestSegmentManager_Close(t *testing.T) {
 	t.Parallel()
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
-	sm, _ := NewSegmentManager(ctx.tempDir)
+	sm, _ := NewSegmentManager(ctx.tempDir, SegmentOptions{})
 	sm.Append(createEntry("data1"))
 	sm.Append(createEntry("data2"))
 
@@ -160,7 +284,7 @@ func TestSegmentManager_Close(t *testing.T) {
 	assert.Empty(t, sm.segments, "Segments map should be empty after Close")
 
 	_, err = sm.Read(1, 0)
-	assert.ErrorContains(t, err, "segment 1 not found", "Read should fail because segments map is empty")
+	assert.ErrorIs(t, err, ErrSegmentNotFound, "Read should fail because segments map is empty")
 }
 func TestNewSegmentManager_OpenSegmentFails(t *testing.T) {
 	t.Parallel()
@@ -171,7 +295,7 @@ func TestNewSegmentManager_OpenSegmentFails(t *testing.T) {
 	err := os.WriteFile(ctx.tempDir, []byte("not a directory"), 0644)
 	assert.NoError(t, err)
 
-	_, err = NewSegmentManager(ctx.tempDir)
+	_, err = NewSegmentManager(ctx.tempDir, SegmentOptions{})
 	assert.Error(t, err, "Expected error because base path is not a directory")
 }
 
@@ -188,8 +312,7 @@ func TestSegmentManager_GetActiveSegment_NoActive(t *testing.T) {
 	}
 
 	_, err := sm.GetActiveSegment()
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "no active segment")
+	assert.ErrorIs(t, err, ErrNoActiveSegment)
 }
 
 func TestSegmentManager_Append_NoActive(t *testing.T) {
@@ -205,8 +328,7 @@ func TestSegmentManager_Append_NoActive(t *testing.T) {
 	}
 
 	_, _, err := sm.Append(createEntry("k"))
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "no active segment")
+	assert.ErrorIs(t, err, ErrNoActiveSegment)
 }
 
 func TestSegmentManager_Append_ActiveNotFound(t *testing.T) {
@@ -222,8 +344,8 @@ func TestSegmentManager_Append_ActiveNotFound(t *testing.T) {
 	}
 
 	_, _, err := sm.Append(createEntry("k"))
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "active segment 5 not found")
+	assert.ErrorIs(t, err, ErrSegmentNotFound)
+	assert.ErrorContains(t, err, "active segment 5", "the message still names the segment")
 }
 
 func TestSegmentManager_Read_MissingSegment(t *testing.T) {
@@ -231,11 +353,98 @@ func TestSegmentManager_Read_MissingSegment(t *testing.T) {
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
-	sm, _ := NewSegmentManager(ctx.tempDir)
+	sm, _ := NewSegmentManager(ctx.tempDir, SegmentOptions{})
 	_, ok := sm.GetSegment(999)
 	assert.False(t, ok)
 
 	_, err := sm.Read(999, 0)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "segment 999 not found")
+	assert.ErrorIs(t, err, ErrSegmentNotFound)
+}
+
+func TestSegmentManager_ConcurrentRollover(t *testing.T) {
+	t.Parallel()
+	ctx := setupTest(t)
+	defer teardownTest(ctx)
+
+	const (
+		writers          = 16
+		entriesPerWriter = 50
+		maxEntries       = 25
+	)
+
+	sm, err := NewSegmentManager(ctx.tempDir, SegmentOptions{MaxEntriesPerSegment: maxEntries})
+	require.NoError(t, err)
+	defer sm.Close()
+
+	type location struct {
+		segmentID int
+		offset    int64
+	}
+	locations := make(map[string]location)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < entriesPerWriter; i++ {
+				key := fmt.Sprintf("w%d_k%d", w, i)
+				segmentID, offset, err := sm.Append(createEntry(key))
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				locations[key] = location{segmentID, offset}
+				mu.Unlock()
+
+				// Readers race with rollover too
+				sm.GetInactiveSegmentIDs()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Every entry is readable where Append said it was written
+	require.Len(t, locations, writers*entriesPerWriter)
+	for key, loc := range locations {
+		entry, err := sm.Read(loc.segmentID, loc.offset)
+		require.NoError(t, err)
+		assert.Equal(t, key, string(entry.Key))
+	}
+
+	// Segment IDs are contiguous and every segment except the last is exactly full
+	total := writers * entriesPerWriter
+	wantSegments := (total + maxEntries - 1) / maxEntries
+	ids := sm.GetSegmentIDs()
+	require.Len(t, ids, wantSegments)
+	for i, id := range ids {
+		assert.Equal(t, i+1, id, "Segment IDs should be contiguous")
+		segment, _ := sm.GetSegment(id)
+		if i < len(ids)-1 {
+			assert.Equal(t, maxEntries, segment.EntryCount())
+		}
+	}
+}
+
+func TestSegmentManager_ReadOnly(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	sm, err := NewSegmentManager(dir, SegmentOptions{})
+	require.NoError(t, err)
+	_, _, err = sm.Append(createEntry("data"))
+	require.NoError(t, err)
+	require.NoError(t, sm.Close())
+
+	readOnly, err := NewSegmentManager(dir, SegmentOptions{ReadOnly: true})
+	require.NoError(t, err)
+	defer readOnly.Close()
+
+	assert.Equal(t, []int{1}, readOnly.GetSegmentIDs(), "no active segment is created")
+	_, err = readOnly.GetActiveSegment()
+	assert.ErrorIs(t, err, ErrNoActiveSegment)
+	entry, err := readOnly.Read(1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "data_value", string(entry.Value))
 }
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -31,7 +34,7 @@ func TestNewSegment(t *testing.T) {
 	defer teardownTest(ctx)
 
 	segmentID := 1
-	seg, err := NewSegment(segmentID, ctx.tempDir)
+	seg, err := NewSegment(segmentID, ctx.tempDir, SegmentOptions{})
 	assert.NoError(t, err)
 	defer seg.Close()
 	assert.Equal(t, segmentID, seg.ID())
@@ -46,12 +49,12 @@ func TestOpenSegment(t *testing.T) {
 
 	segmentID := 2
 
-	initSeg, _ := NewSegment(segmentID, ctx.tempDir)
+	initSeg, _ := NewSegment(segmentID, ctx.tempDir, SegmentOptions{})
 	initSeg.Append(createTestEntry("k", "v"))
 	initSize := initSeg.Size()
 	initSeg.Close()
 
-	seg, err := OpenSegment(segmentID, ctx.tempDir)
+	seg, err := OpenSegment(segmentID, ctx.tempDir, SegmentOptions{})
 	assert.NoError(t, err)
 	defer seg.Close()
 	assert.False(t, seg.IsActive(), "Opened segment should be inactive/read-only")
@@ -63,7 +66,7 @@ func TestSegment_AppendAndRead(t *testing.T) {
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
-	seg, _ := NewSegment(3, ctx.tempDir)
+	seg, _ := NewSegment(3, ctx.tempDir, SegmentOptions{})
 	defer seg.Close()
 
 	entry1 := createTestEntry("key_1", "value_a")
@@ -86,13 +89,243 @@ func TestSegment_AppendAndRead(t *testing.T) {
 	assert.True(t, bytes.Equal(entry2.Value, readEntry2.Value))
 }
 
+func TestSegment_ReadInto(t *testing.T) {
+	// Not parallel: testing.AllocsPerRun refuses to run in parallel tests
+	ctx := setupTest(t)
+	defer teardownTest(ctx)
+
+	seg, _ := NewSegment(3, ctx.tempDir, SegmentOptions{})
+	defer seg.Close()
+
+	long := createTestEntry("long_key", "a much longer value than the others")
+	short := createTestEntry("k", "v")
+	tombstone := short.TombstoneEntry()
+	var offsets []int64
+	for _, e := range []*Entry{long, short, tombstone} {
+		offset, err := seg.Append(e)
+		assert.NoError(t, err)
+		offsets = append(offsets, offset)
+	}
+
+	entry := &Entry{}
+	assert.NoError(t, seg.ReadInto(offsets[0], entry))
+	assert.Equal(t, "long_key", string(entry.Key))
+	assert.Equal(t, long.Value, entry.Value)
+	buf := &entry.buf[0]
+
+	assert.NoError(t, seg.ReadInto(offsets[1], entry))
+	assert.Equal(t, "k", string(entry.Key))
+	assert.Equal(t, "v", string(entry.Value))
+	assert.Equal(t, uint32(1), entry.ValueSize)
+	assert.Same(t, buf, &entry.buf[0], "A large enough buffer is reused")
+
+	assert.NoError(t, seg.ReadInto(offsets[2], entry))
+	assert.True(t, entry.IsTombstone())
+	assert.Nil(t, entry.Value, "Nothing is left over from the previous entry")
+
+	assert.ErrorIs(t, seg.ReadInto(seg.Size(), entry), ErrPositionOutOfRange)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = seg.ReadInto(offsets[0], entry)
+	})
+	assert.Zero(t, allocs, "Reading into a reused entry should not allocate")
+}
+
+func TestSegment_ConcurrentReads(t *testing.T) {
+	t.Parallel()
+	ctx := setupTest(t)
+	defer teardownTest(ctx)
+
+	seg, _ := NewSegment(3, ctx.tempDir, SegmentOptions{})
+	defer seg.Close()
+
+	offsets := make([]int64, 50)
+	for i := range offsets {
+		offset, err := seg.Append(createTestEntry(fmt.Sprintf("key_%d", i), fmt.Sprintf("value_%d_%s", i, bytes.Repeat([]byte("x"), i*10))))
+		assert.NoError(t, err)
+		offsets[i] = offset
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			entry := &Entry{}
+			for n := 0; n < 200; n++ {
+				i := (g*7 + n) % len(offsets)
+				if err := seg.ReadInto(offsets[i], entry); err != nil {
+					t.Error(err)
+					return
+				}
+				if string(entry.Key) != fmt.Sprintf("key_%d", i) {
+					t.Errorf("read %q at offset of key_%d", entry.Key, i)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkSegment_Read(b *testing.B) {
+	seg, err := NewSegment(1, b.TempDir(), SegmentOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer seg.Close()
+
+	offsets := make([]int64, 1000)
+	for i := range offsets {
+		offsets[i], err = seg.Append(createTestEntry(fmt.Sprintf("user:%08d", i), string(bytes.Repeat([]byte("v"), 512))))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("Read", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := seg.Read(offsets[i%len(offsets)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("ReadInto", func(b *testing.B) {
+		entry := &Entry{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := seg.ReadInto(offsets[i%len(offsets)], entry); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkSegment_ReadParallel reads from many goroutines at once, with
+// ReadAt as Segment does and with the seek and read it replaced, which has to
+// hold a lock from the seek until the read is done
+func BenchmarkSegment_ReadParallel(b *testing.B) {
+	seg, err := NewSegment(1, b.TempDir(), SegmentOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer seg.Close()
+
+	offsets := make([]int64, 1000)
+	for i := range offsets {
+		offsets[i], err = seg.Append(createTestEntry(fmt.Sprintf("user:%08d", i), string(bytes.Repeat([]byte("v"), 512))))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("pread", func(b *testing.B) {
+		var next atomic.Int64
+		b.SetParallelism(8)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			entry := &Entry{}
+			for pb.Next() {
+				if err := seg.ReadInto(offsets[next.Add(1)%int64(len(offsets))], entry); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+
+	b.Run("seek", func(b *testing.B) {
+		var mu sync.Mutex
+		var next atomic.Int64
+		b.SetParallelism(8)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			entry := &Entry{}
+			buf := make([]byte, 4096)
+			for pb.Next() {
+				pos := offsets[next.Add(1)%int64(len(offsets))]
+				mu.Lock()
+				_, err := seg.file.Seek(pos, io.SeekStart)
+				if err == nil {
+					_, err = io.ReadFull(seg.file, buf[:legacyHeaderSize])
+				}
+				var size int
+				if err == nil {
+					hdrSize, _, keySize, valueSize := decodeHeaderPrefix(buf)
+					size = hdrSize + int(keySize) + int(valueSize)
+					_, err = io.ReadFull(seg.file, buf[legacyHeaderSize:size])
+				}
+				mu.Unlock()
+				if err == nil {
+					err = decodeEntry(buf[:size], nil, entry)
+				}
+				if err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
+
+func TestSegment_ReadWhileAppending(t *testing.T) {
+	t.Parallel()
+	ctx := setupTest(t)
+	defer teardownTest(ctx)
+
+	seg, _ := NewSegment(3, ctx.tempDir, SegmentOptions{})
+	defer seg.Close()
+
+	first, err := seg.Append(createTestEntry("first", "value"))
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				entry, err := seg.Read(first)
+				if err != nil || string(entry.Key) != "first" {
+					t.Errorf("read %v, %v", entry, err)
+					return
+				}
+			}
+		}()
+	}
+
+	var offsets []int64
+	for i := 0; i < 200; i++ {
+		offset, err := seg.Append(createTestEntry(fmt.Sprintf("key_%d", i), "value"))
+		assert.NoError(t, err)
+		offsets = append(offsets, offset)
+	}
+	close(done)
+	wg.Wait()
+
+	// Reads never moved the offset the appends were written at
+	for i, offset := range offsets {
+		entry, err := seg.Read(offset)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("key_%d", i), string(entry.Key))
+	}
+}
+
 func TestSegment_FullCapacityChecks(t *testing.T) {
 	t.Parallel()
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
 	entry := createTestEntry("a", "b")
-	segSize, _ := NewSegment(4, ctx.tempDir)
+	segSize, _ := NewSegment(4, ctx.tempDir, SegmentOptions{})
 	defer segSize.Close()
 
 	segSize.mu.Lock()
@@ -102,7 +335,7 @@ func TestSegment_FullCapacityChecks(t *testing.T) {
 	_, err := segSize.Append(entry)
 	assert.ErrorIs(t, err, ErrSegmentFull)
 
-	segCount, _ := NewSegment(5, ctx.tempDir)
+	segCount, _ := NewSegment(5, ctx.tempDir, SegmentOptions{})
 	defer segCount.Close()
 
 	segCount.mu.Lock()
@@ -118,7 +351,7 @@ func TestSegment_Close(t *testing.T) {
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
-	seg, _ := NewSegment(6, ctx.tempDir)
+	seg, _ := NewSegment(6, ctx.tempDir, SegmentOptions{})
 
 	assert.NoError(t, seg.Close())
 
@@ -131,18 +364,17 @@ func TestSegment_ReadErrorConditions(t *testing.T) {
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
-	seg, _ := NewSegment(7, ctx.tempDir)
+	seg, _ := NewSegment(7, ctx.tempDir, SegmentOptions{})
 	defer seg.Close()
 
 	entry := createTestEntry("x", "y")
 	seg.Append(entry)
 
 	_, err := seg.Read(seg.Size() + 1)
-	assert.ErrorContains(t, err, "is beyond segment size", "Read should fail beyond EOF")
+	assert.ErrorIs(t, err, ErrPositionOutOfRange, "Read should fail beyond EOF")
 
 	_, err = seg.Read(1)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to read", "Read from invalid position should fail to read header or data")
+	assert.ErrorIs(t, err, ErrTruncatedEntry, "Read from invalid position should fail to read header or data")
 }
 
 func TestSegment_Concurrency(t *testing.T) {
@@ -150,7 +382,7 @@ func TestSegment_Concurrency(t *testing.T) {
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
-	seg, _ := NewSegment(8, ctx.tempDir)
+	seg, _ := NewSegment(8, ctx.tempDir, SegmentOptions{})
 	defer seg.Close()
 
 	var wg sync.WaitGroup
@@ -186,7 +418,7 @@ func TestSegment_AccessorMethods(t *testing.T) {
 	ctx := setupTest(t)
 	defer teardownTest(ctx)
 
-	seg, _ := NewSegment(9, ctx.tempDir)
+	seg, _ := NewSegment(9, ctx.tempDir, SegmentOptions{})
 	defer seg.Close()
 
 	seg.Append(createTestEntry("k", "v"))
@@ -198,6 +430,16 @@ func TestSegment_AccessorMethods(t *testing.T) {
 	assert.Equal(t, int64(DefaultMaxSegmentSize), seg.maxSize, "maxSize must be correctly set and match DefaultMaxSegmentSize")
 	assert.Equal(t, DefaultMaxEntriesPerSegment, seg.maxEntries)
 	assert.Contains(t, seg.Path(), "segment_9.log")
+
+	configured, _ := NewSegment(10, ctx.tempDir, SegmentOptions{MaxSegmentSize: 1024, MaxEntriesPerSegment: 5})
+	defer configured.Close()
+	assert.Equal(t, int64(1024), configured.maxSize, "maxSize must match the configured MaxSegmentSize")
+	assert.Equal(t, 5, configured.maxEntries)
+
+	reopened, _ := OpenSegment(10, ctx.tempDir, SegmentOptions{MaxSegmentSize: 2048})
+	defer reopened.Close()
+	assert.Equal(t, int64(2048), reopened.maxSize)
+	assert.Equal(t, DefaultMaxEntriesPerSegment, reopened.maxEntries, "Unset limits fall back to defaults")
 }
 func TestSegment_ErrorPaths(t *testing.T) {
 	t.Parallel()
@@ -205,37 +447,38 @@ func TestSegment_ErrorPaths(t *testing.T) {
 	defer teardownTest(ctx)
 
 	t.Run("NewSegment fails on invalid path", func(t *testing.T) {
-		_, err := NewSegment(1, "/invalid/path/that/does/not/exist")
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "failed to create segment file")
+		_, err := NewSegment(1, "/invalid/path/that/does/not/exist", SegmentOptions{})
+		assert.ErrorIs(t, err, fs.ErrNotExist)
 	})
 
 	t.Run("NewSegment fails when file.Stat fails", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("directory permissions are not enforced for root")
+		}
 		dir := filepath.Join(ctx.tempDir, "unreadable_dir")
 		err := os.Mkdir(dir, 0000)
 		assert.NoError(t, err)
-		_, err = NewSegment(2, dir)
+		_, err = NewSegment(2, dir, SegmentOptions{})
 		assert.Error(t, err, "Expected Stat to fail due to unreadable directory")
 		os.Chmod(dir, 0755)
 	})
 
 	t.Run("OpenSegment fails for non-existent file", func(t *testing.T) {
-		_, err := OpenSegment(9999, ctx.tempDir)
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "failed to open segment file")
+		_, err := OpenSegment(9999, ctx.tempDir, SegmentOptions{})
+		assert.ErrorIs(t, err, fs.ErrNotExist)
 	})
 
 	t.Run("Append fails when write fails", func(t *testing.T) {
-		seg, _ := NewSegment(10, ctx.tempDir)
+		seg, _ := NewSegment(10, ctx.tempDir, SegmentOptions{})
 		seg.file.Close()
 		entry := createTestEntry("bad", "write")
 
 		_, err := seg.Append(entry)
-		assert.ErrorContains(t, err, "failed to write entry")
+		assert.ErrorIs(t, err, os.ErrClosed)
 	})
 
 	t.Run("Read fails when seek fails", func(t *testing.T) {
-		seg, _ := NewSegment(11, ctx.tempDir)
+		seg, _ := NewSegment(11, ctx.tempDir, SegmentOptions{})
 		defer seg.Close()
 
 		entry := createTestEntry("a", "b")
@@ -243,7 +486,7 @@ func TestSegment_ErrorPaths(t *testing.T) {
 		seg.file.Close()
 
 		_, err := seg.Read(0)
-		assert.ErrorContains(t, err, "failed to seek")
+		assert.ErrorIs(t, err, os.ErrClosed)
 	})
 
 	t.Run("Read fails due to incomplete header", func(t *testing.T) {
@@ -260,7 +503,7 @@ func TestSegment_ErrorPaths(t *testing.T) {
 		}
 
 		_, err := seg.Read(0)
-		assert.ErrorContains(t, err, "failed to read entry header")
+		assert.ErrorIs(t, err, ErrTruncatedEntry)
 		seg.Close()
 	})
 
@@ -282,17 +525,60 @@ func TestSegment_ErrorPaths(t *testing.T) {
 		}
 
 		_, err := seg.Read(0)
-		assert.ErrorContains(t, err, "failed to read entry data")
+		assert.ErrorIs(t, err, ErrTruncatedEntry)
 		seg.Close()
 	})
 
 	t.Run("Close called twice", func(t *testing.T) {
-		seg, _ := NewSegment(12, ctx.tempDir)
+		seg, _ := NewSegment(12, ctx.tempDir, SegmentOptions{})
 		assert.NoError(t, seg.Close())
 		assert.NoError(t, seg.Close(), "Second close should be a no-op")
 	})
 }
 
+func TestSegment_ReadLegacyAndCorrupt(t *testing.T) {
+	t.Parallel()
+	ctx := setupTest(t)
+	defer teardownTest(ctx)
+
+	t.Run("Reads legacy entries", func(t *testing.T) {
+		path := filepath.Join(ctx.tempDir, "segment_20.log")
+		legacy := make([]byte, 12)
+		binary.LittleEndian.PutUint32(legacy[4:8], 3)
+		binary.LittleEndian.PutUint32(legacy[8:12], 3)
+		legacy = append(legacy, []byte("keyval")...)
+		assert.NoError(t, os.WriteFile(path, legacy, 0644))
+
+		seg, err := OpenSegment(20, ctx.tempDir, SegmentOptions{})
+		assert.NoError(t, err)
+		defer seg.Close()
+
+		entry, err := seg.Read(0)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("key"), entry.Key)
+		assert.Equal(t, []byte("val"), entry.Value)
+		assert.Equal(t, int64(entry.Size()), seg.Size())
+	})
+
+	t.Run("Detects corrupted entries", func(t *testing.T) {
+		seg, _ := NewSegment(21, ctx.tempDir, SegmentOptions{})
+		offset, err := seg.Append(createTestEntry("key", "value"))
+		assert.NoError(t, err)
+		seg.Close()
+
+		data, _ := os.ReadFile(seg.Path())
+		data[seg.Size()-1] ^= 0xFF // Last value byte, before the footer
+		assert.NoError(t, os.WriteFile(seg.Path(), data, 0644))
+
+		reopened, err := OpenSegment(21, ctx.tempDir, SegmentOptions{})
+		assert.NoError(t, err)
+		defer reopened.Close()
+
+		_, err = reopened.Read(offset)
+		assert.ErrorIs(t, err, ErrCorruptEntry)
+	})
+}
+
 // Helper to reopen a file safely
 func mustOpenFile(path string) *os.File {
 	f, err := os.OpenFile(path, os.O_RDWR, 0644)
@@ -301,3 +587,38 @@ func mustOpenFile(path string) *os.File {
 	}
 	return f
 }
+
+func TestParseSyncMode(t *testing.T) {
+	t.Parallel()
+	for input, want := range map[string]SyncMode{
+		"":         SyncNone,
+		"none":     SyncNone,
+		"always":   SyncAlways,
+		"interval": SyncInterval,
+		"group":    SyncGroup,
+	} {
+		mode, err := ParseSyncMode(input)
+		assert.NoError(t, err)
+		assert.Equal(t, want, mode)
+	}
+
+	_, err := ParseSyncMode("sometimes")
+	assert.ErrorIs(t, err, ErrInvalidSyncMode)
+}
+
+func TestSegment_Append_SyncAlways(t *testing.T) {
+	t.Parallel()
+	ctx := setupTest(t)
+	defer teardownTest(ctx)
+
+	seg, err := NewSegment(30, ctx.tempDir, SegmentOptions{SyncMode: SyncAlways})
+	assert.NoError(t, err)
+	defer seg.Close()
+
+	offset, err := seg.Append(createTestEntry("durable", "value"))
+	assert.NoError(t, err)
+
+	read, err := seg.Read(offset)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), read.Value)
+}
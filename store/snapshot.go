@@ -0,0 +1,167 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// snapshotFileName is the index snapshot file inside the data directory
+	snapshotFileName = "index.snapshot"
+
+	// snapshotMagic identifies an index snapshot and its format version
+	snapshotMagic = "LKVSNAP1"
+
+	// snapshotSegmentSize is id + size + dead bytes
+	snapshotSegmentSize = 20
+)
+
+// snapshotSegment records how much of a segment an index snapshot covers
+type snapshotSegment struct {
+	ID        int
+	Size      int64
+	DeadBytes int64
+}
+
+// snapshotPath returns the index snapshot path for a data directory
+func snapshotPath(basePath string) string {
+	return filepath.Join(basePath, snapshotFileName)
+}
+
+// writeSnapshot serializes the HashTable together with the size of every
+// segment it covers, so a restart only replays bytes appended after it. The
+// caller must hold s.mu.
+func (s *Store) writeSnapshot() error {
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+
+	ids := s.segmentManager.GetSegmentIDs()
+	count := make([]byte, 4)
+	binary.LittleEndian.PutUint32(count, uint32(len(ids)))
+	buf.Write(count)
+
+	record := make([]byte, snapshotSegmentSize)
+	for _, id := range ids {
+		segment, ok := s.segmentManager.GetSegment(id)
+		if !ok {
+			return fmt.Errorf("segment %d disappeared while snapshotting", id)
+		}
+		binary.LittleEndian.PutUint32(record[0:4], uint32(id))
+		binary.LittleEndian.PutUint64(record[4:12], uint64(segment.Size()))
+		binary.LittleEndian.PutUint64(record[12:20], uint64(s.deadBytes[id]))
+		buf.Write(record)
+	}
+
+	if err := s.hashTable.Serialize(&buf); err != nil {
+		return fmt.Errorf("failed to serialize index: %w", err)
+	}
+
+	crc := make([]byte, 4)
+	binary.LittleEndian.PutUint32(crc, crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(crc)
+
+	path := snapshotPath(s.basePath)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), s.segmentOpts.fileMode()); err != nil {
+		return fmt.Errorf("failed to write index snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename index snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// loadSnapshot loads the index snapshot into the HashTable and returns the
+// offset up to which each covered segment has been applied. It returns an
+// error wrapping os.ErrNotExist when there is no snapshot, or ErrCorruptSnapshot
+// when the snapshot does not match the segments on disk. On error the
+// HashTable and dead byte accounting are left untouched.
+func (s *Store) loadSnapshot() (map[int]int64, error) {
+	data, err := os.ReadFile(snapshotPath(s.basePath))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < len(snapshotMagic)+8 || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, fmt.Errorf("%w: bad header", ErrCorruptSnapshot)
+	}
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptSnapshot)
+	}
+
+	body = body[len(snapshotMagic):]
+	count := int(binary.LittleEndian.Uint32(body[0:4]))
+	body = body[4:]
+	if len(body) < count*snapshotSegmentSize {
+		return nil, fmt.Errorf("%w: truncated segment list", ErrCorruptSnapshot)
+	}
+
+	segments := make([]snapshotSegment, count)
+	for i := range segments {
+		record := body[i*snapshotSegmentSize : (i+1)*snapshotSegmentSize]
+		segments[i] = snapshotSegment{
+			ID:        int(binary.LittleEndian.Uint32(record[0:4])),
+			Size:      int64(binary.LittleEndian.Uint64(record[4:12])),
+			DeadBytes: int64(binary.LittleEndian.Uint64(record[12:20])),
+		}
+	}
+	body = body[count*snapshotSegmentSize:]
+
+	offsets, err := s.checkSnapshotSegments(segments)
+	if err != nil {
+		return nil, err
+	}
+
+	hashTable := NewShardedHashTable(len(s.hashTable.shards))
+	if err := hashTable.load(bytes.NewReader(body)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+
+	s.hashTable = hashTable
+	for _, segment := range segments {
+		if segment.DeadBytes > 0 {
+			s.deadBytes[segment.ID] = segment.DeadBytes
+		}
+	}
+
+	return offsets, nil
+}
+
+// checkSnapshotSegments verifies that the segments on disk only differ from
+// the ones recorded in a snapshot by appends to the newest recorded segment
+// and by newer segments
+func (s *Store) checkSnapshotSegments(segments []snapshotSegment) (map[int]int64, error) {
+	offsets := make(map[int]int64, len(segments))
+	newest := 0
+	for _, recorded := range segments {
+		offsets[recorded.ID] = recorded.Size
+		if recorded.ID > newest {
+			newest = recorded.ID
+		}
+	}
+
+	for _, recorded := range segments {
+		segment, ok := s.segmentManager.GetSegment(recorded.ID)
+		if !ok {
+			return nil, fmt.Errorf("%w: segment %d is missing", ErrCorruptSnapshot, recorded.ID)
+		}
+		if segment.Size() < recorded.Size || (recorded.ID != newest && segment.Size() != recorded.Size) {
+			return nil, fmt.Errorf("%w: segment %d changed size", ErrCorruptSnapshot, recorded.ID)
+		}
+	}
+
+	for _, id := range s.segmentManager.GetSegmentIDs() {
+		if _, ok := offsets[id]; !ok && id < newest {
+			return nil, fmt.Errorf("%w: segment %d is not covered", ErrCorruptSnapshot, id)
+		}
+	}
+
+	return offsets, nil
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/himakhaitan/logkv-store/pkg/config"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStore_LoadsFromSnapshot(t *testing.T) {
+	dataDir := t.TempDir()
+
+	store := openHintStore(t, dataDir)
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("b", "2"))
+	require.NoError(t, store.Delete("a"))
+	require.NoError(t, store.Close())
+
+	assert.FileExists(t, snapshotPath(dataDir))
+
+	reopened := openHintStore(t, dataDir)
+	offsets, err := reopened.loadSnapshot()
+	require.NoError(t, err)
+	segment, ok := reopened.segmentManager.GetSegment(1)
+	require.True(t, ok)
+	assert.Equal(t, map[int]int64{1: segment.Size()}, offsets)
+
+	_, err = reopened.Get("a")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	value, err := reopened.Get("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", value)
+	require.NoError(t, reopened.Close())
+}
+
+func TestStore_Snapshot_ReplaysTail(t *testing.T) {
+	dataDir := t.TempDir()
+
+	store := openHintStore(t, dataDir)
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("b", "2"))
+	store.mu.RLock()
+	require.NoError(t, store.writeSnapshot())
+	store.mu.RUnlock()
+
+	// Writes after the snapshot, then a crash that skips Close
+	require.NoError(t, store.Set("a", "3"))
+	require.NoError(t, store.Delete("b"))
+	require.NoError(t, store.Set("c", "4"))
+	before, err := store.Stats()
+	require.NoError(t, err)
+	require.NoError(t, store.segmentManager.Close())
+
+	reopened := openHintStore(t, dataDir)
+	defer reopened.Close()
+
+	value, err := reopened.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", value)
+	_, err = reopened.Get("b")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	value, err = reopened.Get("c")
+	assert.NoError(t, err)
+	assert.Equal(t, "4", value)
+
+	after, err := reopened.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, before.DeadRatios[1], after.DeadRatios[1], "Dead bytes should survive the snapshot")
+}
+
+func TestStore_CorruptSnapshot_FallsBackToSegments(t *testing.T) {
+	dataDir := t.TempDir()
+
+	store := openHintStore(t, dataDir)
+	require.NoError(t, store.Set("key", "value"))
+	require.NoError(t, store.Close())
+
+	data, err := os.ReadFile(snapshotPath(dataDir))
+	require.NoError(t, err)
+	data[len(data)/2] ^= 0xFF
+	require.NoError(t, os.WriteFile(snapshotPath(dataDir), data, 0644))
+
+	reopened := openHintStore(t, dataDir)
+	value, err := reopened.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+	require.NoError(t, reopened.Close())
+
+	// The bad snapshot is rebuilt on Close
+	rebuilt := openHintStore(t, dataDir)
+	defer rebuilt.Close()
+	_, err = rebuilt.loadSnapshot()
+	assert.NoError(t, err)
+}
+
+func TestStore_StaleSnapshot_FallsBackToSegments(t *testing.T) {
+	dataDir := t.TempDir()
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{DataDir: dataDir, MaxEntriesPerSegment: 2}
+
+	store, err := New(logger, cfg)
+	require.NoError(t, err)
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("b", "2"))
+	require.NoError(t, store.Set("c", "3"))
+	require.NoError(t, store.Close())
+
+	// Rewrite an older segment behind the snapshot's back
+	stripFooter(t, segmentPath(dataDir, 1))
+	file, err := os.OpenFile(segmentPath(dataDir, 1), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.Write(createTestEntry("a", "late").Serialize())
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	require.NoError(t, os.Remove(hintPath(segmentPath(dataDir, 1))))
+
+	reopened, err := New(logger, cfg)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	value, err := reopened.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "late", value)
+}
+
+func TestStore_Compact_RemovesSnapshot(t *testing.T) {
+	dataDir := t.TempDir()
+	logger := zaptest.NewLogger(t)
+	cfg := &config.Config{DataDir: dataDir, MaxEntriesPerSegment: 2}
+
+	store, err := New(logger, cfg)
+	require.NoError(t, err)
+	for _, value := range []string{"1", "2", "3", "4", "5"} {
+		require.NoError(t, store.Set("key", value))
+	}
+	store.mu.RLock()
+	require.NoError(t, store.writeSnapshot())
+	store.mu.RUnlock()
+
+	_, err = store.Compact()
+	require.NoError(t, err)
+	assert.NoFileExists(t, snapshotPath(dataDir))
+	require.NoError(t, store.Close())
+
+	reopened, err := New(logger, cfg)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	value, err := reopened.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "5", value)
+}
+
+func TestStore_PeriodicSnapshot(t *testing.T) {
+	dataDir := t.TempDir()
+
+	store, err := New(zaptest.NewLogger(t), &config.Config{DataDir: dataDir, SnapshotInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.Set("key", "value"))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(snapshotPath(dataDir))
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
@@ -1,11 +1,14 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"math"
 	"os"
-	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,29 +19,96 @@ import (
 
 // Store represents a Bitcask-like append-only log key-value store
 type Store struct {
-	mu             sync.RWMutex
-	basePath       string
-	segmentManager *SegmentManager
-	hashTable      *HashTable
-	logger         *zap.Logger
-	isMerging      atomic.Bool
+	mu                sync.RWMutex
+	basePath          string
+	segmentManager    *SegmentManager
+	hashTable         *HashTable
+	logger            *zap.Logger
+	isMerging         atomic.Bool
+	mergeMu           sync.Mutex     // Guards mergeCall, and claiming or releasing isMerging for a merge
+	mergeCall         *mergeCall     // Merge in progress, if any, for MergeWait callers to wait on
+	segmentOpts       SegmentOptions // Options for segments created by this store
+	deadBytes         map[int]int64  // Reclaimable bytes per segment ID, guarded by mu
+	mergeThreshold    float64        // Dead ratio a segment must exceed to be merged
+	compactionWorkers int            // Segments rewritten in parallel by a merge (0 = default)
+	compactionSize    int64          // Size merged segments are filled up to (0 = one per source)
+	maxKeySize        int            // Maximum key size in bytes (0 = format limit)
+	maxValueSize      int            // Maximum value size in bytes (0 = format limit)
+	cache             *valueCache    // Read cache in front of segments (nil = disabled)
+	missing           *missCache     // Keys recently found missing (nil = disabled)
+	codec             Codec          // Compresses large values (nil = disabled)
+	cipher            *Cipher        // Encrypts values (nil = disabled)
+	compressAbove     int            // Values larger than this are compressed
+	readOnly          bool           // Reject writes and leave files untouched
+	watchers          watchHub       // Subscribers to key changes
+	events            []Event        // Changes awaiting publication, guarded by mu
+	stopCh            chan struct{}  // Closed to stop background goroutines
+	stopOnce          sync.Once      // Guards closing stopCh
+	wg                sync.WaitGroup // Tracks background goroutines
 }
 
 // New creates a new Bitcask-like store
 func New(logger *zap.Logger, config *config.Config) (*Store, error) {
 	dataDir := config.DataDir
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		logger.Warn("Could not create data directory", zap.String("path", dataDir), zap.Error(err))
+	segmentOpts := SegmentOptions{
+		MaxSegmentSize:       config.MaxSegmentSize,
+		MaxEntriesPerSegment: config.MaxEntriesPerSegment,
+		GroupCommitWindow:    config.GroupCommitWindow,
+		GroupCommitBytes:     config.GroupCommitBytes,
+		ReadOnly:             config.ReadOnly,
+		DirMode:              config.DirMode,
+		FileMode:             config.FileMode,
+		Mmap:                 config.MmapReads,
+	}
+	if !config.ReadOnly {
+		if err := os.MkdirAll(dataDir, segmentOpts.dirMode()); err != nil {
+			logger.Warn("Could not create data directory", zap.String("path", dataDir), zap.Error(err))
+		}
+	}
+
+	syncMode, err := ParseSyncMode(config.SyncMode)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := ParseCodec(config.CompressionCodec)
+	if err != nil {
+		return nil, err
+	}
+
+	cipher, err := LoadCipher(config)
+	if err != nil {
+		return nil, err
 	}
 
+	segmentOpts.SyncMode = syncMode
+	segmentOpts.Cipher = cipher
+
 	store := &Store{
-		basePath:  dataDir,
-		hashTable: NewHashTable(),
-		logger:    logger,
+		basePath:          dataDir,
+		hashTable:         NewShardedHashTable(config.IndexShards),
+		logger:            logger,
+		segmentOpts:       segmentOpts,
+		deadBytes:         make(map[int]int64),
+		mergeThreshold:    config.CompactionThreshold,
+		compactionWorkers: config.CompactionConcurrency,
+		compactionSize:    config.CompactionSegmentSize,
+		maxKeySize:        config.MaxKeySize,
+		maxValueSize:      config.MaxValueSize,
+		cache:             newValueCache(config.CacheSize),
+		missing:           newMissCache(config.NegativeCacheSize),
+		codec:             codec,
+		compressAbove:     config.CompressionThreshold,
+		cipher:            cipher,
+		readOnly:          config.ReadOnly,
+		stopCh:            make(chan struct{}),
 	}
 
 	// Initialize segment manager
-	segmentManager, err := NewSegmentManager(dataDir)
+	segmentManager, err := NewSegmentManager(dataDir, store.segmentOpts)
+	if errors.Is(err, ErrDataDirLocked) || errors.Is(err, ErrUnfinishedMerge) {
+		return nil, err
+	}
 	if err != nil {
 		logger.Warn("Could not initialize segment manager", zap.String("path", dataDir), zap.Error(err))
 		// Proceed without segment manager
@@ -46,6 +116,12 @@ func New(logger *zap.Logger, config *config.Config) (*Store, error) {
 	}
 	store.segmentManager = segmentManager
 
+	// Refuse data the configured key does not match, before indexing it
+	if err := store.checkEncryption(); err != nil {
+		segmentManager.Close()
+		return nil, err
+	}
+
 	// Load existing data from segments
 	if err := store.loadFromSegments(); err != nil {
 		logger.Error("Could not load data from segments", zap.String("path", dataDir), zap.Error(err))
@@ -53,28 +129,210 @@ func New(logger *zap.Logger, config *config.Config) (*Store, error) {
 		return nil, err
 	}
 
-	// Periodically trigger background merges at MergeInterval.
+	// Nothing below writes to disk, so a read-only store is ready
+	if store.readOnly {
+		return store, nil
+	}
+
+	// Periodically trigger background merges at MergeInterval (disabled when unset).
+	if config.MergeInterval > 0 {
+		store.wg.Add(1)
+		go store.runMergeLoop(config.MergeInterval)
+	}
+
+	// Periodically tombstone expired keys (otherwise they stay indexed
+	// until read).
+	if config.ExpirySweepInterval > 0 {
+		store.wg.Add(1)
+		go store.runExpiryLoop(config.ExpirySweepInterval)
+	}
+
+	// Periodically snapshot the index (otherwise only on Close).
+	if config.SnapshotInterval > 0 {
+		store.wg.Add(1)
+		go store.runSnapshotLoop(config.SnapshotInterval)
+	}
+
+	// Periodically fsync segments when writes are not synced individually.
+	if syncMode == SyncInterval {
+		interval := config.SyncInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		store.wg.Add(1)
+		go store.runSyncLoop(interval)
+	}
+
+	return store, nil
+}
+
+// runMergeLoop triggers a merge every interval until background work is
+// stopped, which also cancels a merge in progress
+func (s *Store) runMergeLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
-		ticker := time.NewTicker(config.MergeInterval)
-		for {
-			<-ticker.C
-			logger.Info("Starting compaction...")
-			if err := store.Merge(); err != nil {
-				logger.Error("Compaction failed", zap.Error(err))
+		select {
+		case <-s.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			if !s.shouldMerge() {
+				s.logger.Debug("Skipping compaction: no segment exceeds the dead ratio threshold")
+				continue
+			}
+			s.logger.Info("Starting compaction...")
+			if _, err := s.CompactCtx(ctx); errors.Is(err, context.Canceled) {
+				s.logger.Info("Compaction abandoned as the store stops")
+			} else if err != nil {
+				s.logger.Error("Compaction failed", zap.Error(err))
 			} else {
-				logger.Info("Compaction was successful")
+				s.logger.Info("Compaction was successful")
 			}
 		}
-	}()
+	}
+}
 
-	return store, nil
+// runSyncLoop fsyncs all segments every interval until the store is closed
+func (s *Store) runSyncLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			err := s.segmentManager.FlushAll()
+			s.mu.RUnlock()
+			if err != nil {
+				s.logger.Error("Periodic sync failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// runExpiryLoop sweeps expired keys every interval until the store is closed
+func (s *Store) runExpiryLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			swept, err := s.sweepExpired()
+			if err != nil {
+				s.logger.Error("Expiry sweep failed", zap.Error(err))
+			} else if swept > 0 {
+				s.logger.Debug("Swept expired keys", zap.Int("count", swept))
+			}
+		}
+	}
+}
+
+// expirySweepBatch is how many expired keys a sweep tombstones per
+// acquisition of the write lock, so that writers are not held up for long
+const expirySweepBatch = 1000
+
+// sweepExpired writes a tombstone for every key that has expired and drops
+// it from the index, so the memory is freed and compaction counts the space
+// as reclaimable. No watch event is published, since the key stopped being
+// visible when it expired. It stops early once the store is closing, and
+// returns how many keys it removed.
+func (s *Store) sweepExpired() (int, error) {
+	now := time.Now()
+	s.mu.RLock()
+	keys := s.hashTable.ListExpired(now)
+	s.mu.RUnlock()
+
+	swept := 0
+	for len(keys) > 0 {
+		select {
+		case <-s.stopCh:
+			return swept, nil
+		default:
+		}
+
+		batch := keys[:min(len(keys), expirySweepBatch)]
+		keys = keys[len(batch):]
+		err := s.writeLocked(func() (*Commit, error) {
+			for _, key := range batch {
+				// The key may have been rewritten since it was listed
+				entry, exists := s.hashTable.Get(key)
+				if !exists || !entry.IsExpired(now) {
+					continue
+				}
+				// Not waited for: losing the tombstone in a crash is harmless,
+				// since the entry it covers has expired either way
+				if _, err := s.tombstone(key); err != nil {
+					return nil, err
+				}
+				swept++
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return swept, err
+		}
+	}
+	return swept, nil
+}
+
+// runSnapshotLoop writes an index snapshot every interval until the store is closed
+func (s *Store) runSnapshotLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			err := s.writeSnapshot()
+			s.mu.RUnlock()
+			if err != nil {
+				s.logger.Error("Periodic index snapshot failed", zap.Error(err))
+			}
+		}
+	}
 }
 
 // loadFromSegments loads all existing data from segment files into the HashTable
 func (s *Store) loadFromSegments() error {
 	if s.segmentManager == nil {
 		s.logger.Error("Segment manager is not initialized; cannot load segments")
-		return fmt.Errorf("segment manager is not initialized")
+		return ErrStoreNotInitialized
+	}
+
+	offsets, err := s.loadSnapshot()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		// Drop the bad snapshot and rebuild the index from the segments
+		s.logger.Warn("Ignoring unusable index snapshot", zap.Error(err))
+		if !s.readOnly {
+			os.Remove(snapshotPath(s.basePath))
+		}
 	}
 
 	segmentIDs := s.segmentManager.GetSegmentIDs()
@@ -85,6 +343,15 @@ func (s *Store) loadFromSegments() error {
 			continue
 		}
 
+		// Replay only what was appended after the snapshot, if it covers the segment
+		if offset, ok := offsets[segmentID]; ok {
+			if err := s.scanSegmentFrom(segment, offset); err != nil {
+				s.logger.Error("Failed to load segment", zap.Int("segmentID", segmentID), zap.Error(err))
+				return fmt.Errorf("failed to load segment %d: %w", segmentID, err)
+			}
+			continue
+		}
+
 		// Read all entries from the segment
 		if err := s.loadSegmentIntoKeyDir(segment); err != nil {
 			s.logger.Error("Failed to load segment", zap.Int("segmentID", segmentID), zap.Error(err))
@@ -95,27 +362,95 @@ func (s *Store) loadFromSegments() error {
 	return nil
 }
 
-// loadSegmentIntoKeyDir loads all entries from a segment into the HashTable
+// loadSegmentIntoKeyDir loads all entries from a segment into the HashTable,
+// preferring the segment's hint file and falling back to a full scan
 func (s *Store) loadSegmentIntoKeyDir(segment *Segment) error {
-	// For simplicity, we'll read from the beginning of the file
-	// In a production system, you might want to maintain a more sophisticated index
+	if segment.footer == footerMismatch {
+		s.logger.Warn("Segment data does not match its footer", zap.Int("segmentID", segment.ID()))
+	}
+
+	records, err := readHintFile(segment)
+	if err == nil {
+		s.loadHintRecords(segment.ID(), records)
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		// Drop the bad hint so it is rebuilt on the next Close
+		s.logger.Warn("Ignoring unusable hint file", zap.Int("segmentID", segment.ID()), zap.Error(err))
+		if !s.readOnly {
+			os.Remove(hintPath(segment.Path()))
+		}
+	}
+
+	return s.scanSegmentIntoKeyDir(segment)
+}
+
+// loadHintRecords applies hint records for a segment to the HashTable
+func (s *Store) loadHintRecords(segmentID int, records []hintRecord) {
+	now := time.Now()
+	for _, record := range records {
+		key := string(record.Key)
+		expired := record.ExpiresAt != 0 && uint32(now.Unix()) >= record.ExpiresAt
+		s.markSuperseded(key)
+		if record.ValueSize == 0 || expired {
+			// The tombstone or expired entry itself is reclaimable
+			s.markDead(segmentID, entryDiskSize(key, record.ValueSize, record.ExpiresAt))
+			s.hashTable.Delete(key)
+			continue
+		}
+		s.hashTable.PutWithExpiry(key, segmentID, record.ValuePos, record.ValueSize, record.Timestamp, record.ExpiresAt)
+	}
+}
+
+// scanSegmentIntoKeyDir reads every entry of a segment into the HashTable
+func (s *Store) scanSegmentIntoKeyDir(segment *Segment) error {
+	return s.scanSegmentFrom(segment, 0)
+}
 
-	pos := int64(0)
+// scanSegmentFrom reads the entries of a segment starting at pos into the HashTable
+func (s *Store) scanSegmentFrom(segment *Segment, pos int64) error {
 	segmentSize := segment.Size()
+	now := time.Now()
 
+	entry := &Entry{}
 	for pos < segmentSize {
-		entry, err := segment.Read(pos)
+		err := segment.ReadInto(pos, entry)
+		if errors.Is(err, ErrTruncatedEntry) {
+			// A write was cut short by a crash; drop the partial tail
+			s.logger.Warn("Truncating partially written entry",
+				zap.Int("segmentID", segment.ID()), zap.Int64("offset", pos), zap.Int64("size", segmentSize))
+			if s.readOnly {
+				break // Ignore the tail but leave the file as it is
+			}
+			if err := segment.Truncate(pos); err != nil {
+				return err
+			}
+			break
+		}
+		if errors.Is(err, ErrCorruptEntry) || errors.Is(err, ErrInvalidEntry) {
+			// Entries are only found by walking from the previous one, so
+			// nothing past a bad entry can be trusted to start on a boundary.
+			// The file is left as it is for inspection; damaged segments are
+			// never resumed for appending.
+			s.logger.Warn("Ignoring segment data after invalid entry",
+				zap.Int("segmentID", segment.ID()), zap.Int64("offset", pos),
+				zap.Int64("ignoredBytes", segmentSize-pos), zap.Error(err))
+			break
+		}
 		if err != nil {
 			return fmt.Errorf("failed to read entry at position %d: %w", pos, err)
 		}
 
 		key := string(entry.Key)
 
-		// Only add to HashTable if it's not a tombstone
-		if !entry.IsTombstone() {
-			s.hashTable.Put(key, segment.ID(), pos, entry.ValueSize, entry.Timestamp)
+		s.markSuperseded(key)
+
+		// Only add to HashTable if it's neither a tombstone nor expired
+		if !entry.IsTombstone() && !entry.IsExpired(now) {
+			s.hashTable.PutWithExpiry(key, segment.ID(), pos, entry.ValueSize, entry.Timestamp, entry.ExpiresAt)
 		} else {
-			// Remove from HashTable if it's a tombstone
+			// Remove from HashTable if it's a tombstone or expired
+			s.markDead(segment.ID(), int64(entry.Size()))
 			s.hashTable.Delete(key)
 		}
 
@@ -128,244 +463,1572 @@ func (s *Store) loadSegmentIntoKeyDir(segment *Segment) error {
 
 // Get retrieves a value by key
 func (s *Store) Get(key string) (string, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+// GetCtx is Get, returning ctx's error instead if ctx is already done
+func (s *Store) GetCtx(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	value, err := s.GetBytes([]byte(key))
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// GetBytes retrieves a raw value by raw key
+func (s *Store) GetBytes(key []byte) ([]byte, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	entry, exists := s.hashTable.Get(key)
+	return s.get(key)
+}
+
+// get reads the current value of a key; the caller must hold s.mu
+func (s *Store) get(key []byte) ([]byte, error) {
+	value, _, _, err := s.lookup(string(key))
+	return value, err
+}
+
+// lookup reads a value, its content type and its index entry; the caller must
+// hold s.mu
+func (s *Store) lookup(key string) ([]byte, string, *HashTableEntry, error) {
+	entry, exists := s.find(key)
 	if !exists {
-		return "", ErrKeyNotFound
+		return nil, "", nil, ErrKeyNotFound
+	}
+
+	if value, contentType, ok := s.cache.get(key, entry.FileID, entry.ValuePos); ok {
+		return value, contentType, entry, nil
 	}
 
 	// Read the entry from the segment
 	logEntry, err := s.segmentManager.Read(entry.FileID, entry.ValuePos)
 	if err != nil {
-		return "", fmt.Errorf("failed to read entry: %w", err)
+		return nil, "", nil, fmt.Errorf("failed to read entry: %w", err)
 	}
+	s.cache.put(key, logEntry.Value, logEntry.ContentType, entry.FileID, entry.ValuePos)
 
-	return string(logEntry.Value), nil
+	return logEntry.Value, logEntry.ContentType, entry, nil
 }
 
-// Set stores a key-value pair
-func (s *Store) Set(key, value string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// EntryMeta describes where and when a value was written
+type EntryMeta struct {
+	Timestamp   uint32 // Unix timestamp of the write
+	ValueSize   uint32 // Size of the value in bytes, as Get returns it
+	FileID      int    // ID of the segment holding the value
+	ValuePos    int64  // Offset of the entry in its segment
+	ContentType string // Media type the value was stored with (empty = none)
+}
 
-	log.Println("Setting key:", key, "Value:", value)
+// GetWithMeta retrieves a value by key along with its metadata
+func (s *Store) GetWithMeta(key string) (string, EntryMeta, error) {
+	return s.GetWithMetaCtx(context.Background(), key)
+}
 
-	if s.segmentManager == nil {
-		return fmt.Errorf("store not properly initialized")
+// GetWithMetaCtx is GetWithMeta, returning ctx's error instead if ctx is
+// already done
+func (s *Store) GetWithMetaCtx(ctx context.Context, key string) (string, EntryMeta, error) {
+	if err := ctx.Err(); err != nil {
+		return "", EntryMeta{}, err
 	}
 
-	// Create entry
-	entry := &Entry{
-		Timestamp: uint32(time.Now().Unix()),
-		KeySize:   uint32(len(key)),
-		ValueSize: uint32(len(value)),
-		Key:       []byte(key),
-		Value:     []byte(value),
-	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	// Append to active segment
-	segmentID, offset, err := s.segmentManager.Append(entry)
+	value, contentType, entry, err := s.lookup(key)
 	if err != nil {
-		return fmt.Errorf("failed to append entry: %w", err)
+		return "", EntryMeta{}, err
 	}
 
-	// Update HashTable
-	s.hashTable.Put(key, segmentID, offset, entry.ValueSize, entry.Timestamp)
-
-	return nil
+	return string(value), EntryMeta{
+		Timestamp:   entry.Timestamp,
+		ValueSize:   uint32(len(value)),
+		FileID:      entry.FileID,
+		ValuePos:    entry.ValuePos,
+		ContentType: contentType,
+	}, nil
 }
 
-// Delete removes a key (creates a tombstone entry)
-func (s *Store) Delete(key string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Exists reports whether a key is present without reading its value from disk
+func (s *Store) Exists(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	if s.segmentManager == nil {
-		return fmt.Errorf("store not properly initialized")
+	_, exists := s.find(key)
+	return exists, nil
+}
+
+// find returns the index entry of a live key, consulting and filling the
+// negative cache. Misses are remembered under s.mu, which every write holds
+// exclusively while it forgets the key, so a miss cannot be remembered after
+// the key is written. The caller must hold s.mu.
+func (s *Store) find(key string) (*HashTableEntry, bool) {
+	if s.missing.has(key) {
+		return nil, false
 	}
+	entry, exists := s.hashTable.Get(key)
+	if !exists || entry.IsExpired(time.Now()) {
+		s.missing.add(key)
+		return nil, false
+	}
+	return entry, true
+}
 
-	// Check if key exists
-	_, exists := s.hashTable.Get(key)
-	if !exists {
-		return ErrKeyNotFound
+// MultiGet retrieves the values of many keys under a single read lock.
+// Keys that are not found are omitted from the values and returned as missing.
+func (s *Store) MultiGet(keys []string) (map[string]string, []string, error) {
+	return s.MultiGetCtx(context.Background(), keys)
+}
+
+// MultiGetCtx is MultiGet, checking ctx before each segment read and
+// returning its error once it is done
+func (s *Store) MultiGetCtx(ctx context.Context, keys []string) (map[string]string, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	// Create tombstone entry
-	tombstoneEntry := &Entry{
-		Timestamp: uint32(time.Now().Unix()),
-		KeySize:   uint32(len(key)),
-		ValueSize: 0, // Zero value size indicates tombstone
-		Key:       []byte(key),
-		Value:     nil,
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type lookup struct {
+		key   string
+		entry *HashTableEntry
 	}
 
-	// Append tombstone to active segment
-	_, _, err := s.segmentManager.Append(tombstoneEntry)
-	if err != nil {
-		return fmt.Errorf("failed to append tombstone: %w", err)
+	now := time.Now()
+	values := make(map[string]string, len(keys))
+	missing := make([]string, 0)
+	lookups := make([]lookup, 0, len(keys))
+	for _, key := range keys {
+		entry, exists := s.hashTable.Get(key)
+		if !exists || entry.IsExpired(now) {
+			missing = append(missing, key)
+			continue
+		}
+		lookups = append(lookups, lookup{key: key, entry: entry})
 	}
 
-	// Remove from HashTable
-	s.hashTable.Delete(key)
+	// Read segments in file order to keep disk access sequential
+	sort.Slice(lookups, func(i, j int) bool {
+		if lookups[i].entry.FileID != lookups[j].entry.FileID {
+			return lookups[i].entry.FileID < lookups[j].entry.FileID
+		}
+		return lookups[i].entry.ValuePos < lookups[j].entry.ValuePos
+	})
 
-	return nil
-}
+	for _, l := range lookups {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		logEntry, err := s.segmentManager.Read(l.entry.FileID, l.entry.ValuePos)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read entry for key %q: %w", l.key, err)
+		}
+		values[l.key] = string(logEntry.Value)
+	}
 
-// List returns all keys
-func (s *Store) List() ([]string, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return values, missing, nil
+}
 
-	return s.hashTable.List(), nil
+// Set stores a key-value pair
+func (s *Store) Set(key, value string) error {
+	return s.SetBytes([]byte(key), []byte(value))
 }
 
-type Stats struct {
-	TotalKeys int
-	TotalSize int64
-	Segments  int
+// SetBytes stores a raw key-value pair
+func (s *Store) SetBytes(key, value []byte) error {
+	return s.set(key, value, "", 0)
 }
 
-// Stats returns database statistics
-func (s *Store) Stats() (Stats, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// SetWithContentType stores a key-value pair along with the media type of
+// the value, which GetWithMeta returns in EntryMeta.ContentType
+func (s *Store) SetWithContentType(key, value, contentType string) error {
+	return s.set([]byte(key), []byte(value), contentType, 0)
+}
 
-	totalKeys, totalSize := s.hashTable.Stats()
+// SetWithTTL stores a key-value pair that expires after ttl
+func (s *Store) SetWithTTL(key, value string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
 
-	// Count segments
-	segmentCount := 0
-	if s.segmentManager != nil {
-		segmentCount = len(s.segmentManager.GetSegmentIDs())
+	// Round the expiry up to the next second so a key never expires early
+	expiry := time.Now().Add(ttl)
+	expiresAt := expiry.Unix()
+	if expiry.Nanosecond() > 0 {
+		expiresAt++
 	}
 
-	return Stats{
-		TotalKeys: totalKeys,
-		TotalSize: totalSize,
-		Segments:  segmentCount,
-	}, nil
+	return s.set([]byte(key), []byte(value), "", uint32(expiresAt))
 }
 
-// Close closes the store and all its resources
-func (s *Store) Close() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// set appends a key-value pair with an optional content type, expiring at
+// expiresAt (0 = never)
+func (s *Store) set(key, value []byte, contentType string, expiresAt uint32) error {
+	return s.writeLocked(func() (*Commit, error) {
+		s.logger.Debug("Setting key", zap.ByteString("key", key))
+		return s.put(key, value, contentType, expiresAt)
+	})
+}
 
-	if s.segmentManager != nil {
-		return s.segmentManager.Close()
+// writeLocked runs fn under the write lock, then releases the lock before
+// waiting for fn's writes to be committed, so writers in SyncGroup mode can
+// share an fsync
+func (s *Store) writeLocked(fn func() (*Commit, error)) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	commit, err := fn()
+	events := s.events
+	s.events = nil
+	if len(events) == 0 {
+		s.mu.Unlock()
+	} else {
+		// Take the hub lock before releasing s.mu so that concurrent writers
+		// publish in the order their changes were applied
+		s.watchers.mu.Lock()
+		s.mu.Unlock()
+		s.watchers.publish(events)
+		s.watchers.mu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	if err := commit.Wait(); err != nil {
+		return fmt.Errorf("failed to sync entry: %w", err)
 	}
-
 	return nil
 }
 
-// Merge compacts inactive segments by copying only live (non-tombstone) records.
-func (s *Store) Merge() error {
-	if s.isMerging.Load() {
-		return ErrMergeInProgress
+// validate checks a key-value pair against the configured and on-disk size limits
+func (s *Store) validate(key, value []byte) error {
+	if len(key) == 0 {
+		return ErrEmptyKey
 	}
+	if len(key) > int(keySizeMask) || (s.maxKeySize > 0 && len(key) > s.maxKeySize) {
+		return fmt.Errorf("%w: %d bytes", ErrKeyTooLarge, len(key))
+	}
+	if uint64(len(value)) > math.MaxUint32 || (s.maxValueSize > 0 && len(value) > s.maxValueSize) {
+		return fmt.Errorf("%w: %d bytes", ErrValueTooLarge, len(value))
+	}
+	return nil
+}
 
-	s.isMerging.Store(true)
-	defer s.isMerging.Store(false)
-
-	sm := s.segmentManager
-	ids := sm.GetInactiveSegmentIDs()
-	if len(ids) == 0 {
-		s.logger.Info("No inactive segments to compact")
+// codecFor returns the codec to compress value with, or nil to store it as is
+func (s *Store) codecFor(value []byte) Codec {
+	if s.codec == nil || len(value) <= s.compressAbove {
 		return nil
 	}
+	return s.codec
+}
 
-	s.logger.Info("Starting compaction", zap.Ints("segments", ids))
+// put appends a key-value pair and indexes it; the caller must hold s.mu for
+// writing, and wait for the returned Commit once it has released s.mu
+func (s *Store) put(key, value []byte, contentType string, expiresAt uint32) (*Commit, error) {
+	if s.segmentManager == nil {
+		return nil, ErrStoreNotInitialized
+	}
+	if err := s.validate(key, value); err != nil {
+		return nil, err
+	}
+	if len(contentType) > maxContentTypeSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrContentTypeTooLarge, len(contentType))
+	}
 
-	tmpDir := filepath.Join(s.basePath, "merge_tmp")
-	_ = os.RemoveAll(tmpDir)
-	if err := os.MkdirAll(tmpDir, 0755); err != nil {
-		return fmt.Errorf("create tmp dir: %w", err)
+	// Create entry
+	entry := &Entry{
+		Timestamp:   uint32(time.Now().Unix()),
+		KeySize:     uint32(len(key)),
+		ValueSize:   uint32(len(value)),
+		ExpiresAt:   expiresAt,
+		Key:         key,
+		Value:       value,
+		ContentType: contentType,
+		Codec:       s.codecFor(value),
+		Cipher:      s.cipher,
 	}
 
-	mergeSM, err := NewSegmentManager(tmpDir)
+	// Append to active segment
+	segmentID, offset, commit, err := s.segmentManager.AppendAsync(entry)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to append entry: %w", err)
 	}
 
-	mergeHT := NewHashTable()
-	snap := s.hashTable.Clone() // snap for checking updated keys while compacting
+	// Update HashTable
+	s.cache.remove(string(key))
+	s.missing.remove(string(key))
+	s.markSuperseded(string(key))
+	s.hashTable.PutWithExpiry(string(key), segmentID, offset, entry.ValueSize, entry.Timestamp, entry.ExpiresAt)
+	s.record(string(key), EventSet)
 
-	for _, id := range ids {
-		seg, ok := s.segmentManager.GetSegment(id)
-		if !ok {
-			continue
-		}
+	return commit, nil
+}
 
-		var pos int64
-		size := seg.Size()
-		for pos < size {
-			se, err := seg.Read(pos)
-			if err != nil {
-				return fmt.Errorf("compaction failed seg=%d off=%d: %w", id, pos, err)
-			}
+// entryDiskSize returns the on-disk size of an entry with the given key, value
+// size and expiry
+func entryDiskSize(key string, valueSize, expiresAt uint32) int64 {
+	size := headerSize + len(key) + int(valueSize)
+	if expiresAt != 0 {
+		size += 4
+	}
+	return int64(size)
+}
 
-			oldOff := pos
-			pos += int64(se.Size()) // advance regardless of branch
+// markDead records size reclaimable bytes in a segment
+func (s *Store) markDead(segmentID int, size int64) {
+	if s.deadBytes == nil {
+		s.deadBytes = make(map[int]int64)
+	}
+	s.deadBytes[segmentID] += size
+}
 
-			if se.IsTombstone() {
-				continue
-			}
+// markSuperseded marks the currently indexed entry for key, if any, as dead.
+// Call it before the index entry is replaced or removed.
+func (s *Store) markSuperseded(key string) {
+	if old, ok := s.hashTable.Get(key); ok {
+		s.markDead(old.FileID, entryDiskSize(key, old.StoredSize, old.ExpiresAt))
+	}
+}
 
-			key := string(se.Key) // redundant alloc (could be optimize)
-			he, ok := snap.Get(key)
-			if !ok || he.FileID != id || he.ValuePos != oldOff {
-				continue
-			}
+// deadRatio returns the fraction of a segment's bytes that compaction would
+// reclaim; the caller must hold s.mu
+func (s *Store) deadRatio(segment *Segment) float64 {
+	size := segment.Size()
+	if size == 0 {
+		return 0
+	}
+	return math.Min(float64(s.deadBytes[segment.ID()])/float64(size), 1)
+}
 
-			newId, newOff, err := mergeSM.Append(se)
-			if err != nil {
-				return fmt.Errorf("failed to append entry: %w", err)
-			}
+// mergeCandidates returns the inactive segments whose dead ratio exceeds the
+// merge threshold. With no threshold every inactive segment qualifies.
+func (s *Store) mergeCandidates() []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-			mergeHT.Put(key, newId, newOff, uint32(se.Size()), se.Timestamp)
-		}
+	ids := s.segmentManager.GetInactiveSegmentIDs()
+	if s.mergeThreshold <= 0 {
+		return ids
 	}
 
-	// Ensure merged files are durable before swapping.
-	mergeSM.FlushAll()
-
-	// Short stop-the-world: move files, rebuild segment manager, commit index.
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	// Remove old segments
+	candidates := make([]int, 0, len(ids))
 	for _, id := range ids {
-		if err := s.segmentManager.DeleteSegment(id); err != nil {
-			return fmt.Errorf("delete seg %d: %w", id, err)
+		segment, ok := s.segmentManager.GetSegment(id)
+		if ok && s.deadRatio(segment) > s.mergeThreshold {
+			candidates = append(candidates, id)
+		}
+	}
+	return candidates
+}
+
+// checkFooters checks the data of segments about to be merged against their
+// footers, warning about any changed since they were sealed. The merge still
+// rewrites their live entries, which are found through the index.
+func (s *Store) checkFooters(ids []int) {
+	for _, id := range ids {
+		segment, ok := s.segmentManager.GetSegment(id)
+		if !ok {
+			continue
+		}
+		intact, err := segment.verify()
+		if err != nil {
+			s.logger.Warn("Could not check segment against its footer", zap.Int("segmentID", id), zap.Error(err))
+		} else if !intact {
+			s.logger.Warn("Segment data does not match its footer", zap.Int("segmentID", id))
+		}
+	}
+}
+
+// shouldMerge reports whether any segment is worth compacting
+func (s *Store) shouldMerge() bool {
+	if s.segmentManager == nil {
+		return false
+	}
+	return len(s.mergeCandidates()) > 0
+}
+
+// KeyValue is a single key-value pair in a batch write
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// SetBatch stores multiple key-value pairs in order under a single lock.
+// If an append fails, a *BatchError reports how many pairs were written.
+func (s *Store) SetBatch(pairs []KeyValue) error {
+	var commits []*Commit
+	err := s.writeLocked(func() (*Commit, error) {
+		var err error
+		commits, err = s.setBatch(pairs)
+		return nil, err
+	})
+
+	// Wait for what was written even if the batch stopped part way
+	for _, commit := range commits {
+		if syncErr := commit.Wait(); syncErr != nil && err == nil {
+			err = fmt.Errorf("failed to sync entry: %w", syncErr)
+		}
+	}
+	return err
+}
+
+// setBatch appends and indexes pairs and returns the commits to wait for;
+// the caller must hold s.mu for writing
+func (s *Store) setBatch(pairs []KeyValue) ([]*Commit, error) {
+	if s.segmentManager == nil {
+		return nil, ErrStoreNotInitialized
+	}
+
+	// Reject the whole batch up front rather than failing part way through
+	for _, pair := range pairs {
+		if err := s.validate([]byte(pair.Key), []byte(pair.Value)); err != nil {
+			return nil, fmt.Errorf("key %q: %w", pair.Key, err)
+		}
+	}
+
+	type location struct {
+		segmentID int
+		offset    int64
+		entry     *Entry
+	}
+
+	// Append all entries first, then update the HashTable in one pass
+	written := make([]location, 0, len(pairs))
+	var commits []*Commit
+	var appendErr error
+	timestamp := uint32(time.Now().Unix())
+	for _, pair := range pairs {
+		entry := &Entry{
+			Timestamp: timestamp,
+			KeySize:   uint32(len(pair.Key)),
+			ValueSize: uint32(len(pair.Value)),
+			Key:       []byte(pair.Key),
+			Value:     []byte(pair.Value),
+			Codec:     s.codecFor([]byte(pair.Value)),
+			Cipher:    s.cipher,
+		}
+
+		segmentID, offset, commit, err := s.segmentManager.AppendAsync(entry)
+		if err != nil {
+			appendErr = err
+			break
+		}
+		written = append(written, location{segmentID: segmentID, offset: offset, entry: entry})
+		if commit != nil && (len(commits) == 0 || commits[len(commits)-1] != commit) {
+			commits = append(commits, commit)
+		}
+	}
+
+	for _, loc := range written {
+		s.cache.remove(string(loc.entry.Key))
+		s.missing.remove(string(loc.entry.Key))
+		s.markSuperseded(string(loc.entry.Key))
+		s.hashTable.Put(string(loc.entry.Key), loc.segmentID, loc.offset, loc.entry.ValueSize, loc.entry.Timestamp)
+		s.record(string(loc.entry.Key), EventSet)
+	}
+
+	if appendErr != nil {
+		return commits, &BatchError{Written: len(written), Err: fmt.Errorf("failed to append entry: %w", appendErr)}
+	}
+
+	return commits, nil
+}
+
+// TTL returns the remaining time to live of a key.
+// A zero duration means the key never expires.
+func (s *Store) TTL(key string) (time.Duration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	entry, exists := s.hashTable.Get(key)
+	if !exists || entry.IsExpired(now) {
+		return 0, ErrKeyNotFound
+	}
+
+	if entry.ExpiresAt == 0 {
+		return 0, nil
+	}
+	return time.Unix(int64(entry.ExpiresAt), 0).Sub(now), nil
+}
+
+// Delete removes a key (creates a tombstone entry)
+func (s *Store) Delete(key string) error {
+	return s.writeLocked(func() (*Commit, error) {
+		return s.remove(key)
+	})
+}
+
+// DeleteIfExists deletes key and reports whether it existed. Unlike Delete,
+// a missing key is not an error.
+func (s *Store) DeleteIfExists(key string) (bool, error) {
+	existed := true
+	err := s.writeLocked(func() (*Commit, error) {
+		commit, err := s.remove(key)
+		if errors.Is(err, ErrKeyNotFound) {
+			existed = false
+			return nil, nil
+		}
+		return commit, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return existed, nil
+}
+
+// DeleteBatch deletes every key that exists, under a single acquisition of
+// the write lock, and reports how many did. Missing keys are skipped rather
+// than failing the batch, and a key listed twice is only deleted once. If a
+// tombstone cannot be written the batch stops with a *BatchError, and the
+// keys deleted before it stay deleted.
+func (s *Store) DeleteBatch(keys []string) (int, error) {
+	deleted := 0
+	var commits []*Commit
+	err := s.writeLocked(func() (*Commit, error) {
+		for _, key := range keys {
+			commit, err := s.remove(key)
+			if errors.Is(err, ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, &BatchError{Written: deleted, Err: err}
+			}
+			deleted++
+			if commit != nil && (len(commits) == 0 || commits[len(commits)-1] != commit) {
+				commits = append(commits, commit)
+			}
+		}
+		return nil, nil
+	})
+
+	// Wait for what was written even if the batch stopped part way
+	for _, commit := range commits {
+		if syncErr := commit.Wait(); syncErr != nil && err == nil {
+			err = fmt.Errorf("failed to sync entry: %w", syncErr)
+		}
+	}
+	return deleted, err
+}
+
+// remove appends a tombstone for a key; the caller must hold s.mu for writing,
+// and wait for the returned Commit once it has released s.mu
+func (s *Store) remove(key string) (*Commit, error) {
+	if s.segmentManager == nil {
+		return nil, ErrStoreNotInitialized
+	}
+
+	// Check if key exists
+	entry, exists := s.hashTable.Get(key)
+	if !exists || entry.IsExpired(time.Now()) {
+		return nil, ErrKeyNotFound
+	}
+
+	commit, err := s.tombstone(key)
+	if err != nil {
+		return nil, err
+	}
+	s.record(key, EventDelete)
+	return commit, nil
+}
+
+// tombstone appends a tombstone for an indexed key and drops it from the
+// index; the caller must hold s.mu for writing
+func (s *Store) tombstone(key string) (*Commit, error) {
+	if s.segmentManager == nil {
+		return nil, ErrStoreNotInitialized
+	}
+
+	// Create tombstone entry
+	tombstoneEntry := &Entry{
+		Timestamp: uint32(time.Now().Unix()),
+		KeySize:   uint32(len(key)),
+		ValueSize: 0, // Zero value size indicates tombstone
+		Key:       []byte(key),
+		Value:     nil,
+	}
+
+	// Append tombstone to active segment
+	segmentID, _, commit, err := s.segmentManager.AppendAsync(tombstoneEntry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append tombstone: %w", err)
+	}
+
+	// Remove from HashTable; both the old value and the tombstone are now dead
+	s.cache.remove(key)
+	s.markSuperseded(key)
+	s.markDead(segmentID, int64(tombstoneEntry.Size()))
+	s.hashTable.Delete(key)
+
+	return commit, nil
+}
+
+// CompareAndSwap sets key to newValue only if its current value equals
+// oldValue, and reports whether the swap happened
+func (s *Store) CompareAndSwap(key, oldValue, newValue string) (bool, error) {
+	swapped := false
+	err := s.writeLocked(func() (*Commit, error) {
+		current, err := s.get([]byte(key))
+		if err != nil || string(current) != oldValue {
+			return nil, err
 		}
+		swapped = true
+		return s.put([]byte(key), []byte(newValue), "", 0)
+	})
+	if err != nil {
+		return false, err
 	}
+	return swapped, nil
+}
 
-	// Move merged files into base dir.
-	files, err := os.ReadDir(tmpDir)
+// CompareAndDelete deletes key only if its current value equals oldValue,
+// and reports whether the delete happened
+func (s *Store) CompareAndDelete(key, oldValue string) (bool, error) {
+	deleted := false
+	err := s.writeLocked(func() (*Commit, error) {
+		current, err := s.get([]byte(key))
+		if err != nil || string(current) != oldValue {
+			return nil, err
+		}
+		deleted = true
+		return s.remove(key)
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+// Copy sets dst to the value of src, keeping its expiry and content type.
+// Copying a key onto itself does nothing.
+func (s *Store) Copy(src, dst string) error {
+	return s.writeLocked(func() (*Commit, error) {
+		return s.copyKey(src, dst)
+	})
+}
+
+// Rename moves the value of src to dst, with its expiry and content type,
+// replacing any value dst had. Readers see either both keys as they were or
+// the result. Renaming a key onto itself does nothing.
+func (s *Store) Rename(src, dst string) error {
+	var commits []*Commit
+	err := s.writeLocked(func() (*Commit, error) {
+		commit, err := s.copyKey(src, dst)
+		if err != nil || src == dst {
+			return commit, err
+		}
+		commits = append(commits, commit)
+		commit, err = s.remove(src)
+		if commit != nil && commit != commits[0] {
+			commits = append(commits, commit)
+		}
+		return nil, err
+	})
+
+	for _, commit := range commits {
+		if syncErr := commit.Wait(); syncErr != nil && err == nil {
+			err = fmt.Errorf("failed to sync entry: %w", syncErr)
+		}
+	}
+	return err
+}
+
+// copyKey writes the value, content type and expiry of src to dst; the caller
+// must hold s.mu for writing, and wait for the returned Commit once it has
+// released s.mu
+func (s *Store) copyKey(src, dst string) (*Commit, error) {
+	value, contentType, entry, err := s.lookup(src)
+	if err != nil || src == dst {
+		return nil, err
+	}
+	return s.put([]byte(dst), value, contentType, entry.ExpiresAt)
+}
+
+// IncrBy adds delta to the integer value of key and returns the new value.
+// A missing key is treated as 0.
+func (s *Store) IncrBy(key string, delta int64) (int64, error) {
+	var next int64
+	err := s.writeLocked(func() (*Commit, error) {
+		var current int64
+		value, err := s.get([]byte(key))
+		switch {
+		case err == nil:
+			current, err = strconv.ParseInt(string(value), 10, 64)
+			if err != nil {
+				return nil, ErrNotInteger
+			}
+		case !errors.Is(err, ErrKeyNotFound):
+			return nil, err
+		}
+
+		if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+			return nil, ErrIntegerOverflow
+		}
+
+		next = current + delta
+		return s.put([]byte(key), []byte(strconv.FormatInt(next, 10)), "", 0)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// AppendValue appends suffix to the value of key, keeping its content type
+// and expiry, and returns the new value. A missing key is treated as empty.
+func (s *Store) AppendValue(key, suffix string) (string, error) {
+	var next []byte
+	err := s.writeLocked(func() (*Commit, error) {
+		value, contentType, entry, err := s.lookup(key)
+		if err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return nil, err
+		}
+		expiresAt := uint32(0)
+		if entry != nil {
+			expiresAt = entry.ExpiresAt
+		}
+
+		next = append(value[:len(value):len(value)], suffix...)
+		return s.put([]byte(key), next, contentType, expiresAt)
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(next), nil
+}
+
+// KeyMeta describes a key without reading its value
+type KeyMeta struct {
+	Key        string
+	Timestamp  uint32        // Unix timestamp of the write
+	StoredSize uint32        // Size of the value as written to disk
+	TTL        time.Duration // Time left before the key expires (0 = never)
+}
+
+// ListWithMeta returns every unexpired key with its metadata, sorted by key
+func (s *Store) ListWithMeta() ([]KeyMeta, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	keys := s.hashTable.ListUnexpired(now)
+	sort.Strings(keys)
+	metas := make([]KeyMeta, 0, len(keys))
+	for _, key := range keys {
+		entry, ok := s.hashTable.Get(key)
+		if !ok {
+			continue
+		}
+		meta := KeyMeta{Key: key, Timestamp: entry.Timestamp, StoredSize: entry.StoredSize}
+		if entry.ExpiresAt != 0 {
+			meta.TTL = time.Unix(int64(entry.ExpiresAt), 0).Sub(now)
+		}
+		metas = append(metas, meta)
+	}
+	return metas, nil
+}
+
+// List returns all keys
+func (s *Store) List() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.hashTable.ListUnexpired(time.Now()), nil
+}
+
+// Scan returns all keys with the given prefix in sorted order
+func (s *Store) Scan(prefix string) ([]string, error) {
+	return s.ScanCtx(context.Background(), prefix)
+}
+
+// ScanCtx is Scan, giving up with ctx's error once ctx is done
+func (s *Store) ScanCtx(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.hashTable.KeysCtx(ctx, prefix)
+}
+
+// Range returns all keys where start <= key < end in sorted order.
+// An empty start or end leaves that bound open, and start > end yields no keys.
+// The index is unordered, so each call filters every key and sorts the
+// matches: O(n + m log m) for n keys and m matches.
+func (s *Store) Range(start, end string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.hashTable.KeysInRange(start, end), nil
+}
+
+// ListPage returns up to limit keys after cursor in sorted order, along with
+// the cursor for the next page. Pass an empty cursor for the first page; the
+// returned cursor is empty once there are no more keys. A limit <= 0 returns
+// every remaining key.
+func (s *Store) ListPage(cursor string, limit int) ([]string, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if limit <= 0 {
+		return s.hashTable.KeysAfter(cursor, 0), "", nil
+	}
+
+	// Fetch one extra key to learn whether another page follows
+	keys := s.hashTable.KeysAfter(cursor, limit+1)
+	if len(keys) <= limit {
+		return keys, "", nil
+	}
+	keys = keys[:limit]
+	return keys, keys[limit-1], nil
+}
+
+type Stats struct {
+	TotalKeys           int
+	TotalSize           int64 // Same as LiveSize
+	DiskSize            int64 // Sum of segment file sizes, including headers and dead entries
+	LiveSize            int64 // Sum of the stored sizes of live values, as compressed or encrypted on disk
+	Segments            int
+	DeadRatios          map[int]float64 // Reclaimable fraction of each segment by ID
+	CacheHits           uint64
+	CacheMisses         uint64
+	ActiveSegmentID     int   // Segment new writes are appended to (0 when there is none)
+	ActiveSegmentOffset int64 // Size of the active segment, where the next write lands
+}
+
+// SegmentInfo describes one segment file
+type SegmentInfo struct {
+	ID        int
+	Size      int64
+	Entries   int // Undercounts segments indexed from an index snapshot at startup
+	Active    bool
+	DeadRatio float64 // Estimated reclaimable fraction of Size
+}
+
+// Rotate seals the active segment and starts a new one, making the old
+// segment eligible for compaction straight away. It returns the ID of the
+// new active segment.
+func (s *Store) Rotate() (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.segmentManager == nil {
+		return 0, ErrStoreNotInitialized
+	}
+	return s.segmentManager.Rotate()
+}
+
+// SegmentStats returns a description of every segment in ID order
+func (s *Store) SegmentStats() []SegmentInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.segmentManager == nil {
+		return nil
+	}
+	segments := s.segmentManager.Segments()
+	infos := make([]SegmentInfo, 0, len(segments))
+	for _, segment := range segments {
+		infos = append(infos, SegmentInfo{
+			ID:        segment.ID(),
+			Size:      segment.Size(),
+			Entries:   segment.EntryCount(),
+			Active:    segment.IsActive(),
+			DeadRatio: s.deadRatio(segment),
+		})
+	}
+	return infos
+}
+
+// Stats returns database statistics. Writes and the commit phase of a merge
+// hold s.mu for writing, so the figures describe the store either before or
+// after any of them, never part way through.
+func (s *Store) Stats() (Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	totalKeys, totalSize := s.hashTable.Stats()
+
+	// Count segments and their dead ratios
+	segmentCount := 0
+	diskSize := int64(0)
+	deadRatios := make(map[int]float64)
+	activeID, activeOffset := 0, int64(0)
+	if s.segmentManager != nil {
+		ids := s.segmentManager.GetSegmentIDs()
+		segmentCount = len(ids)
+		for _, id := range ids {
+			if segment, ok := s.segmentManager.GetSegment(id); ok {
+				deadRatios[id] = s.deadRatio(segment)
+				diskSize += segment.Size()
+			}
+		}
+		if active, err := s.segmentManager.GetActiveSegment(); err == nil {
+			activeID, activeOffset = active.ID(), active.Size()
+		}
+	}
+
+	cacheHits, cacheMisses := s.cache.counters()
+
+	return Stats{
+		TotalKeys:           totalKeys,
+		TotalSize:           totalSize,
+		DiskSize:            diskSize,
+		LiveSize:            totalSize,
+		Segments:            segmentCount,
+		DeadRatios:          deadRatios,
+		CacheHits:           cacheHits,
+		CacheMisses:         cacheMisses,
+		ActiveSegmentID:     activeID,
+		ActiveSegmentOffset: activeOffset,
+	}, nil
+}
+
+// Check reports why the store cannot serve requests, if it cannot. The store
+// must be initialized and, unless it is read-only, have an open active
+// segment whose file is still reachable.
+func (s *Store) Check() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.segmentManager == nil {
+		return ErrStoreNotInitialized
+	}
+	if s.readOnly {
+		return nil
+	}
+	active, err := s.segmentManager.GetActiveSegment()
 	if err != nil {
 		return err
 	}
+	return active.writable()
+}
 
-	for _, file := range files {
+// StopBackground stops the periodic merge, sync, expiry sweep and snapshot
+// loops, abandoning a merge they are running, and waits for them to exit.
+// The store keeps serving requests, so a server can stop background work
+// before draining them; Close calls it too.
+func (s *Store) StopBackground() {
+	s.stopOnce.Do(func() {
+		if s.stopCh != nil {
+			close(s.stopCh)
+		}
+	})
+	s.wg.Wait()
+}
 
-		info, err := file.Info()
-		if err != nil {
+// Close stops background work, flushes every segment to disk and closes
+// the store and all its resources
+func (s *Store) Close() error {
+	// Stop background goroutines before taking the lock, since a running
+	// merge needs it to finish
+	s.StopBackground()
+	s.watchers.closeAll()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.segmentManager != nil && s.readOnly {
+		return s.segmentManager.Close()
+	}
+	if s.segmentManager != nil {
+		flushErr := s.segmentManager.FlushAll()
+		if flushErr != nil {
+			s.logger.Error("Could not flush segments on close", zap.Error(flushErr))
+		}
+		s.writeHintFiles()
+		if err := s.writeSnapshot(); err != nil {
+			s.logger.Warn("Could not write index snapshot", zap.Error(err))
+		}
+		if err := s.segmentManager.Close(); err != nil {
 			return err
 		}
+		return flushErr
+	}
+
+	return nil
+}
+
+// writeHintFiles writes hint files for segments that don't have one yet, and
+// rewrites the active segment's, which may have grown since it was written.
+// Every segment is immutable once the store is closed, so its hint stays
+// valid until the segment is resumed. Failures are logged only: a missing
+// hint just means a slower startup.
+func (s *Store) writeHintFiles() {
+	for _, id := range s.segmentManager.GetSegmentIDs() {
+		segment, ok := s.segmentManager.GetSegment(id)
+		if !ok || segment.Size() == 0 {
+			continue
+		}
+		if _, err := os.Stat(hintPath(segment.Path())); err == nil && !segment.IsActive() {
+			continue
+		}
+		if err := writeHintFile(segment); err != nil {
+			s.logger.Warn("Could not write hint file", zap.Int("segmentID", id), zap.Error(err))
+		}
+	}
+}
+
+// DefaultCompactionConcurrency is how many segments a merge rewrites in
+// parallel when the config does not say
+const DefaultCompactionConcurrency = 4
+
+// MergeResult summarizes a completed compaction
+type MergeResult struct {
+	SegmentsCompacted int
+	BytesReclaimed    int64
+}
+
+// Merge compacts inactive segments by rewriting each one with only its live
+// records. Every segment keeps its ID, so the load order across segments (and
+// with it which entry wins for a key) is unchanged. With a compaction segment
+// size configured, rewrites of neighbouring segments are then joined into
+// files of up to that size, each taking the lowest ID it replaces.
+func (s *Store) Merge() error {
+	_, err := s.Compact()
+	return err
+}
+
+// keysInSegments maps every key with an entry in the given segments, in
+// ascending ID order, to the lowest of their IDs holding it. Hint files are
+// used where they are usable.
+func (s *Store) keysInSegments(ids []int) (map[string]int, error) {
+	keys := make(map[string]int)
+	add := func(key string, id int) {
+		if _, ok := keys[key]; !ok {
+			keys[key] = id
+		}
+	}
+
+	for _, id := range ids {
+		segment, ok := s.segmentManager.GetSegment(id)
+		if !ok {
+			continue
+		}
+		if records, err := readHintFile(segment); err == nil {
+			for _, record := range records {
+				add(string(record.Key), id)
+			}
+			continue
+		}
 
-		err = os.Rename(
-			path.Join(tmpDir, file.Name()),
-			path.Join(s.basePath, file.Name()),
-		)
+		size := segment.Size()
+		for pos := int64(0); pos < size; {
+			entry, err := segment.Read(pos)
+			if err != nil {
+				return nil, fmt.Errorf("read seg=%d off=%d: %w", id, pos, err)
+			}
+			add(string(entry.Key), id)
+			pos += int64(entry.Size())
+		}
+	}
+	return keys, nil
+}
+
+// rewriteSegment copies the live entries of segment id, and the tombstones
+// and expired entries keep says are still needed, to a new segment in dir
+// and indexes the live ones in mergeHT. It returns the new segment, even on
+// error so the caller can close it, and the bytes reclaimed. A segment that
+// no longer exists is skipped with a nil result.
+func (s *Store) rewriteSegment(ctx context.Context, id int, dir string, snap, mergeHT *HashTable, now time.Time, keep func(key string, id int) bool) (*Segment, int64, error) {
+	seg, ok := s.segmentManager.GetSegment(id)
+	if !ok {
+		return nil, 0, nil
+	}
+
+	// A compacted segment is never larger than its source, so lift the limits
+	// to guarantee each rewrite fits in a single file
+	out, err := NewSegment(id, dir, SegmentOptions{
+		MaxSegmentSize:       math.MaxInt64,
+		MaxEntriesPerSegment: math.MaxInt,
+		Cipher:               s.cipher,
+		FileMode:             s.segmentOpts.FileMode,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var pos int64
+	size := seg.Size()
+	for pos < size {
+		if err := ctx.Err(); err != nil {
+			return out, 0, err
+		}
+		se, err := seg.Read(pos)
 		if err != nil {
-			return err
+			return out, 0, fmt.Errorf("compaction failed seg=%d off=%d: %w", id, pos, err)
+		}
+
+		oldOff := pos
+		pos += int64(se.Size()) // advance regardless of branch
+
+		if se.IsTombstone() || se.IsExpired(now) {
+			if keep(string(se.Key), id) {
+				if _, err := out.Append(se); err != nil {
+					return out, 0, fmt.Errorf("failed to append entry: %w", err)
+				}
+			}
+			continue
+		}
+
+		key := string(se.Key) // redundant alloc (could be optimize)
+		he, ok := snap.Get(key)
+		if !ok || he.FileID != id || he.ValuePos != oldOff {
+			continue
+		}
+
+		newOff, err := out.Append(se)
+		if err != nil {
+			return out, 0, fmt.Errorf("failed to append entry: %w", err)
+		}
+
+		mergeHT.PutWithExpiry(key, id, newOff, se.ValueSize, se.Timestamp, se.ExpiresAt)
+	}
+
+	// Write a hint file so the merged segment loads quickly on restart,
+	// and make both durable before swapping.
+	if err := writeHintFile(out); err != nil {
+		return out, 0, fmt.Errorf("write hint for merged seg %d: %w", id, err)
+	}
+	if err := out.Flush(); err != nil {
+		return out, 0, fmt.Errorf("sync merged seg %d: %w", id, err)
+	}
+
+	return out, size - out.Size(), nil
+}
+
+// mergeOutput is a segment written by a merge and the IDs of the segments it
+// replaces, the lowest of which is its own
+type mergeOutput struct {
+	seg      *Segment
+	replaces []int
+}
+
+// coalesceSegments joins runs of merged segments into files in dir of up to
+// s.compactionSize bytes, moving their live entries in mergeHT along. Only
+// segments that are neighbours in the store's ID order are joined, so every
+// entry keeps its place in the load order. A run of one is left as it is.
+func (s *Store) coalesceSegments(ctx context.Context, merged []mergeOutput, dir string, mergeHT *HashTable) ([]mergeOutput, error) {
+	if err := os.MkdirAll(dir, s.segmentOpts.dirMode()); err != nil {
+		return merged, fmt.Errorf("create tmp dir: %w", err)
+	}
+
+	// Position of every segment in the store, to tell neighbours apart from
+	// segments with an unmerged one between them
+	order := make(map[int]int)
+	for i, id := range s.segmentManager.GetSegmentIDs() {
+		order[id] = i
+	}
+
+	var runs [][]mergeOutput
+	var runSize int64
+	for i, m := range merged {
+		size := m.seg.Size()
+		joins := i > 0 &&
+			order[m.replaces[0]] == order[merged[i-1].replaces[0]]+1 &&
+			runSize+size <= s.compactionSize
+		if !joins {
+			runs = append(runs, nil)
+			runSize = 0
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], m)
+		runSize += size
+	}
+
+	result := make([]mergeOutput, 0, len(runs))
+	for i, run := range runs {
+		if len(run) == 1 {
+			result = append(result, run[0])
+			continue
+		}
+		joined, err := s.joinSegments(ctx, run, dir, mergeHT)
+		if joined.seg != nil {
+			result = append(result, joined)
+		}
+		if err != nil {
+			// Hand everything back so the caller closes it
+			for _, rest := range runs[i:] {
+				result = append(result, rest...)
+			}
+			return result, err
+		}
+		for _, m := range run {
+			m.seg.Close()
+		}
+	}
+	return result, nil
+}
+
+// joinSegments copies the entries of run, in order, to one segment in dir
+// taking the first one's ID, and points the live ones in mergeHT at it
+func (s *Store) joinSegments(ctx context.Context, run []mergeOutput, dir string, mergeHT *HashTable) (mergeOutput, error) {
+	id := run[0].seg.ID()
+	out, err := NewSegment(id, dir, SegmentOptions{
+		MaxSegmentSize:       math.MaxInt64,
+		MaxEntriesPerSegment: math.MaxInt,
+		Cipher:               s.cipher,
+		FileMode:             s.segmentOpts.FileMode,
+	})
+	if err != nil {
+		return mergeOutput{}, err
+	}
+	joined := mergeOutput{seg: out}
+
+	entry := &Entry{}
+	for _, m := range run {
+		joined.replaces = append(joined.replaces, m.replaces...)
+		size := m.seg.Size()
+		for pos := int64(0); pos < size; pos += int64(entry.Size()) {
+			if err := ctx.Err(); err != nil {
+				return joined, err
+			}
+			if err := m.seg.ReadInto(pos, entry); err != nil {
+				return joined, fmt.Errorf("compaction failed seg=%d off=%d: %w", m.seg.ID(), pos, err)
+			}
+			newOff, err := out.Append(entry)
+			if err != nil {
+				return joined, fmt.Errorf("failed to append entry: %w", err)
+			}
+
+			key := string(entry.Key)
+			if he, ok := mergeHT.Get(key); ok && he.FileID == m.seg.ID() && he.ValuePos == pos {
+				mergeHT.PutWithExpiry(key, id, newOff, entry.ValueSize, entry.Timestamp, entry.ExpiresAt)
+			}
+		}
+	}
+
+	if err := writeHintFile(out); err != nil {
+		return joined, fmt.Errorf("write hint for merged seg %d: %w", id, err)
+	}
+	if err := out.Flush(); err != nil {
+		return joined, fmt.Errorf("sync merged seg %d: %w", id, err)
+	}
+	return joined, nil
+}
+
+// Compact runs a merge and reports how many segments it rewrote and how many
+// bytes it reclaimed
+func (s *Store) Compact() (MergeResult, error) {
+	return s.CompactCtx(context.Background())
+}
+
+// CompactCtx is Compact, abandoning the merge with ctx's error if ctx is done
+// before the rewritten segments are swapped in. An abandoned merge leaves
+// the store as it was.
+func (s *Store) CompactCtx(ctx context.Context) (MergeResult, error) {
+	return s.runMerge(ctx, false)
+}
+
+// MergeWait is CompactCtx, except that a caller finding a merge already
+// running waits for it to finish and returns its result, rather than
+// ErrMergeInProgress, so concurrent callers share one merge. It still
+// returns ErrMergeInProgress while a snapshot holds merges off, and stops
+// waiting with ctx's error when ctx is done. Cancelling the caller that
+// started the merge abandons it for every caller.
+func (s *Store) MergeWait(ctx context.Context) (MergeResult, error) {
+	return s.runMerge(ctx, true)
+}
+
+// mergeCall is a merge in progress. Its result and err are set before done
+// is closed.
+type mergeCall struct {
+	done   chan struct{}
+	result MergeResult
+	err    error
+}
+
+// runMerge starts a merge, or with wait set, joins the one running
+func (s *Store) runMerge(ctx context.Context, wait bool) (MergeResult, error) {
+	if err := ctx.Err(); err != nil {
+		return MergeResult{}, err
+	}
+	if s.readOnly {
+		return MergeResult{}, ErrReadOnly
+	}
+
+	s.mergeMu.Lock()
+	if !s.isMerging.CompareAndSwap(false, true) {
+		// Snapshots claim isMerging without a call to wait on
+		running := s.mergeCall
+		s.mergeMu.Unlock()
+		if !wait || running == nil {
+			return MergeResult{}, ErrMergeInProgress
+		}
+		select {
+		case <-running.done:
+			return running.result, running.err
+		case <-ctx.Done():
+			return MergeResult{}, ctx.Err()
+		}
+	}
+	call := &mergeCall{done: make(chan struct{})}
+	s.mergeCall = call
+	s.mergeMu.Unlock()
+	defer func() {
+		s.mergeMu.Lock()
+		s.mergeCall = nil
+		s.isMerging.Store(false)
+		s.mergeMu.Unlock()
+		close(call.done)
+	}()
+
+	call.result, call.err = s.compact(ctx)
+	return call.result, call.err
+}
+
+// compact runs a merge. The caller must hold isMerging.
+func (s *Store) compact(ctx context.Context) (MergeResult, error) {
+	ids := s.mergeCandidates()
+	if len(ids) == 0 {
+		s.logger.Info("No segments to compact")
+		return MergeResult{}, nil
+	}
+	s.checkFooters(ids)
+
+	s.logger.Info("Starting compaction", zap.Ints("segments", ids))
+
+	tmpDir := filepath.Join(s.basePath, mergeDirName)
+	_ = os.RemoveAll(tmpDir)
+	if err := os.MkdirAll(tmpDir, s.segmentOpts.dirMode()); err != nil {
+		return MergeResult{}, fmt.Errorf("create tmp dir: %w", err)
+	}
+
+	merged := make([]mergeOutput, 0, len(ids))
+	var result MergeResult
+	defer func() {
+		for _, out := range merged {
+			out.seg.Close()
+		}
+	}()
+
+	mergeHT := NewHashTable()
+	snap := s.hashTable.Clone() // snap for checking updated keys while compacting
+	now := time.Now()
+
+	// Segments left out of this merge may hold older values that tombstones
+	// and expired entries still need to shadow. Only those older than a
+	// merged segment matter; ids is in ascending order.
+	merging := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		merging[id] = true
+	}
+	var unmerged []int
+	for _, id := range s.segmentManager.GetSegmentIDs() {
+		if !merging[id] && id < ids[len(ids)-1] {
+			unmerged = append(unmerged, id)
 		}
 	}
 
-	// Merge segment managers
-	s.segmentManager.Merge(mergeSM)
-	// Merge hash tables
+	// A tombstone or expired entry is kept only while an older unmerged
+	// segment still holds its key. Which keys those hold is read on first need.
+	var olderKeysOnce sync.Once
+	var olderKeys map[string]int // Key -> oldest unmerged segment holding it
+	var olderKeysErr error
+	mustShadow := func(key string, id int) bool {
+		if len(unmerged) == 0 || unmerged[0] >= id {
+			return false
+		}
+		olderKeysOnce.Do(func() {
+			olderKeys, olderKeysErr = s.keysInSegments(unmerged)
+			if olderKeysErr != nil {
+				s.logger.Warn("Keeping all tombstones: could not read unmerged segments", zap.Error(olderKeysErr))
+			}
+		})
+		if olderKeysErr != nil {
+			return true
+		}
+		oldest, ok := olderKeys[key]
+		return ok && oldest < id
+	}
+
+	// Rewrite the segments in parallel. Each goes to its own file and the
+	// hash tables are safe for concurrent use, so the workers share nothing
+	// else; the swap below is serialized.
+	rewrites := make([]*Segment, len(ids))
+	reclaimed := make([]int64, len(ids))
+	errs := make([]error, len(ids))
+	workers := s.compactionWorkers
+	if workers <= 0 {
+		workers = DefaultCompactionConcurrency
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(ids)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				rewrites[i], reclaimed[i], errs[i] = s.rewriteSegment(ctx, ids[i], tmpDir, snap, mergeHT, now, mustShadow)
+			}
+		}()
+	}
+	for i := range ids {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for i, out := range rewrites {
+		if out == nil {
+			continue
+		}
+		merged = append(merged, mergeOutput{seg: out, replaces: []int{ids[i]}})
+		result.SegmentsCompacted++
+		result.BytesReclaimed += reclaimed[i]
+	}
+	if err := ctx.Err(); err != nil {
+		return MergeResult{}, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return MergeResult{}, err
+	}
+	if s.compactionSize > 0 {
+		var err error
+		merged, err = s.coalesceSegments(ctx, merged, filepath.Join(tmpDir, "coalesced"), mergeHT)
+		if err != nil {
+			return MergeResult{}, err
+		}
+	}
+
+	// Short stop-the-world: swap files, reopen segments, commit index.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The snapshot describes the segments about to be replaced
+	if err := os.Remove(snapshotPath(s.basePath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return MergeResult{}, fmt.Errorf("remove index snapshot: %w", err)
+	}
+
+	// Seal the merged segments, then commit the merge by writing the
+	// manifest of what the data directory holds once they are swapped in.
+	// A crash before this point leaves the old segments in place; after it,
+	// the next open finishes the swap.
+	replaced := make(map[int]bool)
+	var manifest mergeManifest
+	var installed []int
+	for _, m := range merged {
+		if err := m.seg.Close(); err != nil {
+			return MergeResult{}, fmt.Errorf("close merged seg %d: %w", m.seg.ID(), err)
+		}
+		for _, id := range m.replaces {
+			replaced[id] = true
+		}
+		if m.seg.Size() > 0 { // Otherwise nothing survived and the segment is dropped
+			rel, err := filepath.Rel(tmpDir, m.seg.Path())
+			if err != nil {
+				return MergeResult{}, err
+			}
+			manifest.Merged = append(manifest.Merged, rel)
+			installed = append(installed, m.seg.ID())
+		}
+	}
+	for _, id := range s.segmentManager.GetSegmentIDs() {
+		if !replaced[id] {
+			manifest.Segments = append(manifest.Segments, id)
+		}
+	}
+	manifest.Segments = append(manifest.Segments, installed...)
+	sort.Ints(manifest.Segments)
+	if err := writeMergeManifest(tmpDir, manifest, s.segmentOpts.fileMode()); err != nil {
+		return MergeResult{}, err
+	}
+
+	// Remove the old segments and move the merged files into place
+	for id := range replaced {
+		if err := s.segmentManager.DeleteSegment(id); err != nil {
+			return MergeResult{}, fmt.Errorf("delete seg %d: %w", id, err)
+		}
+	}
+	if err := applyMergeManifest(s.basePath, tmpDir, manifest); err != nil {
+		return MergeResult{}, fmt.Errorf("swap merged segments: %w", err)
+	}
+	for _, id := range installed {
+		seg, err := OpenSegment(id, s.basePath, s.segmentOpts)
+		if err != nil {
+			return MergeResult{}, fmt.Errorf("reopen merged seg %d: %w", id, err)
+		}
+		s.segmentManager.AddSegment(seg)
+	}
+	if err := os.RemoveAll(tmpDir); err != nil {
+		s.logger.Warn("Could not remove merge directory", zap.String("path", tmpDir), zap.Error(err))
+	}
+
+	// Merge hash tables; cached values may now point at rewritten offsets
 	s.hashTable.Merge(mergeHT, snap)
+	s.cache.purge()
 
-	return nil
+	// Reset dead byte accounting: old segments are gone, and merged copies of
+	// keys written during compaction are already dead
+	for _, id := range ids {
+		delete(s.deadBytes, id)
+	}
+	for _, key := range mergeHT.List() {
+		entry, _ := mergeHT.Get(key)
+		if cur, ok := s.hashTable.Get(key); !ok || cur.FileID != entry.FileID || cur.ValuePos != entry.ValuePos {
+			s.markDead(entry.FileID, entryDiskSize(key, entry.StoredSize, entry.ExpiresAt))
+		}
+	}
+
+	return result, nil
+}
+
+// MergeReport describes what a merge would do if it ran now
+type MergeReport struct {
+	Segments   []int // Segments a merge would rewrite
+	LiveBytes  int64 // Bytes of live entries a merge would copy
+	DeadBytes  int64 // Bytes of superseded, deleted and expired entries
+	Tombstones int   // Deletion markers among the dead entries
+}
+
+// MergeDryRun reports what a merge would reclaim without writing anything.
+// It reads the merge candidates against a snapshot of the index, so writes
+// can continue meanwhile. Like a merge it holds isMerging while it reads, so
+// the segments cannot be rewritten under it, and it returns
+// ErrMergeInProgress if a merge or snapshot is running. A real merge keeps
+// the tombstones still needed to hide older values, so it may reclaim
+// slightly less than DeadBytes.
+func (s *Store) MergeDryRun() (MergeReport, error) {
+	if s.segmentManager == nil {
+		return MergeReport{}, ErrStoreNotInitialized
+	}
+	if !s.isMerging.CompareAndSwap(false, true) {
+		return MergeReport{}, ErrMergeInProgress
+	}
+	defer s.isMerging.Store(false)
+
+	report := MergeReport{Segments: s.mergeCandidates()}
+	snap := s.hashTable.Clone()
+	now := time.Now()
+
+	for _, id := range report.Segments {
+		seg, ok := s.segmentManager.GetSegment(id)
+		if !ok {
+			continue
+		}
+		size := seg.Size()
+		for pos := int64(0); pos < size; {
+			entry, err := seg.Read(pos)
+			if err != nil {
+				return MergeReport{}, fmt.Errorf("read seg=%d off=%d: %w", id, pos, err)
+			}
+			entrySize := int64(entry.Size())
+
+			switch he, ok := snap.Get(string(entry.Key)); {
+			case entry.IsTombstone():
+				report.Tombstones++
+				report.DeadBytes += entrySize
+			case entry.IsExpired(now), !ok, he.FileID != id, he.ValuePos != pos:
+				report.DeadBytes += entrySize
+			default:
+				report.LiveBytes += entrySize
+			}
+			pos += entrySize
+		}
+	}
+	return report, nil
 }
@@ -1,24 +1,38 @@
 package store
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand/v2"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/himakhaitan/logkv-store/pkg/config"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 )
 
-func setupStoreIntegration(t *testing.T) (*Store, string) {
+func setupStoreIntegration(t testing.TB) (*Store, string) {
 	logger := zaptest.NewLogger(t)
 	tempDir, err := os.MkdirTemp("", "store_int_test")
 	require.NoError(t, err)
 
 	cfg := &config.Config{DataDir: tempDir}
 
-	realSM, err := NewSegmentManager(cfg.DataDir)
+	realSM, err := NewSegmentManager(cfg.DataDir, SegmentOptions{})
 	require.NoError(t, err)
 
 	realHT := NewHashTable()
@@ -33,6 +47,17 @@ func setupStoreIntegration(t *testing.T) (*Store, string) {
 	return store, tempDir
 }
 
+// forceRollover marks the active segment full and writes a trigger key so
+// the next append rolls over, making the old segment eligible for merge.
+func forceRollover(t testing.TB, store *Store) {
+	active, err := store.segmentManager.GetActiveSegment()
+	require.NoError(t, err)
+	active.mu.Lock()
+	active.entryCount = active.maxEntries
+	active.mu.Unlock()
+	require.NoError(t, store.Set("trigger", "rollover"))
+}
+
 func TestStore_SetGetIntegration(t *testing.T) {
 	t.Parallel()
 	store, tempDir := setupStoreIntegration(t)
@@ -89,8 +114,7 @@ func TestStore_Set_Error(t *testing.T) {
 	store.segmentManager = nil
 
 	err := store.Set("foo", "bar")
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "store not properly initialized")
+	assert.ErrorIs(t, err, ErrStoreNotInitialized)
 }
 
 func TestStore_Get_KeyNotFound(t *testing.T) {
@@ -112,9 +136,41 @@ func TestStore_Delete_KeyNotFound(t *testing.T) {
 	assert.ErrorIs(t, err, ErrKeyNotFound)
 }
 
+func TestStore_DeleteIfExists(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	existed, err := store.DeleteIfExists("nonexistent")
+	assert.NoError(t, err, "A missing key is not an error")
+	assert.False(t, existed)
+
+	require.NoError(t, store.Set("key", "value"))
+	existed, err = store.DeleteIfExists("key")
+	assert.NoError(t, err)
+	assert.True(t, existed)
+	_, err = store.Get("key")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	// Deleting again behaves like the key was never there
+	existed, err = store.DeleteIfExists("key")
+	assert.NoError(t, err)
+	assert.False(t, existed)
+	assert.ErrorIs(t, store.Delete("key"), ErrKeyNotFound, "Delete still reports missing keys")
+}
+
+// invalidDataDir returns a path that cannot be created as a directory
+// because one of its parents is a regular file.
+func invalidDataDir(t *testing.T) string {
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	require.NoError(t, os.WriteFile(blocker, []byte("not a directory"), 0644))
+	return filepath.Join(blocker, "invalid_dir")
+}
+
 func TestStore_New_MkdirAllFail(t *testing.T) {
 	logger := zaptest.NewLogger(t)
-	cfg := &config.Config{DataDir: "/root/invalid_dir"}
+	cfg := &config.Config{DataDir: invalidDataDir(t)}
 	s, err := New(logger, cfg)
 	assert.NoError(t, err)
 	assert.NotNil(t, s)
@@ -122,7 +178,7 @@ func TestStore_New_MkdirAllFail(t *testing.T) {
 
 func TestStore_New_SegmentManagerFail(t *testing.T) {
 	logger := zaptest.NewLogger(t)
-	cfg := &config.Config{DataDir: "/root/invalid_dir"}
+	cfg := &config.Config{DataDir: invalidDataDir(t)}
 	s, err := New(logger, cfg)
 	assert.NoError(t, err)
 	assert.NotNil(t, s)
@@ -136,8 +192,7 @@ func TestStore_loadFromSegments_SegmentManagerNil(t *testing.T) {
 	}
 
 	err := store.loadFromSegments()
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "segment manager is not initialized")
+	assert.ErrorIs(t, err, ErrStoreNotInitialized)
 }
 
 func TestStore_LoadSegment_Tombstone(t *testing.T) {
@@ -152,6 +207,7 @@ func TestStore_LoadSegment_Tombstone(t *testing.T) {
 	err = store.Delete("foo")
 	assert.NoError(t, err)
 
+	require.NoError(t, store.Close())
 	reloadedStore, err := New(store.logger, &config.Config{DataDir: tempDir})
 	assert.NoError(t, err)
 	defer reloadedStore.Close()
@@ -179,6 +235,7 @@ func TestStore_LoadFromSegments_WithValidData(t *testing.T) {
 	err := store.Set("hello", "world")
 	require.NoError(t, err)
 
+	require.NoError(t, store.Close())
 	newStore, err := New(store.logger, &config.Config{DataDir: tempDir})
 	require.NoError(t, err)
 	defer newStore.Close()
@@ -196,3 +253,2415 @@ func TestStore_Close_NoSegmentManager(t *testing.T) {
 	err := store.Close()
 	assert.NoError(t, err, "Close with nil segmentManager should not fail")
 }
+
+func TestStore_SetGetBytes_Binary(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	key := []byte{'b', 0x00, 'i', 0x00, 'n'}
+	value := []byte{0xff, 0xfe, 0x00, 0x80, 0xc3, 0x28}
+	deleted := []byte{0x00, 0x01}
+
+	require.NoError(t, store.SetBytes(key, value))
+	require.NoError(t, store.SetBytes(deleted, []byte{0x00}))
+	require.NoError(t, store.Delete(string(deleted)))
+
+	result, err := store.GetBytes(key)
+	assert.NoError(t, err)
+	assert.Equal(t, value, result, "Binary value must round-trip unchanged")
+
+	require.NoError(t, store.Close())
+	reloadedStore, err := New(store.logger, &config.Config{DataDir: tempDir})
+	require.NoError(t, err)
+	defer reloadedStore.Close()
+
+	result, err = reloadedStore.GetBytes(key)
+	assert.NoError(t, err, "Binary key should survive a reload")
+	assert.Equal(t, value, result)
+
+	_, err = reloadedStore.GetBytes(deleted)
+	assert.ErrorIs(t, err, ErrKeyNotFound, "Tombstoned binary key should stay deleted after reload")
+}
+
+func TestStore_SetWithTTL(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.SetWithTTL("session", "abc", time.Hour))
+	require.NoError(t, store.Set("forever", "xyz"))
+
+	value, err := store.Get("session")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", value)
+
+	ttl, err := store.TTL("session")
+	assert.NoError(t, err)
+	assert.Greater(t, ttl, 59*time.Minute, "Remaining TTL should be close to the requested TTL")
+	assert.LessOrEqual(t, ttl, time.Hour+time.Second)
+
+	ttl, err = store.TTL("forever")
+	assert.NoError(t, err)
+	assert.Zero(t, ttl, "Keys without expiry should report a zero TTL")
+
+	_, err = store.TTL("missing")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	assert.ErrorIs(t, store.SetWithTTL("bad", "ttl", 0), ErrInvalidTTL)
+}
+
+func TestStore_ExpiredKeys(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	expired := uint32(time.Now().Add(-time.Minute).Unix())
+	require.NoError(t, store.set([]byte("old"), []byte("gone"), "", expired))
+	require.NoError(t, store.SetWithTTL("fresh", "here", time.Hour))
+
+	_, err := store.Get("old")
+	assert.ErrorIs(t, err, ErrKeyNotFound, "Expired keys should not be returned")
+	assert.ErrorIs(t, store.Delete("old"), ErrKeyNotFound, "Expired keys are logically deleted")
+
+	keys, _ := store.List()
+	assert.Equal(t, []string{"fresh"}, keys, "Expired keys should not be listed")
+
+	require.NoError(t, store.Close())
+	reloadedStore, err := New(store.logger, &config.Config{DataDir: tempDir})
+	require.NoError(t, err)
+	defer reloadedStore.Close()
+
+	_, err = reloadedStore.Get("old")
+	assert.ErrorIs(t, err, ErrKeyNotFound, "Expired keys should not be loaded")
+
+	ttl, err := reloadedStore.TTL("fresh")
+	assert.NoError(t, err)
+	assert.Greater(t, ttl, time.Duration(0), "Expiry should survive a reload")
+}
+
+func TestStore_Merge_DropsExpired(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	expired := uint32(time.Now().Add(-time.Minute).Unix())
+	require.NoError(t, store.set([]byte("old"), []byte("gone"), "", expired))
+	require.NoError(t, store.SetWithTTL("fresh", "here", time.Hour))
+
+	forceRollover(t, store)
+	require.NoError(t, store.Merge())
+
+	value, err := store.Get("fresh")
+	assert.NoError(t, err)
+	assert.Equal(t, "here", value)
+
+	mergedIDs := store.segmentManager.GetSegmentIDs()
+	for _, id := range mergedIDs {
+		seg, _ := store.segmentManager.GetSegment(id)
+		var pos int64
+		for pos < seg.Size() {
+			entry, err := seg.Read(pos)
+			require.NoError(t, err)
+			assert.NotEqual(t, "old", string(entry.Key), "Expired entries should be dropped by compaction")
+			pos += int64(entry.Size())
+		}
+	}
+}
+
+func TestStore_SetBatch(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	pairs := []KeyValue{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "2"},
+		{Key: "a", Value: "3"}, // Later pairs win
+	}
+	require.NoError(t, store.SetBatch(pairs))
+
+	value, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", value, "Batch should preserve write order")
+
+	value, err = store.Get("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", value)
+
+	require.NoError(t, store.Close())
+	reloadedStore, err := New(store.logger, &config.Config{DataDir: tempDir})
+	require.NoError(t, err)
+	defer reloadedStore.Close()
+
+	value, err = reloadedStore.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", value, "Batch writes should survive a reload")
+}
+
+func TestStore_SetBatch_PartialFailure(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	// Fill the active segment after one entry and make rollover fail
+	active, err := store.segmentManager.GetActiveSegment()
+	require.NoError(t, err)
+	active.mu.Lock()
+	active.maxEntries = 1
+	active.mu.Unlock()
+	store.segmentManager.basePath = filepath.Join(tempDir, "missing")
+
+	err = store.SetBatch([]KeyValue{{Key: "ok", Value: "1"}, {Key: "fail", Value: "2"}})
+
+	var batchErr *BatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Equal(t, 1, batchErr.Written, "BatchError should report the number of written entries")
+	}
+
+	value, err := store.Get("ok")
+	assert.NoError(t, err, "Entries written before the failure should be visible")
+	assert.Equal(t, "1", value)
+
+	_, err = store.Get("fail")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestStore_DeleteBatch(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.SetBatch([]KeyValue{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}, {Key: "c", Value: "3"}}))
+
+	deleted, err := store.DeleteBatch([]string{"a", "missing", "b", "a"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted, "Missing and repeated keys are not counted")
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, keys)
+
+	deleted, err = store.DeleteBatch([]string{"missing"})
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	// The tombstones survive a restart
+	require.NoError(t, store.Close())
+	reloaded, err := New(store.logger, &config.Config{DataDir: tempDir})
+	require.NoError(t, err)
+	defer reloaded.Close()
+	keys, err = reloaded.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, keys)
+}
+
+func TestStore_DeleteBatch_PartialFailure(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("b", "2"))
+
+	// Fill the active segment after one more entry and make rollover fail
+	active, err := store.segmentManager.GetActiveSegment()
+	require.NoError(t, err)
+	active.mu.Lock()
+	active.maxEntries = 3
+	active.mu.Unlock()
+	store.segmentManager.basePath = filepath.Join(tempDir, "missing")
+
+	deleted, err := store.DeleteBatch([]string{"missing", "a", "b"})
+	assert.Equal(t, 1, deleted)
+	var batchErr *BatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Equal(t, 1, batchErr.Written)
+	}
+
+	_, err = store.Get("a")
+	assert.ErrorIs(t, err, ErrKeyNotFound, "Deletes before the failure should stick")
+	value, err := store.Get("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", value)
+}
+
+func benchmarkPairs(n int) []KeyValue {
+	pairs := make([]KeyValue, n)
+	for i := range pairs {
+		pairs[i] = KeyValue{Key: fmt.Sprintf("key_%d", i), Value: fmt.Sprintf("value_%d", i)}
+	}
+	return pairs
+}
+
+func BenchmarkStore_SetPerKey(b *testing.B) {
+	store, tempDir := setupStoreIntegration(b)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	pairs := benchmarkPairs(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, pair := range pairs {
+			if err := store.Set(pair.Key, pair.Value); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkStore_SetBatch(b *testing.B) {
+	store, tempDir := setupStoreIntegration(b)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	pairs := benchmarkPairs(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.SetBatch(pairs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkStore_Compact writes 20000 keys into 5000-entry segments,
+// overwrites every other key, and compacts the 6 sealed segments with one
+// worker and with the default number
+func BenchmarkStore_Compact(b *testing.B) {
+	value := strings.Repeat("v", 256)
+	pairs := make([]KeyValue, 20000)
+	for i := range pairs {
+		pairs[i] = KeyValue{Key: fmt.Sprintf("key_%d", i), Value: value}
+	}
+
+	for _, workers := range []int{1, DefaultCompactionConcurrency} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				store, err := New(zaptest.NewLogger(b), &config.Config{
+					DataDir:               b.TempDir(),
+					MaxEntriesPerSegment:  5000,
+					CompactionConcurrency: workers,
+				})
+				require.NoError(b, err)
+				require.NoError(b, store.SetBatch(pairs))
+				for j := 0; j < len(pairs); j += 2 {
+					require.NoError(b, store.SetBatch(pairs[j:j+1]))
+				}
+				forceRollover(b, store)
+				b.StartTimer()
+
+				result, err := store.Compact()
+				require.NoError(b, err)
+				require.Equal(b, 6, result.SegmentsCompacted)
+
+				b.StopTimer()
+				require.NoError(b, store.Close())
+			}
+		})
+	}
+}
+
+func BenchmarkStore_Set_SyncMode(b *testing.B) {
+	for _, mode := range []SyncMode{SyncNone, SyncInterval, SyncAlways, SyncGroup} {
+		b.Run(string(mode), func(b *testing.B) {
+			store, err := New(zaptest.NewLogger(b), &config.Config{
+				DataDir:      b.TempDir(),
+				SyncMode:     string(mode),
+				SyncInterval: 10 * time.Millisecond,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer store.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := store.Set("key_"+strconv.Itoa(i), "value"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStore_Set_Parallel_Durable compares concurrent writers that each
+// wait for durability: SyncAlways fsyncs every write, SyncGroup shares one
+// fsync between the writes in each window. ns/op is the inverse of
+// throughput; latency-ns is how long each Set took to return.
+func BenchmarkStore_Set_Parallel_Durable(b *testing.B) {
+	for _, mode := range []SyncMode{SyncAlways, SyncGroup} {
+		b.Run(string(mode), func(b *testing.B) {
+			store, err := New(zaptest.NewLogger(b), &config.Config{
+				DataDir:           b.TempDir(),
+				SyncMode:          string(mode),
+				GroupCommitWindow: time.Millisecond,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer store.Close()
+
+			var n, latency atomic.Int64
+			b.SetParallelism(16)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					start := time.Now()
+					if err := store.Set("key_"+strconv.FormatInt(n.Add(1), 10), "value"); err != nil {
+						b.Error(err)
+						return
+					}
+					latency.Add(int64(time.Since(start)))
+				}
+			})
+			b.ReportMetric(float64(latency.Load())/float64(b.N), "latency-ns")
+		})
+	}
+}
+
+// benchmarkValueSize is the size of the values the store benchmarks write,
+// about that of a small JSON document
+const benchmarkValueSize = 512
+
+// benchmarkKeyCount is how many distinct keys the read benchmarks spread over
+const benchmarkKeyCount = 10000
+
+// mixedReadRatio overrides the read ratios BenchmarkStore_Mixed runs with
+var mixedReadRatio = flag.Float64("store.readratio", 0, "Fraction of BenchmarkStore_Mixed operations that are reads (0 = run the default ratios)")
+
+func benchmarkKey(i int) string {
+	return fmt.Sprintf("user:%08d", i)
+}
+
+// newBenchmarkStore opens a store on cfg in a temporary directory, without
+// logging, holding benchmarkValueSize-byte values for keys 0 to n-1
+func newBenchmarkStore(b *testing.B, cfg config.Config, n int) *Store {
+	cfg.DataDir = b.TempDir()
+	store, err := New(zap.NewNop(), &cfg)
+	require.NoError(b, err)
+	b.Cleanup(func() { store.Close() })
+
+	value := strings.Repeat("v", benchmarkValueSize)
+	pairs := make([]KeyValue, 0, 1000)
+	for i := 0; i < n; i++ {
+		pairs = append(pairs, KeyValue{Key: benchmarkKey(i), Value: value})
+		if len(pairs) == cap(pairs) || i == n-1 {
+			require.NoError(b, store.SetBatch(pairs))
+			pairs = pairs[:0]
+		}
+	}
+	return store
+}
+
+func BenchmarkStore_Set(b *testing.B) {
+	store := newBenchmarkStore(b, config.Config{}, 0)
+	value := strings.Repeat("v", benchmarkValueSize)
+
+	b.ReportAllocs()
+	b.SetBytes(benchmarkValueSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.Set(benchmarkKey(i), value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkStore_Get reads keys that are in the value cache (hot) and, with
+// the cache disabled, random keys that have to be read from their segments
+// (cold). Cold reads still come from the OS page cache.
+func BenchmarkStore_Get(b *testing.B) {
+	b.Run("hot", func(b *testing.B) {
+		const working = 100
+		store := newBenchmarkStore(b, config.Config{CacheSize: working}, benchmarkKeyCount)
+		for i := 0; i < working; i++ {
+			_, err := store.Get(benchmarkKey(i))
+			require.NoError(b, err)
+		}
+
+		b.ReportAllocs()
+		b.SetBytes(benchmarkValueSize)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := store.Get(benchmarkKey(i % working)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cold", func(b *testing.B) {
+		store := newBenchmarkStore(b, config.Config{}, benchmarkKeyCount)
+		rng := rand.New(rand.NewPCG(1, 2))
+
+		b.ReportAllocs()
+		b.SetBytes(benchmarkValueSize)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := store.Get(benchmarkKey(rng.IntN(benchmarkKeyCount))); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkStore_Mixed runs concurrent readers and writers over the same
+// keys, with the share of reads given by -store.readratio or, by default,
+// 50%, 90% and 99%
+func BenchmarkStore_Mixed(b *testing.B) {
+	ratios := []float64{0.5, 0.9, 0.99}
+	if *mixedReadRatio > 0 {
+		ratios = []float64{*mixedReadRatio}
+	}
+
+	for _, ratio := range ratios {
+		b.Run(fmt.Sprintf("reads=%g%%", ratio*100), func(b *testing.B) {
+			store := newBenchmarkStore(b, config.Config{CacheSize: benchmarkKeyCount / 10}, benchmarkKeyCount)
+			value := strings.Repeat("w", benchmarkValueSize)
+			var seed atomic.Uint64
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewPCG(seed.Add(1), 0))
+				for pb.Next() {
+					key := benchmarkKey(rng.IntN(benchmarkKeyCount))
+					var err error
+					if rng.Float64() < ratio {
+						_, err = store.Get(key)
+					} else {
+						err = store.Set(key, value)
+					}
+					if err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkStore_MergeThroughput compacts the sealed segments of a store in
+// which every other key has since been overwritten. Throughput is in bytes
+// of segment compacted per second.
+func BenchmarkStore_MergeThroughput(b *testing.B) {
+	const keys = 16000
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		store := newBenchmarkStore(b, config.Config{MaxEntriesPerSegment: keys / 8}, keys)
+		value := strings.Repeat("w", benchmarkValueSize)
+		for j := 0; j < keys; j += 2 {
+			require.NoError(b, store.Set(benchmarkKey(j), value))
+		}
+		forceRollover(b, store)
+		var input int64
+		for _, id := range store.mergeCandidates() {
+			segment, ok := store.segmentManager.GetSegment(id)
+			require.True(b, ok)
+			input += segment.Size()
+		}
+		b.SetBytes(input)
+		b.ReportAllocs()
+		b.StartTimer()
+
+		if _, err := store.Compact(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStore_SyncGroup_ConcurrentWrites(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	dataDir := t.TempDir()
+	cfg := &config.Config{DataDir: dataDir, SyncMode: "group", GroupCommitWindow: time.Millisecond}
+	store, err := New(logger, cfg)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, store.Set("key_"+strconv.Itoa(i), "value_"+strconv.Itoa(i)))
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, store.SetBatch([]KeyValue{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}))
+	require.NoError(t, store.Delete("a"))
+	n, err := store.IncrBy("counter", 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	require.NoError(t, store.Close())
+
+	reopened, err := New(logger, cfg)
+	require.NoError(t, err)
+	defer reopened.Close()
+	for i := 0; i < 50; i++ {
+		value, err := reopened.Get("key_" + strconv.Itoa(i))
+		require.NoError(t, err)
+		assert.Equal(t, "value_"+strconv.Itoa(i), value)
+	}
+	_, err = reopened.Get("a")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	value, err := reopened.Get("counter")
+	require.NoError(t, err)
+	assert.Equal(t, "3", value)
+}
+
+// dirListing returns the names and sizes of the files in dir
+func dirListing(t *testing.T, dir string) map[string]int64 {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	listing := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		require.NoError(t, err)
+		listing[entry.Name()] = info.Size()
+	}
+	return listing
+}
+
+func TestStore_ReadOnly(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	dataDir := t.TempDir()
+
+	store, err := New(logger, &config.Config{DataDir: dataDir})
+	require.NoError(t, err)
+	require.NoError(t, store.Set("kept", "value"))
+	require.NoError(t, store.Set("deleted", "value"))
+	require.NoError(t, store.Delete("deleted"))
+	require.NoError(t, store.Close())
+	before := dirListing(t, dataDir)
+
+	readOnly, err := New(logger, &config.Config{DataDir: dataDir, ReadOnly: true, MergeInterval: time.Millisecond})
+	require.NoError(t, err)
+
+	value, err := readOnly.Get("kept")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+	_, err = readOnly.Get("deleted")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	keys, err := readOnly.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kept"}, keys)
+
+	assert.ErrorIs(t, readOnly.Set("new", "value"), ErrReadOnly)
+	assert.ErrorIs(t, readOnly.Delete("kept"), ErrReadOnly)
+	assert.ErrorIs(t, readOnly.SetBatch([]KeyValue{{Key: "a", Value: "1"}}), ErrReadOnly)
+	_, err = readOnly.IncrBy("counter", 1)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, readOnly.Merge(), ErrReadOnly)
+
+	time.Sleep(5 * time.Millisecond) // A merge loop, if one were running, would have ticked
+	require.NoError(t, readOnly.Close())
+	assert.Equal(t, before, dirListing(t, dataDir), "a read-only store must not touch the data dir")
+}
+
+func TestStore_ReadOnly_MissingDataDir(t *testing.T) {
+	t.Parallel()
+	dataDir := filepath.Join(t.TempDir(), "missing")
+
+	store, err := New(zaptest.NewLogger(t), &config.Config{DataDir: dataDir, ReadOnly: true})
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.Get("anything")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.NoDirExists(t, dataDir)
+}
+
+func TestStore_MultiGet(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("b", "2"))
+	require.NoError(t, store.Set("c", "3"))
+	require.NoError(t, store.Delete("c"))
+
+	values, missing, err := store.MultiGet([]string{"b", "a", "c", "unknown"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, values)
+	assert.ElementsMatch(t, []string{"c", "unknown"}, missing, "Missing keys should be reported, not fail the call")
+
+	values, missing, err = store.MultiGet(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, values)
+	assert.Empty(t, missing)
+}
+
+func TestStore_Range(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	// Interleave inserts and deletes
+	require.NoError(t, store.Set("d", "4"))
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("c", "3"))
+	require.NoError(t, store.Delete("d"))
+	require.NoError(t, store.Set("b", "2"))
+	require.NoError(t, store.Set("e", "5"))
+	require.NoError(t, store.Delete("a"))
+	require.NoError(t, store.Set("d", "6"))
+
+	tests := []struct {
+		name       string
+		start, end string
+		expected   []string
+	}{
+		{name: "Closed interval", start: "b", end: "d", expected: []string{"b", "c"}},
+		{name: "Open start", start: "", end: "c", expected: []string{"b"}},
+		{name: "Open end", start: "c", end: "", expected: []string{"c", "d", "e"}},
+		{name: "Fully open", start: "", end: "", expected: []string{"b", "c", "d", "e"}},
+		{name: "Start after end", start: "e", end: "b", expected: []string{}},
+		{name: "Empty interval", start: "c", end: "c", expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := store.Range(tt.start, tt.end)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, keys)
+		})
+	}
+}
+
+func TestStore_CompareAndSwap(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("k", "v1"))
+
+	swapped, err := store.CompareAndSwap("k", "wrong", "v2")
+	assert.NoError(t, err)
+	assert.False(t, swapped, "Swap must not happen when the old value differs")
+
+	swapped, err = store.CompareAndSwap("k", "v1", "v2")
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	value, _ := store.Get("k")
+	assert.Equal(t, "v2", value)
+
+	_, err = store.CompareAndSwap("missing", "", "x")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestStore_CompareAndDelete(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("k", "v1"))
+
+	deleted, err := store.CompareAndDelete("k", "wrong")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	deleted, err = store.CompareAndDelete("k", "v1")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	_, err = store.Get("k")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestStore_Copy(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.SetWithTTL("src", "v1", time.Hour))
+	require.NoError(t, store.Set("dst", "old"))
+
+	require.NoError(t, store.Copy("src", "dst"))
+	value, err := store.Get("dst")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", value, "Copy overwrites the destination")
+	value, err = store.Get("src")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", value)
+
+	ttl, err := store.TTL("dst")
+	require.NoError(t, err)
+	assert.Greater(t, ttl, 59*time.Minute, "The expiry is copied")
+
+	require.NoError(t, store.Copy("src", "src"))
+	stats, err := store.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalKeys)
+
+	assert.ErrorIs(t, store.Copy("missing", "dst"), ErrKeyNotFound)
+	assert.ErrorIs(t, store.Copy("missing", "missing"), ErrKeyNotFound)
+	value, _ = store.Get("dst")
+	assert.Equal(t, "v1", value)
+}
+
+func TestStore_SetWithContentType(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	cfg := &config.Config{DataDir: tempDir, CacheSize: 8}
+	store, err := New(zaptest.NewLogger(t), cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, store.SetWithContentType("image", "\x89PNG", "image/png"))
+	require.NoError(t, store.Set("plain", "text"))
+
+	for range 2 { // The second read is served from the cache
+		value, meta, err := store.GetWithMeta("image")
+		require.NoError(t, err)
+		assert.Equal(t, "\x89PNG", value)
+		assert.Equal(t, "image/png", meta.ContentType)
+		assert.Equal(t, uint32(len("\x89PNG")), meta.ValueSize, "The size is the value's, without the content type")
+	}
+	stats, err := store.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("\x89PNG")+1+len("image/png")+len("text")), stats.LiveSize, "Live bytes are counted as stored")
+	_, meta, err := store.GetWithMeta("plain")
+	require.NoError(t, err)
+	assert.Empty(t, meta.ContentType)
+
+	require.NoError(t, store.Copy("image", "copy"))
+	_, err = store.AppendValue("copy", "more")
+	require.NoError(t, err)
+	value, meta, err := store.GetWithMeta("copy")
+	require.NoError(t, err)
+	assert.Equal(t, "\x89PNGmore", value)
+	assert.Equal(t, "image/png", meta.ContentType, "Copy and AppendValue keep the content type")
+
+	require.NoError(t, store.Set("image", "replaced"))
+	_, meta, err = store.GetWithMeta("image")
+	require.NoError(t, err)
+	assert.Empty(t, meta.ContentType, "A plain Set drops the content type")
+
+	err = store.SetWithContentType("image", "v", strings.Repeat("x", 256))
+	assert.ErrorIs(t, err, ErrContentTypeTooLarge)
+
+	// The content type survives a restart and a merge
+	require.NoError(t, store.Close())
+	store, err = New(zaptest.NewLogger(t), cfg)
+	require.NoError(t, err)
+	defer store.Close()
+	forceRollover(t, store)
+	result, err := store.Compact()
+	require.NoError(t, err)
+	require.NotZero(t, result.SegmentsCompacted)
+	value, meta, err = store.GetWithMeta("copy")
+	require.NoError(t, err)
+	assert.Equal(t, "\x89PNGmore", value)
+	assert.Equal(t, "image/png", meta.ContentType)
+}
+
+func TestStore_Rename(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("b", "2"))
+
+	events, cancel := store.Subscribe()
+	defer cancel()
+
+	require.NoError(t, store.Rename("a", "b"))
+	_, err := store.Get("a")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	value, err := store.Get("b")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value, "Rename overwrites the destination")
+	set, del := <-events, <-events
+	assert.Equal(t, []string{"b", "set", "a", "delete"}, []string{set.Key, string(set.Op), del.Key, string(del.Op)})
+
+	require.NoError(t, store.Rename("b", "b"), "Renaming onto itself does nothing")
+	value, err = store.Get("b")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	assert.ErrorIs(t, store.Rename("a", "c"), ErrKeyNotFound)
+	_, err = store.Get("c")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	// The rename survives a restart
+	require.NoError(t, store.Close())
+	reloaded, err := New(store.logger, &config.Config{DataDir: tempDir})
+	require.NoError(t, err)
+	defer reloaded.Close()
+	keys, err := reloaded.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, keys)
+}
+
+func TestStore_CompareAndSwap_Concurrent(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("counter", "0"))
+
+	numGoroutines := 20
+	incrementsPerGoroutine := 10
+
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for done := 0; done < incrementsPerGoroutine; {
+				current, err := store.Get("counter")
+				if !assert.NoError(t, err) {
+					return
+				}
+				n, _ := strconv.Atoi(current)
+				swapped, err := store.CompareAndSwap("counter", current, strconv.Itoa(n+1))
+				if !assert.NoError(t, err) {
+					return
+				}
+				if swapped {
+					done++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, err := store.Get("counter")
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(numGoroutines*incrementsPerGoroutine), value, "Every successful CAS must be counted exactly once")
+}
+
+func TestStore_IncrBy(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	value, err := store.IncrBy("hits", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), value, "Missing key should be treated as 0")
+
+	value, err = store.IncrBy("hits", -7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-2), value)
+
+	stored, _ := store.Get("hits")
+	assert.Equal(t, "-2", stored)
+
+	require.NoError(t, store.Set("name", "abc"))
+	_, err = store.IncrBy("name", 1)
+	assert.ErrorIs(t, err, ErrNotInteger)
+
+	require.NoError(t, store.Set("big", strconv.FormatInt(math.MaxInt64, 10)))
+	_, err = store.IncrBy("big", 1)
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
+}
+
+func TestStore_IncrBy_Concurrent(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.IncrBy("counter", 1)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	value, err := store.Get("counter")
+	assert.NoError(t, err)
+	assert.Equal(t, "50", value)
+}
+
+func TestStore_AppendValue(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	value, err := store.AppendValue("log", "first")
+	assert.NoError(t, err)
+	assert.Equal(t, "first", value, "Missing key should be treated as empty")
+
+	value, err = store.AppendValue("log", ",second")
+	assert.NoError(t, err)
+	assert.Equal(t, "first,second", value)
+
+	stored, _ := store.Get("log")
+	assert.Equal(t, "first,second", stored)
+
+	_, err = store.AppendValue("", "x")
+	assert.ErrorIs(t, err, ErrEmptyKey)
+}
+
+func TestStore_AppendValue_KeepsTTL(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.SetWithTTL("log", "first", time.Hour))
+	before, err := store.TTL("log")
+	require.NoError(t, err)
+
+	_, err = store.AppendValue("log", ",second")
+	require.NoError(t, err)
+	after, err := store.TTL("log")
+	require.NoError(t, err)
+	assert.Positive(t, after, "Appending must not drop the expiry")
+	assert.LessOrEqual(t, after, before)
+
+	// A key without a TTL still never expires
+	_, err = store.AppendValue("plain", "x")
+	require.NoError(t, err)
+	ttl, err := store.TTL("plain")
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+}
+
+func TestStore_AppendValue_Concurrent(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.AppendValue("log", "ab")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	value, err := store.Get("log")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("ab", 50), value)
+}
+
+func TestStore_Merge_PreservesValueSize(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	values := map[string]string{"a": "1", "b": "twenty", "c": "three hundred"}
+	for k, v := range values {
+		require.NoError(t, store.Set(k, v))
+	}
+	require.NoError(t, store.Set("a", "overwritten")) // Leaves a dead entry behind
+	values["a"] = "overwritten"
+
+	forceRollover(t, store)
+	values["trigger"] = "rollover"
+
+	var expected int64
+	for _, v := range values {
+		expected += int64(len(v))
+	}
+
+	before, err := store.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, expected, before.TotalSize, "TotalSize should equal the sum of value lengths before merge")
+
+	require.NoError(t, store.Merge())
+
+	after, err := store.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, expected, after.TotalSize, "TotalSize should equal the sum of value lengths after merge")
+	assert.Equal(t, before.TotalKeys, after.TotalKeys)
+
+	for k, v := range values {
+		got, err := store.Get(k)
+		assert.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestStore_Close_StopsMergeLoop(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	tempDir := t.TempDir()
+
+	before := runtime.NumGoroutine()
+
+	store, err := New(logger, &config.Config{DataDir: tempDir, MergeInterval: time.Millisecond})
+	require.NoError(t, err)
+	assert.Greater(t, runtime.NumGoroutine(), before, "New should start the merge loop")
+
+	time.Sleep(5 * time.Millisecond) // Let the loop tick at least once
+	require.NoError(t, store.Close())
+	assert.NoError(t, store.Close(), "Closing twice should be safe")
+
+	// Poll directly: assert.Eventually runs its own goroutines
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before, "Merge loop goroutine should exit after Close")
+}
+
+func TestStore_SweepExpired(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	past := uint32(time.Now().Unix()) - 1
+	require.NoError(t, store.set([]byte("expired"), []byte("old"), "", past))
+	require.NoError(t, store.set([]byte("renewed"), []byte("old"), "", past))
+	require.NoError(t, store.Set("renewed", "new"))
+	require.NoError(t, store.SetWithTTL("later", "value", time.Hour))
+	_, indexed := store.hashTable.Get("expired")
+	require.True(t, indexed, "Expired keys stay indexed until swept or read")
+
+	swept, err := store.sweepExpired()
+	require.NoError(t, err)
+	assert.Equal(t, 1, swept)
+	_, indexed = store.hashTable.Get("expired")
+	assert.False(t, indexed)
+
+	// The overwritten entry, the expired one and its tombstone are reclaimable
+	expected := entryDiskSize("renewed", 3, past) + entryDiskSize("expired", 3, past) + entryDiskSize("expired", 0, 0)
+	assert.Equal(t, expected, store.deadBytes[1])
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"renewed", "later"}, keys)
+
+	swept, err = store.sweepExpired()
+	require.NoError(t, err)
+	assert.Zero(t, swept, "Nothing is left to sweep")
+}
+
+func TestStore_ExpiryLoop(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	dataDir := t.TempDir()
+
+	store, err := New(logger, &config.Config{DataDir: dataDir, ExpirySweepInterval: 5 * time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, store.set([]byte("unread"), []byte("value"), "", uint32(time.Now().Unix())-1))
+	require.NoError(t, store.Set("kept", "value"))
+
+	require.Eventually(t, func() bool {
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+		_, indexed := store.hashTable.Get("unread")
+		return !indexed
+	}, time.Second, 5*time.Millisecond, "The sweeper should drop the unread expired key")
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kept"}, keys)
+	require.NoError(t, store.Close())
+
+	// The tombstone keeps the key gone after a restart
+	reopened, err := New(logger, &config.Config{DataDir: dataDir})
+	require.NoError(t, err)
+	defer reopened.Close()
+	_, indexed := reopened.hashTable.Get("unread")
+	assert.False(t, indexed)
+}
+
+func TestStore_New_SyncMode(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	_, err := New(logger, &config.Config{DataDir: t.TempDir(), SyncMode: "sometimes"})
+	assert.ErrorIs(t, err, ErrInvalidSyncMode)
+
+	for _, mode := range []string{"always", "interval", "group"} {
+		dataDir := t.TempDir()
+		store, err := New(logger, &config.Config{DataDir: dataDir, SyncMode: mode, SyncInterval: time.Millisecond})
+		require.NoError(t, err)
+		require.NoError(t, store.Set("key", mode))
+		time.Sleep(5 * time.Millisecond) // Let the sync loop tick in interval mode
+		require.NoError(t, store.Close())
+
+		reopened, err := New(logger, &config.Config{DataDir: dataDir, SyncMode: mode})
+		require.NoError(t, err)
+		value, err := reopened.Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, mode, value)
+		require.NoError(t, reopened.Close())
+	}
+}
+
+func TestStore_ConfiguredSegmentLimits_TriggerRollover(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	// Entry count limit
+	store, err := New(logger, &config.Config{DataDir: t.TempDir(), MaxEntriesPerSegment: 2})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.Set("key_"+strconv.Itoa(i), "value"))
+	}
+	assert.Len(t, store.segmentManager.GetSegmentIDs(), 3, "2 entries per segment should roll over twice")
+	require.NoError(t, store.Close())
+
+	// Byte size limit: each entry exceeds the limit, so every write after the first rolls over
+	store, err = New(logger, &config.Config{DataDir: t.TempDir(), MaxSegmentSize: 16})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.Set("key_"+strconv.Itoa(i), "value"))
+	}
+	assert.Len(t, store.segmentManager.GetSegmentIDs(), 3)
+	for i := 0; i < 3; i++ {
+		value, err := store.Get("key_" + strconv.Itoa(i))
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value)
+	}
+	require.NoError(t, store.Close())
+}
+
+func TestStore_Stats_DiskAndLiveSize(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("a", "12345"))
+	require.NoError(t, store.Set("b", "old"))
+	require.NoError(t, store.Set("b", "new!"))
+	require.NoError(t, store.Delete("a"))
+
+	stats, err := store.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("new!")), stats.LiveSize, "Only live values count")
+	assert.Equal(t, stats.LiveSize, stats.TotalSize)
+
+	want := entryDiskSize("a", 5, 0) + entryDiskSize("b", 3, 0) + entryDiskSize("b", 4, 0) + entryDiskSize("a", 0, 0)
+	assert.Equal(t, want, stats.DiskSize, "Every entry written counts, including headers and tombstones")
+
+	info, err := os.Stat(segmentPath(tempDir, 1))
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), stats.DiskSize)
+}
+
+func TestStore_Stats_ActiveSegmentOffset(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.Set(fmt.Sprintf("key%d", i), "value"))
+	}
+
+	stats, err := store.Stats()
+	require.NoError(t, err)
+	active, err := store.segmentManager.GetActiveSegment()
+	require.NoError(t, err)
+	assert.Equal(t, active.ID(), stats.ActiveSegmentID)
+	assert.Equal(t, active.Size(), stats.ActiveSegmentOffset)
+	assert.Equal(t, stats.DiskSize, stats.ActiveSegmentOffset, "Every write so far is in the active segment")
+
+	// The offset moves on with the next write
+	require.NoError(t, store.Set("key5", "value"))
+	after, err := store.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, stats.ActiveSegmentOffset+entryDiskSize("key5", 5, 0), after.ActiveSegmentOffset)
+}
+
+func TestStore_DataDirLock(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	dataDir := t.TempDir()
+
+	first, err := New(logger, &config.Config{DataDir: dataDir})
+	require.NoError(t, err)
+
+	_, err = New(logger, &config.Config{DataDir: dataDir})
+	assert.ErrorIs(t, err, ErrDataDirLocked)
+
+	// A read-only store writes nothing, so it may look at a locked directory
+	readOnly, err := New(logger, &config.Config{DataDir: dataDir, ReadOnly: true})
+	require.NoError(t, err)
+	require.NoError(t, readOnly.Close())
+
+	require.NoError(t, first.Close())
+	second, err := New(logger, &config.Config{DataDir: dataDir})
+	require.NoError(t, err, "Closing the store should release the lock")
+	require.NoError(t, second.Close())
+}
+
+func TestStore_FileModes(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	dataDir := filepath.Join(t.TempDir(), "data")
+
+	store, err := New(logger, &config.Config{DataDir: dataDir, DirMode: 0o700, FileMode: 0o600})
+	require.NoError(t, err)
+	require.NoError(t, store.Set("key", "value"))
+	require.NoError(t, store.Close())
+
+	info, err := os.Stat(dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	for _, path := range []string{segmentPath(dataDir, 1), hintPath(segmentPath(dataDir, 1)), snapshotPath(dataDir)} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), path)
+	}
+}
+
+func TestStore_SegmentStats(t *testing.T) {
+	t.Parallel()
+	logger := zaptest.NewLogger(t)
+	dataDir := t.TempDir()
+	cfg := &config.Config{DataDir: dataDir, MaxEntriesPerSegment: 2}
+
+	store, err := New(logger, cfg)
+	require.NoError(t, err)
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("a", "2"))
+	require.NoError(t, store.Set("b", "3")) // Rolls over to segment 2
+
+	infos := store.SegmentStats()
+	require.Len(t, infos, 2)
+	assert.Equal(t, 1, infos[0].ID)
+	assert.Equal(t, 2, infos[0].Entries)
+	assert.False(t, infos[0].Active)
+	assert.Equal(t, 2*entryDiskSize("a", 1, 0), infos[0].Size)
+	assert.InDelta(t, 0.5, infos[0].DeadRatio, 1e-9, "The first write of a is dead")
+	assert.Equal(t, 2, infos[1].ID)
+	assert.Equal(t, 1, infos[1].Entries)
+	assert.True(t, infos[1].Active)
+	require.NoError(t, store.Close())
+
+	// Entry counts survive a restart, whether loaded from hints or a scan
+	require.NoError(t, os.Remove(hintPath(segmentPath(dataDir, 2))))
+	require.NoError(t, os.Remove(snapshotPath(dataDir)))
+	reopened, err := New(logger, cfg)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	infos = reopened.SegmentStats()
+	require.Len(t, infos, 2, "Reopening resumes the last segment")
+	assert.Equal(t, 2, infos[0].Entries)
+	assert.False(t, infos[0].Active)
+	assert.Equal(t, 1, infos[1].Entries)
+	assert.True(t, infos[1].Active)
+}
+
+func TestStore_DeadRatio_Tracking(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("live", "value"))
+	stats, err := store.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, stats.DeadRatios[1], "A fresh segment has no garbage")
+
+	// Overwrite and delete: the first two entries and the tombstone are dead
+	require.NoError(t, store.Set("key", "old"))
+	require.NoError(t, store.Set("key", "new"))
+	require.NoError(t, store.Delete("key"))
+
+	active, err := store.segmentManager.GetActiveSegment()
+	require.NoError(t, err)
+	live := entryDiskSize("live", uint32(len("value")), 0)
+	want := float64(active.Size()-live) / float64(active.Size())
+
+	stats, err = store.Stats()
+	require.NoError(t, err)
+	assert.InDelta(t, want, stats.DeadRatios[1], 1e-9)
+
+	// Reloading from disk reconstructs the same accounting
+	reloaded := &Store{
+		basePath:       tempDir,
+		hashTable:      NewHashTable(),
+		logger:         store.logger,
+		segmentManager: store.segmentManager,
+	}
+	require.NoError(t, reloaded.loadFromSegments())
+	assert.Equal(t, store.deadBytes, reloaded.deadBytes)
+}
+
+func TestStore_Merge_Parallel(t *testing.T) {
+	t.Parallel()
+	store, err := New(zaptest.NewLogger(t), &config.Config{
+		DataDir:               t.TempDir(),
+		MaxEntriesPerSegment:  10,
+		CompactionConcurrency: 3,
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Keys overwritten and deleted across many segments
+	want := make(map[string]string)
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 30; i++ {
+			key := fmt.Sprintf("key_%d", i)
+			value := fmt.Sprintf("value_%d_%d", i, round)
+			require.NoError(t, store.SetBatch([]KeyValue{{Key: key, Value: value}}))
+			want[key] = value
+		}
+	}
+	for i := 0; i < 30; i += 3 {
+		key := fmt.Sprintf("key_%d", i)
+		require.NoError(t, store.Delete(key))
+		delete(want, key)
+	}
+	forceRollover(t, store)
+	want["trigger"] = "rollover"
+
+	// Writes during the merge must win over the copies it makes
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i < 30; i += 3 {
+			assert.NoError(t, store.SetBatch([]KeyValue{{Key: fmt.Sprintf("key_%d", i), Value: "during"}}))
+		}
+	}()
+	result, err := store.Compact()
+	require.NoError(t, err)
+	<-done
+	for i := 1; i < 30; i += 3 {
+		want[fmt.Sprintf("key_%d", i)] = "during"
+	}
+	assert.Greater(t, result.SegmentsCompacted, 3)
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, keys, len(want))
+	for key, value := range want {
+		got, err := store.Get(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, value, got, key)
+	}
+}
+
+func TestStore_Stats_ConsistentDuringMerge(t *testing.T) {
+	t.Parallel()
+	store, err := New(zaptest.NewLogger(t), &config.Config{
+		DataDir:              t.TempDir(),
+		MaxEntriesPerSegment: 50,
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Enough overwritten and deleted keys for a merge to take a while
+	for round := 0; round < 3; round++ {
+		pairs := make([]KeyValue, 0, 1000)
+		for i := 0; i < 1000; i++ {
+			pairs = append(pairs, KeyValue{Key: fmt.Sprintf("key_%04d", i), Value: fmt.Sprintf("value_%d", round)})
+		}
+		require.NoError(t, store.SetBatch(pairs))
+	}
+	for i := 0; i < 1000; i += 4 {
+		require.NoError(t, store.Delete(fmt.Sprintf("key_%04d", i)))
+	}
+	forceRollover(t, store)
+	before, err := store.Stats()
+	require.NoError(t, err)
+
+	// Writers only ever add keys in pairs, so an odd number of new keys, a
+	// live size that doesn't match the key count, or fewer bytes on disk than
+	// live values is a view of a partial state
+	done := make(chan struct{})
+	var writers, pollers sync.WaitGroup
+	writers.Add(1)
+	go func() {
+		defer writers.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			assert.NoError(t, store.SetBatch([]KeyValue{
+				{Key: fmt.Sprintf("new_%05d_a", i), Value: "x"},
+				{Key: fmt.Sprintf("new_%05d_b", i), Value: "x"},
+			}))
+		}
+	}()
+	polls := 0
+	pollers.Add(1)
+	go func() {
+		defer pollers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			stats, err := store.Stats()
+			if !assert.NoError(t, err) {
+				return
+			}
+			added := stats.TotalKeys - before.TotalKeys
+			assert.GreaterOrEqual(t, added, 0)
+			assert.Zero(t, added%2, "Stats saw half of a batch")
+			assert.Equal(t, before.TotalSize+int64(added), stats.TotalSize, "Stats saw a partial write")
+			assert.GreaterOrEqual(t, stats.DiskSize, stats.LiveSize, "Stats saw a partial merge")
+			polls++
+		}
+	}()
+
+	result, err := store.Compact()
+	close(done)
+	writers.Wait()
+	pollers.Wait()
+	require.NoError(t, err)
+	assert.Greater(t, result.SegmentsCompacted, 1)
+	assert.Positive(t, polls)
+
+	after, err := store.Stats()
+	require.NoError(t, err)
+	assert.Zero(t, (after.TotalKeys-before.TotalKeys)%2)
+	assert.Equal(t, before.TotalSize+int64(after.TotalKeys-before.TotalKeys), after.TotalSize)
+}
+
+func TestStore_Merge_LeavesActiveSegmentAlone(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+	store, err := New(zaptest.NewLogger(t), &config.Config{DataDir: dataDir, MaxEntriesPerSegment: 3})
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Only the active segment_1 exists: there is nothing to merge, and its
+	// file must not be touched
+	require.NoError(t, store.Set("a", "1"))
+	activePath := filepath.Join(dataDir, "segment_1.log")
+	before, err := os.ReadFile(activePath)
+	require.NoError(t, err)
+	result, err := store.Compact()
+	require.NoError(t, err)
+	assert.Zero(t, result.SegmentsCompacted)
+	after, err := os.ReadFile(activePath)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	// Once segment_1 is sealed, the merge rewrites it under its own ID and
+	// the new active segment keeps its file
+	require.NoError(t, store.Set("a", "2"))
+	require.NoError(t, store.Set("b", "1"))
+	require.NoError(t, store.Set("c", "1"))
+	active, err := store.segmentManager.GetActiveSegment()
+	require.NoError(t, err)
+	require.Equal(t, 2, active.ID())
+	before, err = os.ReadFile(active.Path())
+	require.NoError(t, err)
+
+	result, err = store.Compact()
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.SegmentsCompacted)
+	assert.Equal(t, []int{1, 2}, store.segmentManager.GetSegmentIDs())
+	after, err = os.ReadFile(active.Path())
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "the active segment must not be overwritten")
+
+	require.NoError(t, store.Set("d", "1"))
+	for key, want := range map[string]string{"a": "2", "b": "1", "c": "1", "d": "1"} {
+		value, err := store.Get(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, want, value, key)
+	}
+}
+
+func TestStore_Merge_CompactionSegmentSize(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+	entrySize := entryDiskSize("key_000", 10, 0)
+	cfg := &config.Config{
+		DataDir:               dataDir,
+		MaxEntriesPerSegment:  10,
+		CompactionSegmentSize: 30 * entrySize,
+	}
+	store, err := New(zaptest.NewLogger(t), cfg)
+	require.NoError(t, err)
+
+	// Ten full segments of live entries, and an active one
+	for i := 0; i <= 100; i++ {
+		require.NoError(t, store.Set(fmt.Sprintf("key_%03d", i), fmt.Sprintf("value_%04d", i)))
+	}
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, store.segmentManager.GetInactiveSegmentIDs())
+
+	result, err := store.Compact()
+	require.NoError(t, err)
+	assert.Equal(t, 10, result.SegmentsCompacted)
+
+	// Three segments fit in each merged one; each takes the lowest ID it replaces
+	assert.Equal(t, []int{1, 4, 7, 10, 11}, store.segmentManager.GetSegmentIDs())
+	for _, id := range []int{1, 4, 7} {
+		seg, ok := store.segmentManager.GetSegment(id)
+		require.True(t, ok)
+		assert.Equal(t, 30*entrySize, seg.Size(), "segment %d", id)
+	}
+
+	check := func(store *Store) {
+		t.Helper()
+		for i := 0; i <= 100; i++ {
+			value, err := store.Get(fmt.Sprintf("key_%03d", i))
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("value_%04d", i), value)
+		}
+	}
+	check(store)
+	require.NoError(t, store.Close())
+
+	reopened, err := New(zaptest.NewLogger(t), cfg)
+	require.NoError(t, err)
+	defer reopened.Close()
+	check(reopened)
+}
+
+func TestStore_Merge_CompactionSegmentSize_SkipsUnmerged(t *testing.T) {
+	t.Parallel()
+	cfg := &config.Config{
+		DataDir:               t.TempDir(),
+		MaxEntriesPerSegment:  4,
+		CompactionThreshold:   0.5,
+		CompactionSegmentSize: 1 << 20,
+	}
+	store, err := New(zaptest.NewLogger(t), cfg)
+	require.NoError(t, err)
+
+	// Segments 1, 2, 4 and 5 end up mostly overwritten by 6 to 8, while
+	// segment 3 stays live and out of the merge
+	for i, key := range []string{
+		"a1", "a2", "a3", "a4", "b1", "b2", "b3", "b4", "c1", "c2", "c3", "c4",
+		"d1", "d2", "d3", "d4", "e1", "e2", "e3", "e4",
+		"a1", "a2", "a3", "b1", "b2", "b3", "d1", "d2", "d3", "e1", "e2", "e3",
+		"x",
+	} {
+		require.NoError(t, store.Set(key, fmt.Sprintf("%s%02d", key, i)))
+	}
+	require.Equal(t, []int{1, 2, 4, 5}, store.mergeCandidates())
+
+	want := make(map[string]string)
+	keys, err := store.List()
+	require.NoError(t, err)
+	for _, key := range keys {
+		want[key], err = store.Get(key)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, store.Merge())
+
+	// Segment 3 sits between 2 and 4, so they are not joined across it
+	assert.Equal(t, []int{1, 3, 4, 6, 7, 8, 9}, store.segmentManager.GetSegmentIDs())
+	for _, id := range []int{1, 4} {
+		seg, ok := store.segmentManager.GetSegment(id)
+		require.True(t, ok)
+		assert.Equal(t, 2*entryDiskSize("a1", 4, 0), seg.Size(), "segment %d", id)
+	}
+	for key, value := range want {
+		got, err := store.Get(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, value, got, key)
+	}
+	require.NoError(t, store.Close())
+}
+
+func TestStore_Merge_KeepsTombstonesShadowingOlderSegments(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	dataDir := t.TempDir()
+	cfg := &config.Config{DataDir: dataDir, MaxEntriesPerSegment: 3, CompactionThreshold: 0.6}
+
+	store, err := New(logger, cfg)
+	require.NoError(t, err)
+
+	// Segment 1 is mostly live, so it stays out of the merge while still
+	// holding the old value of k
+	require.NoError(t, store.Set("k", "v"))
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("c", "1"))
+
+	// Segment 2 is all garbage: k's tombstone, and x set and deleted within it
+	require.NoError(t, store.Delete("k"))
+	require.NoError(t, store.Set("x", "1"))
+	require.NoError(t, store.Delete("x"))
+	require.NoError(t, store.Set("trigger", "rollover"))
+
+	assert.Equal(t, []int{2}, store.mergeCandidates())
+	require.NoError(t, store.Merge())
+
+	seg2, ok := store.segmentManager.GetSegment(2)
+	require.True(t, ok)
+	assert.Equal(t, entryDiskSize("k", 0, 0), seg2.Size(),
+		"Only k's tombstone is kept; no older segment holds x")
+	require.NoError(t, store.Close())
+
+	// Dropping the tombstone would bring k back from segment 1 on reload
+	reopened, err := New(logger, cfg)
+	require.NoError(t, err)
+	_, err = reopened.Get("k")
+	assert.ErrorIs(t, err, ErrKeyNotFound, "k must stay deleted")
+	_, err = reopened.Get("x")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	value, err := reopened.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	// Once segment 1 is merged too, nothing is left for the tombstone to shadow
+	reopened.mergeThreshold = 0
+	require.NoError(t, reopened.Merge())
+	_, ok = reopened.segmentManager.GetSegment(2)
+	assert.False(t, ok, "Segment 2 should be dropped with its tombstone")
+	_, err = reopened.Get("k")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	require.NoError(t, reopened.Close())
+}
+
+func TestStore_ShouldMerge_Threshold(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+	store.mergeThreshold = 0.4
+
+	// Segment 1: all live data
+	for i := 0; i < 10; i++ {
+		require.NoError(t, store.Set("clean_"+strconv.Itoa(i), "value"))
+	}
+	forceRollover(t, store)
+	assert.False(t, store.shouldMerge(), "A segment with no garbage should not be merged")
+
+	// Segment 2: mostly overwritten data
+	for i := 0; i < 10; i++ {
+		require.NoError(t, store.Set("dirty", "value_"+strconv.Itoa(i)))
+	}
+	forceRollover(t, store)
+	require.NoError(t, store.Set("dirty", "final"))
+	assert.True(t, store.shouldMerge())
+	assert.Equal(t, []int{2}, store.mergeCandidates())
+
+	require.NoError(t, store.Merge())
+	_, ok := store.segmentManager.GetSegment(1)
+	assert.True(t, ok, "The clean segment should be left alone")
+	assert.False(t, store.shouldMerge())
+
+	value, err := store.Get("dirty")
+	assert.NoError(t, err)
+	assert.Equal(t, "final", value)
+	value, err = store.Get("clean_3")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	// The rewritten segment keeps its place in the load order
+	require.NoError(t, store.Close())
+	reopened, err := New(store.logger, &config.Config{DataDir: tempDir})
+	require.NoError(t, err)
+	defer reopened.Close()
+	value, err = reopened.Get("dirty")
+	assert.NoError(t, err)
+	assert.Equal(t, "final", value)
+}
+
+func TestStore_Compact_Result(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("key", "old"))
+	require.NoError(t, store.Set("key", "new"))
+	forceRollover(t, store)
+
+	seg, ok := store.segmentManager.GetSegment(1)
+	require.True(t, ok)
+	before := seg.Size()
+
+	store.isMerging.Store(true)
+	_, err := store.Compact()
+	assert.ErrorIs(t, err, ErrMergeInProgress)
+	store.isMerging.Store(false)
+
+	result, err := store.Compact()
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.SegmentsCompacted)
+
+	seg, ok = store.segmentManager.GetSegment(1)
+	require.True(t, ok)
+	assert.Equal(t, before-seg.Size(), result.BytesReclaimed)
+	assert.Equal(t, entryDiskSize("key", uint32(len("old")), 0), result.BytesReclaimed)
+}
+
+func TestStore_MergeDryRun(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	report, err := store.MergeDryRun()
+	require.NoError(t, err)
+	assert.Empty(t, report.Segments, "A fresh store has nothing to merge")
+
+	require.NoError(t, store.Set("key", "old"))
+	require.NoError(t, store.Set("key", "new"))
+	require.NoError(t, store.Set("gone", "value"))
+	require.NoError(t, store.Delete("gone"))
+	forceRollover(t, store)
+
+	seg, ok := store.segmentManager.GetSegment(1)
+	require.True(t, ok)
+	before := seg.Size()
+
+	// A dry run is refused while a merge runs
+	store.isMerging.Store(true)
+	_, err = store.MergeDryRun()
+	require.ErrorIs(t, err, ErrMergeInProgress)
+	assert.True(t, store.isMerging.Load(), "The running merge keeps its claim")
+	store.isMerging.Store(false)
+
+	report, err = store.MergeDryRun()
+	require.NoError(t, err)
+	assert.False(t, store.isMerging.Load(), "A dry run releases the claim")
+
+	assert.Equal(t, []int{1}, report.Segments)
+	assert.Equal(t, 1, report.Tombstones)
+	assert.Equal(t, entryDiskSize("key", uint32(len("new")), 0), report.LiveBytes)
+	assert.Equal(t, before, report.LiveBytes+report.DeadBytes)
+	assert.Equal(t, before, seg.Size(), "A dry run writes nothing")
+
+	result, err := store.Compact()
+	require.NoError(t, err)
+	assert.Equal(t, report.DeadBytes, result.BytesReclaimed)
+}
+
+// doneAfterCtx is a context that reports itself cancelled after n calls to Err
+type doneAfterCtx struct {
+	context.Context
+	n     int32
+	calls atomic.Int32
+}
+
+func (c *doneAfterCtx) Err() error {
+	if c.calls.Add(1) > c.n {
+		return context.Canceled
+	}
+	return nil
+}
+
+// blockingCtx holds up the merge it is passed to: its second Err call, made
+// once the merge is under way, signals started and blocks until release
+// is closed
+type blockingCtx struct {
+	context.Context
+	started chan struct{}
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (c *blockingCtx) Err() error {
+	if c.calls.Add(1) == 2 {
+		close(c.started)
+		<-c.release
+	}
+	return nil
+}
+
+// joinedCtx signals joined when a merge waiter starts selecting on Done
+type joinedCtx struct {
+	context.Context
+	joined chan struct{}
+}
+
+func (c *joinedCtx) Done() <-chan struct{} {
+	c.joined <- struct{}{}
+	return c.Context.Done()
+}
+
+func TestStore_MergeWait_Coalesces(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+	core, logs := observer.New(zap.InfoLevel)
+	store.logger = zap.New(core)
+
+	require.NoError(t, store.Set("key", "old"))
+	require.NoError(t, store.Set("key", "new"))
+	forceRollover(t, store)
+
+	leader := &blockingCtx{Context: context.Background(), started: make(chan struct{}), release: make(chan struct{})}
+	type outcome struct {
+		result MergeResult
+		err    error
+	}
+	leaderDone := make(chan outcome, 1)
+	go func() {
+		result, err := store.MergeWait(leader)
+		leaderDone <- outcome{result, err}
+	}()
+	<-leader.started
+
+	// Without waiting, a second merge still fails fast
+	_, err := store.CompactCtx(context.Background())
+	assert.ErrorIs(t, err, ErrMergeInProgress)
+
+	const waiters = 8
+	joined := make(chan struct{}, waiters)
+	results := make(chan outcome, waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			result, err := store.MergeWait(&joinedCtx{Context: context.Background(), joined: joined})
+			results <- outcome{result, err}
+		}()
+	}
+	for i := 0; i < waiters; i++ {
+		<-joined
+	}
+	close(leader.release)
+
+	first := <-leaderDone
+	require.NoError(t, first.err)
+	assert.Equal(t, 1, first.result.SegmentsCompacted)
+	for i := 0; i < waiters; i++ {
+		got := <-results
+		require.NoError(t, got.err)
+		assert.Equal(t, first.result, got.result, "Every waiter gets the result of the merge it joined")
+	}
+	assert.Equal(t, 1, logs.FilterMessage("Starting compaction").Len(), "Only one merge ran")
+
+	// Once it finishes the next caller starts a merge of its own
+	_, err = store.MergeWait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, logs.FilterMessage("Starting compaction").Len())
+}
+
+func TestStore_MergeWait_SnapshotAndCancel(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("key", "old"))
+	require.NoError(t, store.Set("key", "new"))
+	forceRollover(t, store)
+
+	// A snapshot holding merges off leaves nothing to wait for
+	store.isMerging.Store(true)
+	_, err := store.MergeWait(context.Background())
+	assert.ErrorIs(t, err, ErrMergeInProgress)
+	store.isMerging.Store(false)
+
+	// A waiter whose context ends gives up without stopping the merge
+	leader := &blockingCtx{Context: context.Background(), started: make(chan struct{}), release: make(chan struct{})}
+	leaderDone := make(chan error, 1)
+	go func() {
+		_, err := store.MergeWait(leader)
+		leaderDone <- err
+	}()
+	<-leader.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = store.MergeWait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(leader.release)
+	assert.NoError(t, <-leaderDone)
+}
+
+func TestStore_ContextCancelled(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.Set("b", "2"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := store.GetCtx(ctx, "a")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, _, err = store.GetWithMetaCtx(ctx, "a")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, _, err = store.MultiGetCtx(ctx, []string{"a", "b"})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = store.ScanCtx(ctx, "")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = store.IteratorCtx(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = store.CompactCtx(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Cancellation part way through a batch stops before the remaining reads
+	_, _, err = store.MultiGetCtx(&doneAfterCtx{Context: context.Background(), n: 2}, []string{"a", "b"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	values, missing, err := store.MultiGetCtx(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, values)
+	assert.Empty(t, missing)
+}
+
+func TestStore_CompactCtx_CancelledMidway(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("key", "old"))
+	require.NoError(t, store.Set("key", "new"))
+	require.NoError(t, store.Set("other", "value"))
+	forceRollover(t, store)
+
+	seg, ok := store.segmentManager.GetSegment(1)
+	require.True(t, ok)
+	before := seg.Size()
+
+	// Cancel while the segment is being rewritten
+	_, err := store.CompactCtx(&doneAfterCtx{Context: context.Background(), n: 2})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	seg, ok = store.segmentManager.GetSegment(1)
+	require.True(t, ok)
+	assert.Equal(t, before, seg.Size(), "an abandoned merge must not replace the segment")
+	value, err := store.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, "new", value)
+
+	result, err := store.Compact()
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.SegmentsCompacted)
+	value, err = store.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, "new", value)
+}
+
+func TestStore_Check(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+
+	assert.NoError(t, store.Check())
+	assert.ErrorIs(t, (&Store{}).Check(), ErrStoreNotInitialized)
+
+	active, err := store.segmentManager.GetActiveSegment()
+	require.NoError(t, err)
+	require.NoError(t, active.file.Close())
+	assert.ErrorIs(t, store.Check(), os.ErrClosed, "an unusable active segment file fails the check")
+
+	_ = store.Close() // Fails on the file closed above
+	assert.ErrorIs(t, store.Check(), ErrNoActiveSegment)
+}
+
+func TestStore_Exists(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("present", "value"))
+	require.NoError(t, store.Set("deleted", "value"))
+	require.NoError(t, store.Delete("deleted"))
+	store.hashTable.PutWithExpiry("expired", 1, 0, 5, 0, uint32(time.Now().Add(-time.Second).Unix()))
+
+	// Exists must not read segments, so it keeps working with their files closed
+	active, err := store.segmentManager.GetActiveSegment()
+	require.NoError(t, err)
+	require.NoError(t, active.file.Close())
+
+	for key, want := range map[string]bool{"present": true, "deleted": false, "expired": false, "unknown": false} {
+		exists, err := store.Exists(key)
+		assert.NoError(t, err)
+		assert.Equal(t, want, exists, key)
+	}
+}
+
+func TestStore_GetWithMeta(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	before := uint32(time.Now().Unix())
+	require.NoError(t, store.Set("key", "value"))
+
+	value, meta, err := store.GetWithMeta("key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, uint32(len("value")), meta.ValueSize)
+	assert.Equal(t, 1, meta.FileID)
+	assert.Equal(t, int64(0), meta.ValuePos)
+	assert.GreaterOrEqual(t, meta.Timestamp, before)
+
+	require.NoError(t, store.Set("key", "value"))
+	_, meta, err = store.GetWithMeta("key")
+	require.NoError(t, err)
+	assert.Equal(t, entryDiskSize("key", uint32(len("value")), 0), meta.ValuePos, "A rewrite lands further along the log")
+
+	_, _, err = store.GetWithMeta("missing")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestStore_SizeLimits(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+	store.maxKeySize = 4
+	store.maxValueSize = 8
+
+	assert.NoError(t, store.Set("abcd", "12345678"), "Sizes at the limit are allowed")
+	assert.ErrorIs(t, store.Set("abcde", "v"), ErrKeyTooLarge)
+	assert.ErrorIs(t, store.Set("k", "123456789"), ErrValueTooLarge)
+	assert.ErrorIs(t, store.Set("", "v"), ErrEmptyKey)
+	assert.ErrorIs(t, store.SetWithTTL("k", "123456789", time.Minute), ErrValueTooLarge)
+
+	// A batch with one oversized pair writes nothing
+	err := store.SetBatch([]KeyValue{{Key: "ok", Value: "v"}, {Key: "k", Value: "123456789"}})
+	assert.ErrorIs(t, err, ErrValueTooLarge)
+	exists, _ := store.Exists("ok")
+	assert.False(t, exists)
+}
+
+func TestStore_RecoversTruncatedTail(t *testing.T) {
+	partialHeader := []byte{1, 2, 3, 4, 5}
+	partialBody := createTestEntry("lost", "never fully written").Serialize()
+	partialBody = partialBody[:len(partialBody)-3]
+
+	for name, garbage := range map[string][]byte{"partial header": partialHeader, "partial body": partialBody} {
+		t.Run(name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			dataDir := t.TempDir()
+
+			store, err := New(logger, &config.Config{DataDir: dataDir})
+			require.NoError(t, err)
+			require.NoError(t, store.Set("good", "value"))
+			require.NoError(t, store.Close())
+			require.NoError(t, os.Remove(hintPath(segmentPath(dataDir, 1))))
+
+			// The crash that cut the entry short also left no footer
+			path := segmentPath(dataDir, 1)
+			stripFooter(t, path)
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			goodSize := info.Size()
+
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+			require.NoError(t, err)
+			_, err = file.Write(garbage)
+			require.NoError(t, err)
+			require.NoError(t, file.Close())
+
+			reopened, err := New(logger, &config.Config{DataDir: dataDir})
+			require.NoError(t, err)
+			defer reopened.Close()
+
+			value, err := reopened.Get("good")
+			assert.NoError(t, err)
+			assert.Equal(t, "value", value)
+
+			info, err = os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, goodSize, info.Size(), "The partial entry should be truncated away")
+		})
+	}
+}
+
+func TestStore_StopsAtInvalidEntry(t *testing.T) {
+	corruptions := map[string]func(entry []byte){
+		"checksum mismatch": func(entry []byte) { entry[len(entry)-1] ^= 0xff },
+		"zeroed hole":       func(entry []byte) { clear(entry) },
+	}
+
+	for name, corrupt := range corruptions {
+		t.Run(name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+			dataDir := t.TempDir()
+
+			store, err := New(logger, &config.Config{DataDir: dataDir})
+			require.NoError(t, err)
+			require.NoError(t, store.Set("a", "1"))
+			require.NoError(t, store.Set("b", "2"))
+			require.NoError(t, store.Set("c", "3"))
+			require.NoError(t, store.Close())
+			require.NoError(t, os.Remove(hintPath(segmentPath(dataDir, 1))))
+			require.NoError(t, os.Remove(snapshotPath(dataDir)))
+
+			// Damage b, the middle entry of the segment
+			path := segmentPath(dataDir, 1)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			entrySize := createTestEntry("a", "1").Size()
+			corrupt(data[entrySize : 2*entrySize])
+			require.NoError(t, os.WriteFile(path, data, 0644))
+
+			reopened, err := New(logger, &config.Config{DataDir: dataDir})
+			require.NoError(t, err)
+			defer reopened.Close()
+
+			value, err := reopened.Get("a")
+			require.NoError(t, err)
+			assert.Equal(t, "1", value)
+			for _, key := range []string{"b", "c"} {
+				_, err := reopened.Get(key)
+				assert.ErrorIs(t, err, ErrKeyNotFound, "%s follows the bad entry", key)
+			}
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(data)), info.Size(), "The ignored bytes are left in place")
+
+			require.NoError(t, reopened.Set("d", "4"))
+			value, err = reopened.Get("d")
+			require.NoError(t, err)
+			assert.Equal(t, "4", value)
+		})
+	}
+}
+
+func TestStore_NegativeCache(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+	store.missing = newMissCache(16)
+
+	miss := func(key string) {
+		t.Helper()
+		_, err := store.Get(key)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+		require.True(t, store.missing.has(key), "A miss is remembered")
+	}
+
+	// Every kind of write makes a key remembered as missing visible at once
+	miss("set")
+	require.NoError(t, store.Set("set", "1"))
+	value, err := store.Get("set")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	miss("batch")
+	require.NoError(t, store.SetBatch([]KeyValue{{Key: "batch", Value: "2"}}))
+	exists, err := store.Exists("batch")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	miss("copy")
+	require.NoError(t, store.Copy("set", "copy"))
+	value, err = store.Get("copy")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	miss("ttl")
+	require.NoError(t, store.SetWithTTL("ttl", "3", time.Hour))
+	value, err = store.Get("ttl")
+	require.NoError(t, err)
+	assert.Equal(t, "3", value)
+
+	// Exists fills the cache too, and deleting a key leaves it missing
+	require.NoError(t, store.Delete("set"))
+	exists, err = store.Exists("set")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.True(t, store.missing.has("set"))
+	_, err = store.Get("set")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestStore_NegativeCache_ConcurrentMisses(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+	store.missing = newMissCache(16)
+
+	// Readers missing the key while it is written must not leave a miss
+	// behind that hides it
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key%d", i)
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for r := 0; r < 4; r++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						_, _ = store.Get(key)
+					}
+				}
+			}()
+		}
+		require.NoError(t, store.Set(key, "value"))
+		value, err := store.Get(key)
+		close(stop)
+		wg.Wait()
+		require.NoError(t, err)
+		assert.Equal(t, "value", value)
+	}
+}
+
+func TestStore_ReadCache(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+	store.cache = newValueCache(16)
+
+	require.NoError(t, store.Set("key", "v1"))
+	for i := 0; i < 3; i++ {
+		value, err := store.Get("key")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", value)
+	}
+	stats, _ := store.Stats()
+	assert.Equal(t, uint64(2), stats.CacheHits)
+	assert.Equal(t, uint64(1), stats.CacheMisses)
+
+	// Writes invalidate the cached value
+	require.NoError(t, store.Set("key", "v2"))
+	value, _ := store.Get("key")
+	assert.Equal(t, "v2", value)
+	require.NoError(t, store.Delete("key"))
+	_, err := store.Get("key")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	// Compaction relocates entries without serving stale data
+	require.NoError(t, store.Set("moved", "before"))
+	require.NoError(t, store.Set("moved", "after"))
+	_, _ = store.Get("moved")
+	forceRollover(t, store)
+	require.NoError(t, store.Merge())
+	value, err = store.Get("moved")
+	require.NoError(t, err)
+	assert.Equal(t, "after", value)
+}
+
+func TestStore_Compression(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	dataDir := t.TempDir()
+	cfg := &config.Config{DataDir: dataDir, CompressionCodec: "gzip", CompressionThreshold: 64}
+	large := strings.Repeat("compressible ", 100)
+
+	store, err := New(logger, cfg)
+	require.NoError(t, err)
+	require.NoError(t, store.Set("large", large))
+	require.NoError(t, store.Set("small", "value"))
+
+	value, meta, err := store.GetWithMeta("large")
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+	assert.Equal(t, uint32(len(large)), meta.ValueSize, "The size is the value's, as read")
+	entry, ok := store.hashTable.Get("large")
+	require.True(t, ok)
+	assert.Less(t, int(entry.StoredSize), len(large), "Index should hold the on-disk size")
+	_, meta, err = store.GetWithMeta("small")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(len("value")), meta.ValueSize, "Values under the threshold are not compressed")
+	require.NoError(t, store.Close())
+
+	// Compressed values stay readable with compression turned off
+	require.NoError(t, os.Remove(snapshotPath(dataDir)))
+	reopened, err := New(logger, &config.Config{DataDir: dataDir})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	value, err = reopened.Get("large")
+	require.NoError(t, err)
+	assert.Equal(t, large, value)
+
+	_, err = New(logger, &config.Config{DataDir: t.TempDir(), CompressionCodec: "lz4"})
+	assert.ErrorIs(t, err, ErrUnknownCodec)
+}
+
+func TestStore_ListWithMeta(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("b", "value"))
+	require.NoError(t, store.SetWithTTL("a", "v", time.Hour))
+	require.NoError(t, store.set([]byte("expired"), []byte("old"), "", uint32(time.Now().Unix())-1))
+
+	metas, err := store.ListWithMeta()
+	require.NoError(t, err)
+	require.Len(t, metas, 2, "Expired keys are left out")
+
+	assert.Equal(t, "a", metas[0].Key)
+	assert.Equal(t, uint32(1), metas[0].StoredSize)
+	assert.Greater(t, metas[0].TTL, 59*time.Minute)
+	assert.LessOrEqual(t, metas[0].TTL, time.Hour+time.Second)
+
+	assert.Equal(t, "b", metas[1].Key)
+	assert.Equal(t, uint32(5), metas[1].StoredSize)
+	assert.Zero(t, metas[1].TTL, "No TTL for keys that never expire")
+	assert.InDelta(t, time.Now().Unix(), int64(metas[1].Timestamp), 2)
+}
+
+func TestStore_ListPage(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	var want []string
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("key_%02d", i)
+		require.NoError(t, store.Set(key, "value"))
+		want = append(want, key)
+	}
+	require.NoError(t, store.Delete("key_07"))
+	want = append(want[:7], want[8:]...)
+	store.hashTable.PutWithExpiry("key_99", 1, 0, 5, 0, uint32(time.Now().Add(-time.Second).Unix()))
+
+	var got []string
+	cursor := ""
+	pages := 0
+	for {
+		keys, next, err := store.ListPage(cursor, 10)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(keys), 10)
+		got = append(got, keys...)
+		pages++
+		if next == "" {
+			break
+		}
+		assert.Equal(t, keys[len(keys)-1], next)
+		cursor = next
+	}
+	assert.Equal(t, want, got, "Pages should cover every live key once, in order")
+	assert.Equal(t, 3, pages)
+
+	// An exact multiple of the limit ends without an empty page
+	keys, next, err := store.ListPage("key_14", 10)
+	require.NoError(t, err)
+	assert.Len(t, keys, 10)
+	assert.Empty(t, next)
+
+	all, next, err := store.ListPage("", 0)
+	require.NoError(t, err)
+	assert.Equal(t, want, all)
+	assert.Empty(t, next)
+}
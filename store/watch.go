@@ -0,0 +1,111 @@
+package store
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventOp is the kind of change an Event reports
+type EventOp string
+
+const (
+	EventSet    EventOp = "set"
+	EventDelete EventOp = "delete"
+)
+
+// Event describes a change made to a key
+type Event struct {
+	Key       string
+	Op        EventOp
+	Timestamp time.Time
+}
+
+// WatchBufferSize is the number of events buffered for each subscriber. A
+// subscriber that falls this far behind is dropped.
+const WatchBufferSize = 256
+
+// watchHub fans events out to subscribers. Its zero value has none.
+type watchHub struct {
+	mu     sync.Mutex
+	subs   map[chan Event]struct{}
+	count  atomic.Int32 // len(subs), readable without mu
+	closed bool         // The store is closed; new subscriptions end at once
+}
+
+// Subscribe returns a channel of the changes made after the call, in the
+// order they were applied, and a function that ends the subscription.
+// Delivery never blocks writers: a subscriber that lets WatchBufferSize
+// events pile up has its channel closed and must resubscribe, re-reading any
+// state it depends on. Closing the store closes every channel.
+func (s *Store) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, WatchBufferSize)
+
+	s.watchers.mu.Lock()
+	if s.watchers.closed {
+		s.watchers.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if s.watchers.subs == nil {
+		s.watchers.subs = make(map[chan Event]struct{})
+	}
+	s.watchers.subs[ch] = struct{}{}
+	s.watchers.count.Add(1)
+	s.watchers.mu.Unlock()
+
+	return ch, func() {
+		s.watchers.mu.Lock()
+		defer s.watchers.mu.Unlock()
+		s.watchers.drop(ch)
+	}
+}
+
+// watching reports whether there is anyone to publish to
+func (h *watchHub) watching() bool {
+	return h.count.Load() > 0
+}
+
+// drop ends a subscription if it is still open; the caller must hold h.mu
+func (h *watchHub) drop(ch chan Event) {
+	if _, ok := h.subs[ch]; !ok {
+		return
+	}
+	delete(h.subs, ch)
+	close(ch)
+	h.count.Add(-1)
+}
+
+// publish sends events to every subscriber without blocking, dropping those
+// whose buffers are full; the caller must hold h.mu
+func (h *watchHub) publish(events []Event) {
+	for ch := range h.subs {
+	send:
+		for _, event := range events {
+			select {
+			case ch <- event:
+			default:
+				h.drop(ch)
+				break send
+			}
+		}
+	}
+}
+
+// closeAll ends every subscription
+func (h *watchHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subs {
+		h.drop(ch)
+	}
+}
+
+// record queues an event for writeLocked to publish once s.mu is released;
+// the caller must hold s.mu for writing
+func (s *Store) record(key string, op EventOp) {
+	if s.watchers.watching() {
+		s.events = append(s.events, Event{Key: key, Op: op, Timestamp: time.Now()})
+	}
+}
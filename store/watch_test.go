@@ -0,0 +1,151 @@
+package store
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nextEvent returns the next event on ch, failing the test if none arrives
+func nextEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case event, ok := <-ch:
+		require.True(t, ok, "subscription ended unexpectedly")
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return Event{}
+	}
+}
+
+func TestStore_Subscribe(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	require.NoError(t, store.Set("before", "1"))
+
+	events, cancel := store.Subscribe()
+	defer cancel()
+
+	require.NoError(t, store.Set("a", "1"))
+	require.NoError(t, store.SetBatch([]KeyValue{{Key: "b", Value: "2"}, {Key: "c", Value: "3"}}))
+	require.NoError(t, store.Delete("a"))
+	_, err := store.IncrBy("n", 1)
+	require.NoError(t, err)
+	assert.Error(t, store.Delete("missing"), "failed writes publish nothing")
+	require.NoError(t, store.Set("last", "x"))
+
+	want := []struct {
+		key string
+		op  EventOp
+	}{{"a", EventSet}, {"b", EventSet}, {"c", EventSet}, {"a", EventDelete}, {"n", EventSet}, {"last", EventSet}}
+	for _, w := range want {
+		event := nextEvent(t, events)
+		assert.Equal(t, w.key, event.Key)
+		assert.Equal(t, w.op, event.Op)
+		assert.WithinDuration(t, time.Now(), event.Timestamp, time.Minute)
+	}
+
+	cancel()
+	_, ok := <-events
+	assert.False(t, ok, "cancel should close the channel")
+	require.NoError(t, store.Set("after", "1"))
+}
+
+func TestStore_Subscribe_Order(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	events, cancel := store.Subscribe()
+	defer cancel()
+
+	// Concurrent writers to one key must be published in the order applied,
+	// so the last event matches the final value
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 10 {
+				if (i+j)%3 == 0 {
+					_, _ = store.DeleteIfExists("k")
+				} else {
+					assert.NoError(t, store.Set("k", "v"))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Events are published before the writes return, so all are buffered
+	var last Event
+	for drained := false; !drained; {
+		select {
+		case last = <-events:
+		default:
+			drained = true
+		}
+	}
+	require.Equal(t, "k", last.Key)
+	exists, err := store.Exists("k")
+	require.NoError(t, err)
+	if exists {
+		assert.Equal(t, EventSet, last.Op)
+	} else {
+		assert.Equal(t, EventDelete, last.Op)
+	}
+}
+
+func TestStore_Subscribe_SlowConsumer(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+	defer store.Close()
+
+	slow, cancelSlow := store.Subscribe()
+	defer cancelSlow()
+	fast, cancelFast := store.Subscribe()
+	defer cancelFast()
+
+	for range WatchBufferSize + 1 {
+		require.NoError(t, store.Set("k", "v"))
+		nextEvent(t, fast)
+	}
+
+	// The slow subscriber gets what fitted in its buffer, then is dropped
+	for range WatchBufferSize {
+		nextEvent(t, slow)
+	}
+	_, ok := <-slow
+	assert.False(t, ok, "a subscriber that falls behind should be dropped")
+
+	require.NoError(t, store.Set("k", "v"))
+	assert.Equal(t, "k", nextEvent(t, fast).Key, "other subscribers are unaffected")
+}
+
+func TestStore_Subscribe_Close(t *testing.T) {
+	t.Parallel()
+	store, tempDir := setupStoreIntegration(t)
+	defer os.RemoveAll(tempDir)
+
+	events, cancel := store.Subscribe()
+	defer cancel()
+	require.NoError(t, store.Close())
+
+	_, ok := <-events
+	assert.False(t, ok, "closing the store should end subscriptions")
+
+	late, cancelLate := store.Subscribe()
+	defer cancelLate()
+	_, ok = <-late
+	assert.False(t, ok, "subscribing to a closed store should end at once")
+}
@@ -7,25 +7,168 @@ type BaseResponse struct {
 }
 
 type SetRequest struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+type GetResponse struct {
+	BaseResponse
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Timestamp   int64  `json:"timestamp"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+type MultiGetRequest struct {
+	Keys []string `json:"keys"`
+}
+
+type BatchDeleteRequest struct {
+	Keys []string `json:"keys"`
+}
+
+type BatchDeleteResponse struct {
+	BaseResponse
+	Deleted int `json:"deleted"`
+	Missing int `json:"missing"`
+}
+
+type MultiGetResponse struct {
+	BaseResponse
+	Values  map[string]string `json:"values"`
+	Missing []string          `json:"missing"`
+}
+
+type ExistsResponse struct {
+	BaseResponse
+	Key    string `json:"key"`
+	Exists bool   `json:"exists"`
+}
+
+type VersionResponse struct {
+	BaseResponse
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+type PingResponse struct {
+	BaseResponse
+	Version       string  `json:"version"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	Keys          int     `json:"keys"`
+	Segments      int     `json:"segments"`
+}
+
+type HealthResponse struct {
+	BaseResponse
+	Status string `json:"status"`
+}
+
+type IncrRequest struct {
+	Delta int64 `json:"delta"`
+}
+
+type IncrResponse struct {
+	BaseResponse
+	Key   string `json:"key"`
+	Value int64  `json:"value"`
+}
+
+type AppendRequest struct {
+	Suffix string `json:"suffix"`
+}
+
+type CopyRequest struct {
+	To string `json:"to"`
+}
+
+type AppendResponse struct {
+	BaseResponse
 	Key   string `json:"key"`
 	Value string `json:"value"`
 }
 
-type GetResponse struct {
+type ListKeysResponse struct {
 	BaseResponse
+	Keys       []string `json:"keys"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// KeyInfo describes a key listed with ?meta=true
+type KeyInfo struct {
 	Key       string `json:"key"`
-	Value     string `json:"value"`
 	Timestamp int64  `json:"timestamp"`
+	Size      uint32 `json:"size"`          // Size of the value as stored on disk
+	TTL       int64  `json:"ttl,omitempty"` // Seconds left before the key expires; omitted if it never does
 }
 
-type ListKeysResponse struct {
+type ListKeysMetaResponse struct {
 	BaseResponse
-	Keys []string `json:"keys"`
+	Keys []KeyInfo `json:"keys"`
 }
 
 type StatsResponse struct {
 	BaseResponse
-	TotalKeys int   `json:"total_keys"`
-	TotalSize int64 `json:"total_size"`
-	Segments  int   `json:"segments"`
+	TotalKeys   int             `json:"total_keys"`
+	TotalSize   int64           `json:"total_size"`
+	DiskSize    int64           `json:"disk_size"`
+	LiveSize    int64           `json:"live_size"`
+	Segments    int             `json:"segments"`
+	DeadRatios  map[int]float64 `json:"dead_ratios,omitempty"`
+	CacheHits   uint64          `json:"cache_hits"`
+	CacheMisses uint64          `json:"cache_misses"`
+
+	ActiveSegmentID     int   `json:"active_segment_id"`
+	ActiveSegmentOffset int64 `json:"active_segment_offset"`
+}
+
+type SegmentInfo struct {
+	ID        int     `json:"id"`
+	Size      int64   `json:"size"`
+	Entries   int     `json:"entries"`
+	Active    bool    `json:"active"`
+	DeadRatio float64 `json:"dead_ratio"`
+}
+
+type SegmentsResponse struct {
+	BaseResponse
+	Segments []SegmentInfo `json:"segments"`
+}
+
+type CompactResponse struct {
+	BaseResponse
+	SegmentsCompacted int   `json:"segments_compacted"`
+	BytesReclaimed    int64 `json:"bytes_reclaimed"`
+}
+
+type RotateResponse struct {
+	BaseResponse
+	SegmentID int `json:"segment_id"`
+}
+
+type CompactPreviewResponse struct {
+	BaseResponse
+	Segments   []int `json:"segments"`
+	LiveBytes  int64 `json:"live_bytes"`
+	DeadBytes  int64 `json:"dead_bytes"`
+	Tombstones int   `json:"tombstones"`
+}
+
+type WatchEvent struct {
+	Key       string `json:"key"`
+	Op        string `json:"op"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type ExportRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type ImportResponse struct {
+	BaseResponse
+	Imported int `json:"imported"`
 }